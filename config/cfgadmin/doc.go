@@ -0,0 +1,52 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgadmin provides an HTTP API to read and write the scoped
+// configuration of a config.Service.
+//
+// The Handler serves three routes relative to its mount point:
+//
+//		GET  /sections                      lists sections, groups and fields
+//		GET  /values?website=1&store=2      effective values of a scope
+//		PUT  /values?website=1&store=2      validates and writes values
+//
+// The query parameters website and store select the scope. Without them the
+// default scope applies, a store ID requires its website ID. GET /values
+// follows the hierarchy store -> website -> default -> field default and
+// reports where each value has been found. The optional parameter route
+// restricts the values to a section, group or field, for example
+// route=payment/gateway.
+//
+// PUT and POST /values accept a JSON object mapping routes to values. All
+// values get validated with a cfgvalid.Writer before anything gets written
+// into the scope defined by the query parameters. Invalid values return the
+// status 422 with the cfgvalid.Errors. Values of fields with type
+// element.TypeObscure are masked when reading; the mask gets ignored when
+// writing and other values get encrypted with Options.Encrypter.
+//
+// The Handler does no authentication on its own. New returns an error without
+// Options.Middleware unless Options.Unprotected has been set, even for a read
+// only Handler. Protect it with the middleware of the packages net/auth or
+// net/jwt:
+//
+//		authSrv := auth.MustNew(auth.WithSimpleBasicAuth("admin", pass, "Config"))
+//		h, err := cfgadmin.New(cfgSrv, sections, cfgadmin.Options{
+//			Middleware: mw.MiddlewareSlice{authSrv.WithAuthentication},
+//			Encrypter:  keyring,
+//		})
+//		http.Handle("/admin/config/", http.StripPrefix("/admin/config", h))
+//
+// Both middlewares expect the requested scope in the context, see
+// scope.WithContext and package net/runmode.
+package cfgadmin
@@ -0,0 +1,548 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgadmin
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	loghttp "github.com/corestoreio/log/http"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/cfgvalid"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/net/mw"
+	"github.com/corestoreio/pkg/net/response"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// maxBodySize limits the size of a request body when writing values.
+const maxBodySize = 1 << 20
+
+// Service reads and writes the configuration values. Implemented by
+// *config.Service.
+type Service interface {
+	config.Getter
+	config.Writer
+}
+
+// Options configures the Handler.
+type Options struct {
+	// Middleware protects the Handler, for example with
+	// auth.Service.WithAuthentication or jwt.Service.WithToken.
+	Middleware mw.MiddlewareSlice
+	// Encrypter encrypts the values of obscure fields before writing. If nil,
+	// obscure fields cannot be written.
+	Encrypter cfgmodel.Encrypter
+	// ReadOnly disables the writing of values.
+	ReadOnly bool
+	// Unprotected allows a Handler without Middleware, for example when the
+	// caller protects the route elsewhere. Otherwise New returns a NotValid
+	// error, also for a ReadOnly Handler.
+	Unprotected bool
+	// Log logs written values and failed requests. Default log.BlackHole.
+	Log log.Logger
+}
+
+// Section describes a section in the response of GET /sections.
+type Section struct {
+	ID        string   `json:"id"`
+	Label     string   `json:"label,omitempty"`
+	Scopes    []string `json:"scopes"`
+	SortOrder int      `json:"sort_order,omitempty"`
+	Groups    []Group  `json:"groups"`
+}
+
+// Group describes a group in the response of GET /sections.
+type Group struct {
+	ID        string   `json:"id"`
+	Label     string   `json:"label,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+	Scopes    []string `json:"scopes"`
+	SortOrder int      `json:"sort_order,omitempty"`
+	Fields    []Field  `json:"fields"`
+}
+
+// Field describes a field in the response of GET /sections. Type contains the
+// lower case name of the element.FieldType, for example "multiselect". The
+// default value of obscure fields gets omitted.
+type Field struct {
+	ID         string          `json:"id"`
+	Route      string          `json:"route"`
+	Label      string          `json:"label,omitempty"`
+	Comment    string          `json:"comment,omitempty"`
+	Tooltip    string          `json:"tooltip,omitempty"`
+	Type       string          `json:"type,omitempty"`
+	Scopes     []string        `json:"scopes"`
+	SortOrder  int             `json:"sort_order,omitempty"`
+	CanBeEmpty bool            `json:"can_be_empty,omitempty"`
+	Default    interface{}     `json:"default,omitempty"`
+	Source     cfgsource.Slice `json:"source,omitempty"`
+}
+
+// Value describes an effective value in the response of GET /values.
+type Value struct {
+	Route string      `json:"route"`
+	Value interface{} `json:"value"`
+	// Scope and ScopeID define where the value has been found. An empty Scope
+	// means the default value of the field.
+	Scope   string `json:"scope,omitempty"`
+	ScopeID int64  `json:"scope_id"`
+	// Inherited reports whether the value comes from a parent scope or from
+	// the default value of the field.
+	Inherited bool `json:"inherited"`
+	Obscure   bool `json:"obscure,omitempty"`
+}
+
+// Values the response of GET /values.
+type Values struct {
+	Scope   string  `json:"scope"`
+	ScopeID int64   `json:"scope_id"`
+	Values  []Value `json:"values"`
+}
+
+// errorResponse gets sent for all failed requests.
+type errorResponse struct {
+	Error  string          `json:"error"`
+	Fields cfgvalid.Errors `json:"fields,omitempty"`
+}
+
+type field struct {
+	route string
+	element.Field
+}
+
+func (f field) isObscure() bool {
+	return isObscure(f.Field)
+}
+
+func isObscure(f element.Field) bool {
+	return f.Type != nil && f.Type.Type() == element.TypeObscure
+}
+
+// Handler implements the HTTP API. Create it with New.
+type Handler struct {
+	srv      Service
+	valid    *cfgvalid.Writer
+	o        Options
+	sections []Section
+	// fields in the order of the sections.
+	fields []field
+	// index maps a route to its field.
+	index   map[string]element.Field
+	handler http.Handler
+}
+
+// New creates a new Handler for the fields of the sections. The Handler
+// requires Options.Middleware unless Options.Unprotected has been set.
+func New(srv Service, ss element.SectionSlice, o Options) (*Handler, error) {
+	if len(o.Middleware) == 0 && !o.Unprotected {
+		return nil, errors.NewNotValidf("[cfgadmin] New: Handler without Middleware. Set Options.Middleware or Unprotected")
+	}
+	if o.Log == nil {
+		o.Log = log.BlackHole{}
+	}
+	vw, err := cfgvalid.NewWriter(srv, ss)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgadmin] New")
+	}
+	idx, err := ss.FieldsByRoute()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgadmin] New")
+	}
+	h := &Handler{
+		srv:      srv,
+		valid:    vw,
+		o:        o,
+		sections: make([]Section, 0, len(ss)),
+		fields:   make([]field, 0, ss.TotalFields()),
+		index:    idx,
+	}
+	for _, s := range ss {
+		sec := Section{
+			ID:        s.ID.String(),
+			Label:     s.Label.String(),
+			Scopes:    scopeNames(s.Scopes),
+			SortOrder: s.SortOrder,
+			Groups:    make([]Group, 0, len(s.Groups)),
+		}
+		for _, g := range s.Groups {
+			grp := Group{
+				ID:        g.ID.String(),
+				Label:     g.Label.String(),
+				Comment:   g.Comment.String(),
+				Scopes:    scopeNames(g.Scopes),
+				SortOrder: g.SortOrder,
+				Fields:    make([]Field, 0, len(g.Fields)),
+			}
+			for _, f := range g.Fields {
+				r, err := f.Route(s.ID, g.ID)
+				if err != nil {
+					return nil, errors.Wrapf(err, "[cfgadmin] New Field.Route. Section %q Group %q", s.ID, g.ID)
+				}
+				fi := field{route: r.String(), Field: f}
+				fld := Field{
+					ID:         f.ID.String(),
+					Route:      fi.route,
+					Label:      f.Label.String(),
+					Comment:    f.Comment.String(),
+					Tooltip:    f.Tooltip.String(),
+					Scopes:     scopeNames(f.Scopes),
+					SortOrder:  f.SortOrder,
+					CanBeEmpty: f.CanBeEmpty,
+					Source:     f.Source,
+				}
+				if f.Type != nil {
+					fld.Type = strings.ToLower(strings.TrimPrefix(f.Type.Type().String(), "Type"))
+				}
+				if !fi.isObscure() {
+					fld.Default = defaultValue(f)
+				}
+				h.fields = append(h.fields, fi)
+				grp.Fields = append(grp.Fields, fld)
+			}
+			sec.Groups = append(sec.Groups, grp)
+		}
+		h.sections = append(h.sections, sec)
+	}
+	h.handler = o.Middleware.ChainFunc(h.serve)
+	return h, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	allow := "GET, HEAD"
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/sections":
+		if r.Method == "GET" || r.Method == "HEAD" {
+			h.writeJSON(w, r, http.StatusOK, h.sections)
+			return
+		}
+	case "/values":
+		switch {
+		case r.Method == "GET" || r.Method == "HEAD":
+			h.getValues(w, r)
+			return
+		case h.o.ReadOnly:
+		case r.Method == "PUT" || r.Method == "POST":
+			h.putValues(w, r)
+			return
+		default:
+			allow = "GET, HEAD, PUT, POST"
+		}
+	default:
+		h.writeError(w, r, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.Header().Set("Allow", allow)
+	h.writeError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+}
+
+// scoped creates the scope from the query parameters website and store.
+func (h *Handler) scoped(r *http.Request) (config.Scoped, error) {
+	q := r.URL.Query()
+	var ids [2]int64
+	for i, k := range [...]string{"website", "store"} {
+		v := q.Get(k)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			return config.Scoped{}, errors.NewNotValidf("Invalid %s ID %q", k, v)
+		}
+		ids[i] = id
+	}
+	sg := h.srv.NewScoped(ids[0], ids[1])
+	if !sg.IsValid() {
+		return config.Scoped{}, errors.NewNotValidf("Store ID %d requires a website ID", ids[1])
+	}
+	return sg, nil
+}
+
+func (h *Handler) getValues(w http.ResponseWriter, r *http.Request) {
+	sg, err := h.scoped(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	prefix := strings.Trim(r.URL.Query().Get("route"), "/")
+
+	scp, id := sg.ScopeID().Unpack()
+	vs := Values{
+		Scope:   scp.StrType(),
+		ScopeID: id,
+		Values:  make([]Value, 0, len(h.fields)),
+	}
+	for _, f := range h.fields {
+		if prefix != "" && f.route != prefix && !strings.HasPrefix(f.route, prefix+"/") {
+			continue
+		}
+		v, err := h.value(sg, f)
+		if err != nil {
+			h.internalError(w, r, err)
+			return
+		}
+		vs.Values = append(vs.Values, v)
+	}
+	if prefix != "" && len(vs.Values) == 0 {
+		h.writeError(w, r, http.StatusNotFound, "Unknown route "+strconv.Quote(prefix))
+		return
+	}
+	h.writeJSON(w, r, http.StatusOK, vs)
+}
+
+// value walks up the scope hierarchy allowed by the field and falls back to
+// the default value of the field.
+func (h *Handler) value(sg config.Scoped, f field) (Value, error) {
+	ret := Value{
+		Route:   f.route,
+		Obscure: f.isObscure(),
+	}
+	p, err := cfgpath.NewByParts(f.route)
+	if err != nil {
+		return ret, errors.Wrapf(err, "[cfgadmin] Route %q", f.route)
+	}
+
+	ids := make(scope.TypeIDs, 0, 3)
+	if sg.StoreID > 0 && (f.Scopes == 0 || f.Scopes.Has(scope.Store)) {
+		ids = append(ids, scope.MakeTypeID(scope.Store, sg.StoreID))
+	}
+	if sg.WebsiteID > 0 && (f.Scopes == 0 || f.Scopes.Has(scope.Website)) {
+		ids = append(ids, scope.MakeTypeID(scope.Website, sg.WebsiteID))
+	}
+	ids = append(ids, scope.DefaultTypeID)
+
+	ret.Value, ret.Inherited = defaultValue(f.Field), true
+	for _, id := range ids {
+		v, err := h.get(p.Bind(id), f.Field)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return ret, errors.Wrapf(err, "[cfgadmin] Path %q", p.Bind(id))
+		}
+		scp, scpID := id.Unpack()
+		ret.Value, ret.Scope, ret.ScopeID = v, scp.StrType(), scpID
+		ret.Inherited = id != sg.ScopeID()
+		break
+	}
+	if ret.Obscure && ret.Value != nil {
+		ret.Value = cfgdump.MaskValue
+	}
+	return ret, nil
+}
+
+// get reads a value of a single scope in the type defined by the field type
+// or the type of the default value.
+func (h *Handler) get(p cfgpath.Path, f element.Field) (interface{}, error) {
+	if f.Type != nil {
+		switch f.Type.Type() {
+		case element.TypeTime:
+			return h.srv.Time(p)
+		case element.TypeDuration:
+			d, err := h.srv.Duration(p)
+			return d.String(), err
+		case element.TypeMultiselect:
+			s, err := h.srv.String(p)
+			return splitMulti(s), err
+		}
+	}
+	switch f.Default.(type) {
+	case bool:
+		return h.srv.Bool(p)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return h.srv.Int(p)
+	case float32, float64:
+		return h.srv.Float64(p)
+	}
+	return h.srv.String(p)
+}
+
+// defaultValue returns the default value of a field in the same
+// representation as get.
+func defaultValue(f element.Field) interface{} {
+	switch dv := f.Default.(type) {
+	case time.Duration:
+		return dv.String()
+	case string:
+		if f.Type != nil && f.Type.Type() == element.TypeMultiselect {
+			return splitMulti(dv)
+		}
+	}
+	return f.Default
+}
+
+// scopeNames converts the permission into the scope names of table
+// core_config_data. An empty slice allows all scopes.
+func scopeNames(p scope.Perm) []string {
+	ret := make([]string, 0, 3)
+	for _, t := range [...]scope.Type{scope.Default, scope.Website, scope.Store} {
+		if p.Has(t) {
+			ret = append(ret, t.StrType())
+		}
+	}
+	return ret
+}
+
+func splitMulti(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func (h *Handler) putValues(w http.ResponseWriter, r *http.Request) {
+	sg, err := h.scoped(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid JSON body: "+err.Error())
+		return
+	}
+	routes := make([]string, 0, len(body))
+	for route := range body {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+
+	target := sg.ScopeID()
+	vs := make([]cfgvalid.Value, 0, len(routes))
+	for _, route := range routes {
+		p, err := cfgpath.NewByParts(route)
+		if err != nil {
+			h.writeError(w, r, http.StatusBadRequest, "Invalid route "+strconv.Quote(route))
+			return
+		}
+		v := normalize(body[route])
+		if f, ok := h.index[route]; ok && isObscure(f) && v != nil {
+			if v == cfgdump.MaskValue {
+				continue // unchanged
+			}
+			if h.o.Encrypter == nil {
+				h.writeError(w, r, http.StatusBadRequest, "Cannot write obscure route "+strconv.Quote(route)+" without an Encrypter")
+				return
+			}
+		}
+		vs = append(vs, cfgvalid.Value{Path: p.Bind(target), Value: v})
+	}
+
+	if err := h.valid.ValidateAll(vs...); err != nil {
+		if es, ok := err.(cfgvalid.Errors); ok {
+			h.writeJSON(w, r, http.StatusUnprocessableEntity, errorResponse{Error: "Validation failed", Fields: es})
+			return
+		}
+		h.internalError(w, r, err)
+		return
+	}
+
+	// Encrypt all values before writing to avoid partial writes.
+	for i, v := range vs {
+		if !isObscure(h.index[v.Path.Route.String()]) || v.Value == nil {
+			continue
+		}
+		ct, err := h.o.Encrypter.Encrypt([]byte(valueString(v.Value)))
+		if err != nil {
+			h.internalError(w, r, errors.Wrapf(err, "[cfgadmin] Encrypt Path %q", v.Path))
+			return
+		}
+		vs[i].Value = ct
+	}
+	for _, v := range vs {
+		if err := h.srv.Write(v.Path, v.Value); err != nil {
+			h.internalError(w, r, errors.Wrapf(err, "[cfgadmin] Write Path %q", v.Path))
+			return
+		}
+		if h.o.Log.IsInfo() {
+			h.o.Log.Info("cfgadmin.Handler.Write", log.Stringer("path", v.Path), loghttp.Request("request", r))
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalize converts JSON numbers without a fraction into integers and a JSON
+// array of a multiselect field into a comma separated string like in
+// core_config_data.
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case float64:
+		if val == math.Trunc(val) && math.Abs(val) < 1<<53 {
+			return int(val)
+		}
+	case []interface{}:
+		vals := make([]string, len(val))
+		for i, iv := range val {
+			switch ivt := normalize(iv).(type) {
+			case string:
+				vals[i] = ivt
+			case int:
+				vals[i] = strconv.Itoa(ivt)
+			default:
+				return v // rejected by the validation
+			}
+		}
+		return strings.Join(vals, ",")
+	}
+	return v
+}
+
+// valueString converts a validated scalar value into its string
+// representation.
+func valueString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	return ""
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	if err := response.NewPrinter(w, r).JSON(code, v); err != nil {
+		h.o.Log.Info("cfgadmin.Handler.writeJSON", log.Err(err), loghttp.Request("request", r))
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	h.writeJSON(w, r, code, errorResponse{Error: msg})
+}
+
+// internalError logs the error and hides its details from the client.
+func (h *Handler) internalError(w http.ResponseWriter, r *http.Request, err error) {
+	h.o.Log.Info("cfgadmin.Handler.Error", log.Err(err), loghttp.Request("request", r))
+	h.writeError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+}
@@ -0,0 +1,313 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgadmin_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgadmin"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/net/auth"
+	"github.com/corestoreio/pkg/net/mw"
+	"github.com/corestoreio/pkg/storage/text"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/hashpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	if err := hashpool.Register("sha256", sha256.New); err != nil {
+		panic(err)
+	}
+}
+
+var testSections = element.MustNewConfiguration(
+	element.Section{
+		ID:    cfgpath.NewRoute("payment"),
+		Label: text.Chars("Payment"),
+		Groups: element.NewGroupSlice(
+			element.Group{
+				ID:    cfgpath.NewRoute("gateway"),
+				Label: text.Chars("Gateway"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("title"), Label: text.Chars("Title"), Type: element.TypeText, Scopes: scope.PermStore, Default: "Gateway"},
+					element.Field{ID: cfgpath.NewRoute("active"), Type: element.TypeSelect, Scopes: scope.PermWebsite, Default: false},
+					element.Field{ID: cfgpath.NewRoute("sort"), Type: element.TypeText, Scopes: scope.PermDefault, Default: 10},
+					element.Field{ID: cfgpath.NewRoute("secret"), Type: element.TypeObscure, Scopes: scope.PermWebsite, Default: "s3cr3t"},
+					element.Field{ID: cfgpath.NewRoute("timeout"), Type: element.TypeDuration, Scopes: scope.PermWebsite, Default: time.Minute},
+					element.Field{
+						ID: cfgpath.NewRoute("methods"), Type: element.TypeMultiselect, Scopes: scope.PermStore,
+						Source: cfgsource.MustNewByString("visa", "Visa", "amex", "American Express", "sepa", "SEPA"),
+					},
+				),
+			},
+		),
+	},
+)
+
+var rot13 = cfgmodel.EncryptFunc(func(s []byte) ([]byte, error) {
+	return append([]byte("enc:"), s...), nil
+})
+
+func newService(t *testing.T) *config.Service {
+	srv, err := config.NewService(config.NewInMemoryStore())
+	require.NoError(t, err)
+	p := cfgpath.MustNewByParts("payment/gateway/title")
+	require.NoError(t, srv.Write(p.BindWebsite(1), "Gateway Website"))
+	require.NoError(t, srv.Write(p.BindStore(2), "Zahlung"))
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("payment/gateway/active").BindWebsite(1), 1))
+	// store scope not allowed for field sort, must be ignored
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("payment/gateway/sort").BindStore(2), 99))
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("payment/gateway/secret"), []byte("enc:xyz")))
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("payment/gateway/methods").BindStore(2), "visa,sepa"))
+	return srv
+}
+
+func newHandler(t *testing.T, o cfgadmin.Options) (*config.Service, *cfgadmin.Handler) {
+	srv := newService(t)
+	h, err := cfgadmin.New(srv, testSections, o)
+	require.NoError(t, err)
+	return srv, h
+}
+
+func serve(h http.Handler, method, url, body string) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != "" {
+		req = httptest.NewRequest(method, url, strings.NewReader(body))
+	} else {
+		req = httptest.NewRequest(method, url, nil)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandler_Sections(t *testing.T) {
+	_, h := newHandler(t, cfgadmin.Options{Unprotected: true})
+	rec := serve(h, "GET", "/sections", "")
+	require.Exactly(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, rec.Header().Get("Content-Type"), "application/json")
+
+	var secs []cfgadmin.Section
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &secs))
+	require.Len(t, secs, 1)
+	assert.Exactly(t, "Payment", secs[0].Label)
+	require.Len(t, secs[0].Groups[0].Fields, 6)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `"route":"payment/gateway/title","label":"Title","type":"text","scopes":["default","websites","stores"]`)
+	assert.Contains(t, body, `"type":"duration","scopes":["default","websites"],"default":"1m0s"`)
+	assert.Contains(t, body, `"source":[{"Value":"visa","Label":"Visa"},{"Value":"amex","Label":"American Express"},{"Value":"sepa","Label":"SEPA"}]`)
+	assert.NotContains(t, body, "s3cr3t", "Default value of obscure field must not be listed")
+}
+
+func TestHandler_Values(t *testing.T) {
+	_, h := newHandler(t, cfgadmin.Options{Unprotected: true})
+
+	get := func(t *testing.T, url string) cfgadmin.Values {
+		rec := serve(h, "GET", url, "")
+		require.Exactly(t, http.StatusOK, rec.Code, rec.Body.String())
+		var vs cfgadmin.Values
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &vs))
+		return vs
+	}
+
+	t.Run("store", func(t *testing.T) {
+		vs := get(t, "/values?website=1&store=2")
+		assert.Exactly(t, "stores", vs.Scope)
+		assert.Exactly(t, int64(2), vs.ScopeID)
+		assert.Exactly(t, []cfgadmin.Value{
+			{Route: "payment/gateway/title", Value: "Zahlung", Scope: "stores", ScopeID: 2},
+			{Route: "payment/gateway/active", Value: true, Scope: "websites", ScopeID: 1, Inherited: true},
+			{Route: "payment/gateway/sort", Value: 10.0, Inherited: true},
+			{Route: "payment/gateway/secret", Value: "******", Scope: "default", Inherited: true, Obscure: true},
+			{Route: "payment/gateway/timeout", Value: "1m0s", Inherited: true},
+			{Route: "payment/gateway/methods", Value: []interface{}{"visa", "sepa"}, Scope: "stores", ScopeID: 2},
+		}, vs.Values)
+	})
+	t.Run("website with route filter", func(t *testing.T) {
+		vs := get(t, "/values?website=1&route=payment/gateway/title")
+		assert.Exactly(t, "websites", vs.Scope)
+		assert.Exactly(t, []cfgadmin.Value{
+			{Route: "payment/gateway/title", Value: "Gateway Website", Scope: "websites", ScopeID: 1},
+		}, vs.Values)
+	})
+	t.Run("default", func(t *testing.T) {
+		vs := get(t, "/values?route=payment")
+		assert.Exactly(t, "default", vs.Scope)
+		require.Len(t, vs.Values, 6)
+		assert.Exactly(t, cfgadmin.Value{Route: "payment/gateway/title", Value: "Gateway", Inherited: true}, vs.Values[0])
+		assert.Exactly(t, cfgadmin.Value{Route: "payment/gateway/methods", Value: nil, Inherited: true}, vs.Values[5])
+	})
+}
+
+func TestHandler_Write(t *testing.T) {
+	srv, h := newHandler(t, cfgadmin.Options{Encrypter: rot13, Unprotected: true})
+
+	rec := serve(h, "PUT", "/values?website=1", `{
+		"payment/gateway/title": "Neu",
+		"payment/gateway/active": false,
+		"payment/gateway/secret": "pa$$",
+		"payment/gateway/timeout": "90s"
+	}`)
+	require.Exactly(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	sg := srv.NewScoped(1, 0)
+	s, err := sg.String(cfgpath.NewRoute("payment/gateway/title"))
+	require.NoError(t, err)
+	assert.Exactly(t, "Neu", s)
+	b, err := sg.Bool(cfgpath.NewRoute("payment/gateway/active"))
+	require.NoError(t, err)
+	assert.False(t, b)
+	bt, err := srv.Byte(cfgpath.MustNewByParts("payment/gateway/secret").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "enc:pa$$", string(bt))
+
+	// masked obscure value stays untouched, arrays become CSV
+	rec = serve(h, "POST", "/values?website=1&store=2", `{
+		"payment/gateway/methods": ["amex", "visa"],
+		"payment/gateway/secret": "******"
+	}`)
+	require.Exactly(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	s, err = srv.String(cfgpath.MustNewByParts("payment/gateway/methods").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "amex,visa", s)
+	bt, err = srv.Byte(cfgpath.MustNewByParts("payment/gateway/secret").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "enc:pa$$", string(bt))
+}
+
+func TestHandler_Write_Invalid(t *testing.T) {
+	srv, h := newHandler(t, cfgadmin.Options{Unprotected: true})
+
+	rec := serve(h, "PUT", "/values?website=1&store=2", `{
+		"payment/gateway/title": "Valid",
+		"payment/gateway/sort": 5,
+		"payment/gateway/methods": ["bitcoin"],
+		"payment/unknown/path": 1
+	}`)
+	require.Exactly(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	var resp struct {
+		Error  string
+		Fields []struct {
+			Field string
+			Code  string
+		}
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Exactly(t, "Validation failed", resp.Error)
+	require.Len(t, resp.Fields, 3)
+	assert.Exactly(t, "payment/gateway/methods", resp.Fields[0].Field)
+	assert.Exactly(t, "source", resp.Fields[0].Code)
+	assert.Exactly(t, "scope", resp.Fields[1].Code)
+	assert.Exactly(t, "unknown_path", resp.Fields[2].Code)
+
+	s, err := srv.String(cfgpath.MustNewByParts("payment/gateway/title").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "Zahlung", s, "Valid values must not be written")
+}
+
+func TestHandler_Errors(t *testing.T) {
+	_, h := newHandler(t, cfgadmin.Options{Unprotected: true})
+	_, hRO := newHandler(t, cfgadmin.Options{ReadOnly: true, Unprotected: true})
+
+	tests := []struct {
+		h          http.Handler
+		method     string
+		url        string
+		body       string
+		wantCode   int
+		wantErr    string
+		wantHeader string
+	}{
+		{h, "GET", "/unknown", "", http.StatusNotFound, "Not Found", ""},
+		{h, "GET", "/values?website=x", "", http.StatusBadRequest, `Invalid website ID \"x\"`, ""},
+		{h, "GET", "/values?store=2", "", http.StatusBadRequest, "Store ID 2 requires a website ID", ""},
+		{h, "GET", "/values?route=catalog", "", http.StatusNotFound, `Unknown route \"catalog\"`, ""},
+		{h, "DELETE", "/values", "", http.StatusMethodNotAllowed, "Method Not Allowed", "GET, HEAD, PUT, POST"},
+		{h, "POST", "/sections", "", http.StatusMethodNotAllowed, "Method Not Allowed", "GET, HEAD"},
+		{h, "PUT", "/values", "{", http.StatusBadRequest, "Invalid JSON body", ""},
+		{h, "PUT", "/values", `{"payment":1}`, http.StatusBadRequest, `Invalid route \"payment\"`, ""},
+		{h, "PUT", "/values", `{"payment/gateway/secret":"x"}`, http.StatusBadRequest, "without an Encrypter", ""},
+		{hRO, "PUT", "/values", `{"payment/gateway/title":"x"}`, http.StatusMethodNotAllowed, "Method Not Allowed", "GET, HEAD"},
+	}
+	for i, test := range tests {
+		rec := serve(test.h, test.method, test.url, test.body)
+		assert.Exactly(t, test.wantCode, rec.Code, "Index %d: %s", i, rec.Body.String())
+		assert.Contains(t, rec.Body.String(), test.wantErr, "Index %d", i)
+		assert.Exactly(t, test.wantHeader, rec.Header().Get("Allow"), "Index %d", i)
+	}
+}
+
+func TestHandler_Auth(t *testing.T) {
+	srv := newService(t)
+	authSrv, err := auth.New(auth.WithRootConfig(srv), auth.WithResourceACLs(nil, nil), auth.WithSimpleBasicAuth("admin", "s3cr3t", "Config"))
+	require.NoError(t, err)
+
+	// runmode usually adds the scope to the context
+	withScope := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(scope.WithContext(r.Context(), 0, 0)))
+		})
+	}
+	h, err := cfgadmin.New(srv, testSections, cfgadmin.Options{
+		Middleware: mw.MiddlewareSlice{withScope, authSrv.WithAuthentication},
+	})
+	require.NoError(t, err)
+
+	rec := serve(h, "GET", "/sections", "")
+	assert.Exactly(t, http.StatusUnauthorized, rec.Code)
+	assert.Exactly(t, `Basic realm="Config"`, rec.Header().Get("WWW-Authenticate"))
+
+	req := httptest.NewRequest("GET", "/sections", nil).WithContext(context.Background())
+	req.SetBasicAuth("admin", "s3cr3t")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Exactly(t, http.StatusOK, rec.Code, rec.Body.String())
+}
+
+func TestNew_RequiresMiddleware(t *testing.T) {
+	srv := newService(t)
+
+	for _, o := range []cfgadmin.Options{
+		{},
+		{ReadOnly: true},
+	} {
+		_, err := cfgadmin.New(srv, testSections, o)
+		assert.True(t, errors.IsNotValid(err), "%#v: %+v", o, err)
+	}
+
+	for _, o := range []cfgadmin.Options{
+		{Unprotected: true},
+		{ReadOnly: true, Unprotected: true},
+		{Middleware: mw.MiddlewareSlice{func(h http.Handler) http.Handler { return h }}},
+	} {
+		_, err := cfgadmin.New(srv, testSections, o)
+		assert.NoError(t, err, "%#v", o)
+	}
+}
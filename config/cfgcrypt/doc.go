@@ -0,0 +1,35 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgcrypt provides authenticated encryption for the values of
+// cfgmodel.Obscure fields.
+//
+// A Keyring contains several keys, each with an ID and an algorithm,
+// AES-256-GCM or XChaCha20-Poly1305. The primary key encrypts new values, all
+// keys decrypt. The ciphertext contains the key ID, so old values stay
+// readable after adding a new primary key:
+//
+//		cs:<key ID>:<algorithm>:<base64(nonce + sealed data)>
+//
+// A Keyring can be loaded from a file or an environment variable, see
+// ParseKeyring. The MagentoDecrypter reads the values encrypted by Magento 2
+// with the key from app/etc/env.php and can be set as Keyring.Fallback to
+// migrate a Magento database. Rotate re-encrypts all obscure values of a
+// config.Storager with the primary key.
+//
+//		kr, err := cfgcrypt.LoadKeyringEnv("CONFIG_KEYS")
+//		kr.Fallback, err = cfgcrypt.NewMagentoDecrypter(envPHPCryptKey)
+//		password := cfgmodel.NewObscure("carriers/dhl/password",
+//			cfgmodel.WithEncrypter(kr), cfgmodel.WithDecrypter(kr))
+package cfgcrypt
@@ -0,0 +1,308 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// KeySize length of a key secret in bytes.
+const KeySize = 32
+
+// prefix of each ciphertext created by a Keyring.
+const prefix = "cs:"
+
+// Algorithm defines the AEAD cipher of a key.
+type Algorithm uint8
+
+// Supported algorithms.
+const (
+	AESGCM Algorithm = iota + 1
+	XChaCha20Poly1305
+)
+
+var algorithmNames = [...]string{"", "aesgcm", "xchacha20poly1305"}
+
+// String returns the name used in the ciphertext and in the keyring format.
+func (a Algorithm) String() string {
+	if int(a) < len(algorithmNames) {
+		return algorithmNames[a]
+	}
+	return "unknown"
+}
+
+func algorithmByName(name string) (Algorithm, error) {
+	for i, n := range algorithmNames {
+		if i > 0 && n == name {
+			return Algorithm(i), nil
+		}
+	}
+	return 0, errors.NewNotSupportedf("[cfgcrypt] Unknown algorithm %q", name)
+}
+
+// Key a secret with its ID and algorithm.
+type Key struct {
+	// ID identifies the key in the ciphertext. Must not contain a colon.
+	ID        string
+	Algorithm Algorithm
+	// Secret must have the length KeySize.
+	Secret []byte
+}
+
+func (k Key) aead() (cipher.AEAD, error) {
+	if k.ID == "" || strings.ContainsAny(k.ID, ": \t\n,") {
+		return nil, errors.NewNotValidf("[cfgcrypt] Invalid key ID %q", k.ID)
+	}
+	if len(k.Secret) != KeySize {
+		return nil, errors.NewNotValidf("[cfgcrypt] Key %q: Secret must have %d bytes, got %d", k.ID, KeySize, len(k.Secret))
+	}
+	switch k.Algorithm {
+	case AESGCM:
+		b, err := aes.NewCipher(k.Secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgcrypt] Key %q", k.ID)
+		}
+		a, err := cipher.NewGCM(b)
+		return a, errors.Wrapf(err, "[cfgcrypt] Key %q", k.ID)
+	case XChaCha20Poly1305:
+		a, err := chacha20poly1305.NewX(k.Secret)
+		return a, errors.Wrapf(err, "[cfgcrypt] Key %q", k.ID)
+	}
+	return nil, errors.NewNotSupportedf("[cfgcrypt] Key %q: Unknown algorithm %d", k.ID, k.Algorithm)
+}
+
+// GenerateKey creates a new random key.
+func GenerateKey(id string, a Algorithm) (Key, error) {
+	k := Key{ID: id, Algorithm: a, Secret: make([]byte, KeySize)}
+	if _, err := io.ReadFull(rand.Reader, k.Secret); err != nil {
+		return Key{}, errors.Wrap(err, "[cfgcrypt] GenerateKey")
+	}
+	if _, err := k.aead(); err != nil {
+		return Key{}, errors.Wrap(err, "[cfgcrypt] GenerateKey")
+	}
+	return k, nil
+}
+
+// String returns the key in the keyring format id:algorithm:base64(secret).
+func (k Key) String() string {
+	return k.ID + ":" + k.Algorithm.String() + ":" + base64.StdEncoding.EncodeToString(k.Secret)
+}
+
+type keyAEAD struct {
+	Key
+	aead cipher.AEAD
+}
+
+// Keyring encrypts with the primary key and decrypts with any key. It
+// implements the interfaces cfgmodel.Encrypter and cfgmodel.Decrypter. Safe
+// for concurrent use.
+type Keyring struct {
+	// Fallback decrypts values not created by a Keyring, e.g. the
+	// MagentoDecrypter. Optional. Set it before the first use.
+	Fallback interface {
+		Decrypt([]byte) ([]byte, error)
+	}
+
+	mu      sync.RWMutex
+	keys    map[string]keyAEAD
+	primary string
+}
+
+// NewKeyring creates a new Keyring. The first key becomes the primary key.
+func NewKeyring(primary Key, others ...Key) (*Keyring, error) {
+	kr := &Keyring{keys: make(map[string]keyAEAD, len(others)+1)}
+	for _, k := range append([]Key{primary}, others...) {
+		if err := kr.Add(k); err != nil {
+			return nil, errors.Wrap(err, "[cfgcrypt] NewKeyring")
+		}
+	}
+	kr.primary = primary.ID
+	return kr, nil
+}
+
+// Add adds a key for decryption. Error behaviour: NotValid, AlreadyExists.
+func (kr *Keyring) Add(k Key) error {
+	a, err := k.aead()
+	if err != nil {
+		return errors.Wrap(err, "[cfgcrypt] Keyring.Add")
+	}
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.keys[k.ID]; ok {
+		return errors.NewAlreadyExistsf("[cfgcrypt] Keyring.Add: Key %q already exists", k.ID)
+	}
+	kr.keys[k.ID] = keyAEAD{Key: k, aead: a}
+	return nil
+}
+
+// SetPrimary sets the key used for encryption. Error behaviour: NotFound.
+func (kr *Keyring) SetPrimary(id string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	if _, ok := kr.keys[id]; !ok {
+		return errors.NewNotFoundf("[cfgcrypt] Keyring.SetPrimary: Key %q not found", id)
+	}
+	kr.primary = id
+	return nil
+}
+
+// Primary returns the ID of the primary key.
+func (kr *Keyring) Primary() string {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+	return kr.primary
+}
+
+// Encrypt encrypts the plaintext with the primary key.
+func (kr *Keyring) Encrypt(plaintext []byte) ([]byte, error) {
+	kr.mu.RLock()
+	k := kr.keys[kr.primary]
+	kr.mu.RUnlock()
+
+	header := prefix + k.ID + ":" + k.Algorithm.String() + ":"
+	nonce := make([]byte, k.aead.NonceSize(), k.aead.NonceSize()+len(plaintext)+k.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "[cfgcrypt] Keyring.Encrypt")
+	}
+	sealed := k.aead.Seal(nonce, nonce, plaintext, []byte(header))
+
+	ret := make([]byte, len(header)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(ret, header)
+	base64.StdEncoding.Encode(ret[len(header):], sealed)
+	return ret, nil
+}
+
+// KeyID returns the ID of the key which encrypted the ciphertext. Returns
+// false if the ciphertext has not been created by a Keyring.
+func KeyID(ciphertext []byte) (string, bool) {
+	if !bytes.HasPrefix(ciphertext, []byte(prefix)) {
+		return "", false
+	}
+	parts := bytes.SplitN(ciphertext[len(prefix):], []byte(":"), 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	return string(parts[0]), true
+}
+
+// Decrypt decrypts a ciphertext created by any key of the Keyring or by the
+// Fallback. Error behaviour: NotFound for an unknown key ID, NotValid for a
+// modified or malformed ciphertext, NotSupported for a foreign format without
+// a Fallback. An empty ciphertext returns an empty plaintext.
+func (kr *Keyring) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, nil
+	}
+	id, ok := KeyID(ciphertext)
+	if !ok {
+		if kr.Fallback == nil {
+			return nil, errors.NewNotSupportedf("[cfgcrypt] Keyring.Decrypt: Unknown format")
+		}
+		pt, err := kr.Fallback.Decrypt(ciphertext)
+		return pt, errors.Wrap(err, "[cfgcrypt] Keyring.Decrypt.Fallback")
+	}
+
+	kr.mu.RLock()
+	k, ok := kr.keys[id]
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, errors.NewNotFoundf("[cfgcrypt] Keyring.Decrypt: Key %q not found", id)
+	}
+
+	header := prefix + id + ":" + k.Algorithm.String() + ":"
+	if !bytes.HasPrefix(ciphertext, []byte(header)) {
+		return nil, errors.NewNotValidf("[cfgcrypt] Keyring.Decrypt: Algorithm mismatch for key %q", id)
+	}
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(ciphertext)-len(header)))
+	n, err := base64.StdEncoding.Decode(sealed, ciphertext[len(header):])
+	if err != nil {
+		return nil, errors.NewNotValid(err, "[cfgcrypt] Keyring.Decrypt.Base64")
+	}
+	sealed = sealed[:n]
+	ns := k.aead.NonceSize()
+	if len(sealed) < ns+k.aead.Overhead() {
+		return nil, errors.NewNotValidf("[cfgcrypt] Keyring.Decrypt: Ciphertext too short")
+	}
+	pt, err := k.aead.Open(nil, sealed[:ns], sealed[ns:], []byte(header))
+	if err != nil {
+		return nil, errors.NewNotValid(err, "[cfgcrypt] Keyring.Decrypt.Open")
+	}
+	return pt, nil
+}
+
+// ParseKeyring creates a Keyring from keys in the format written by
+// Key.String, separated by commas or new lines. The first key becomes the
+// primary key. Empty lines and lines starting with # get ignored.
+//		2017-06:xchacha20poly1305:SGVsbG8gV29ybGQhIEhlbGxvIFdvcmxkISBIZWxsbyE=
+//		2016-01:aesgcm:QUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUFBQUE=
+func ParseKeyring(data string) (*Keyring, error) {
+	var keys []Key
+	for _, line := range strings.FieldsFunc(data, func(r rune) bool { return r == ',' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		parts := strings.Split(line, ":")
+		if len(parts) != 3 {
+			return nil, errors.NewNotValidf("[cfgcrypt] ParseKeyring: Expecting id:algorithm:secret, got %d parts", len(parts))
+		}
+		a, err := algorithmByName(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgcrypt] ParseKeyring Key %q", parts[0])
+		}
+		secret, err := base64.StdEncoding.DecodeString(parts[2])
+		if err != nil {
+			return nil, errors.NewNotValid(err, "[cfgcrypt] ParseKeyring Key "+parts[0])
+		}
+		keys = append(keys, Key{ID: parts[0], Algorithm: a, Secret: secret})
+	}
+	if len(keys) == 0 {
+		return nil, errors.NewEmptyf("[cfgcrypt] ParseKeyring: No keys found")
+	}
+	return NewKeyring(keys[0], keys[1:]...)
+}
+
+// LoadKeyringFile reads a Keyring from a file. See ParseKeyring.
+func LoadKeyringFile(filename string) (*Keyring, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgcrypt] LoadKeyringFile %q", filename)
+	}
+	kr, err := ParseKeyring(string(data))
+	return kr, errors.Wrapf(err, "[cfgcrypt] LoadKeyringFile %q", filename)
+}
+
+// LoadKeyringEnv reads a Keyring from an environment variable. See
+// ParseKeyring. Error behaviour: NotFound.
+func LoadKeyringEnv(name string) (*Keyring, error) {
+	data, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.NewNotFoundf("[cfgcrypt] LoadKeyringEnv: Variable %q not found", name)
+	}
+	kr, err := ParseKeyring(data)
+	return kr, errors.Wrapf(err, "[cfgcrypt] LoadKeyringEnv %q", name)
+}
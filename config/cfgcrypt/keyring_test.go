@@ -0,0 +1,175 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgcrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustKey(id string, a cfgcrypt.Algorithm, fill byte) cfgcrypt.Key {
+	return cfgcrypt.Key{ID: id, Algorithm: a, Secret: bytes.Repeat([]byte{fill}, cfgcrypt.KeySize)}
+}
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	for _, a := range []cfgcrypt.Algorithm{cfgcrypt.AESGCM, cfgcrypt.XChaCha20Poly1305} {
+		t.Run(a.String(), func(t *testing.T) {
+			kr, err := cfgcrypt.NewKeyring(mustKey("k1", a, 'a'))
+			require.NoError(t, err)
+
+			ct, err := kr.Encrypt([]byte("Gopher Secret"))
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(ct, []byte("cs:k1:"+a.String()+":")), "%s", ct)
+			id, ok := cfgcrypt.KeyID(ct)
+			assert.True(t, ok)
+			assert.Exactly(t, "k1", id)
+
+			ct2, err := kr.Encrypt([]byte("Gopher Secret"))
+			require.NoError(t, err)
+			assert.NotEqual(t, ct, ct2, "Nonce must be random")
+
+			pt, err := kr.Decrypt(ct)
+			require.NoError(t, err)
+			assert.Exactly(t, "Gopher Secret", string(pt))
+		})
+	}
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	kr, err := cfgcrypt.NewKeyring(mustKey("old", cfgcrypt.AESGCM, 'o'))
+	require.NoError(t, err)
+	ctOld, err := kr.Encrypt([]byte("old value"))
+	require.NoError(t, err)
+
+	require.NoError(t, kr.Add(mustKey("new", cfgcrypt.XChaCha20Poly1305, 'n')))
+	assert.True(t, errors.IsAlreadyExists(kr.Add(mustKey("new", cfgcrypt.AESGCM, 'x'))))
+	assert.True(t, errors.IsNotFound(kr.SetPrimary("missing")))
+	require.NoError(t, kr.SetPrimary("new"))
+	assert.Exactly(t, "new", kr.Primary())
+
+	ctNew, err := kr.Encrypt([]byte("new value"))
+	require.NoError(t, err)
+	id, _ := cfgcrypt.KeyID(ctNew)
+	assert.Exactly(t, "new", id)
+
+	pt, err := kr.Decrypt(ctOld)
+	require.NoError(t, err)
+	assert.Exactly(t, "old value", string(pt))
+
+	other, err := cfgcrypt.NewKeyring(mustKey("new", cfgcrypt.XChaCha20Poly1305, 'n'))
+	require.NoError(t, err)
+	_, err = other.Decrypt(ctOld)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
+
+func TestKeyring_Decrypt_Errors(t *testing.T) {
+	kr, err := cfgcrypt.NewKeyring(mustKey("k1", cfgcrypt.AESGCM, 'a'))
+	require.NoError(t, err)
+	ct, err := kr.Encrypt([]byte("Gopher"))
+	require.NoError(t, err)
+
+	t.Run("tampered header", func(t *testing.T) {
+		_, err := kr.Decrypt(bytes.Replace(ct, []byte("aesgcm"), []byte("xchacha20poly1305"), 1))
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+	})
+	t.Run("tampered payload", func(t *testing.T) {
+		raw, err := base64.StdEncoding.DecodeString(string(ct[len("cs:k1:aesgcm:"):]))
+		require.NoError(t, err)
+		raw[len(raw)-1] ^= 0xff
+		_, err = kr.Decrypt(append([]byte("cs:k1:aesgcm:"), base64.StdEncoding.EncodeToString(raw)...))
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+	})
+	t.Run("too short", func(t *testing.T) {
+		_, err := kr.Decrypt([]byte("cs:k1:aesgcm:AAAA"))
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+	})
+	t.Run("foreign format without fallback", func(t *testing.T) {
+		_, err := kr.Decrypt([]byte("0:3:abc"))
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+	})
+}
+
+func TestNewKeyring_Errors(t *testing.T) {
+	_, err := cfgcrypt.NewKeyring(cfgcrypt.Key{ID: "k1", Algorithm: cfgcrypt.AESGCM, Secret: []byte("short")})
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	_, err = cfgcrypt.NewKeyring(mustKey("k:1", cfgcrypt.AESGCM, 'a'))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	_, err = cfgcrypt.NewKeyring(mustKey("k1", cfgcrypt.Algorithm(9), 'a'))
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+}
+
+func TestParseKeyring(t *testing.T) {
+	k1, err := cfgcrypt.GenerateKey("2017-06", cfgcrypt.XChaCha20Poly1305)
+	require.NoError(t, err)
+	k2 := mustKey("2016-01", cfgcrypt.AESGCM, 'b')
+
+	old, err := cfgcrypt.NewKeyring(k2)
+	require.NoError(t, err)
+	ct, err := old.Encrypt([]byte("Gopher"))
+	require.NoError(t, err)
+
+	data := "# primary key first\n" + k1.String() + "\n\n" + k2.String() + "\n"
+	check := func(t *testing.T, kr *cfgcrypt.Keyring, err error) {
+		require.NoError(t, err)
+		assert.Exactly(t, "2017-06", kr.Primary())
+		pt, err := kr.Decrypt(ct)
+		require.NoError(t, err)
+		assert.Exactly(t, "Gopher", string(pt))
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		kr, err := cfgcrypt.ParseKeyring(data)
+		check(t, kr, err)
+	})
+	t.Run("file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cfgcrypt")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		fn := filepath.Join(dir, "keys")
+		require.NoError(t, ioutil.WriteFile(fn, []byte(data), 0600))
+		kr, err := cfgcrypt.LoadKeyringFile(fn)
+		check(t, kr, err)
+	})
+	t.Run("env", func(t *testing.T) {
+		const name = "CFGCRYPT_TEST_KEYS"
+		require.NoError(t, os.Setenv(name, k1.String()+","+k2.String()))
+		defer os.Unsetenv(name)
+		kr, err := cfgcrypt.LoadKeyringEnv(name)
+		check(t, kr, err)
+
+		_, err = cfgcrypt.LoadKeyringEnv(name + "_MISSING")
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := cfgcrypt.ParseKeyring("# nothing\n")
+		assert.True(t, errors.IsEmpty(err), "%+v", err)
+		_, err = cfgcrypt.ParseKeyring("k1:aesgcm")
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+		_, err = cfgcrypt.ParseKeyring("k1:rot13:" + strings.Repeat("A", 44))
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+		_, err = cfgcrypt.ParseKeyring("k1:aesgcm:%%%")
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// magentoCipherSodium the cipher version of Magento 2.2 and later, libsodium
+// ChaCha20-Poly1305 IETF.
+const magentoCipherSodium = 3
+
+// MagentoDecrypter decrypts values encrypted by Magento 2 with the sodium
+// cipher, the default since Magento 2.2. Older mcrypt ciphers are not
+// supported. It implements the interface cfgmodel.Decrypter.
+type MagentoDecrypter struct {
+	keys [][]byte
+}
+
+// NewMagentoDecrypter creates a new decrypter from the value of crypt/key in
+// app/etc/env.php. Multiple keys are separated by white space, the line
+// number is the key version. Keys with the prefix "base64" get decoded.
+func NewMagentoDecrypter(cryptKey string) (*MagentoDecrypter, error) {
+	fields := strings.Fields(cryptKey)
+	if len(fields) == 0 {
+		return nil, errors.NewEmptyf("[cfgcrypt] NewMagentoDecrypter: No keys found")
+	}
+	md := &MagentoDecrypter{keys: make([][]byte, len(fields))}
+	for i, f := range fields {
+		key := []byte(f)
+		if strings.HasPrefix(f, "base64") {
+			k, err := base64.StdEncoding.DecodeString(f[len("base64"):])
+			if err != nil {
+				return nil, errors.NewNotValid(err, "[cfgcrypt] NewMagentoDecrypter: Key version "+strconv.Itoa(i))
+			}
+			key = k
+		}
+		if len(key) != chacha20poly1305.KeySize {
+			return nil, errors.NewNotValidf("[cfgcrypt] NewMagentoDecrypter: Key version %d must have %d bytes, got %d", i, chacha20poly1305.KeySize, len(key))
+		}
+		md.keys[i] = key
+	}
+	return md, nil
+}
+
+// Decrypt decrypts a value in the format keyVersion:cipherVersion:payload or
+// cipherVersion:payload where the key version defaults to zero. Error
+// behaviour: NotValid, NotFound for an unknown key version, NotSupported for
+// cipher versions other than sodium.
+func (md *MagentoDecrypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	parts := bytes.Split(ciphertext, []byte(":"))
+	var keyVersion, cipherVersion int
+	var err error
+	switch len(parts) {
+	case 3:
+		if keyVersion, err = strconv.Atoi(string(parts[0])); err != nil {
+			return nil, errors.NewNotValid(err, "[cfgcrypt] MagentoDecrypter.Decrypt.KeyVersion")
+		}
+		parts = parts[1:]
+		fallthrough
+	case 2:
+		if cipherVersion, err = strconv.Atoi(string(parts[0])); err != nil {
+			return nil, errors.NewNotValid(err, "[cfgcrypt] MagentoDecrypter.Decrypt.CipherVersion")
+		}
+	default:
+		return nil, errors.NewNotSupportedf("[cfgcrypt] MagentoDecrypter.Decrypt: Legacy format without cipher version")
+	}
+	if cipherVersion != magentoCipherSodium {
+		return nil, errors.NewNotSupportedf("[cfgcrypt] MagentoDecrypter.Decrypt: Cipher version %d", cipherVersion)
+	}
+	if keyVersion < 0 || keyVersion >= len(md.keys) {
+		return nil, errors.NewNotFoundf("[cfgcrypt] MagentoDecrypter.Decrypt: Key version %d", keyVersion)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, errors.NewNotValid(err, "[cfgcrypt] MagentoDecrypter.Decrypt.Base64")
+	}
+	if len(payload) < chacha20poly1305.NonceSize+chacha20poly1305.Overhead {
+		return nil, errors.NewNotValidf("[cfgcrypt] MagentoDecrypter.Decrypt: Ciphertext too short")
+	}
+	aead, err := chacha20poly1305.New(md.keys[keyVersion])
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgcrypt] MagentoDecrypter.Decrypt")
+	}
+	// Magento passes the nonce as additional data.
+	nonce := payload[:chacha20poly1305.NonceSize]
+	pt, err := aead.Open(nil, nonce, payload[chacha20poly1305.NonceSize:], nonce)
+	if err != nil {
+		return nil, errors.NewNotValid(err, "[cfgcrypt] MagentoDecrypter.Decrypt.Open")
+	}
+	return pt, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt_test
+
+import (
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgcrypt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	magentoKey0 = "b3a5e6f8d2c4a1b0e9f7d6c5b4a39281"
+	magentoKey1 = "0123456789abcdef0123456789abcdef"
+)
+
+// magentoEncrypt mirrors Magento\Framework\Encryption\Adapter\SodiumChachaIetf.
+func magentoEncrypt(t *testing.T, key string, keyVersion int, plaintext string) string {
+	aead, err := chacha20poly1305.New([]byte(key))
+	require.NoError(t, err)
+	nonce := []byte("123456789012")
+	payload := aead.Seal(nonce, nonce, []byte(plaintext), nonce)
+	return strconv.Itoa(keyVersion) + ":3:" + base64.StdEncoding.EncodeToString(payload)
+}
+
+func TestMagentoDecrypter(t *testing.T) {
+	md, err := cfgcrypt.NewMagentoDecrypter(magentoKey0 + "\n" + magentoKey1)
+	require.NoError(t, err)
+
+	pt, err := md.Decrypt([]byte(magentoEncrypt(t, magentoKey0, 0, "Magento Secret")))
+	require.NoError(t, err)
+	assert.Exactly(t, "Magento Secret", string(pt))
+
+	pt, err = md.Decrypt([]byte(magentoEncrypt(t, magentoKey1, 1, "Rotated Secret")))
+	require.NoError(t, err)
+	assert.Exactly(t, "Rotated Secret", string(pt))
+
+	// two part format defaults to key version 0
+	pt, err = md.Decrypt([]byte(magentoEncrypt(t, magentoKey0, 0, "Short")[2:]))
+	require.NoError(t, err)
+	assert.Exactly(t, "Short", string(pt))
+}
+
+func TestMagentoDecrypter_Base64Key(t *testing.T) {
+	key := "abcdefghijklmnopqrstuvwxyz012345"
+	md, err := cfgcrypt.NewMagentoDecrypter("base64" + base64.StdEncoding.EncodeToString([]byte(key)))
+	require.NoError(t, err)
+	pt, err := md.Decrypt([]byte(magentoEncrypt(t, key, 0, "Gopher")))
+	require.NoError(t, err)
+	assert.Exactly(t, "Gopher", string(pt))
+}
+
+func TestMagentoDecrypter_Errors(t *testing.T) {
+	_, err := cfgcrypt.NewMagentoDecrypter(" \n")
+	assert.True(t, errors.IsEmpty(err), "%+v", err)
+	_, err = cfgcrypt.NewMagentoDecrypter("tooshort")
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+
+	md, err := cfgcrypt.NewMagentoDecrypter(magentoKey0)
+	require.NoError(t, err)
+
+	tests := []struct {
+		ciphertext string
+		errBhf     errors.BehaviourFunc
+	}{
+		{"legacy", errors.IsNotSupported},
+		{"0:2:abc", errors.IsNotSupported},
+		{"x:3:abc", errors.IsNotValid},
+		{"0:y:abc", errors.IsNotValid},
+		{magentoEncrypt(t, magentoKey0, 1, "Gopher"), errors.IsNotFound},
+		{"0:3:%%%", errors.IsNotValid},
+		{"0:3:AAAA", errors.IsNotValid},
+		{magentoEncrypt(t, magentoKey1, 0, "Wrong key"), errors.IsNotValid},
+	}
+	for i, test := range tests {
+		_, err := md.Decrypt([]byte(test.ciphertext))
+		assert.True(t, test.errBhf(err), "Index %d: %+v", i, err)
+	}
+}
+
+func TestKeyring_Fallback(t *testing.T) {
+	md, err := cfgcrypt.NewMagentoDecrypter(magentoKey0)
+	require.NoError(t, err)
+	kr, err := cfgcrypt.NewKeyring(mustKey("k1", cfgcrypt.AESGCM, 'a'))
+	require.NoError(t, err)
+	kr.Fallback = md
+
+	pt, err := kr.Decrypt([]byte(magentoEncrypt(t, magentoKey0, 0, "From Magento")))
+	require.NoError(t, err)
+	assert.Exactly(t, "From Magento", string(pt))
+}
@@ -0,0 +1,101 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt
+
+import (
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+)
+
+// RotateResult reports the work done by Rotate.
+type RotateResult struct {
+	// Rotated contains the paths whose values got re-encrypted.
+	Rotated cfgpath.PathSlice
+	// Skipped counts the values already encrypted with the primary key or
+	// being empty.
+	Skipped int
+}
+
+// Rotate re-encrypts the values of all fields with type element.TypeObscure
+// with the primary key of the Keyring. Values encrypted by other keys of the
+// Keyring or by its Fallback get decrypted first. Values already encrypted
+// with the primary key get skipped, so Rotate can run again after an error.
+// Remove an old key from the Keyring only after Rotate succeeded.
+func Rotate(s config.Storager, kr *Keyring, ss element.SectionSlice) (RotateResult, error) {
+	var res RotateResult
+	fs, err := ss.FieldsByRoute()
+	if err != nil {
+		return res, errors.Wrap(err, "[cfgcrypt] Rotate.FieldsByRoute")
+	}
+	obscure := make(map[string]bool)
+	for r, f := range fs {
+		if f.Type != nil && f.Type.Type() == element.TypeObscure {
+			obscure[r] = true
+		}
+	}
+	if len(obscure) == 0 {
+		return res, nil
+	}
+
+	keys, err := s.AllKeys()
+	if err != nil {
+		return res, errors.Wrap(err, "[cfgcrypt] Rotate.AllKeys")
+	}
+	primary := kr.Primary()
+	for _, k := range keys {
+		if !obscure[k.Route.String()] {
+			continue
+		}
+		v, err := s.Get(k)
+		if err != nil {
+			return res, errors.Wrapf(err, "[cfgcrypt] Rotate.Get Path %q", k)
+		}
+
+		var ct []byte
+		switch vt := v.(type) {
+		case []byte:
+			ct = vt
+		case string:
+			ct = []byte(vt)
+		case nil:
+		default:
+			return res, errors.NewNotSupportedf("[cfgcrypt] Rotate: Path %q has unsupported type %T", k, v)
+		}
+		if id, ok := KeyID(ct); len(ct) == 0 || (ok && id == primary) {
+			res.Skipped++
+			continue
+		}
+
+		pt, err := kr.Decrypt(ct)
+		if err != nil {
+			return res, errors.Wrapf(err, "[cfgcrypt] Rotate.Decrypt Path %q", k)
+		}
+		if ct, err = kr.Encrypt(pt); err != nil {
+			return res, errors.Wrapf(err, "[cfgcrypt] Rotate.Encrypt Path %q", k)
+		}
+		// keep the type of the stored value
+		var nv interface{} = ct
+		if _, ok := v.(string); ok {
+			nv = string(ct)
+		}
+		if err := s.Set(k, nv); err != nil {
+			return res, errors.Wrapf(err, "[cfgcrypt] Rotate.Set Path %q", k)
+		}
+		res.Rotated = append(res.Rotated, k)
+	}
+	return res, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgcrypt_test
+
+import (
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgcrypt"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSections = element.MustNewConfiguration(
+	element.Section{
+		ID: cfgpath.NewRoute("carriers"),
+		Groups: element.NewGroupSlice(
+			element.Group{
+				ID: cfgpath.NewRoute("dhl"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("title"), Type: element.TypeText, Scopes: scope.PermStore},
+					element.Field{ID: cfgpath.NewRoute("password"), Type: element.TypeObscure, Scopes: scope.PermWebsite},
+				),
+			},
+		),
+	},
+)
+
+func TestRotate(t *testing.T) {
+	oldKR, err := cfgcrypt.NewKeyring(mustKey("old", cfgcrypt.AESGCM, 'o'))
+	require.NoError(t, err)
+	ctOld, err := oldKR.Encrypt([]byte("old secret"))
+	require.NoError(t, err)
+
+	md, err := cfgcrypt.NewMagentoDecrypter(magentoKey0)
+	require.NoError(t, err)
+	kr, err := cfgcrypt.NewKeyring(mustKey("new", cfgcrypt.XChaCha20Poly1305, 'n'), mustKey("old", cfgcrypt.AESGCM, 'o'))
+	require.NoError(t, err)
+	kr.Fallback = md
+	ctNew, err := kr.Encrypt([]byte("new secret"))
+	require.NoError(t, err)
+
+	pw := cfgpath.MustNewByParts("carriers/dhl/password")
+	s := config.NewInMemoryStore()
+	require.NoError(t, s.Set(pw, string(ctOld)))
+	require.NoError(t, s.Set(pw.BindWebsite(1), []byte(magentoEncrypt(t, magentoKey0, 0, "magento secret"))))
+	require.NoError(t, s.Set(pw.BindWebsite(2), ctNew))
+	require.NoError(t, s.Set(pw.BindWebsite(3), ""))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("carriers/dhl/title"), "DHL"))
+
+	res, err := cfgcrypt.Rotate(s, kr, testSections)
+	require.NoError(t, err)
+	assert.Len(t, res.Rotated, 2)
+	assert.Exactly(t, 2, res.Skipped)
+
+	v, err := s.Get(pw)
+	require.NoError(t, err)
+	assert.IsType(t, "", v, "Rotate must keep the type")
+	v, err = s.Get(pw.BindWebsite(2))
+	require.NoError(t, err)
+	assert.Exactly(t, ctNew, v, "Already rotated value must not change")
+	v, err = s.Get(cfgpath.MustNewByParts("carriers/dhl/title"))
+	require.NoError(t, err)
+	assert.Exactly(t, "DHL", v)
+
+	// old keys and Magento are not needed anymore
+	newKR, err := cfgcrypt.NewKeyring(mustKey("new", cfgcrypt.XChaCha20Poly1305, 'n'))
+	require.NoError(t, err)
+	srv, err := config.NewService(s)
+	require.NoError(t, err)
+	obs := cfgmodel.NewObscure("carriers/dhl/password",
+		cfgmodel.WithFieldFromSectionSlice(testSections),
+		cfgmodel.WithEncrypter(newKR), cfgmodel.WithDecrypter(newKR),
+	)
+	for websiteID, want := range map[int64]string{0: "old secret", 1: "magento secret", 2: "new secret", 3: ""} {
+		pt, err := obs.Get(srv.NewScoped(websiteID, 0))
+		require.NoError(t, err, "Website %d", websiteID)
+		assert.Exactly(t, want, string(pt), "Website %d", websiteID)
+	}
+
+	res, err = cfgcrypt.Rotate(s, kr, testSections)
+	require.NoError(t, err)
+	assert.Len(t, res.Rotated, 0)
+	assert.Exactly(t, 4, res.Skipped)
+}
+
+func TestRotate_Errors(t *testing.T) {
+	kr, err := cfgcrypt.NewKeyring(mustKey("new", cfgcrypt.AESGCM, 'n'))
+	require.NoError(t, err)
+
+	s := config.NewInMemoryStore()
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("carriers/dhl/password"), "0:3:magento"))
+	_, err = cfgcrypt.Rotate(s, kr, testSections)
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("carriers/dhl/password"), 42))
+	_, err = cfgcrypt.Rotate(s, kr, testSections)
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+}
@@ -0,0 +1,30 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgdump exports all values of a config.Storager into a
+// deterministic YAML or JSON document grouped by scope and imports such a
+// document again. Moving the configuration between staging and production
+// works like:
+//
+//		doc, err := cfgdump.Export(staging, cfgdump.Options{Sections: sections})
+//		err = doc.Encode(w, cfgdump.FormatYAML)
+//		// ...
+//		doc, err = cfgdump.Decode(r, cfgdump.FormatYAML)
+//		changes, err := cfgdump.Import(production, doc, cfgdump.Options{Sections: sections, DryRun: true})
+//		fmt.Print(changes)
+//
+// The element.SectionSlice identifies the fields of type
+// element.TypeObscure, whose encrypted values get masked or excluded, and
+// validates the paths and scopes during import.
+package cfgdump
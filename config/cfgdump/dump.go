@@ -0,0 +1,323 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"gopkg.in/yaml.v2"
+)
+
+// MaskValue replaces the value of an obscure field in a masked export. Import
+// skips all obscure fields containing MaskValue.
+const MaskValue = "******"
+
+// ObscureMode defines how Export and Import handle the values of fields with
+// type element.TypeObscure.
+type ObscureMode uint8
+
+// Supported ObscureModes. Default ObscureMask.
+const (
+	ObscureMask ObscureMode = iota
+	ObscureExclude
+	ObscureInclude
+)
+
+// Format of a dumped Document.
+type Format uint8
+
+// Supported document formats.
+const (
+	FormatYAML Format = iota
+	FormatJSON
+)
+
+// Options for Export and Import.
+type Options struct {
+	// Sections defines the known fields. Optional. Without Sections no value
+	// can be detected as obscure and Import does not validate the paths.
+	Sections element.SectionSlice
+	// Obscure defines the handling of obscure fields.
+	Obscure ObscureMode
+	// SkipUnknown ignores all paths without a field in Sections. Otherwise
+	// Import returns a NotFound error for an unknown path.
+	SkipUnknown bool
+	// DryRun Import only calculates the changes without writing them.
+	DryRun bool
+}
+
+// fields maps a route to its field.
+type fields map[string]element.Field
+
+func (o Options) fields() (fields, error) {
+	if len(o.Sections) == 0 {
+		return nil, nil
+	}
+	fs, err := o.Sections.FieldsByRoute()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Options.Sections")
+	}
+	return fields(fs), nil
+}
+
+// lookup returns the field of a route. found is true when Sections are not
+// set.
+func (fs fields) lookup(route string) (f element.Field, found bool) {
+	if fs == nil {
+		return element.Field{}, true
+	}
+	f, found = fs[route]
+	return
+}
+
+func isObscure(f element.Field) bool {
+	return f.Type != nil && f.Type.Type() == element.TypeObscure
+}
+
+// Document contains the values of all paths grouped by scope. The map keys
+// get sorted by the encoders which makes a dump deterministic.
+type Document struct {
+	// Default maps a route to its value.
+	Default map[string]interface{} `json:"default,omitempty" yaml:"default,omitempty"`
+	// Websites maps the website ID to the routes and values.
+	Websites map[int64]map[string]interface{} `json:"websites,omitempty" yaml:"websites,omitempty"`
+	// Stores maps the store ID to the routes and values.
+	Stores map[int64]map[string]interface{} `json:"stores,omitempty" yaml:"stores,omitempty"`
+}
+
+// Set adds a value to the Document. Error behaviour: NotSupported.
+func (d *Document) Set(p cfgpath.Path, v interface{}) error {
+	scp, id := p.ScopeID.Unpack()
+	r := p.Route.String()
+	switch scp {
+	case scope.Default:
+		if d.Default == nil {
+			d.Default = make(map[string]interface{})
+		}
+		d.Default[r] = v
+	case scope.Website:
+		d.Websites = setScoped(d.Websites, id, r, v)
+	case scope.Store:
+		d.Stores = setScoped(d.Stores, id, r, v)
+	default:
+		return errors.NewNotSupportedf("[cfgdump] Scope %s of Path %q", scp, p)
+	}
+	return nil
+}
+
+func setScoped(m map[int64]map[string]interface{}, id int64, route string, v interface{}) map[int64]map[string]interface{} {
+	if m == nil {
+		m = make(map[int64]map[string]interface{})
+	}
+	if m[id] == nil {
+		m[id] = make(map[string]interface{})
+	}
+	m[id][route] = v
+	return m
+}
+
+// entry a path and its value within a Document.
+type entry struct {
+	path  cfgpath.Path
+	value interface{}
+}
+
+// entries returns all paths of the Document in the order default, websites
+// and stores.
+func (d *Document) entries() ([]entry, error) {
+	var es []entry
+	add := func(scp scope.Type, id int64, m map[string]interface{}) error {
+		for r, v := range m {
+			p, err := cfgpath.NewByParts(r)
+			if err != nil {
+				return errors.Wrapf(err, "[cfgdump] Route %q", r)
+			}
+			es = append(es, entry{path: p.Bind(scope.MakeTypeID(scp, id)), value: v})
+		}
+		return nil
+	}
+	if err := add(scope.Default, 0, d.Default); err != nil {
+		return nil, err
+	}
+	for id, m := range d.Websites {
+		if err := add(scope.Website, id, m); err != nil {
+			return nil, err
+		}
+	}
+	for id, m := range d.Stores {
+		if err := add(scope.Store, id, m); err != nil {
+			return nil, err
+		}
+	}
+	sortEntries(es)
+	return es, nil
+}
+
+// Encode writes the Document in the requested format.
+func (d *Document) Encode(w io.Writer, f Format) error {
+	var data []byte
+	var err error
+	switch f {
+	case FormatJSON:
+		data, err = json.MarshalIndent(d, "", "  ")
+		data = append(data, '\n')
+	case FormatYAML:
+		data, err = yaml.Marshal(d)
+	default:
+		return errors.NewNotSupportedf("[cfgdump] Encode: Unknown format %d", f)
+	}
+	if err != nil {
+		return errors.Wrap(err, "[cfgdump] Encode")
+	}
+	_, err = w.Write(data)
+	return errors.Wrap(err, "[cfgdump] Encode.Write")
+}
+
+// Decode reads a Document in the requested format. JSON numbers become int or
+// float64 like in YAML. Error behaviour: NotValid, NotSupported.
+func Decode(r io.Reader, f Format) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Decode.ReadAll")
+	}
+	d := new(Document)
+	switch f {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(d)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, d)
+	default:
+		return nil, errors.NewNotSupportedf("[cfgdump] Decode: Unknown format %d", f)
+	}
+	if err != nil {
+		return nil, errors.NewNotValid(err, "[cfgdump] Decode")
+	}
+	if f == FormatJSON {
+		if err := d.convertNumbers(); err != nil {
+			return nil, errors.Wrap(err, "[cfgdump] Decode")
+		}
+	}
+	return d, nil
+}
+
+func (d *Document) convertNumbers() error {
+	conv := func(m map[string]interface{}) error {
+		for k, v := range m {
+			n, ok := v.(json.Number)
+			if !ok {
+				continue
+			}
+			if i, err := n.Int64(); err == nil {
+				m[k] = int(i)
+				continue
+			}
+			fl, err := n.Float64()
+			if err != nil {
+				return errors.NewNotValid(err, "[cfgdump] Route "+k)
+			}
+			m[k] = fl
+		}
+		return nil
+	}
+	if err := conv(d.Default); err != nil {
+		return err
+	}
+	for _, m := range d.Websites {
+		if err := conv(m); err != nil {
+			return err
+		}
+	}
+	for _, m := range d.Stores {
+		if err := conv(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Export reads all keys and their values from the Storager. Obscure fields get
+// masked, excluded or included, depending on Options.Obscure. Error behaviour:
+// NotSupported for values which are not a scalar.
+func Export(s config.Storager, o Options) (*Document, error) {
+	fs, err := o.fields()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Export")
+	}
+	keys, err := s.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Export.AllKeys")
+	}
+	d := new(Document)
+	for _, k := range keys {
+		f, found := fs.lookup(k.Route.String())
+		if !found && o.SkipUnknown {
+			continue
+		}
+		obscure := isObscure(f)
+		if obscure && o.Obscure == ObscureExclude {
+			continue
+		}
+		v, err := s.Get(k)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Export.Get Path %q", k)
+		}
+		if v, err = normalize(v); err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Export Path %q", k)
+		}
+		if obscure && o.Obscure == ObscureMask {
+			v = MaskValue
+		}
+		if err := d.Set(k, v); err != nil {
+			return nil, errors.Wrap(err, "[cfgdump] Export")
+		}
+	}
+	return d, nil
+}
+
+// normalize converts a value into a scalar supported by YAML and JSON.
+func normalize(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return v, nil
+	case []byte:
+		return string(val), nil
+	case time.Time:
+		return val.Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return val.String(), nil
+	}
+	return nil, errors.NewNotSupportedf("[cfgdump] Value type %T is not a scalar", v)
+}
+
+// valueString returns the string used to compare two values.
+func valueString(v interface{}) (string, error) {
+	v, err := normalize(v)
+	if err != nil || v == nil {
+		return "", err
+	}
+	return fmt.Sprint(v), nil
+}
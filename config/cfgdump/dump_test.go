@@ -0,0 +1,187 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testSections = element.MustNewConfiguration(
+	element.Section{
+		ID: cfgpath.NewRoute("payment"),
+		Groups: element.NewGroupSlice(
+			element.Group{
+				ID: cfgpath.NewRoute("gateway"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("title"), Type: element.TypeText, Scopes: scope.PermStore},
+					element.Field{ID: cfgpath.NewRoute("secret"), Type: element.TypeObscure, Scopes: scope.PermWebsite},
+					element.Field{ID: cfgpath.NewRoute("sort"), Type: element.TypeText, Scopes: scope.PermDefault},
+				),
+			},
+		),
+	},
+)
+
+func newStorage(t *testing.T) config.Storager {
+	s := config.NewInMemoryStore()
+	for _, kv := range []struct {
+		p cfgpath.Path
+		v interface{}
+	}{
+		{cfgpath.MustNewByParts("payment/gateway/title"), "Gateway"},
+		{cfgpath.MustNewByParts("payment/gateway/title").BindStore(2), []byte("Zahlung")},
+		{cfgpath.MustNewByParts("payment/gateway/title").BindStore(10), "Paiement"},
+		{cfgpath.MustNewByParts("payment/gateway/secret").BindWebsite(1), "0:3:encrypted"},
+		{cfgpath.MustNewByParts("payment/gateway/sort"), 10},
+		{cfgpath.MustNewByParts("unknown/path/key"), true},
+	} {
+		require.NoError(t, s.Set(kv.p, kv.v))
+	}
+	return s
+}
+
+func TestExport_YAML(t *testing.T) {
+	d, err := cfgdump.Export(newStorage(t), cfgdump.Options{Sections: testSections})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, d.Encode(&buf, cfgdump.FormatYAML))
+	assert.Exactly(t, `default:
+  payment/gateway/sort: 10
+  payment/gateway/title: Gateway
+  unknown/path/key: true
+websites:
+  1:
+    payment/gateway/secret: '******'
+stores:
+  2:
+    payment/gateway/title: Zahlung
+  10:
+    payment/gateway/title: Paiement
+`, buf.String())
+}
+
+func TestExport_JSON(t *testing.T) {
+	d, err := cfgdump.Export(newStorage(t), cfgdump.Options{
+		Sections:    testSections,
+		Obscure:     cfgdump.ObscureExclude,
+		SkipUnknown: true,
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, d.Encode(&buf, cfgdump.FormatJSON))
+	assert.Exactly(t, `{
+  "default": {
+    "payment/gateway/sort": 10,
+    "payment/gateway/title": "Gateway"
+  },
+  "stores": {
+    "10": {
+      "payment/gateway/title": "Paiement"
+    },
+    "2": {
+      "payment/gateway/title": "Zahlung"
+    }
+  }
+}
+`, buf.String())
+
+	d2, err := cfgdump.Decode(&buf, cfgdump.FormatJSON)
+	require.NoError(t, err)
+	assert.Exactly(t, d, d2)
+}
+
+func TestImport(t *testing.T) {
+	d, err := cfgdump.Decode(strings.NewReader(`
+default:
+  payment/gateway/sort: 10
+  payment/gateway/title: Gateway Pro
+websites:
+  1:
+    payment/gateway/secret: '******'
+  3:
+    payment/gateway/secret: 0:3:new
+stores:
+  2:
+    payment/gateway/title: Zahlung
+  4:
+    payment/gateway/title: Betalning
+`), cfgdump.FormatYAML)
+	require.NoError(t, err)
+
+	s := newStorage(t)
+	cs, err := cfgdump.Import(s, d, cfgdump.Options{Sections: testSections, DryRun: true})
+	require.NoError(t, err)
+	assert.Exactly(t, `~ default/0/payment/gateway/title: "Gateway" => "Gateway Pro"
++ websites/3/payment/gateway/secret: "******"
++ stores/4/payment/gateway/title: "Betalning"
+`, cs.String())
+
+	v, err := s.Get(cfgpath.MustNewByParts("payment/gateway/title"))
+	require.NoError(t, err)
+	assert.Exactly(t, "Gateway", v, "DryRun must not write")
+
+	cs, err = cfgdump.Import(s, d, cfgdump.Options{Sections: testSections})
+	require.NoError(t, err)
+	assert.Len(t, cs, 3)
+
+	v, err = s.Get(cfgpath.MustNewByParts("payment/gateway/secret").BindWebsite(3))
+	require.NoError(t, err)
+	assert.Exactly(t, "0:3:new", v)
+	v, err = s.Get(cfgpath.MustNewByParts("payment/gateway/secret").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "0:3:encrypted", v)
+
+	cs, err = cfgdump.Import(s, d, cfgdump.Options{Sections: testSections})
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+}
+
+func TestImport_Errors(t *testing.T) {
+	s := newStorage(t)
+	d := new(cfgdump.Document)
+	require.NoError(t, d.Set(cfgpath.MustNewByParts("unknown/path/key"), false))
+	_, err := cfgdump.Import(s, d, cfgdump.Options{Sections: testSections})
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	cs, err := cfgdump.Import(s, d, cfgdump.Options{Sections: testSections, SkipUnknown: true})
+	require.NoError(t, err)
+	assert.Empty(t, cs)
+
+	d = new(cfgdump.Document)
+	require.NoError(t, d.Set(cfgpath.MustNewByParts("payment/gateway/sort").BindStore(1), 5))
+	_, err = cfgdump.Import(s, d, cfgdump.Options{Sections: testSections})
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	d = new(cfgdump.Document)
+	require.NoError(t, d.Set(cfgpath.MustNewByParts("payment/gateway/sort"), []int{1}))
+	_, err = cfgdump.Import(s, d, cfgdump.Options{Sections: testSections})
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	_, err = cfgdump.Decode(strings.NewReader(`{`), cfgdump.FormatJSON)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
@@ -0,0 +1,156 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// ChangeType defines whether an import adds or modifies a value.
+type ChangeType uint8
+
+// Supported ChangeTypes.
+const (
+	ChangeAdd ChangeType = iota + 1
+	ChangeModify
+)
+
+// Change describes the new value of a path in an Import.
+type Change struct {
+	Type ChangeType
+	Path cfgpath.Path
+	// Old value, nil for ChangeAdd.
+	Old interface{}
+	New interface{}
+	// Obscure true if the field has the type element.TypeObscure. String
+	// prints MaskValue instead of the values.
+	Obscure bool
+}
+
+// String returns a diff like line, e.g.:
+//		+ stores/2/general/locale/code: "de_CH"
+//		~ default/0/web/secure/base_url: "http://a/" => "https://a/"
+func (c Change) String() string {
+	o, n := c.Old, c.New
+	if c.Obscure {
+		o, n = MaskValue, MaskValue
+	}
+	if c.Type == ChangeAdd {
+		return fmt.Sprintf("+ %s: %#v", c.Path, n)
+	}
+	return fmt.Sprintf("~ %s: %#v => %#v", c.Path, o, n)
+}
+
+// Changes a list of changes ordered by scope, scope ID and route.
+type Changes []Change
+
+// String returns the diff of all changes, one per line.
+func (cs Changes) String() string {
+	var buf bytes.Buffer
+	for _, c := range cs {
+		buf.WriteString(c.String())
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// Import compares the Document with the current state of the Storager and
+// writes the new and modified values, unless Options.DryRun has been set. All
+// paths get validated before the first write. Obscure fields containing
+// MaskValue, or all obscure fields with ObscureExclude, get skipped. Keys
+// missing in the Document do not get deleted. Error behaviour: NotFound for
+// an unknown path, NotSupported for a path in a scope not allowed by the
+// field or a value which is not a scalar.
+func Import(s config.Storager, d *Document, o Options) (Changes, error) {
+	fs, err := o.fields()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Import")
+	}
+	es, err := d.entries()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Import")
+	}
+
+	var cs Changes
+	for _, e := range es {
+		f, found := fs.lookup(e.path.Route.String())
+		if !found {
+			if o.SkipUnknown {
+				continue
+			}
+			return nil, errors.NewNotFoundf("[cfgdump] Import: Path %q not defined in the sections", e.path)
+		}
+		if scp := e.path.ScopeID.Type(); f.Scopes > 0 && !f.Scopes.Has(scp) {
+			return nil, errors.NewNotSupportedf("[cfgdump] Import: Path %q not allowed in scope %s", e.path, scp)
+		}
+		obscure := isObscure(f)
+		if obscure && (o.Obscure == ObscureExclude || e.value == MaskValue) {
+			continue
+		}
+		newVal, err := valueString(e.value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Import Path %q", e.path)
+		}
+
+		c := Change{Type: ChangeAdd, Path: e.path, New: e.value, Obscure: obscure}
+		old, err := s.Get(e.path)
+		switch {
+		case errors.IsNotFound(err):
+		case err != nil:
+			return nil, errors.Wrapf(err, "[cfgdump] Import.Get Path %q", e.path)
+		default:
+			oldVal, err := valueString(old)
+			if err != nil {
+				return nil, errors.Wrapf(err, "[cfgdump] Import Path %q", e.path)
+			}
+			if oldVal == newVal {
+				continue
+			}
+			c.Type = ChangeModify
+			c.Old = old
+		}
+		cs = append(cs, c)
+	}
+
+	if o.DryRun {
+		return cs, nil
+	}
+	for i, c := range cs {
+		if err := s.Set(c.Path, c.New); err != nil {
+			return cs[:i], errors.Wrapf(err, "[cfgdump] Import.Set Path %q", c.Path)
+		}
+	}
+	return cs, nil
+}
+
+func sortEntries(es []entry) {
+	sort.Slice(es, func(i, j int) bool {
+		si, idi := es[i].path.ScopeID.Unpack()
+		sj, idj := es[j].path.ScopeID.Unpack()
+		switch {
+		case si != sj:
+			return si < sj
+		case idi != idj:
+			return idi < idj
+		}
+		return es[i].path.Route.String() < es[j].path.Route.String()
+	})
+}
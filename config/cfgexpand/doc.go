@@ -0,0 +1,34 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgexpand replaces placeholders in configuration values.
+//
+// A placeholder has the form {{name}} or {{name:argument}}, using the
+// delimiters config.LeftDelim and config.RightDelim:
+//
+//		{{config:web/secure/base_url}}  value of another path in the same scope
+//		{{env:CDN_HOST}}                environment variable
+//		{{secure_base_url}}             registered alias or function
+//
+// The aliases base_url, secure_base_url and unsecure_base_url are
+// registered by default, see the placeholders in package cfgmodel. Unknown
+// placeholders stay untouched, because values like email templates use the
+// same delimiters. References to other paths get expanded recursively and a
+// cycle returns a NotValid error.
+//
+// The Expander caches the expanded values per scope. It subscribes to the
+// sections of all cached paths and flushes the cache on every write. The
+// results of functions get cached as part of the expanded value.
+// Environment variables are not watched, call Flush after changing them.
+package cfgexpand
@@ -0,0 +1,277 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgexpand
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// Func returns the replacement of a placeholder. Argument contains the text
+// after the colon, or is empty.
+type Func func(sg config.Scoped, argument string) (string, error)
+
+// Options configures an Expander.
+type Options struct {
+	// LookupEnv returns an environment variable. Default os.LookupEnv.
+	LookupEnv func(key string) (string, bool)
+	// MaxDepth maximum nesting of references to other paths. Default 16.
+	MaxDepth int
+}
+
+type cacheKey struct {
+	websiteID, storeID int64
+	route              string
+}
+
+// Expander replaces placeholders in configuration values. Safe for
+// concurrent use.
+type Expander struct {
+	lookupEnv func(key string) (string, bool)
+	maxDepth  int
+	sub       config.Subscriber
+
+	mu      sync.RWMutex
+	funcs   map[string]Func
+	aliases map[string]string
+	cache   map[cacheKey]string
+	// gen increases with each Flush and prevents caching a value which has
+	// been loaded before the flush.
+	gen uint64
+
+	// subMu must not be locked while holding mu, because the publisher calls
+	// MessageConfig while holding its own lock.
+	subMu sync.Mutex
+	// sections to which the Expander has subscribed.
+	sections map[string]bool
+}
+
+// New creates a new Expander. If the Subscriber is nil, e.g. without pub/sub
+// in the config.Service, the Expander does not cache.
+func New(sub config.Subscriber, o Options) *Expander {
+	if o.LookupEnv == nil {
+		o.LookupEnv = os.LookupEnv
+	}
+	if o.MaxDepth < 1 {
+		o.MaxDepth = 16
+	}
+	e := &Expander{
+		lookupEnv: o.LookupEnv,
+		maxDepth:  o.MaxDepth,
+		sub:       sub,
+		funcs:     make(map[string]Func),
+		aliases:   make(map[string]string),
+		cache:     make(map[cacheKey]string),
+		sections:  make(map[string]bool),
+	}
+	e.RegisterAlias("base_url", "web/unsecure/base_url")
+	e.RegisterAlias("unsecure_base_url", "web/unsecure/base_url")
+	e.RegisterAlias("secure_base_url", "web/secure/base_url")
+	return e
+}
+
+// Register adds or replaces a function. The names config and env are
+// reserved.
+func (e *Expander) Register(name string, fn Func) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.aliases, name)
+	e.funcs[name] = fn
+}
+
+// RegisterAlias adds or replaces a placeholder which gets replaced with the
+// expanded value of the route, like {{config:route}}.
+func (e *Expander) RegisterAlias(name, route string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.funcs, name)
+	e.aliases[name] = route
+}
+
+// MessageConfig implements config.MessageReceiver and flushes the cache.
+func (e *Expander) MessageConfig(_ cfgpath.Path) error {
+	e.Flush()
+	return nil
+}
+
+// Flush clears the cache.
+func (e *Expander) Flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.gen++
+	e.cache = make(map[cacheKey]string)
+}
+
+// String returns the value of the route in the scope with all placeholders
+// replaced. Error behaviour: NotFound, NotValid.
+func (e *Expander) String(sg config.Scoped, r cfgpath.Route) (string, error) {
+	return e.resolve(sg, r.String(), nil)
+}
+
+// Expand replaces all placeholders in a value. Error behaviour: NotFound,
+// NotValid.
+func (e *Expander) Expand(sg config.Scoped, value string) (string, error) {
+	return e.expand(sg, value, nil)
+}
+
+// resolve loads and expands a route. stack contains the routes currently
+// being expanded.
+func (e *Expander) resolve(sg config.Scoped, route string, stack []string) (string, error) {
+	for _, r := range stack {
+		if r == route {
+			return "", errors.NewNotValidf("[cfgexpand] Cycle detected: %s -> %s", strings.Join(stack, " -> "), route)
+		}
+	}
+	if len(stack) >= e.maxDepth {
+		return "", errors.NewNotValidf("[cfgexpand] Maximum depth %d exceeded: %s", e.maxDepth, strings.Join(stack, " -> "))
+	}
+
+	// Subscribe before loading the value, otherwise a write between loading
+	// and subscribing would not flush the cache and leave a stale value.
+	if err := e.subscribe(route); err != nil {
+		return "", err
+	}
+
+	key := cacheKey{websiteID: sg.WebsiteID, storeID: sg.StoreID, route: route}
+	e.mu.RLock()
+	v, ok := e.cache[key]
+	gen := e.gen
+	e.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	raw, err := sg.String(cfgpath.NewRoute(route))
+	if err != nil {
+		return "", errors.Wrapf(err, "[cfgexpand] Route %q", route)
+	}
+	if v, err = e.expand(sg, raw, append(stack, route)); err != nil {
+		return "", err
+	}
+	e.store(key, v, gen)
+	return v, nil
+}
+
+// subscribe subscribes once to the section of the route.
+func (e *Expander) subscribe(route string) error {
+	if e.sub == nil {
+		return nil
+	}
+	section := route
+	if i := strings.IndexByte(section, cfgpath.Separator); i > 0 {
+		section = section[:i]
+	}
+
+	e.subMu.Lock()
+	if !e.sections[section] {
+		if _, err := e.sub.Subscribe(cfgpath.NewRoute(section), e); err != nil {
+			e.subMu.Unlock()
+			return errors.Wrapf(err, "[cfgexpand] Subscribe Section %q", section)
+		}
+		e.sections[section] = true
+	}
+	e.subMu.Unlock()
+	return nil
+}
+
+// store caches the value if no Flush happened in the meantime.
+func (e *Expander) store(key cacheKey, v string, gen uint64) {
+	if e.sub == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.gen == gen {
+		e.cache[key] = v
+	}
+}
+
+// expand replaces all placeholders in value.
+func (e *Expander) expand(sg config.Scoped, value string, stack []string) (string, error) {
+	if !strings.Contains(value, config.LeftDelim) {
+		return value, nil
+	}
+	var buf bytes.Buffer
+	for {
+		start := strings.Index(value, config.LeftDelim)
+		if start < 0 {
+			break
+		}
+		end := strings.Index(value[start+len(config.LeftDelim):], config.RightDelim)
+		if end < 0 {
+			break
+		}
+		end += start + len(config.LeftDelim)
+
+		buf.WriteString(value[:start])
+		name, arg := splitPlaceholder(value[start+len(config.LeftDelim) : end])
+		repl, ok, err := e.replace(sg, name, arg, stack)
+		if err != nil {
+			return "", errors.Wrapf(err, "[cfgexpand] Placeholder %q", value[start:end+len(config.RightDelim)])
+		}
+		if ok {
+			buf.WriteString(repl)
+		} else {
+			buf.WriteString(value[start : end+len(config.RightDelim)])
+		}
+		value = value[end+len(config.RightDelim):]
+	}
+	buf.WriteString(value)
+	return buf.String(), nil
+}
+
+func splitPlaceholder(s string) (name, arg string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+	}
+	return s, ""
+}
+
+// replace returns the replacement of a placeholder. ok is false for unknown
+// names.
+func (e *Expander) replace(sg config.Scoped, name, arg string, stack []string) (_ string, ok bool, _ error) {
+	switch name {
+	case "config":
+		v, err := e.resolve(sg, arg, stack)
+		return v, true, err
+	case "env":
+		v, found := e.lookupEnv(arg)
+		if !found {
+			return "", true, errors.NewNotFoundf("[cfgexpand] Environment variable %q not found", arg)
+		}
+		return v, true, nil
+	}
+
+	e.mu.RLock()
+	fn, found := e.funcs[name]
+	route, isAlias := e.aliases[name]
+	e.mu.RUnlock()
+	if isAlias {
+		v, err := e.resolve(sg, route, stack)
+		return v, true, err
+	}
+	if !found {
+		return "", false, nil
+	}
+	v, err := fn(sg, arg)
+	return v, true, err
+}
@@ -0,0 +1,179 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgexpand_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgexpand"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newService(t *testing.T, kv map[string]string) *config.Service {
+	srv, err := config.NewService(config.NewInMemoryStore(), config.WithPubSub())
+	require.NoError(t, err)
+	for fq, v := range kv {
+		p, err := cfgpath.SplitFQ(fq)
+		require.NoError(t, err)
+		require.NoError(t, srv.Write(p, v))
+	}
+	return srv
+}
+
+func TestExpander_String(t *testing.T) {
+	srv := newService(t, map[string]string{
+		"default/0/web/unsecure/base_url": "http://example.com/",
+		"default/0/web/secure/base_url":   "https://example.com/",
+		"stores/2/web/secure/base_url":    "https://example.ch/",
+		"default/0/web/secure/media_url":  "{{secure_base_url}}media/",
+		"default/0/web/secure/cdn_url":    "https://{{env:CDN_HOST}}/{{ config : web/secure/media_url }}",
+		"default/0/design/email/logo":     "{{base_url}}logo.png?v={{version}}",
+		"default/0/design/email/footer":   "{{var store.name}} {{unknown}} {{",
+	})
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	e := cfgexpand.New(srv, cfgexpand.Options{
+		LookupEnv: func(key string) (string, bool) {
+			if key == "CDN_HOST" {
+				return "cdn.example.com", true
+			}
+			return "", false
+		},
+	})
+	e.Register("version", func(sg config.Scoped, _ string) (string, error) {
+		return "42", nil
+	})
+
+	tests := []struct {
+		sg    config.Scoped
+		route string
+		want  string
+	}{
+		{srv.NewScoped(0, 0), "web/secure/media_url", "https://example.com/media/"},
+		{srv.NewScoped(1, 2), "web/secure/media_url", "https://example.ch/media/"},
+		{srv.NewScoped(1, 0), "web/secure/media_url", "https://example.com/media/"},
+		{srv.NewScoped(0, 0), "web/secure/cdn_url", "https://cdn.example.com/https://example.com/media/"},
+		{srv.NewScoped(0, 0), "design/email/logo", "http://example.com/logo.png?v=42"},
+		{srv.NewScoped(0, 0), "design/email/footer", "{{var store.name}} {{unknown}} {{"},
+	}
+	for i, test := range tests {
+		v, err := e.String(test.sg, cfgpath.NewRoute(test.route))
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.want, v, "Index %d", i)
+	}
+
+	v, err := e.Expand(srv.NewScoped(1, 2), "Visit {{secure_base_url}}")
+	require.NoError(t, err)
+	assert.Exactly(t, "Visit https://example.ch/", v)
+
+	_, err = e.Expand(srv.NewScoped(0, 0), "{{env:MISSING}}")
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
+
+func TestExpander_Cycle(t *testing.T) {
+	srv := newService(t, map[string]string{
+		"default/0/aa/bb/cc": "{{config:aa/bb/dd}}",
+		"default/0/aa/bb/dd": "x{{config:aa/bb/ee}}",
+		"default/0/aa/bb/ee": "{{config:aa/bb/cc}}",
+	})
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	e := cfgexpand.New(srv, cfgexpand.Options{})
+	_, err := e.String(srv.NewScoped(0, 0), cfgpath.NewRoute("aa/bb/cc"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	assert.Contains(t, err.Error(), "aa/bb/cc -> aa/bb/dd -> aa/bb/ee -> aa/bb/cc")
+}
+
+func TestExpander_CacheInvalidation(t *testing.T) {
+	srv := newService(t, map[string]string{
+		"default/0/web/secure/base_url":  "https://example.com/",
+		"default/0/design/head/logo_src": "{{secure_base_url}}logo.png",
+	})
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	e := cfgexpand.New(srv, cfgexpand.Options{})
+	sg := srv.NewScoped(0, 0)
+	r := cfgpath.NewRoute("design/head/logo_src")
+
+	v, err := e.String(sg, r)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://example.com/logo.png", v)
+
+	// a write to the referenced path in another section flushes the cache
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("web/secure/base_url"), "https://shop.example.com/"))
+	want := "https://shop.example.com/logo.png"
+	deadline := time.Now().Add(2 * time.Second)
+	for v != want && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		v, err = e.String(sg, r)
+		require.NoError(t, err)
+	}
+	assert.Exactly(t, want, v)
+}
+
+// orderRecorder records the calls to String and Subscribe.
+type orderRecorder struct {
+	*config.Service
+	calls []string
+}
+
+func (or *orderRecorder) String(p cfgpath.Path) (string, error) {
+	or.calls = append(or.calls, "String "+p.Route.String())
+	return or.Service.String(p)
+}
+
+func (or *orderRecorder) Subscribe(r cfgpath.Route, mr config.MessageReceiver) (int, error) {
+	or.calls = append(or.calls, "Subscribe "+r.String())
+	return or.Service.Subscribe(r, mr)
+}
+
+func TestExpander_SubscribeBeforeLoad(t *testing.T) {
+	srv := newService(t, map[string]string{
+		"default/0/web/secure/base_url": "https://example.com/",
+	})
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	or := &orderRecorder{Service: srv}
+	e := cfgexpand.New(or, cfgexpand.Options{})
+	v, err := e.String(config.NewScoped(or, 0, 0), cfgpath.NewRoute("web/secure/base_url"))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://example.com/", v)
+	require.NotEmpty(t, or.calls)
+	assert.Exactly(t, "Subscribe web", or.calls[0], "%v", or.calls)
+}
+
+func TestExpander_MaxDepth(t *testing.T) {
+	kv := make(map[string]string)
+	for i := 0; i < 5; i++ {
+		kv["default/0/aa/bb/c"+string(rune('0'+i))] = "{{config:aa/bb/c" + string(rune('1'+i)) + "}}"
+	}
+	kv["default/0/aa/bb/c5"] = "end"
+	srv := newService(t, kv)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	v, err := cfgexpand.New(nil, cfgexpand.Options{MaxDepth: 6}).String(srv.NewScoped(0, 0), cfgpath.NewRoute("aa/bb/c0"))
+	require.NoError(t, err)
+	assert.Exactly(t, "end", v)
+
+	_, err = cfgexpand.New(nil, cfgexpand.Options{MaxDepth: 3}).String(srv.NewScoped(0, 0), cfgpath.NewRoute("aa/bb/c0"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	assert.True(t, strings.Contains(err.Error(), "Maximum depth 3"), "%+v", err)
+}
@@ -0,0 +1,234 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// WatchOptions configures the Watch functions of the models.
+type WatchOptions struct {
+	// Debounce waits until no further write to the route happened for this
+	// duration before the value gets loaded and delivered. Zero disables
+	// debouncing.
+	Debounce time.Duration
+	// OnError gets called when the value cannot be loaded, for example
+	// because of an invalid format. The channel does not receive a value in
+	// this case. Optional.
+	OnError func(error)
+}
+
+// errWatchStopped evicts the MessageReceiver after the context of a watch has
+// been canceled.
+var errWatchStopped = errors.NewAlreadyClosedf("[cfgmodel] Watch stopped")
+
+// watchReceiver implements config.MessageReceiver and forwards the messages
+// without blocking the publisher. Bursts get coalesced into one signal.
+type watchReceiver struct {
+	ctx    context.Context
+	signal chan struct{}
+}
+
+func (wr watchReceiver) MessageConfig(_ cfgpath.Path) error {
+	if wr.ctx.Err() != nil {
+		return errWatchStopped
+	}
+	select {
+	case wr.signal <- struct{}{}:
+	default: // a signal is already pending
+	}
+	return nil
+}
+
+// watch subscribes to the route and calls update once at the start and after
+// each (debounced) write to the route, until the context gets canceled. Then
+// it unsubscribes, if the Subscriber supports it, and calls done.
+func watch(ctx context.Context, sub config.Subscriber, r cfgpath.Route, o WatchOptions, update func() error, done func()) error {
+	wr := watchReceiver{ctx: ctx, signal: make(chan struct{}, 1)}
+	subID, err := sub.Subscribe(r, wr)
+	if err != nil {
+		return errors.Wrapf(err, "[cfgmodel] Watch.Subscribe Route %q", r)
+	}
+
+	go func() {
+		defer done()
+		if us, ok := sub.(interface {
+			Unsubscribe(int) error
+		}); ok {
+			defer us.Unsubscribe(subID)
+		}
+
+		callUpdate := func() {
+			if err := update(); err != nil && o.OnError != nil {
+				o.OnError(errors.Wrapf(err, "[cfgmodel] Watch Route %q", r))
+			}
+		}
+
+		callUpdate() // initial value
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wr.signal:
+			}
+			if o.Debounce > 0 && !debounce(ctx, wr.signal, o.Debounce) {
+				return
+			}
+			callUpdate()
+		}
+	}()
+	return nil
+}
+
+// debounce waits until no signal has been received for the duration d.
+// Returns false if the context has been canceled.
+func debounce(ctx context.Context, signal <-chan struct{}, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-signal:
+			if !t.Stop() {
+				<-t.C
+			}
+			t.Reset(d)
+		case <-t.C:
+			return true
+		}
+	}
+}
+
+// Watch sends the current value of the scope and afterwards each changed
+// value to the returned channel. A write to the route triggers a reload, even
+// when it happens in another scope, but only changed values get delivered. The
+// channel gets closed after the context has been canceled.
+//		ch, err := rateLimitPeriod.Watch(ctx, srv, 1, 2, cfgmodel.WatchOptions{Debounce: time.Second})
+//		for d := range ch {
+//			limiter.SetPeriod(d)
+//		}
+func (t Duration) Watch(ctx context.Context, s config.GetterPubSuber, websiteID, storeID int64, o WatchOptions) (<-chan time.Duration, error) {
+	sg := s.NewScoped(websiteID, storeID)
+	ch := make(chan time.Duration)
+	var last time.Duration
+	first := true
+	err := watch(ctx, s, t.route, o, func() error {
+		v, err := t.Get(sg)
+		if err != nil || (!first && v == last) {
+			return err
+		}
+		first, last = false, v
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+		}
+		return nil
+	}, func() { close(ch) })
+	return ch, errors.Wrap(err, "[cfgmodel] Duration.Watch")
+}
+
+// Watch sends the current value of the scope and afterwards each changed
+// value to the returned channel. See Duration.Watch.
+func (b Bool) Watch(ctx context.Context, s config.GetterPubSuber, websiteID, storeID int64, o WatchOptions) (<-chan bool, error) {
+	sg := s.NewScoped(websiteID, storeID)
+	ch := make(chan bool)
+	var last bool
+	first := true
+	err := watch(ctx, s, b.route, o, func() error {
+		v, err := b.Get(sg)
+		if err != nil || (!first && v == last) {
+			return err
+		}
+		first, last = false, v
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+		}
+		return nil
+	}, func() { close(ch) })
+	return ch, errors.Wrap(err, "[cfgmodel] Bool.Watch")
+}
+
+// Watch sends the current value of the scope and afterwards each changed
+// value to the returned channel. See Duration.Watch.
+func (str Str) Watch(ctx context.Context, s config.GetterPubSuber, websiteID, storeID int64, o WatchOptions) (<-chan string, error) {
+	sg := s.NewScoped(websiteID, storeID)
+	ch := make(chan string)
+	var last string
+	first := true
+	err := watch(ctx, s, str.route, o, func() error {
+		v, err := str.Get(sg)
+		if err != nil || (!first && v == last) {
+			return err
+		}
+		first, last = false, v
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+		}
+		return nil
+	}, func() { close(ch) })
+	return ch, errors.Wrap(err, "[cfgmodel] Str.Watch")
+}
+
+// Watch sends the current value of the scope and afterwards each changed
+// value to the returned channel. See Duration.Watch.
+func (i Int) Watch(ctx context.Context, s config.GetterPubSuber, websiteID, storeID int64, o WatchOptions) (<-chan int, error) {
+	sg := s.NewScoped(websiteID, storeID)
+	ch := make(chan int)
+	var last int
+	first := true
+	err := watch(ctx, s, i.route, o, func() error {
+		v, err := i.Get(sg)
+		if err != nil || (!first && v == last) {
+			return err
+		}
+		first, last = false, v
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+		}
+		return nil
+	}, func() { close(ch) })
+	return ch, errors.Wrap(err, "[cfgmodel] Int.Watch")
+}
+
+// Watch sends the current value of the scope and afterwards each changed
+// value to the returned channel. See Duration.Watch.
+func (f Float64) Watch(ctx context.Context, s config.GetterPubSuber, websiteID, storeID int64, o WatchOptions) (<-chan float64, error) {
+	sg := s.NewScoped(websiteID, storeID)
+	ch := make(chan float64)
+	var last float64
+	first := true
+	err := watch(ctx, s, f.route, o, func() error {
+		v, err := f.Get(sg)
+		if err != nil || (!first && v == last) {
+			return err
+		}
+		first, last = false, v
+		select {
+		case ch <- v:
+		case <-ctx.Done():
+		}
+		return nil
+	}, func() { close(ch) })
+	return ch, errors.Wrap(err, "[cfgmodel] Float64.Watch")
+}
@@ -0,0 +1,125 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPubSubService(t *testing.T) *config.Service {
+	srv, err := config.NewService(config.NewInMemoryStore(), config.WithPubSub())
+	require.NoError(t, err)
+	return srv
+}
+
+func receiveDuration(t *testing.T, ch <-chan time.Duration) time.Duration {
+	select {
+	case d, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return d
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+	return 0
+}
+
+func TestDurationWatch(t *testing.T) {
+	srv := newPubSubService(t)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	const route = "web/limit/period"
+	dm := cfgmodel.NewDuration(route)
+	p := cfgpath.MustNewByParts(route)
+	require.NoError(t, srv.Write(p, "1s"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := dm.Watch(ctx, srv, 0, 0, cfgmodel.WatchOptions{})
+	require.NoError(t, err)
+	assert.Exactly(t, time.Second, receiveDuration(t, ch), "initial value")
+
+	require.NoError(t, srv.Write(p, "1s")) // unchanged value, not delivered
+	require.NoError(t, srv.Write(p, "2m"))
+	assert.Exactly(t, 2*time.Minute, receiveDuration(t, ch))
+
+	cancel()
+	for range ch {
+	}
+	// evicted subscriber must not block the publisher
+	require.NoError(t, srv.Write(p, "3m"))
+}
+
+func TestIntWatch_Debounce(t *testing.T) {
+	srv := newPubSubService(t)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	const route = "web/limit/burst"
+	im := cfgmodel.NewInt(route)
+	p := cfgpath.MustNewByParts(route)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := im.Watch(ctx, srv, 0, 0, cfgmodel.WatchOptions{Debounce: 50 * time.Millisecond})
+	require.NoError(t, err)
+	assert.Exactly(t, 0, <-ch, "initial value without a default")
+
+	for i := 1; i <= 10; i++ {
+		require.NoError(t, srv.Write(p, i))
+	}
+	select {
+	case v := <-ch:
+		assert.Exactly(t, 10, v, "only the last value of the burst")
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+	select {
+	case v := <-ch:
+		t.Fatalf("unexpected value %d", v)
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestDurationWatch_OnError(t *testing.T) {
+	srv := newPubSubService(t)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	const route = "web/limit/period"
+	p := cfgpath.MustNewByParts(route)
+	require.NoError(t, srv.Write(p, "invalid"))
+
+	errc := make(chan error, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := cfgmodel.NewDuration(route).Watch(ctx, srv, 0, 0, cfgmodel.WatchOptions{
+		OnError: func(err error) { errc <- err },
+	})
+	require.NoError(t, err)
+	select {
+	case err := <-errc:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	require.NoError(t, srv.Write(p, "5s"))
+	assert.Exactly(t, 5*time.Second, receiveDuration(t, ch))
+}
@@ -0,0 +1,24 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgvalid validates configuration writes against the fields of an
+// element.SectionSlice.
+//
+// A Writer wraps a config.Writer, e.g. the config.Service, and rejects writes
+// to unknown paths, at scopes not allowed by element.Field.Scopes, values
+// whose type does not match the field and values not contained in
+// element.Field.Source. WriteAll validates all values of a submitted admin
+// form before writing the first one and returns an Errors slice containing
+// one FieldError per invalid field.
+package cfgvalid
@@ -0,0 +1,354 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgvalid
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Code classifies a FieldError.
+type Code uint8
+
+// Codes of a FieldError.
+const (
+	CodeUnknownPath Code = iota + 1
+	CodeScope
+	CodeType
+	CodeSource
+)
+
+var codeNames = [...]string{"", "unknown_path", "scope", "type", "source"}
+
+// String returns the name of the code, e.g. "unknown_path".
+func (c Code) String() string {
+	if int(c) < len(codeNames) {
+		return codeNames[c]
+	}
+	return "Code(" + strconv.Itoa(int(c)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c Code) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// FieldError describes why a value cannot be written to a path. The JSON
+// encoding addresses the field of an admin form.
+type FieldError struct {
+	Path cfgpath.Path `json:"-"`
+	// Field the route of the field, e.g. web/secure/base_url.
+	Field string `json:"field"`
+	// Scope e.g. default, websites or stores.
+	Scope   string `json:"scope"`
+	ScopeID int64  `json:"scope_id"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+func newFieldError(p cfgpath.Path, c Code, format string, args ...interface{}) *FieldError {
+	scp, id := p.ScopeID.Unpack()
+	return &FieldError{
+		Path:    p,
+		Field:   p.Route.String(),
+		Scope:   scp.StrType(),
+		ScopeID: id,
+		Code:    c,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Error implements the error interface.
+func (fe *FieldError) Error() string {
+	return "[cfgvalid] " + fe.Path.String() + ": " + fe.Message
+}
+
+// NotValid implements the error behaviour NotValid.
+func (fe *FieldError) NotValid() bool { return true }
+
+// Errors contains all FieldErrors of a validation. Error behaviour: NotValid.
+type Errors []*FieldError
+
+// Error implements the error interface and prints one FieldError per line.
+func (es Errors) Error() string {
+	var buf bytes.Buffer
+	for i, fe := range es {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(fe.Error())
+	}
+	return buf.String()
+}
+
+// NotValid implements the error behaviour NotValid.
+func (es Errors) NotValid() bool { return true }
+
+// Value a path and its value for WriteAll.
+type Value struct {
+	Path  cfgpath.Path
+	Value interface{}
+}
+
+// Writer validates the values before writing them to the underlying
+// config.Writer.
+type Writer struct {
+	w config.Writer
+	// fields maps the route to the field.
+	fields map[string]element.Field
+}
+
+// NewWriter creates a new validating Writer. The sections define the known
+// fields.
+func NewWriter(w config.Writer, ss element.SectionSlice) (*Writer, error) {
+	fs, err := ss.FieldsByRoute()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgvalid] NewWriter")
+	}
+	return &Writer{
+		w:      w,
+		fields: fs,
+	}, nil
+}
+
+// Validate checks a value without writing it. Returns nil or Errors with one
+// FieldError.
+func (vw *Writer) Validate(p cfgpath.Path, v interface{}) error {
+	if fe := vw.validate(p, v); fe != nil {
+		return Errors{fe}
+	}
+	return nil
+}
+
+// ValidateAll checks all values and returns nil or Errors containing one
+// FieldError per invalid value.
+func (vw *Writer) ValidateAll(vs ...Value) error {
+	var es Errors
+	for _, v := range vs {
+		if fe := vw.validate(v.Path, v.Value); fe != nil {
+			es = append(es, fe)
+		}
+	}
+	if len(es) > 0 {
+		return es
+	}
+	return nil
+}
+
+// Write implements config.Writer. Invalid values return the unwrapped Errors
+// of Validate.
+func (vw *Writer) Write(p cfgpath.Path, v interface{}) error {
+	if err := vw.Validate(p, v); err != nil {
+		return err
+	}
+	return errors.Wrap(vw.w.Write(p, v), "[cfgvalid] Write")
+}
+
+// WriteAll validates all values and writes them only if all values are
+// valid. Invalid values return the unwrapped Errors of ValidateAll.
+func (vw *Writer) WriteAll(vs ...Value) error {
+	if err := vw.ValidateAll(vs...); err != nil {
+		return err
+	}
+	for _, v := range vs {
+		if err := vw.w.Write(v.Path, v.Value); err != nil {
+			return errors.Wrapf(err, "[cfgvalid] WriteAll Path %q", v.Path)
+		}
+	}
+	return nil
+}
+
+func (vw *Writer) validate(p cfgpath.Path, v interface{}) *FieldError {
+	f, ok := vw.fields[p.Route.String()]
+	if !ok {
+		return newFieldError(p, CodeUnknownPath, "Unknown path")
+	}
+	if scp := p.ScopeID.Type(); f.Scopes > 0 && !f.Scopes.Has(scp) {
+		return newFieldError(p, CodeScope, "Scope %s not allowed, allowed: %s", scp.StrType(), strings.Join(f.Scopes.Human(), ", "))
+	}
+	if v == nil {
+		return nil // NULL resets the value
+	}
+
+	if f.Type != nil && f.Type.Type() == element.TypeMultiselect {
+		vals, ok := multiValues(v)
+		if !ok {
+			return newFieldError(p, CodeType, "Expected a list of values, got %T", v)
+		}
+		if len(vals) == 0 && len(f.Source) > 0 && !f.CanBeEmpty {
+			return newFieldError(p, CodeSource, "At least one value required")
+		}
+		for _, s := range vals {
+			if len(f.Source) > 0 && !inSource(f.Source, s) {
+				return newFieldError(p, CodeSource, "Value %q not allowed", s)
+			}
+		}
+		return nil
+	}
+
+	s, ok := scalarString(v)
+	if !ok {
+		return newFieldError(p, CodeType, "Expected a scalar value, got %T", v)
+	}
+	if msg := checkType(f, v, s); msg != "" {
+		return newFieldError(p, CodeType, "%s", msg)
+	}
+	if len(f.Source) > 0 && !inSource(f.Source, s) {
+		return newFieldError(p, CodeSource, "Value %q not allowed", s)
+	}
+	return nil
+}
+
+// checkType checks the value against the field type and the type of the
+// default value. Returns an empty string on success.
+func checkType(f element.Field, v interface{}, s string) string {
+	if f.Type != nil {
+		switch f.Type.Type() {
+		case element.TypeTime:
+			if _, err := conv.ToTimeE(v); err != nil {
+				return fmt.Sprintf("Expected a date and time, got %q", s)
+			}
+			return ""
+		case element.TypeDuration:
+			if _, err := conv.ToDurationE(v); err != nil {
+				return fmt.Sprintf("Expected a duration, got %q", s)
+			}
+			return ""
+		}
+	}
+
+	switch f.Default.(type) {
+	case bool:
+		switch val := v.(type) {
+		case bool:
+		case string:
+			if _, err := strconv.ParseBool(val); err != nil {
+				return fmt.Sprintf("Expected a boolean, got %q", s)
+			}
+		default:
+			if s != "0" && s != "1" {
+				return fmt.Sprintf("Expected a boolean, got %q", s)
+			}
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		switch val := v.(type) {
+		case float32:
+			if float64(val) != math.Trunc(float64(val)) {
+				return fmt.Sprintf("Expected an integer, got %q", s)
+			}
+		case float64:
+			if val != math.Trunc(val) {
+				return fmt.Sprintf("Expected an integer, got %q", s)
+			}
+		case bool:
+			return fmt.Sprintf("Expected an integer, got %q", s)
+		default:
+			if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+				return fmt.Sprintf("Expected an integer, got %q", s)
+			}
+		}
+	case float32, float64:
+		if _, ok := v.(bool); ok {
+			return fmt.Sprintf("Expected a number, got %q", s)
+		}
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Sprintf("Expected a number, got %q", s)
+		}
+	}
+	return ""
+}
+
+// scalarString converts a scalar value into its string representation.
+func scalarString(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case string:
+		return val, true
+	case []byte:
+		return string(val), true
+	case bool:
+		return strconv.FormatBool(val), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(val), true
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), true
+	case time.Time:
+		return val.Format(time.RFC3339), true
+	case time.Duration:
+		return val.String(), true
+	}
+	return "", false
+}
+
+// multiValues splits the value of a multiselect field. A string contains
+// comma separated values like in core_config_data.
+func multiValues(v interface{}) ([]string, bool) {
+	switch val := v.(type) {
+	case string:
+		if val == "" {
+			return nil, true
+		}
+		return strings.Split(val, ","), true
+	case []byte:
+		return multiValues(string(val))
+	case []string:
+		return val, true
+	case []int:
+		ret := make([]string, len(val))
+		for i, iv := range val {
+			ret[i] = strconv.Itoa(iv)
+		}
+		return ret, true
+	}
+	return nil, false
+}
+
+// inSource reports whether the string representation of a value is one of the
+// values of the source.
+func inSource(src cfgsource.Slice, s string) bool {
+	for _, p := range src {
+		switch p.NotNull {
+		case cfgsource.NotNullString:
+			if p.String == s {
+				return true
+			}
+		case cfgsource.NotNullInt:
+			if strconv.Itoa(p.Int) == s {
+				return true
+			}
+		case cfgsource.NotNullFloat64:
+			if f, err := strconv.ParseFloat(s, 64); err == nil && f == p.Float64 {
+				return true
+			}
+		case cfgsource.NotNullBool:
+			if b, err := strconv.ParseBool(s); err == nil && b == p.Bool {
+				return true
+			}
+		}
+	}
+	return false
+}
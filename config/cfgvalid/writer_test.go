@@ -0,0 +1,146 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgvalid_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/cfgvalid"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Writer = (*cfgvalid.Writer)(nil)
+
+type storageWriter struct {
+	config.Storager
+}
+
+func (sw storageWriter) Write(p cfgpath.Path, v interface{}) error {
+	return sw.Set(p, v)
+}
+
+func newWriter(t *testing.T) (*cfgvalid.Writer, config.Storager) {
+	st := config.NewInMemoryStore()
+	w, err := cfgvalid.NewWriter(storageWriter{st}, element.MustNewConfiguration(
+		element.Section{
+			ID: cfgpath.NewRoute("web"),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute("cookie"),
+					Fields: element.NewFieldSlice(
+						element.Field{ID: cfgpath.NewRoute("cookie_lifetime"), Type: element.TypeText, Scopes: scope.PermWebsite, Default: 3600},
+						element.Field{ID: cfgpath.NewRoute("cookie_httponly"), Type: element.TypeSelect, Scopes: scope.PermStore, Default: true},
+						element.Field{ID: cfgpath.NewRoute("cookie_ratio"), Type: element.TypeText, Default: 0.5},
+						element.Field{ID: cfgpath.NewRoute("cookie_ttl"), Type: element.TypeDuration},
+						element.Field{ID: cfgpath.NewRoute("cookie_restriction"), Type: element.TypeSelect, Scopes: scope.PermStore,
+							Source: cfgsource.MustNewByString("none", "None", "strict", "Strict")},
+						element.Field{ID: cfgpath.NewRoute("cookie_domains"), Type: element.TypeMultiselect,
+							Source: cfgsource.NewByInt(cfgsource.Ints{{Value: 1}, {Value: 2}, {Value: 3}})},
+					),
+				},
+			),
+		},
+	))
+	require.NoError(t, err)
+	return w, st
+}
+
+func TestWriter_Validate(t *testing.T) {
+	w, _ := newWriter(t)
+	p := func(route string) cfgpath.Path { return cfgpath.MustNewByParts(route) }
+
+	tests := []struct {
+		path cfgpath.Path
+		val  interface{}
+		code cfgvalid.Code
+	}{
+		{p("web/cookie/cookie_lifetime"), 7200, 0},
+		{p("web/cookie/cookie_lifetime").BindWebsite(1), "7200", 0},
+		{p("web/cookie/cookie_lifetime").BindStore(1), 7200, cfgvalid.CodeScope},
+		{p("web/cookie/cookie_lifetime"), "1h", cfgvalid.CodeType},
+		{p("web/cookie/cookie_lifetime"), 1.5, cfgvalid.CodeType},
+		{p("web/cookie/cookie_lifetime"), nil, 0},
+		{p("web/cookie/cookie_lifetime"), []string{"1"}, cfgvalid.CodeType},
+		{p("web/cookie/cookie_httponly").BindStore(2), "1", 0},
+		{p("web/cookie/cookie_httponly"), false, 0},
+		{p("web/cookie/cookie_httponly"), 2, cfgvalid.CodeType},
+		{p("web/cookie/cookie_ratio"), "0.75", 0},
+		{p("web/cookie/cookie_ratio"), true, cfgvalid.CodeType},
+		{p("web/cookie/cookie_ttl"), "15m", 0},
+		{p("web/cookie/cookie_ttl"), time.Minute, 0},
+		{p("web/cookie/cookie_ttl"), "soon", cfgvalid.CodeType},
+		{p("web/cookie/cookie_restriction").BindStore(1), "strict", 0},
+		{p("web/cookie/cookie_restriction"), "lax", cfgvalid.CodeSource},
+		{p("web/cookie/cookie_domains"), "1,3", 0},
+		{p("web/cookie/cookie_domains"), []int{2}, 0},
+		{p("web/cookie/cookie_domains"), "1,4", cfgvalid.CodeSource},
+		{p("web/cookie/cookie_domains"), "", cfgvalid.CodeSource},
+		{p("web/cookie/cookie_domains"), 1, cfgvalid.CodeType},
+		{p("web/cookie/unknown"), 1, cfgvalid.CodeUnknownPath},
+	}
+	for i, test := range tests {
+		err := w.Validate(test.path, test.val)
+		if test.code == 0 {
+			assert.NoError(t, err, "Index %d", i)
+			continue
+		}
+		assert.True(t, errors.IsNotValid(err), "Index %d => %+v", i, err)
+		es, ok := err.(cfgvalid.Errors)
+		require.True(t, ok, "Index %d", i)
+		require.Len(t, es, 1, "Index %d", i)
+		assert.Exactly(t, test.code, es[0].Code, "Index %d => %s", i, es[0].Message)
+	}
+}
+
+func TestWriter_WriteAll(t *testing.T) {
+	w, st := newWriter(t)
+
+	err := w.WriteAll(
+		cfgvalid.Value{Path: cfgpath.MustNewByParts("web/cookie/cookie_lifetime"), Value: 60},
+		cfgvalid.Value{Path: cfgpath.MustNewByParts("web/cookie/cookie_lifetime").BindStore(3), Value: 60},
+		cfgvalid.Value{Path: cfgpath.MustNewByParts("web/cookie/cookie_restriction").BindStore(3), Value: "lax"},
+	)
+	es, ok := err.(cfgvalid.Errors)
+	require.True(t, ok, "%+v", err)
+	require.Len(t, es, 2)
+
+	data, err := json.Marshal(es)
+	require.NoError(t, err)
+	assert.Exactly(t, `[{"field":"web/cookie/cookie_lifetime","scope":"stores","scope_id":3,"code":"scope","message":"Scope stores not allowed, allowed: Default, Website"},`+
+		`{"field":"web/cookie/cookie_restriction","scope":"stores","scope_id":3,"code":"source","message":"Value \"lax\" not allowed"}]`, string(data))
+
+	_, err = st.Get(cfgpath.MustNewByParts("web/cookie/cookie_lifetime"))
+	assert.True(t, errors.IsNotFound(err), "nothing written: %+v", err)
+
+	require.NoError(t, w.WriteAll(
+		cfgvalid.Value{Path: cfgpath.MustNewByParts("web/cookie/cookie_lifetime"), Value: 60},
+		cfgvalid.Value{Path: cfgpath.MustNewByParts("web/cookie/cookie_restriction").BindStore(3), Value: "none"},
+	))
+	v, err := st.Get(cfgpath.MustNewByParts("web/cookie/cookie_restriction").BindStore(3))
+	require.NoError(t, err)
+	assert.Exactly(t, "none", v)
+
+	require.NoError(t, w.Write(cfgpath.MustNewByParts("web/cookie/cookie_ratio"), 0.1))
+	assert.True(t, errors.IsNotValid(w.Write(cfgpath.MustNewByParts("web/cookie/cookie_ratio"), "x")))
+}
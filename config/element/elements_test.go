@@ -160,6 +160,36 @@ func TestSectionSliceDefaults(t *testing.T) {
 	)
 }
 
+func TestSectionSliceFieldsByRoute(t *testing.T) {
+
+	pkgCfg := element.MustNewConfiguration(
+		element.Section{
+			ID: cfgpath.NewRoute(`contact`),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute(`email`),
+					Fields: element.NewFieldSlice(
+						element.Field{
+							ID:      cfgpath.NewRoute(`recipient_email`),
+							Default: `hello@example.com`,
+						},
+						element.Field{
+							ConfigPath: cfgpath.NewRoute(`general/store_information/email`),
+							ID:         cfgpath.NewRoute(`sender_email`),
+						},
+					),
+				},
+			),
+		},
+	)
+
+	fm, err := pkgCfg.FieldsByRoute()
+	assert.NoError(t, err)
+	assert.Len(t, fm, 2)
+	assert.Exactly(t, `hello@example.com`, fm["contact/email/recipient_email"].Default)
+	assert.Exactly(t, `sender_email`, fm["general/store_information/email"].ID.String())
+}
+
 func TestSectionSliceMerge(t *testing.T) {
 
 	// Got stuck in comparing JSON?
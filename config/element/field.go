@@ -18,6 +18,7 @@ import (
 	"sort"
 
 	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/storage/text"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/errors"
@@ -59,6 +60,9 @@ type Field struct {
 	CanBeEmpty bool `json:",omitempty"`
 	// Default can contain any default config value: float64, int64, string, bool
 	Default interface{} `json:",omitempty"`
+	// Source contains the allowed values of a select or multiselect field,
+	// known as SourceModel in Magento. Can be nil.
+	Source cfgsource.Slice `json:",omitempty"`
 }
 
 // NewFieldSlice wrapper to create a new FieldSlice
@@ -158,6 +162,9 @@ func (f Field) Update(new Field) Field {
 	if new.Default != nil {
 		f.Default = new.Default
 	}
+	if len(new.Source) > 0 {
+		f.Source = append(cfgsource.Slice(nil), new.Source...)
+	}
 	return f
 }
 
@@ -111,6 +111,24 @@ func (ss SectionSlice) Defaults() (DefaultMap, error) {
 	return dm, nil
 }
 
+// FieldsByRoute iterates over all slices, creates a path and returns a map
+// where the key is the route of the path and the value the field.
+func (ss SectionSlice) FieldsByRoute() (map[string]Field, error) {
+	fm := make(map[string]Field, ss.TotalFields())
+	for _, s := range ss {
+		for _, g := range s.Groups {
+			for _, f := range g.Fields {
+				r, err := f.Route(s.ID, g.ID)
+				if err != nil {
+					return nil, errors.Wrapf(err, "[element] SectionSlice.FieldsByRoute.Field.Route. Section %q Group %q", s.ID, g.ID)
+				}
+				fm[r.String()] = f
+			}
+		}
+	}
+	return fm, nil
+}
+
 // TotalFields calculates the total amount of all fields
 func (ss SectionSlice) TotalFields() int {
 	fs := 0
@@ -181,6 +181,17 @@ func (s *Service) Write(p cfgpath.Path, v interface{}) error {
 	return nil
 }
 
+// MessageConfig implements interface MessageReceiver. It publishes a path to
+// all subscribers of the Service without writing a value. Use it when the
+// value has been changed in the backend by someone else, for example by
+// another node sharing the same distributed Storager. Never returns an error.
+func (s *Service) MessageConfig(p cfgpath.Path) error {
+	if s.pubSub != nil {
+		s.sendMsg(p)
+	}
+	return nil
+}
+
 // get generic getter ... not sure if this should be public ...
 func (s *Service) get(p cfgpath.Path) (interface{}, error) {
 	if s.Log.IsDebug() {
@@ -155,7 +155,10 @@ func (s *pubSub) publish() {
 				return
 			}
 
-			if len(s.subMap) == 0 {
+			s.mu.RLock()
+			noSubs := len(s.subMap) == 0
+			s.mu.RUnlock()
+			if noSubs {
 				break
 			}
 
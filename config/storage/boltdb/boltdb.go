@@ -0,0 +1,205 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"encoding/binary"
+	"os"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// scopeBuckets contains the names of the top level buckets.
+var scopeBuckets = [...]scope.Type{scope.Default, scope.Website, scope.Store}
+
+// Storage implements config.Storager with a bolt database.
+type Storage struct {
+	DB *bolt.DB
+}
+
+// New opens or creates a bolt database at the given path. If options is nil,
+// bolt.DefaultOptions gets used.
+func New(path string, mode os.FileMode, options *bolt.Options) (*Storage, error) {
+	if options == nil {
+		options = bolt.DefaultOptions
+	}
+	db, err := bolt.Open(path, mode, options)
+	if err != nil {
+		return nil, errors.NewFatalf("[boltdb] bolt.Open: %s", err)
+	}
+	return NewWithDB(db), nil
+}
+
+// NewWithDB uses an existing bolt database.
+func NewWithDB(db *bolt.DB) *Storage {
+	return &Storage{DB: db}
+}
+
+// Close closes the bolt database.
+func (s *Storage) Close() error {
+	return errors.Wrap(s.DB.Close(), "[boltdb] DB.Close")
+}
+
+// splitKey returns the name of the scope bucket, the name of the scope ID
+// bucket and the route of a path.
+func splitKey(key cfgpath.Path) (scopeName, idName, route []byte, err error) {
+	r, err := key.Level(-1)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "[boltdb] Path.Level %q", key.Route)
+	}
+	scp, id := key.ScopeID.Unpack()
+	if id < 0 {
+		return nil, nil, nil, errors.NewNotValidf("[boltdb] Invalid scope ID %s", key.ScopeID)
+	}
+	idName = make([]byte, 8)
+	binary.BigEndian.PutUint64(idName, uint64(id))
+	return scp.StrBytes(), idName, r.Bytes(), nil
+}
+
+// Set writes a key with its value. The supported value types are nil,
+// string, []byte, bool, all integer and float types and time.Time. Smaller
+// integer types get returned as int64 or uint64 and float32 as float64.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	scopeName, idName, route, err := splitKey(key)
+	if err != nil {
+		return errors.Wrap(err, "[boltdb] Set")
+	}
+	v, err := encodeValue(value)
+	if err != nil {
+		return errors.Wrapf(err, "[boltdb] Set Key %q", key)
+	}
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		return put(tx, scopeName, idName, route, v)
+	})
+	return errors.Wrapf(err, "[boltdb] Set.Update Key %q", key)
+}
+
+func put(tx *bolt.Tx, scopeName, idName, route, v []byte) error {
+	sb, err := tx.CreateBucketIfNotExists(scopeName)
+	if err != nil {
+		return errors.NewFatalf("[boltdb] CreateBucketIfNotExists %q: %s", scopeName, err)
+	}
+	ib, err := sb.CreateBucketIfNotExists(idName)
+	if err != nil {
+		return errors.NewFatalf("[boltdb] CreateBucketIfNotExists %q/%x: %s", scopeName, idName, err)
+	}
+	if err := ib.Put(route, v); err != nil {
+		return errors.NewFatalf("[boltdb] Put %q: %s", route, err)
+	}
+	return nil
+}
+
+// Get returns the value of a key with the same type as passed to Set.
+// Error behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (value interface{}, err error) {
+	scopeName, idName, route, err := splitKey(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "[boltdb] Get")
+	}
+	var found bool
+	err = s.DB.View(func(tx *bolt.Tx) error {
+		sb := tx.Bucket(scopeName)
+		if sb == nil {
+			return nil
+		}
+		ib := sb.Bucket(idName)
+		if ib == nil {
+			return nil
+		}
+		v := ib.Get(route)
+		if v == nil {
+			return nil
+		}
+		found = true
+		// the slice returned by bolt is only valid during the transaction
+		value, err = decodeValue(append([]byte(nil), v...))
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "[boltdb] Get.View Key %q", key)
+	}
+	if !found {
+		return nil, errors.NewNotFoundf("[boltdb] Key %q not found", key)
+	}
+	return value, nil
+}
+
+// Delete removes a key. Deleting a non-existent key is not an error.
+func (s *Storage) Delete(key cfgpath.Path) error {
+	scopeName, idName, route, err := splitKey(key)
+	if err != nil {
+		return errors.Wrap(err, "[boltdb] Delete")
+	}
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		sb := tx.Bucket(scopeName)
+		if sb == nil {
+			return nil
+		}
+		ib := sb.Bucket(idName)
+		if ib == nil {
+			return nil
+		}
+		return ib.Delete(route)
+	})
+	return errors.Wrapf(err, "[boltdb] Delete.Update Key %q", key)
+}
+
+// AllKeys returns the fully qualified keys sorted by scope, scope ID and
+// route.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	var ps cfgpath.PathSlice
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		return iterate(tx, func(scopeID scope.TypeID, route, _ []byte) error {
+			ps = append(ps, newPath(scopeID, route))
+			return nil
+		})
+	})
+	return ps, errors.Wrap(err, "[boltdb] AllKeys.View")
+}
+
+// iterate calls fn for each stored key. The byte slices are only valid
+// during the transaction.
+func iterate(tx *bolt.Tx, fn func(scopeID scope.TypeID, route, value []byte) error) error {
+	for _, scp := range scopeBuckets {
+		sb := tx.Bucket(scp.StrBytes())
+		if sb == nil {
+			continue
+		}
+		err := sb.ForEach(func(idName, v []byte) error {
+			ib := sb.Bucket(idName)
+			if v != nil || ib == nil || len(idName) != 8 {
+				return errors.NewNotValidf("[boltdb] Unexpected key %x in bucket %q", idName, scp.StrBytes())
+			}
+			scopeID := scope.MakeTypeID(scp, int64(binary.BigEndian.Uint64(idName)))
+			return ib.ForEach(func(route, value []byte) error {
+				return fn(scopeID, route, value)
+			})
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPath(scopeID scope.TypeID, route []byte) cfgpath.Path {
+	return cfgpath.Path{
+		Route:   cfgpath.NewRoute(string(route)),
+		ScopeID: scopeID,
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/boltdb"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*boltdb.Storage)(nil)
+
+func newStorage(t *testing.T) (*boltdb.Storage, func()) {
+	f, err := ioutil.TempFile("", "cfgboltdb_")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	s, err := boltdb.New(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	return s, func() {
+		assert.NoError(t, s.Close())
+		assert.NoError(t, os.Remove(f.Name()))
+	}
+}
+
+func TestStorage_SetGet(t *testing.T) {
+	s, closeFn := newStorage(t)
+	defer closeFn()
+
+	now := time.Date(2017, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := []struct {
+		key cfgpath.Path
+		val interface{}
+	}{
+		{cfgpath.MustNewByParts("aa/bb/cc"), "a string"},
+		{cfgpath.MustNewByParts("aa/bb/cc").BindWebsite(1), []byte("bytes")},
+		{cfgpath.MustNewByParts("aa/bb/cc").BindStore(2), 12345},
+		{cfgpath.MustNewByParts("aa/bb/dd").BindStore(2), int64(-5)},
+		{cfgpath.MustNewByParts("aa/bb/ee").BindStore(2), uint64(5)},
+		{cfgpath.MustNewByParts("aa/bb/ff").BindStore(2), 3.14159},
+		{cfgpath.MustNewByParts("aa/bb/gg").BindStore(2), true},
+		{cfgpath.MustNewByParts("aa/bb/hh").BindStore(2), now},
+		{cfgpath.MustNewByParts("aa/bb/ii").BindStore(2), nil},
+	}
+	for i, test := range tests {
+		require.NoError(t, s.Set(test.key, test.val), "Index %d", i)
+	}
+	for i, test := range tests {
+		v, err := s.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.val, v, "Index %d", i)
+	}
+
+	_, err := s.Get(cfgpath.MustNewByParts("aa/bb/cc").BindStore(3))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	assert.True(t, errors.IsNotSupported(s.Set(cfgpath.MustNewByParts("aa/bb/cc"), struct{}{})))
+
+	require.NoError(t, s.Delete(cfgpath.MustNewByParts("aa/bb/cc")))
+	_, err = s.Get(cfgpath.MustNewByParts("aa/bb/cc"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"websites/1/aa/bb/cc",
+		"stores/2/aa/bb/cc", "stores/2/aa/bb/dd", "stores/2/aa/bb/ee", "stores/2/aa/bb/ff",
+		"stores/2/aa/bb/gg", "stores/2/aa/bb/hh", "stores/2/aa/bb/ii",
+	}, pathStrings(keys))
+}
+
+func pathStrings(ps cfgpath.PathSlice) []string {
+	ret := make([]string, len(ps))
+	for i, p := range ps {
+		ret[i] = p.String()
+	}
+	return ret
+}
+
+func TestStorage_CoreConfigData(t *testing.T) {
+	s, closeFn := newStorage(t)
+	defer closeFn()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `scope`, `scope_id`, `path`, `value` FROM `core_config_data`")).
+		WillReturnRows(sqlmock.NewRows([]string{"scope", "scope_id", "path", "value"}).
+			AddRow("default", 0, "web/unsecure/base_url", "http://example.com/").
+			AddRow("stores", 1, "general/locale/code", "de_CH").
+			AddRow("websites", 2, "web/cookie/cookie_path", nil))
+
+	ctx := context.TODO()
+	n, err := s.ImportCoreConfigData(ctx, dbc, boltdb.CCDOptions{})
+	require.NoError(t, err)
+	assert.Exactly(t, 3, n)
+
+	v, err := s.Get(cfgpath.MustNewByParts("general/locale/code").BindStore(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "de_CH", v)
+
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("web/cookie/cookie_lifetime").BindWebsite(2), 3600))
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?),(?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)")).
+		WithArgs("default", 0, "web/unsecure/base_url", "http://example.com/",
+			"websites", 2, "web/cookie/cookie_lifetime", "3600",
+			"websites", 2, "web/cookie/cookie_path", nil).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)")).
+		WithArgs("stores", 1, "general/locale/code", "de_CH").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err = s.ExportCoreConfigData(ctx, dbc, boltdb.CCDOptions{BatchSize: 3})
+	require.NoError(t, err)
+	assert.Exactly(t, 4, n)
+}
@@ -0,0 +1,189 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// CCDOptions configures the import from and the export to the table
+// core_config_data.
+type CCDOptions struct {
+	// TableName default core_config_data.
+	TableName string
+	// BatchSize number of rows per INSERT statement during export. Default
+	// 500.
+	BatchSize int
+}
+
+func (o CCDOptions) withDefaults() CCDOptions {
+	if o.TableName == "" {
+		o.TableName = "core_config_data"
+	}
+	if o.BatchSize < 1 {
+		o.BatchSize = 500
+	}
+	return o
+}
+
+// ImportCoreConfigData loads all rows of the table core_config_data into the
+// bolt database within one bolt transaction. Existing keys get overwritten,
+// keys not in the table are kept. The values get stored as string, NULL
+// values as nil. Returns the number of imported rows.
+func (s *Storage) ImportCoreConfigData(ctx context.Context, db *dml.ConnPool, o CCDOptions) (rowCount int, err error) {
+	o = o.withDefaults()
+
+	type row struct {
+		scopeName, idName, route, value []byte
+	}
+	var rows []row
+	err = db.SelectFrom(o.TableName).AddColumns("scope", "scope_id", "path", "value").WithArgs().
+		IterateSerial(ctx, func(cm *dml.ColumnMap) error {
+			var scp, path string
+			var scopeID int64
+			var value dml.NullString
+			for cm.Next() {
+				switch c := cm.Column(); c {
+				case "scope":
+					cm.String(&scp)
+				case "scope_id":
+					cm.Int64(&scopeID)
+				case "path":
+					cm.String(&path)
+				case "value":
+					cm.NullString(&value)
+				default:
+					return errors.NewNotFoundf("[boltdb] ImportCoreConfigData: Column %q not found", c)
+				}
+			}
+			if err := cm.Err(); err != nil {
+				return errors.WithStack(err)
+			}
+			if !scope.Valid(scp) {
+				return errors.NewNotSupportedf("[boltdb] ImportCoreConfigData: Unknown scope %q for path %q", scp, path)
+			}
+			p, err := cfgpath.NewByParts(path)
+			if err != nil {
+				return errors.Wrapf(err, "[boltdb] ImportCoreConfigData: Path %q", path)
+			}
+			scopeName, idName, route, err := splitKey(p.Bind(scope.MakeTypeID(scope.FromString(scp), scopeID)))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			var v interface{}
+			if value.Valid {
+				v = value.String
+			}
+			ev, err := encodeValue(v)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			rows = append(rows, row{scopeName: scopeName, idName: idName, route: append([]byte(nil), route...), value: ev})
+			return nil
+		})
+	if err != nil {
+		return 0, errors.Wrapf(err, "[boltdb] ImportCoreConfigData from table %q", o.TableName)
+	}
+
+	err = s.DB.Update(func(tx *bolt.Tx) error {
+		for _, r := range rows {
+			if err := put(tx, r.scopeName, r.idName, r.route, r.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "[boltdb] ImportCoreConfigData.Update")
+	}
+	return len(rows), nil
+}
+
+// ExportCoreConfigData writes all keys of the bolt database into the table
+// core_config_data. Existing rows get updated. The values get converted to
+// strings, bool values to 1 or 0 and time values to the MySQL DATETIME
+// format. Returns the number of exported keys.
+func (s *Storage) ExportCoreConfigData(ctx context.Context, db *dml.ConnPool, o CCDOptions) (rowCount int, err error) {
+	o = o.withDefaults()
+
+	var values []interface{}
+	err = s.DB.View(func(tx *bolt.Tx) error {
+		return iterate(tx, func(scopeID scope.TypeID, route, value []byte) error {
+			v, err := decodeValue(value)
+			if err != nil {
+				return errors.Wrapf(err, "[boltdb] Route %q", route)
+			}
+			var arg interface{}
+			if v != nil {
+				arg = valueToString(v)
+			}
+			scp, id := scopeID.Unpack()
+			values = append(values, scp.StrType(), id, string(route), arg)
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "[boltdb] ExportCoreConfigData.View")
+	}
+
+	const columns = 4
+	a := db.InsertInto(o.TableName).AddColumns("scope", "scope_id", "path", "value").
+		AddOnDuplicateKeyExclude("scope", "scope_id", "path").WithArgs()
+	for len(values) > 0 {
+		n := o.BatchSize * columns
+		if n > len(values) {
+			n = len(values)
+		}
+		if _, err := a.ResetInsert().ExecContext(ctx, values[:n]...); err != nil {
+			return rowCount, errors.Wrapf(err, "[boltdb] ExportCoreConfigData into table %q", o.TableName)
+		}
+		rowCount += n / columns
+		values = values[n:]
+	}
+	return rowCount, nil
+}
+
+func valueToString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case bool:
+		if val {
+			return "1"
+		}
+		return "0"
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case uint64:
+		return strconv.FormatUint(val, 10)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	}
+	return "" // not reachable, decodeValue returns only the above types
+}
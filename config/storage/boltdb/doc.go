@@ -15,6 +15,14 @@
 // Package boltdb uses the bolt database for reading and writing
 // configuration paths.
 //
-// Maybe implements synchronization with MySQL core_config_data table.
-// Converts all values to byte slices.
+// Storage implements config.Storager. Each scope has its own top level bucket
+// named like the scope column of core_config_data (default, websites,
+// stores) with one nested bucket per scope ID. The keys within a scope ID
+// bucket are the routes, e.g. catalog/frontend/list_allow_all. The values get
+// stored together with their type, so Get returns the same type which has
+// been passed to Set.
+//
+// ImportCoreConfigData and ExportCoreConfigData synchronize the bolt file
+// with the MySQL table core_config_data. An edge node can import the
+// configuration once and run afterwards with its local persistent copy.
 package boltdb
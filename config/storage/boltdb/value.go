@@ -0,0 +1,138 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/corestoreio/errors"
+)
+
+// The first byte of a stored value defines its type. Never change the order,
+// existing databases would become unreadable.
+const (
+	typeNil byte = iota
+	typeString
+	typeBytes
+	typeBool
+	typeInt
+	typeInt64
+	typeUint64
+	typeFloat64
+	typeTime
+)
+
+// encodeValue converts v into a byte slice prefixed with its type. Smaller
+// integer and float types get stored as int64, uint64 or float64.
+func encodeValue(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return []byte{typeNil}, nil
+	case string:
+		return append([]byte{typeString}, val...), nil
+	case []byte:
+		return append([]byte{typeBytes}, val...), nil
+	case bool:
+		if val {
+			return []byte{typeBool, 1}, nil
+		}
+		return []byte{typeBool, 0}, nil
+	case int:
+		return encodeUint64(typeInt, uint64(val)), nil
+	case int8:
+		return encodeUint64(typeInt64, uint64(val)), nil
+	case int16:
+		return encodeUint64(typeInt64, uint64(val)), nil
+	case int32:
+		return encodeUint64(typeInt64, uint64(val)), nil
+	case int64:
+		return encodeUint64(typeInt64, uint64(val)), nil
+	case uint:
+		return encodeUint64(typeUint64, uint64(val)), nil
+	case uint8:
+		return encodeUint64(typeUint64, uint64(val)), nil
+	case uint16:
+		return encodeUint64(typeUint64, uint64(val)), nil
+	case uint32:
+		return encodeUint64(typeUint64, uint64(val)), nil
+	case uint64:
+		return encodeUint64(typeUint64, val), nil
+	case float32:
+		return encodeUint64(typeFloat64, math.Float64bits(float64(val))), nil
+	case float64:
+		return encodeUint64(typeFloat64, math.Float64bits(val)), nil
+	case time.Time:
+		b, err := val.MarshalBinary()
+		if err != nil {
+			return nil, errors.Wrap(err, "[boltdb] time.MarshalBinary")
+		}
+		return append([]byte{typeTime}, b...), nil
+	}
+	return nil, errors.NewNotSupportedf("[boltdb] Value type %T not supported", v)
+}
+
+func encodeUint64(typ byte, v uint64) []byte {
+	var b [9]byte
+	b[0] = typ
+	binary.BigEndian.PutUint64(b[1:], v)
+	return b[:]
+}
+
+// decodeValue reverses encodeValue. The byte slice b must not be owned by
+// bolt because the returned byte slices and strings reference it.
+func decodeValue(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, errors.NewNotValidf("[boltdb] Empty value")
+	}
+	typ, data := b[0], b[1:]
+	switch typ {
+	case typeNil:
+		return nil, nil
+	case typeString:
+		return string(data), nil
+	case typeBytes:
+		return data, nil
+	case typeBool:
+		if len(data) != 1 {
+			break
+		}
+		return data[0] == 1, nil
+	case typeInt, typeInt64, typeUint64, typeFloat64:
+		if len(data) != 8 {
+			break
+		}
+		u := binary.BigEndian.Uint64(data)
+		switch typ {
+		case typeInt:
+			return int(int64(u)), nil
+		case typeInt64:
+			return int64(u), nil
+		case typeFloat64:
+			return math.Float64frombits(u), nil
+		}
+		return u, nil
+	case typeTime:
+		var t time.Time
+		if err := t.UnmarshalBinary(data); err != nil {
+			return nil, errors.NewNotValid(err, "[boltdb] time.UnmarshalBinary")
+		}
+		return t, nil
+	default:
+		return nil, errors.NewNotSupportedf("[boltdb] Unknown value type %d", typ)
+	}
+	return nil, errors.NewNotValidf("[boltdb] Invalid length %d for value type %d", len(data), typ)
+}
@@ -12,9 +12,17 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// Package etcd uses etcd service for reading and writing configuration paths.
+// Package etcd uses an etcd style key value store for reading and writing
+// configuration paths.
 //
-// todo(CS): https://github.com/coreos/etcd/tree/master/client
+// Storage implements config.Storager and stores each fully qualified path as
+// one key below a prefix, e.g. /corestore/config/stores/1/general/locale/code.
+// Storage.Watch translates the watch events of the key value store into
+// messages for config.MessageReceiver, e.g. a config.Service, so that a change
+// written on one node reaches the subscribers on all nodes.
 //
-// Maybe implements synchronization with MySQL core_config_data table.
+// The package does not depend on the etcd client. Wrap the clientv3.KV and
+// clientv3.Watcher of https://github.com/coreos/etcd/tree/master/clientv3 to
+// satisfy interface KV. MemKV is an in-process implementation for tests and
+// single node setups.
 package etcd
@@ -0,0 +1,193 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Options configures a Storage.
+type Options struct {
+	// Prefix of all keys. Default /corestore/config/
+	Prefix string
+	// Timeout of each request to the key value store. Default 5s.
+	Timeout time.Duration
+	// Log default log.BlackHole.
+	Log log.Logger
+}
+
+// Storage implements config.Storager with an etcd style key value store. The
+// values get stored as strings, hence Get returns always a string.
+type Storage struct {
+	kv      KV
+	prefix  string
+	timeout time.Duration
+	log     log.Logger
+
+	// mu serializes the writes of this Storage with the processing of the
+	// watch events to detect reliably the own changes.
+	mu sync.Mutex
+	// own contains the revisions written by this Storage and not yet seen by
+	// Watch.
+	own map[int64]struct{}
+	// watching true if Watch has been called, otherwise own stays empty.
+	watching bool
+}
+
+// New creates a new Storage.
+func New(kv KV, o Options) *Storage {
+	if o.Prefix == "" {
+		o.Prefix = "/corestore/config/"
+	}
+	if o.Timeout == 0 {
+		o.Timeout = 5 * time.Second
+	}
+	if o.Log == nil {
+		o.Log = log.BlackHole{}
+	}
+	return &Storage{
+		kv:      kv,
+		prefix:  o.Prefix,
+		timeout: o.Timeout,
+		log:     o.Log,
+		own:     make(map[int64]struct{}),
+	}
+}
+
+func (s *Storage) key(p cfgpath.Path) (string, error) {
+	fq, err := p.FQ()
+	if err != nil {
+		return "", errors.Wrap(err, "[etcd] Path.FQ")
+	}
+	return s.prefix + fq.String(), nil
+}
+
+// Set implements config.Storager. The value gets converted to a string.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	k, err := s.key(key)
+	if err != nil {
+		return errors.Wrap(err, "[etcd] Set")
+	}
+	v, err := conv.ToStringE(value)
+	if err != nil {
+		return errors.Wrapf(err, "[etcd] Set.conv.ToStringE Key %q", k)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rev, err := s.kv.Put(ctx, k, []byte(v))
+	if err != nil {
+		return errors.Wrapf(err, "[etcd] Set.Put Key %q", k)
+	}
+	if s.watching {
+		s.own[rev] = struct{}{}
+	}
+	return nil
+}
+
+// Get implements config.Storager. Error behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	k, err := s.key(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "[etcd] Get")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	v, found, err := s.kv.Get(ctx, k)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[etcd] Get Key %q", k)
+	}
+	if !found {
+		return nil, errors.NewNotFoundf("[etcd] Key %q not found", k)
+	}
+	return string(v), nil
+}
+
+// AllKeys implements config.Storager.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	keys, err := s.kv.Keys(ctx, s.prefix)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[etcd] AllKeys with prefix %q", s.prefix)
+	}
+	ps := make(cfgpath.PathSlice, 0, len(keys))
+	for _, k := range keys {
+		p, err := cfgpath.SplitFQ(strings.TrimPrefix(k, s.prefix))
+		if err != nil {
+			return nil, errors.Wrapf(err, "[etcd] AllKeys Key %q", k)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// Watch starts a goroutine which listens to the changes of all keys below the
+// prefix and calls for each changed path the MessageReceivers, for example
+// the config.Service running on this node. Changes written by this Storage
+// get skipped because config.Service.Write publishes them already. The
+// goroutine terminates when the context gets canceled. Call Watch only once
+// per Storage. Errors of the receivers and invalid keys get logged as Info.
+//		srv := config.MustNewService(etcdStorage, config.WithPubSub())
+//		etcdStorage.Watch(ctx, srv)
+func (s *Storage) Watch(ctx context.Context, receivers ...config.MessageReceiver) {
+	s.mu.Lock()
+	s.watching = true
+	s.mu.Unlock()
+
+	events := s.kv.Watch(ctx, s.prefix)
+	go func() {
+		for e := range events {
+			if s.isOwn(e.Revision) {
+				continue
+			}
+			p, err := cfgpath.SplitFQ(strings.TrimPrefix(e.Key, s.prefix))
+			if err != nil {
+				if s.log.IsInfo() {
+					s.log.Info("etcd.Storage.Watch.SplitFQ", log.Err(err), log.String("key", e.Key))
+				}
+				continue
+			}
+			for _, r := range receivers {
+				if err := r.MessageConfig(p); err != nil && s.log.IsInfo() {
+					s.log.Info("etcd.Storage.Watch.MessageConfig", log.Err(err), log.String("key", e.Key))
+				}
+			}
+		}
+		s.mu.Lock()
+		s.watching = false
+		s.own = make(map[int64]struct{})
+		s.mu.Unlock()
+	}()
+}
+
+func (s *Storage) isOwn(revision int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.own[revision]
+	delete(s.own, revision)
+	return ok
+}
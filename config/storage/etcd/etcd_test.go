@@ -0,0 +1,109 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/etcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*etcd.Storage)(nil)
+var _ etcd.KV = (*etcd.MemKV)(nil)
+
+type chanReceiver chan string
+
+func (c chanReceiver) MessageConfig(p cfgpath.Path) error {
+	c <- p.String()
+	return nil
+}
+
+func (c chanReceiver) wait(t *testing.T) string {
+	select {
+	case s := <-c:
+		return s
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for message")
+	}
+	return ""
+}
+
+func TestStorage_SetGetAllKeys(t *testing.T) {
+	s := etcd.New(etcd.NewMemKV(), etcd.Options{})
+
+	p := cfgpath.MustNewByParts("general/locale/code").BindStore(2)
+	_, err := s.Get(p)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	require.NoError(t, s.Set(p, "de_CH"))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("web/cookie/cookie_lifetime"), 3600))
+	v, err := s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "de_CH", v)
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Exactly(t, "default/0/web/cookie/cookie_lifetime", keys[0].String())
+	assert.Exactly(t, "stores/2/general/locale/code", keys[1].String())
+}
+
+func TestStorage_Watch(t *testing.T) {
+	kv := etcd.NewMemKV()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newNode := func() (*config.Service, chanReceiver) {
+		s := etcd.New(kv, etcd.Options{})
+		srv := config.MustNewService(s, config.WithPubSub())
+		s.Watch(ctx, srv)
+		msgs := make(chanReceiver, 10)
+		_, err := srv.Subscribe(cfgpath.NewRoute("web/cookie"), msgs)
+		require.NoError(t, err)
+		return srv, msgs
+	}
+	nodeA, msgsA := newNode()
+	defer func() { assert.NoError(t, nodeA.Close()) }()
+	nodeB, msgsB := newNode()
+	defer func() { assert.NoError(t, nodeB.Close()) }()
+
+	p := cfgpath.MustNewByParts("web/cookie/cookie_path").BindWebsite(1)
+	require.NoError(t, nodeA.Write(p, "/shop"))
+	assert.Exactly(t, p.String(), msgsA.wait(t))
+	assert.Exactly(t, p.String(), msgsB.wait(t))
+
+	v, err := nodeB.String(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "/shop", v)
+
+	// a change from an external tool reaches both nodes
+	_, err = kv.Put(ctx, "/corestore/config/default/0/web/cookie/cookie_domain", []byte("example.com"))
+	require.NoError(t, err)
+	assert.Exactly(t, "default/0/web/cookie/cookie_domain", msgsA.wait(t))
+	assert.Exactly(t, "default/0/web/cookie/cookie_domain", msgsB.wait(t))
+
+	select {
+	case m := <-msgsA:
+		t.Fatalf("node A received its own write twice: %s", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EventType defines the kind of a change.
+type EventType uint8
+
+// EventPut and EventDelete are the types of a watch Event.
+const (
+	EventPut EventType = iota
+	EventDelete
+)
+
+// Event represents a change of a key received from a watch.
+type Event struct {
+	Type  EventType
+	Key   string
+	Value []byte
+	// Revision of the key value store in which the change happened. Equals
+	// the revision returned by KV.Put.
+	Revision int64
+}
+
+// KV defines the functions of an etcd style key value store used by Storage.
+type KV interface {
+	// Put writes a value and returns the revision of the store.
+	Put(ctx context.Context, key string, value []byte) (revision int64, err error)
+	// Get returns the value of a key. Found is false if the key does not
+	// exist.
+	Get(ctx context.Context, key string) (value []byte, found bool, err error)
+	// Keys returns all keys starting with prefix.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// Watch sends all changes of the keys starting with prefix to the
+	// returned channel in the order of their revision. The channel gets
+	// closed when the context has been canceled.
+	Watch(ctx context.Context, prefix string) <-chan Event
+}
+
+// MemKV implements interface KV in memory. All Storage types created with the
+// same MemKV behave like nodes of a cluster. MemKV is safe for concurrent use.
+type MemKV struct {
+	mu       sync.Mutex
+	revision int64
+	data     map[string][]byte
+	watchers map[*memWatcher]struct{}
+}
+
+// NewMemKV creates a new empty in memory key value store.
+func NewMemKV() *MemKV {
+	return &MemKV{
+		data:     make(map[string][]byte),
+		watchers: make(map[*memWatcher]struct{}),
+	}
+}
+
+// Put implements interface KV.
+func (m *MemKV) Put(_ context.Context, key string, value []byte) (int64, error) {
+	value = append([]byte(nil), value...)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revision++
+	m.data[key] = value
+	m.notify(Event{Type: EventPut, Key: key, Value: value, Revision: m.revision})
+	return m.revision, nil
+}
+
+// Delete removes a key and returns the new revision.
+func (m *MemKV) Delete(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.data[key]; !ok {
+		return m.revision, nil
+	}
+	m.revision++
+	delete(m.data, key)
+	m.notify(Event{Type: EventDelete, Key: key, Revision: m.revision})
+	return m.revision, nil
+}
+
+// Get implements interface KV.
+func (m *MemKV) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	v, ok := m.data[key]
+	m.mu.Unlock()
+	return v, ok, nil
+}
+
+// Keys implements interface KV. The keys are sorted.
+func (m *MemKV) Keys(_ context.Context, prefix string) ([]string, error) {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.data))
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	m.mu.Unlock()
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Watch implements interface KV.
+func (m *MemKV) Watch(ctx context.Context, prefix string) <-chan Event {
+	w := &memWatcher{
+		prefix: prefix,
+		signal: make(chan struct{}, 1),
+		out:    make(chan Event),
+	}
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		w.run(ctx)
+		m.mu.Lock()
+		delete(m.watchers, w)
+		m.mu.Unlock()
+	}()
+	return w.out
+}
+
+// notify must be called with m.mu locked.
+func (m *MemKV) notify(e Event) {
+	for w := range m.watchers {
+		if strings.HasPrefix(e.Key, w.prefix) {
+			w.push(e)
+		}
+	}
+}
+
+// memWatcher queues the events without limit, so a slow receiver never
+// blocks a writer.
+type memWatcher struct {
+	prefix string
+	mu     sync.Mutex
+	queue  []Event
+	signal chan struct{}
+	out    chan Event
+}
+
+func (w *memWatcher) push(e Event) {
+	w.mu.Lock()
+	w.queue = append(w.queue, e)
+	w.mu.Unlock()
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (w *memWatcher) run(ctx context.Context) {
+	defer close(w.out)
+	for {
+		w.mu.Lock()
+		events := w.queue
+		w.queue = nil
+		w.mu.Unlock()
+		for _, e := range events {
+			select {
+			case w.out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case <-w.signal:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history records every configuration write in an audit log and rolls
+// values back to a previous state.
+//
+// Storage wraps the config.Storager of a config.Service. Each Set or Write
+// appends an Entry with path, scope, old value, new value, actor and time to
+// a Sink. The actor gets read from the context of Write, see WithActor.
+// Available sinks: FileSink writes JSON lines into a file and MySQLSink
+// writes into the table core_config_data_history.
+//
+//		hs := history.New(ccdStorage, history.NewFileSink("/var/log/config.log"), history.Options{})
+//		srv := config.MustNewService(hs, config.WithPubSub())
+//		hs.Notify = srv // publish rollbacks
+//
+//		err := hs.Write(history.WithActor(ctx, "admin@example.com"), p, "EUR")
+//		entries, err := hs.History(ctx, p)
+//		err = hs.Undo(ctx, p)
+//		err = hs.RollbackTo(ctx, yesterday)
+package history
@@ -0,0 +1,279 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+type keyCtxActor struct{}
+
+// WithActor returns a new context containing the actor, e.g. the name of the
+// admin user, who changes the configuration.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, keyCtxActor{}, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor.
+func ActorFromContext(ctx context.Context) (string, bool) {
+	a, ok := ctx.Value(keyCtxActor{}).(string)
+	return a, ok
+}
+
+// Entry describes one configuration write.
+type Entry struct {
+	Path cfgpath.Path
+	// Old value before the write. Not valid if the path did not exist or the
+	// value was nil.
+	Old dml.NullString
+	// New written value. Not valid if the value was nil.
+	New   dml.NullString
+	Actor string
+	Time  time.Time
+}
+
+// Filter selects the entries returned by Sink.List.
+type Filter struct {
+	// Paths if empty, all paths match.
+	Paths []cfgpath.Path
+	// After if not zero, only entries written after this time match.
+	After time.Time
+}
+
+func (f Filter) match(e Entry) bool {
+	if !f.After.IsZero() && !e.Time.After(f.After) {
+		return false
+	}
+	if len(f.Paths) == 0 {
+		return true
+	}
+	for _, p := range f.Paths {
+		if p.String() == e.Path.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// Sink persists the entries.
+type Sink interface {
+	// Append adds the entry to the audit log.
+	Append(ctx context.Context, e Entry) error
+	// List returns all matching entries ordered by time in ascending order.
+	List(ctx context.Context, f Filter) ([]Entry, error)
+}
+
+// Deleter gets implemented by a backend which can remove a path, for example
+// boltdb.Storage. Undo and RollbackTo delete a path which did not exist before,
+// instead of setting it to nil.
+type Deleter interface {
+	Delete(key cfgpath.Path) error
+}
+
+// Options configures a Storage.
+type Options struct {
+	// Actor returns the actor of a Write. Default ActorFromContext. Set and
+	// all writes without an actor record an empty actor.
+	Actor func(ctx context.Context) string
+}
+
+// Storage implements config.Storager and records all writes into a Sink.
+type Storage struct {
+	config.Storager
+	sink  Sink
+	actor func(ctx context.Context) string
+	// Notify, if set, gets called after each Write, Undo and RollbackTo, for
+	// example with the config.Service to publish the changed paths. Set does
+	// not call Notify because config.Service.Write publishes already. Assign
+	// Notify before the first write.
+	Notify config.MessageReceiver
+
+	// mu serializes the writes to keep the old values in the correct order.
+	mu sync.Mutex
+}
+
+// New creates a new Storage which records all writes to the backend.
+func New(backend config.Storager, sink Sink, o Options) *Storage {
+	if o.Actor == nil {
+		o.Actor = func(ctx context.Context) string {
+			a, _ := ActorFromContext(ctx)
+			return a
+		}
+	}
+	return &Storage{
+		Storager: backend,
+		sink:     sink,
+		actor:    o.Actor,
+	}
+}
+
+// Set implements config.Storager and records the write without an actor. Use
+// Write to record the actor.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	return s.set(context.Background(), key, value, false)
+}
+
+// Write sets the value in the backend and records the write including the
+// actor of the context. Calls Notify.
+func (s *Storage) Write(ctx context.Context, p cfgpath.Path, value interface{}) error {
+	return s.write(ctx, p, value, false)
+}
+
+func (s *Storage) write(ctx context.Context, p cfgpath.Path, value interface{}, del bool) error {
+	if err := s.set(ctx, p, value, del); err != nil {
+		return errors.Wrap(err, "[history] Write")
+	}
+	return s.notify(p)
+}
+
+// set writes the value into the backend and appends the entry to the sink. If
+// the sink fails, the write gets undone, so that the backend contains no
+// unrecorded changes. If del is true and the backend implements Deleter, the
+// path gets deleted instead of set to nil.
+func (s *Storage) set(ctx context.Context, p cfgpath.Path, value interface{}, del bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := Entry{
+		Path:  p,
+		New:   toNullString(value),
+		Actor: s.actor(ctx),
+	}
+	old, err := s.Storager.Get(p)
+	existed := err == nil
+	switch {
+	case existed:
+		e.Old = toNullString(old)
+	case !errors.IsNotFound(err):
+		return errors.Wrapf(err, "[history] Get Path %q", p)
+	}
+	if err := s.setBackend(p, value, del); err != nil {
+		return errors.Wrapf(err, "[history] Set Path %q", p)
+	}
+	e.Time = time.Now()
+	if err := s.sink.Append(ctx, e); err != nil {
+		if uErr := s.setBackend(p, old, !existed); uErr != nil {
+			return errors.Wrapf(err, "[history] Sink.Append Path %q: undo of the write failed: %s", p, uErr)
+		}
+		return errors.Wrapf(err, "[history] Sink.Append Path %q", p)
+	}
+	return nil
+}
+
+func (s *Storage) setBackend(p cfgpath.Path, value interface{}, del bool) error {
+	if d, ok := s.Storager.(Deleter); ok && del {
+		return d.Delete(p)
+	}
+	return s.Storager.Set(p, value)
+}
+
+func (s *Storage) notify(p cfgpath.Path) error {
+	if s.Notify == nil {
+		return nil
+	}
+	return errors.Wrapf(s.Notify.MessageConfig(p), "[history] Notify Path %q", p)
+}
+
+// History returns all recorded writes of a path, the oldest first.
+func (s *Storage) History(ctx context.Context, p cfgpath.Path) ([]Entry, error) {
+	es, err := s.sink.List(ctx, Filter{Paths: []cfgpath.Path{p}})
+	return es, errors.Wrapf(err, "[history] History Path %q", p)
+}
+
+// Undo restores the value before the most recent write of a path. The
+// rollback itself gets recorded, hence calling Undo twice restores the most
+// recent value again. A path which did not exist before gets deleted, if the
+// backend implements Deleter. Otherwise it gets set to nil, which shadows the
+// value of a parent scope or the default value of a field.
+// Error behaviour: NotFound if the path has no history.
+func (s *Storage) Undo(ctx context.Context, p cfgpath.Path) error {
+	es, err := s.History(ctx, p)
+	if err != nil {
+		return errors.Wrap(err, "[history] Undo")
+	}
+	if len(es) == 0 {
+		return errors.NewNotFoundf("[history] Undo: No history for Path %q", p)
+	}
+	return errors.Wrap(s.restore(ctx, es[len(es)-1]), "[history] Undo")
+}
+
+// RollbackTo restores the values of the paths as they were at time t. Without
+// paths, all paths written after t get restored. Paths which did not exist at
+// time t get deleted or set to nil, see Undo.
+func (s *Storage) RollbackTo(ctx context.Context, t time.Time, paths ...cfgpath.Path) error {
+	es, err := s.sink.List(ctx, Filter{Paths: paths, After: t})
+	if err != nil {
+		return errors.Wrapf(err, "[history] RollbackTo %s", t)
+	}
+	// the first entry after t contains the value of the path at time t.
+	seen := make(map[string]bool, len(es))
+	for _, e := range es {
+		if fq := e.Path.String(); !seen[fq] {
+			seen[fq] = true
+			if err := s.restore(ctx, e); err != nil {
+				return errors.Wrapf(err, "[history] RollbackTo %s", t)
+			}
+		}
+	}
+	return nil
+}
+
+// restore writes the old value of the entry or deletes the path if there was
+// no old value.
+func (s *Storage) restore(ctx context.Context, e Entry) error {
+	var v interface{}
+	if e.Old.Valid {
+		v = e.Old.String
+	}
+	return s.write(ctx, e.Path, v, !e.Old.Valid)
+}
+
+// toNullString converts a value into the string stored in the audit log.
+func toNullString(v interface{}) dml.NullString {
+	var s string
+	switch val := v.(type) {
+	case nil:
+		return dml.NullString{}
+	case string:
+		s = val
+	case []byte:
+		s = string(val)
+	case bool:
+		s = "0"
+		if val {
+			s = "1"
+		}
+	case int:
+		s = strconv.Itoa(val)
+	case int64:
+		s = strconv.FormatInt(val, 10)
+	case float64:
+		s = strconv.FormatFloat(val, 'f', -1, 64)
+	case time.Time:
+		s = val.Format("2006-01-02 15:04:05")
+	default:
+		s = fmt.Sprint(v)
+	}
+	return dml.MakeNullString(s)
+}
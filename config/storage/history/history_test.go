@@ -0,0 +1,239 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/history"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*history.Storage)(nil)
+var _ history.Sink = (*history.FileSink)(nil)
+var _ history.Sink = (*history.MySQLSink)(nil)
+
+type receiver struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (r *receiver) MessageConfig(p cfgpath.Path) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paths = append(r.paths, p.String())
+	return nil
+}
+
+func newStorage(t *testing.T) (*history.Storage, func()) {
+	dir, err := ioutil.TempDir("", "cfghistory_")
+	require.NoError(t, err)
+	s := history.New(config.NewInMemoryStore(), history.NewFileSink(filepath.Join(dir, "history.log")), history.Options{})
+	return s, func() {
+		assert.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func getString(t *testing.T, s config.Storager, p cfgpath.Path) interface{} {
+	v, err := s.Get(p)
+	require.NoError(t, err, "%+v", err)
+	return v
+}
+
+func TestStorage_History(t *testing.T) {
+	s, closeFn := newStorage(t)
+	defer closeFn()
+	rec := new(receiver)
+	s.Notify = rec
+
+	ctx := history.WithActor(context.Background(), "admin")
+	p := cfgpath.MustNewByParts("currency/options/base").BindWebsite(1)
+
+	require.NoError(t, s.Set(p, "USD"))
+	require.NoError(t, s.Write(ctx, p, "EUR"))
+	require.NoError(t, s.Write(ctx, cfgpath.MustNewByParts("currency/options/base"), "CHF"))
+	assert.Exactly(t, []string{"websites/1/currency/options/base", "default/0/currency/options/base"}, rec.paths)
+
+	es, err := s.History(ctx, p)
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	assert.Exactly(t, p.String(), es[0].Path.String())
+	assert.Exactly(t, dml.NullString{}, es[0].Old)
+	assert.Exactly(t, dml.MakeNullString("USD"), es[0].New)
+	assert.Exactly(t, "", es[0].Actor)
+	assert.Exactly(t, dml.MakeNullString("USD"), es[1].Old)
+	assert.Exactly(t, dml.MakeNullString("EUR"), es[1].New)
+	assert.Exactly(t, "admin", es[1].Actor)
+	assert.False(t, es[1].Time.Before(es[0].Time))
+
+	require.NoError(t, s.Undo(ctx, p))
+	assert.Exactly(t, "USD", getString(t, s, p))
+	require.NoError(t, s.Undo(ctx, p))
+	assert.Exactly(t, "EUR", getString(t, s, p))
+
+	err = s.Undo(ctx, cfgpath.MustNewByParts("aa/bb/cc"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
+
+func TestStorage_RollbackTo(t *testing.T) {
+	s, closeFn := newStorage(t)
+	defer closeFn()
+	ctx := context.Background()
+
+	pa := cfgpath.MustNewByParts("aa/bb/cc")
+	pb := cfgpath.MustNewByParts("aa/bb/dd").BindStore(2)
+	require.NoError(t, s.Write(ctx, pa, "a1"))
+	require.NoError(t, s.Write(ctx, pb, 1))
+	time.Sleep(time.Millisecond)
+	pointInTime := time.Now()
+	time.Sleep(time.Millisecond)
+	require.NoError(t, s.Write(ctx, pa, "a2"))
+	require.NoError(t, s.Write(ctx, pa, "a3"))
+	require.NoError(t, s.Write(ctx, pb, 2))
+	pc := cfgpath.MustNewByParts("aa/bb/ee")
+	require.NoError(t, s.Write(ctx, pc, "new"))
+
+	require.NoError(t, s.RollbackTo(ctx, pointInTime, pb))
+	assert.Exactly(t, "1", getString(t, s, pb))
+	assert.Exactly(t, "a3", getString(t, s, pa))
+
+	require.NoError(t, s.RollbackTo(ctx, pointInTime))
+	assert.Exactly(t, "a1", getString(t, s, pa))
+	assert.Exactly(t, "1", getString(t, s, pb))
+	assert.Nil(t, getString(t, s, pc), "path did not exist at the point in time")
+}
+
+// mapStore implements config.Storager and history.Deleter.
+type mapStore struct {
+	kv map[string]interface{}
+}
+
+func (ms *mapStore) Set(key cfgpath.Path, value interface{}) error {
+	ms.kv[key.String()] = value
+	return nil
+}
+
+func (ms *mapStore) Get(key cfgpath.Path) (interface{}, error) {
+	v, ok := ms.kv[key.String()]
+	if !ok {
+		return nil, errors.NewNotFoundf("[history_test] Key %q not found", key)
+	}
+	return v, nil
+}
+
+func (ms *mapStore) AllKeys() (cfgpath.PathSlice, error) { return nil, nil }
+
+func (ms *mapStore) Delete(key cfgpath.Path) error {
+	delete(ms.kv, key.String())
+	return nil
+}
+
+// failingSink fails to append when err is set.
+type failingSink struct {
+	history.Sink
+	err error
+}
+
+func (fs *failingSink) Append(ctx context.Context, e history.Entry) error {
+	if fs.err != nil {
+		return fs.err
+	}
+	return fs.Sink.Append(ctx, e)
+}
+
+func TestStorage_Deleter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfghistory_")
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, os.RemoveAll(dir)) }()
+
+	ms := &mapStore{kv: map[string]interface{}{}}
+	sink := &failingSink{Sink: history.NewFileSink(filepath.Join(dir, "history.log"))}
+	s := history.New(ms, sink, history.Options{})
+	ctx := context.Background()
+
+	pa := cfgpath.MustNewByParts("aa/bb/cc").BindWebsite(1)
+	require.NoError(t, s.Write(ctx, pa, "a1"))
+	require.NoError(t, s.Undo(ctx, pa))
+	_, err = ms.Get(pa)
+	assert.True(t, errors.IsNotFound(err), "Undo must delete the path instead of shadowing the default scope: %+v", err)
+
+	t.Run("failed Append undoes the write", func(t *testing.T) {
+		sink.err = errors.NewWriteFailedf("disk full")
+		defer func() { sink.err = nil }()
+
+		pb := cfgpath.MustNewByParts("aa/bb/dd")
+		require.NoError(t, ms.Set(pb, "b1"))
+		err := s.Write(ctx, pb, "b2")
+		assert.True(t, errors.IsWriteFailed(err), "%+v", err)
+		assert.Exactly(t, "b1", getString(t, s, pb))
+
+		err = s.Write(ctx, pa, "a2")
+		assert.True(t, errors.IsWriteFailed(err), "%+v", err)
+		_, err = ms.Get(pa)
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+		es, err := s.History(ctx, pb)
+		require.NoError(t, err)
+		assert.Len(t, es, 0)
+	})
+}
+
+func TestMySQLSink(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	ms := history.NewMySQLSink(dbc, "")
+	ctx := context.TODO()
+	now := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE IF NOT EXISTS `core_config_data_history`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	require.NoError(t, ms.CreateTable(ctx))
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `core_config_data_history` (`scope`,`scope_id`,`path`,`old_value`,`new_value`,`actor`,`created_at`) VALUES (?,?,?,?,?,?,?)")).
+		WithArgs("stores", 2, "general/locale/code", nil, "de_CH", "admin", now).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, ms.Append(ctx, history.Entry{
+		Path:  cfgpath.MustNewByParts("general/locale/code").BindStore(2),
+		New:   dml.MakeNullString("de_CH"),
+		Actor: "admin",
+		Time:  now,
+	}))
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `scope`, `scope_id`, `path`, `old_value`, `new_value`, `actor`, `created_at` FROM `core_config_data_history` WHERE (`path` IN ('general/locale/code')) ORDER BY `history_id`")).
+		WillReturnRows(sqlmock.NewRows([]string{"scope", "scope_id", "path", "old_value", "new_value", "actor", "created_at"}).
+			AddRow("default", 0, "general/locale/code", nil, "en_US", "", now).
+			AddRow("stores", 2, "general/locale/code", nil, "de_CH", "admin", now))
+	es, err := ms.List(ctx, history.Filter{Paths: []cfgpath.Path{cfgpath.MustNewByParts("general/locale/code").BindStore(2)}})
+	require.NoError(t, err)
+	require.Len(t, es, 1)
+	assert.Exactly(t, "stores/2/general/locale/code", es[0].Path.String())
+	assert.Exactly(t, dml.MakeNullString("de_CH"), es[0].New)
+	assert.Exactly(t, "admin", es[0].Actor)
+	assert.Exactly(t, now, es[0].Time)
+}
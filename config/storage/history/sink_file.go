@@ -0,0 +1,135 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// FileSink appends the entries as JSON lines to a file. Each line contains
+// the fully qualified path, e.g.:
+//		{"path":"stores/2/general/locale/code","old":"en_US","new":"de_CH","actor":"admin","time":"2017-01-02T03:04:05Z"}
+type FileSink struct {
+	filename string
+	mu       sync.Mutex
+}
+
+// NewFileSink creates a new FileSink. The file gets created on the first
+// Append.
+func NewFileSink(filename string) *FileSink {
+	return &FileSink{filename: filename}
+}
+
+// maxLineSize maximum length of a line, which limits the length of the old
+// and new values.
+const maxLineSize = 1 << 20
+
+type fileEntry struct {
+	Path  string    `json:"path"`
+	Old   *string   `json:"old"`
+	New   *string   `json:"new"`
+	Actor string    `json:"actor,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Append implements Sink.
+func (fs *FileSink) Append(_ context.Context, e Entry) error {
+	fq, err := e.Path.FQ()
+	if err != nil {
+		return errors.Wrap(err, "[history] FileSink.Append")
+	}
+	data, err := json.Marshal(fileEntry{
+		Path:  fq.String(),
+		Old:   fromNullString(e.Old),
+		New:   fromNullString(e.New),
+		Actor: e.Actor,
+		Time:  e.Time,
+	})
+	if err != nil {
+		return errors.Wrap(err, "[history] FileSink.Append.Marshal")
+	}
+	data = append(data, '\n')
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, err := os.OpenFile(fs.filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "[history] FileSink.Append.OpenFile %q", fs.filename)
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return errors.Wrapf(err, "[history] FileSink.Append.Write %q", fs.filename)
+	}
+	return errors.Wrapf(f.Close(), "[history] FileSink.Append.Close %q", fs.filename)
+}
+
+// List implements Sink and reads the whole file. A missing file contains no
+// entries.
+func (fs *FileSink) List(_ context.Context, flt Filter) ([]Entry, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, err := os.Open(fs.filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "[history] FileSink.List.Open %q", fs.filename)
+	}
+	defer f.Close()
+
+	var es []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		var fe fileEntry
+		if err := json.Unmarshal(sc.Bytes(), &fe); err != nil {
+			return nil, errors.NewNotValid(err, "[history] FileSink.List "+fs.filename+" line "+strconv.Itoa(line))
+		}
+		p, err := cfgpath.SplitFQ(fe.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[history] FileSink.List %q line %d", fs.filename, line)
+		}
+		if e := (Entry{Path: p, Old: toNullStringPtr(fe.Old), New: toNullStringPtr(fe.New), Actor: fe.Actor, Time: fe.Time}); flt.match(e) {
+			es = append(es, e)
+		}
+	}
+	return es, errors.Wrapf(sc.Err(), "[history] FileSink.List.Scan %q", fs.filename)
+}
+
+// fromNullString avoids dml.NullString.MarshalJSON which depends on
+// dml.JSONMarshalFn.
+func fromNullString(ns dml.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	return &ns.String
+}
+
+func toNullStringPtr(s *string) dml.NullString {
+	if s == nil {
+		return dml.NullString{}
+	}
+	return dml.MakeNullString(*s)
+}
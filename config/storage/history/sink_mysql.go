@@ -0,0 +1,125 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"context"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// MySQLSink writes the entries into a MySQL table. Use CreateTable to create
+// the table.
+type MySQLSink struct {
+	db        *dml.ConnPool
+	tableName string
+}
+
+// NewMySQLSink creates a new MySQLSink. Default table name
+// core_config_data_history.
+func NewMySQLSink(db *dml.ConnPool, tableName string) *MySQLSink {
+	if tableName == "" {
+		tableName = "core_config_data_history"
+	}
+	return &MySQLSink{db: db, tableName: tableName}
+}
+
+// CreateTable creates the history table if it does not exists.
+func (ms *MySQLSink) CreateTable(ctx context.Context) error {
+	_, err := ms.db.WithQueryBuilder(dml.QuerySQL("CREATE TABLE IF NOT EXISTS " + dml.Quoter.Name(ms.tableName) + " (" +
+		"`history_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, " +
+		"`scope` VARCHAR(8) NOT NULL DEFAULT 'default', " +
+		"`scope_id` INT NOT NULL DEFAULT 0, " +
+		"`path` VARCHAR(255) NOT NULL, " +
+		"`old_value` TEXT NULL, " +
+		"`new_value` TEXT NULL, " +
+		"`actor` VARCHAR(255) NOT NULL DEFAULT '', " +
+		"`created_at` DATETIME(6) NOT NULL, " +
+		"PRIMARY KEY (`history_id`), " +
+		"KEY `IDX_PATH` (`path`,`scope`,`scope_id`), " +
+		"KEY `IDX_CREATED_AT` (`created_at`)) ENGINE=InnoDB")).ExecContext(ctx)
+	return errors.Wrapf(err, "[history] MySQLSink.CreateTable %q", ms.tableName)
+}
+
+// Append implements Sink.
+func (ms *MySQLSink) Append(ctx context.Context, e Entry) error {
+	scp, id := e.Path.ScopeID.Unpack()
+	_, err := ms.db.InsertInto(ms.tableName).
+		AddColumns("scope", "scope_id", "path", "old_value", "new_value", "actor", "created_at").
+		WithArgs().ExecContext(ctx, scp.StrType(), id, e.Path.Route.String(), e.Old, e.New, e.Actor, e.Time)
+	return errors.Wrapf(err, "[history] MySQLSink.Append into table %q", ms.tableName)
+}
+
+// List implements Sink.
+func (ms *MySQLSink) List(ctx context.Context, f Filter) ([]Entry, error) {
+	var conds dml.Conditions
+	if len(f.Paths) > 0 {
+		routes := make([]string, len(f.Paths))
+		for i, p := range f.Paths {
+			routes[i] = p.Route.String()
+		}
+		conds = append(conds, dml.Column("path").In().Strs(routes...))
+	}
+	if !f.After.IsZero() {
+		conds = append(conds, dml.Column("created_at").Greater().Time(f.After))
+	}
+
+	var es []Entry
+	err := ms.db.SelectFrom(ms.tableName).
+		AddColumns("scope", "scope_id", "path", "old_value", "new_value", "actor", "created_at").
+		Where(conds...).OrderBy("history_id").WithArgs().
+		IterateSerial(ctx, func(cm *dml.ColumnMap) error {
+			var scp, route string
+			var scopeID int64
+			var e Entry
+			for cm.Next() {
+				switch c := cm.Column(); c {
+				case "scope":
+					cm.String(&scp)
+				case "scope_id":
+					cm.Int64(&scopeID)
+				case "path":
+					cm.String(&route)
+				case "old_value":
+					cm.NullString(&e.Old)
+				case "new_value":
+					cm.NullString(&e.New)
+				case "actor":
+					cm.String(&e.Actor)
+				case "created_at":
+					cm.Time(&e.Time)
+				default:
+					return errors.NewNotFoundf("[history] MySQLSink.List: Column %q not found", c)
+				}
+			}
+			if err := cm.Err(); err != nil {
+				return errors.WithStack(err)
+			}
+			p, err := cfgpath.NewByParts(route)
+			if err != nil {
+				return errors.Wrapf(err, "[history] MySQLSink.List: Path %q", route)
+			}
+			e.Path = p.Bind(scope.MakeTypeID(scope.FromString(scp), scopeID))
+			// the route condition ignores the scope.
+			if f.match(e) {
+				es = append(es, e)
+			}
+			return nil
+		})
+	return es, errors.Wrapf(err, "[history] MySQLSink.List from table %q", ms.tableName)
+}
@@ -0,0 +1,27 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package layered combines several config.Storager into one. A layer with a
+// higher priority overrides the values of the layers below. A typical stack,
+// from the lowest to the highest priority:
+//
+//	- defaults of the element.SectionSlice, see NewDefaultsLayer
+//	- table core_config_data, e.g. ccd.DBStorage
+//	- a YAML or JSON file, see NewFileLayer
+//	- environment variables like CONFIG__WEB__SECURE__BASE_URL, see
+//	  NewEnvLayer
+//
+// Storage.Explain reports for a path which layer supplied the value and
+// which values of the other layers have been shadowed.
+package layered
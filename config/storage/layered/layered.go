@@ -0,0 +1,181 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layered
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// Layer represents one level in the Storage.
+type Layer struct {
+	// Name identifies the layer in the Resolution, e.g. "env".
+	Name string
+	config.Storager
+	// ReadOnly layers never receive a value from Storage.Set.
+	ReadOnly bool
+}
+
+// Storage implements config.Storager and reads a value from the first layer
+// which contains the path, starting with the highest priority.
+type Storage struct {
+	// layers ordered from the lowest to the highest priority.
+	layers []Layer
+}
+
+// New creates a new Storage. The layers must be ordered from the lowest to
+// the highest priority.
+//		defaults, err := layered.NewDefaultsLayer(sections.Defaults())
+//		env, err := layered.NewEnvLayer(os.Environ())
+//		s := layered.New(
+//			defaults,
+//			layered.Layer{Name: "core_config_data", Storager: dbStorage},
+//			fileLayer,
+//			env,
+//		)
+func New(layers ...Layer) *Storage {
+	return &Storage{layers: layers}
+}
+
+// Layers returns the names of the layers ordered from the lowest to the
+// highest priority.
+func (s *Storage) Layers() []string {
+	names := make([]string, len(s.layers))
+	for i, l := range s.layers {
+		names[i] = l.Name
+	}
+	return names
+}
+
+// Set writes the value into the writable layer with the highest priority.
+// Error behaviour: NotSupported if all layers are read only.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		if l := s.layers[i]; !l.ReadOnly {
+			return errors.Wrapf(l.Set(key, value), "[layered] Set Layer %q", l.Name)
+		}
+	}
+	return errors.NewNotSupportedf("[layered] Set: All layers are read only. Path %q", key)
+}
+
+// Get returns the value of the layer with the highest priority which contains
+// the path. Error behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		v, err := l.Get(key)
+		switch {
+		case err == nil:
+			return v, nil
+		case !errors.IsNotFound(err):
+			return nil, errors.Wrapf(err, "[layered] Get Layer %q", l.Name)
+		}
+	}
+	return nil, errors.NewNotFoundf("[layered] Path %q not found in any layer", key)
+}
+
+// AllKeys returns the unique keys of all layers.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	var ret cfgpath.PathSlice
+	seen := make(map[string]bool)
+	for _, l := range s.layers {
+		keys, err := l.AllKeys()
+		if err != nil {
+			return nil, errors.Wrapf(err, "[layered] AllKeys Layer %q", l.Name)
+		}
+		for _, k := range keys {
+			if fq := k.String(); !seen[fq] {
+				seen[fq] = true
+				ret = append(ret, k)
+			}
+		}
+	}
+	return ret, nil
+}
+
+// Step describes the lookup of a path in one layer.
+type Step struct {
+	Layer string
+	Found bool
+	Value interface{}
+	// Err any error other than NotFound.
+	Err error
+}
+
+// Resolution explains from which layer the value of a path gets read.
+type Resolution struct {
+	Path cfgpath.Path
+	// Layer name of the layer which supplies the value. Empty if not found.
+	Layer string
+	Value interface{}
+	// Steps contains the lookup in all layers, ordered from the highest to
+	// the lowest priority. Values found in later steps are shadowed.
+	Steps []Step
+}
+
+// String returns a human readable multi line trace.
+func (r Resolution) String() string {
+	var buf bytes.Buffer
+	if r.Layer == "" {
+		fmt.Fprintf(&buf, "%s: not found\n", r.Path)
+	} else {
+		fmt.Fprintf(&buf, "%s = %#v from layer %q\n", r.Path, r.Value, r.Layer)
+	}
+	for _, st := range r.Steps {
+		switch {
+		case st.Err != nil:
+			fmt.Fprintf(&buf, "\t%s: error %s\n", st.Layer, st.Err)
+		case !st.Found:
+			fmt.Fprintf(&buf, "\t%s: not found\n", st.Layer)
+		case st.Layer == r.Layer:
+			fmt.Fprintf(&buf, "\t%s: %#v (used)\n", st.Layer, st.Value)
+		default:
+			fmt.Fprintf(&buf, "\t%s: %#v (shadowed)\n", st.Layer, st.Value)
+		}
+	}
+	return buf.String()
+}
+
+// Explain looks up a path in all layers. Contrary to Get it does not stop at
+// the first layer which contains the path, so all shadowed values get
+// reported.
+func (s *Storage) Explain(key cfgpath.Path) Resolution {
+	r := Resolution{
+		Path:  key,
+		Steps: make([]Step, 0, len(s.layers)),
+	}
+	for i := len(s.layers) - 1; i >= 0; i-- {
+		l := s.layers[i]
+		v, err := l.Get(key)
+		st := Step{Layer: l.Name}
+		switch {
+		case err == nil:
+			st.Found = true
+			st.Value = v
+			if r.Layer == "" {
+				r.Layer = l.Name
+				r.Value = v
+			}
+		case !errors.IsNotFound(err):
+			st.Err = err
+		}
+		r.Steps = append(r.Steps, st)
+	}
+	return r
+}
@@ -0,0 +1,195 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layered_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/config/storage/layered"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*layered.Storage)(nil)
+
+func newStorage(t *testing.T) *layered.Storage {
+	defaults, err := layered.NewDefaultsLayer(element.DefaultMap{
+		"web/secure/base_url":        "http://localhost/",
+		"general/locale/code":        "en_US",
+		"web/cookie/cookie_lifetime": 3600,
+	})
+	require.NoError(t, err)
+
+	yml, err := layered.NewFileLayer("config.yaml", strings.NewReader(`
+web/secure/base_url: https://example.com/
+stores/2/general/locale/code: de_CH
+`), layered.FormatYAML)
+	require.NoError(t, err)
+
+	jsn, err := layered.NewFileLayer("config.json", strings.NewReader(`{
+"websites/1/web/cookie/cookie_lifetime": 7200,
+"websites/1/web/cookie/cookie_domain": "example.com"
+}`), layered.FormatJSON)
+	require.NoError(t, err)
+
+	env, err := layered.NewEnvLayer([]string{
+		"PATH=/usr/bin",
+		"CONFIG__WEB__SECURE__BASE_URL=https://env.example.com/",
+		"CONFIG__STORES__3__GENERAL__LOCALE__CODE=fr_CH",
+	})
+	require.NoError(t, err)
+
+	return layered.New(
+		defaults,
+		layered.Layer{Name: "db", Storager: config.NewInMemoryStore()},
+		yml,
+		jsn,
+		env,
+	)
+}
+
+func TestStorage_Get(t *testing.T) {
+	s := newStorage(t)
+	assert.Exactly(t, []string{"defaults", "db", "config.yaml", "config.json", "env"}, s.Layers())
+
+	tests := []struct {
+		key  cfgpath.Path
+		want interface{}
+	}{
+		{cfgpath.MustNewByParts("web/secure/base_url"), "https://env.example.com/"},
+		{cfgpath.MustNewByParts("general/locale/code"), "en_US"},
+		{cfgpath.MustNewByParts("general/locale/code").BindStore(2), "de_CH"},
+		{cfgpath.MustNewByParts("general/locale/code").BindStore(3), "fr_CH"},
+		{cfgpath.MustNewByParts("web/cookie/cookie_lifetime"), 3600},
+		{cfgpath.MustNewByParts("web/cookie/cookie_lifetime").BindWebsite(1), 7200},
+		{cfgpath.MustNewByParts("web/cookie/cookie_domain").BindWebsite(1), "example.com"},
+	}
+	for i, test := range tests {
+		v, err := s.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.want, v, "Index %d", i)
+	}
+
+	_, err := s.Get(cfgpath.MustNewByParts("aa/bb/cc"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
+
+func TestStorage_Set(t *testing.T) {
+	s := newStorage(t)
+	p := cfgpath.MustNewByParts("general/locale/code")
+	require.NoError(t, s.Set(p, "de_DE"))
+
+	v, err := s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "de_DE", v)
+
+	// the env layer has a higher priority than the db layer
+	p = cfgpath.MustNewByParts("web/secure/base_url")
+	require.NoError(t, s.Set(p, "https://db.example.com/"))
+	v, err = s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://env.example.com/", v)
+
+	ro := layered.New(layered.Layer{Name: "ro", Storager: config.NewInMemoryStore(), ReadOnly: true})
+	assert.True(t, errors.IsNotSupported(ro.Set(p, 1)))
+}
+
+func TestStorage_AllKeys(t *testing.T) {
+	s := newStorage(t)
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+
+	have := make([]string, len(keys))
+	for i, k := range keys {
+		have[i] = k.String()
+	}
+	sort.Strings(have)
+	assert.Exactly(t, []string{
+		"default/0/general/locale/code",
+		"default/0/web/cookie/cookie_lifetime",
+		"default/0/web/secure/base_url",
+		"stores/2/general/locale/code",
+		"stores/3/general/locale/code",
+		"websites/1/web/cookie/cookie_domain",
+		"websites/1/web/cookie/cookie_lifetime",
+	}, have)
+}
+
+func TestStorage_Explain(t *testing.T) {
+	s := newStorage(t)
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("web/secure/base_url"), "https://db.example.com/"))
+
+	r := s.Explain(cfgpath.MustNewByParts("web/secure/base_url"))
+	assert.Exactly(t, "env", r.Layer)
+	assert.Exactly(t, "https://env.example.com/", r.Value)
+	require.Len(t, r.Steps, 5)
+	assert.Exactly(t, layered.Step{Layer: "config.json"}, r.Steps[1])
+	assert.Exactly(t, layered.Step{Layer: "config.yaml", Found: true, Value: "https://example.com/"}, r.Steps[2])
+
+	assert.Exactly(t, `default/0/web/secure/base_url = "https://env.example.com/" from layer "env"
+	env: "https://env.example.com/" (used)
+	config.json: not found
+	config.yaml: "https://example.com/" (shadowed)
+	db: "https://db.example.com/" (shadowed)
+	defaults: "http://localhost/" (shadowed)
+`, r.String())
+
+	r = s.Explain(cfgpath.MustNewByParts("aa/bb/cc"))
+	assert.Exactly(t, "", r.Layer)
+	assert.Nil(t, r.Value)
+	assert.True(t, strings.HasPrefix(r.String(), "default/0/aa/bb/cc: not found\n"), r.String())
+}
+
+func TestNewFileLayer_Errors(t *testing.T) {
+	_, err := layered.NewFileLayer("x", strings.NewReader(`{"aa/bb/cc": {"a":1}}`), layered.FormatJSON)
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	_, err = layered.NewFileLayer("x", strings.NewReader(`{"aa/bb/cc": `), layered.FormatJSON)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+
+	_, err = layered.NewFileLayer("x", strings.NewReader(``), layered.Format(9))
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	_, err = layered.LoadFileLayer("config.toml")
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+}
+
+func TestNewEnvLayer(t *testing.T) {
+	l, err := layered.NewEnvLayer([]string{
+		"CONFIG__DEFAULT__WEB__SECURE__BASE_URL=https://a/",
+		"CONFIG__WEBSITES__1__WEB__SECURE__BASE_URL=https://b/",
+	})
+	require.NoError(t, err)
+	assert.Exactly(t, "env", l.Name)
+	assert.True(t, l.ReadOnly)
+
+	v, err := l.Get(cfgpath.MustNewByParts("web/secure/base_url").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://b/", v)
+	v, err = l.Get(cfgpath.MustNewByParts("web/secure/base_url"))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://a/", v)
+
+	_, err = layered.NewEnvLayer([]string{"CONFIG__STORES__X__WEB__SECURE__BASE_URL=1"})
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	_, err = layered.NewEnvLayer([]string{"CONFIG__STORES=1"})
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
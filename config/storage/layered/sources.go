@@ -0,0 +1,199 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package layered
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"gopkg.in/yaml.v2"
+)
+
+// EnvPrefix prefix of the environment variables read by NewEnvLayer.
+const EnvPrefix = "CONFIG__"
+
+// envSeparator separates the path parts in the name of an environment
+// variable.
+const envSeparator = "__"
+
+// NewDefaultsLayer creates the read only layer "defaults" from the default
+// values of the fields, e.g. element.SectionSlice.Defaults.
+func NewDefaultsLayer(dm element.DefaultMap) (Layer, error) {
+	st := config.NewInMemoryStore()
+	for route, v := range dm {
+		p, err := cfgpath.NewByParts(route)
+		if err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewDefaultsLayer Route %q", route)
+		}
+		if err := st.Set(p, v); err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewDefaultsLayer Route %q", route)
+		}
+	}
+	return Layer{Name: "defaults", Storager: st, ReadOnly: true}, nil
+}
+
+// Format of a configuration file.
+type Format uint8
+
+// Supported file formats.
+const (
+	FormatYAML Format = iota
+	FormatJSON
+)
+
+// NewFileLayer creates a read only layer from a YAML or JSON document. The
+// document contains one object whose keys are either a route, which applies
+// to the default scope, or a fully qualified path. The values must be
+// scalars.
+//		web/secure/base_url: https://example.com/
+//		stores/2/general/locale/code: de_CH
+func NewFileLayer(name string, r io.Reader, f Format) (Layer, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Layer{}, errors.Wrapf(err, "[layered] NewFileLayer %q ReadAll", name)
+	}
+	var m map[string]interface{}
+	switch f {
+	case FormatJSON:
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.UseNumber()
+		err = d.Decode(&m)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &m)
+	default:
+		return Layer{}, errors.NewNotSupportedf("[layered] NewFileLayer %q: Unknown format %d", name, f)
+	}
+	if err != nil {
+		return Layer{}, errors.NewNotValid(err, "[layered] NewFileLayer "+name)
+	}
+
+	st := config.NewInMemoryStore()
+	for k, v := range m {
+		switch val := v.(type) {
+		case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+			return Layer{}, errors.NewNotSupportedf("[layered] NewFileLayer %q: Key %q must have a scalar value, got %T", name, k, v)
+		case json.Number:
+			// same types as the YAML decoder
+			if i, err := val.Int64(); err == nil {
+				v = int(i)
+			} else if v, err = val.Float64(); err != nil {
+				return Layer{}, errors.NewNotValid(err, "[layered] NewFileLayer "+name)
+			}
+		}
+		p, err := parseKey(k)
+		if err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewFileLayer %q", name)
+		}
+		if err := st.Set(p, v); err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewFileLayer %q Key %q", name, k)
+		}
+	}
+	return Layer{Name: name, Storager: st, ReadOnly: true}, nil
+}
+
+// LoadFileLayer reads a YAML or JSON file, detected by the extension .json,
+// .yaml or .yml. The layer has the name of the file. See NewFileLayer.
+func LoadFileLayer(filename string) (Layer, error) {
+	var f Format
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".json":
+		f = FormatJSON
+	case ".yaml", ".yml":
+		f = FormatYAML
+	default:
+		return Layer{}, errors.NewNotSupportedf("[layered] LoadFileLayer: Unknown file extension %q", ext)
+	}
+	fp, err := os.Open(filename)
+	if err != nil {
+		return Layer{}, errors.Wrapf(err, "[layered] LoadFileLayer %q", filename)
+	}
+	defer fp.Close()
+	return NewFileLayer(filename, fp, f)
+}
+
+// parseKey creates a path from a route or a fully qualified path.
+func parseKey(k string) (cfgpath.Path, error) {
+	if i := strings.IndexByte(k, cfgpath.Separator); i > 0 && scope.Valid(k[:i]) {
+		p, err := cfgpath.SplitFQ(k)
+		return p, errors.Wrapf(err, "[layered] Key %q", k)
+	}
+	p, err := cfgpath.NewByParts(k)
+	return p, errors.Wrapf(err, "[layered] Key %q", k)
+}
+
+// NewEnvLayer creates the read only layer "env" from all environment
+// variables starting with EnvPrefix, usually os.Environ(). The parts of the
+// path get separated by two underscores and converted to lower case. The
+// first part may define the scope:
+//		CONFIG__WEB__SECURE__BASE_URL              => default/0/web/secure/base_url
+//		CONFIG__DEFAULT__WEB__SECURE__BASE_URL     => default/0/web/secure/base_url
+//		CONFIG__WEBSITES__1__WEB__SECURE__BASE_URL => websites/1/web/secure/base_url
+//		CONFIG__STORES__2__GENERAL__LOCALE__CODE   => stores/2/general/locale/code
+// The values are strings.
+func NewEnvLayer(environ []string) (Layer, error) {
+	st := config.NewInMemoryStore()
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			continue
+		}
+		p, err := parseEnvName(kv[len(EnvPrefix):eq])
+		if err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewEnvLayer Variable %q", kv[:eq])
+		}
+		if err := st.Set(p, kv[eq+1:]); err != nil {
+			return Layer{}, errors.Wrapf(err, "[layered] NewEnvLayer Variable %q", kv[:eq])
+		}
+	}
+	return Layer{Name: "env", Storager: st, ReadOnly: true}, nil
+}
+
+func parseEnvName(name string) (cfgpath.Path, error) {
+	parts := strings.Split(strings.ToLower(name), envSeparator)
+	scp := scope.DefaultTypeID
+	switch parts[0] {
+	case scope.StrDefault.String():
+		parts = parts[1:]
+	case scope.StrWebsites.String(), scope.StrStores.String():
+		if len(parts) < 2 {
+			return cfgpath.Path{}, errors.NewNotValidf("[layered] Missing scope ID in %q", name)
+		}
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return cfgpath.Path{}, errors.NewNotValid(err, "[layered] Invalid scope ID in "+name)
+		}
+		scp = scope.MakeTypeID(scope.FromString(parts[0]), id)
+		parts = parts[2:]
+	}
+	p, err := cfgpath.NewByParts(strings.Join(parts, "/"))
+	if err != nil {
+		return cfgpath.Path{}, errors.Wrapf(err, "[layered] Path of %q", name)
+	}
+	return p.Bind(scp), nil
+}
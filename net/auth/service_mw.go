@@ -47,7 +47,7 @@ func (s *Service) WithAuthentication(next http.Handler) http.Handler {
 			if s.Log.IsDebug() {
 				s.Log.Debug("auth.Service.Authenticate.Failed", log.Err(err), log.Stringer("scope", scpCfg.ScopeID), log.Object("scpCfg", scpCfg), loghttp.Request("request", r))
 			}
-			scpCfg.UnauthorizedHandler(errors.Wrap(err, "[auth] Authentication failed"))
+			scpCfg.UnauthorizedHandler(errors.Wrap(err, "[auth] Authentication failed")).ServeHTTP(w, r)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -21,11 +21,16 @@
 package auth_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/net/auth"
+	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/errors"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func withError() auth.Option {
@@ -59,3 +64,33 @@ func TestMustNew_Website(t *testing.T) {
 	}()
 	_ = auth.MustNew(withError())
 }
+
+func TestService_WithAuthentication_UnauthorizedHandler(t *testing.T) {
+	t.Parallel()
+	cfgSrv, err := config.NewService(config.NewInMemoryStore())
+	require.NoError(t, err)
+	defer func() { assert.NoError(t, cfgSrv.Close()) }()
+
+	srv, err := auth.New(
+		auth.WithRootConfig(cfgSrv),
+		auth.WithResourceACLs(nil, nil),
+		auth.WithSimpleBasicAuth("admin", "s3cr3t", "Config"),
+		auth.WithUnauthorizedHandler(func(err error) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Access denied", http.StatusTeapot)
+			})
+		}),
+	)
+	require.NoError(t, err)
+
+	h := srv.WithAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler must not be called")
+	}))
+	req := httptest.NewRequest("GET", "http://corestore.io/anyroute", nil)
+	req = req.WithContext(scope.WithContext(req.Context(), 0, 0))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Exactly(t, http.StatusTeapot, rec.Code)
+	assert.Exactly(t, "Access denied\n", rec.Body.String())
+}
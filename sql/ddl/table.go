@@ -36,7 +36,8 @@ type Table struct {
 	// Columns all table columns
 	Columns Columns
 	// Listeners specific pre defined listeners which gets dispatches to each
-	// DML statement (SELECT, INSERT, UPDATE or DELETE).
+	// DML statement (SELECT, INSERT, UPDATE or DELETE). The Lifecycle
+	// listeners must be merged into the field LifecycleListeners of a DML type.
 	Listeners dml.ListenerBucket
 	// IsView set to true to mark if the table is a view
 	IsView bool
@@ -81,7 +81,7 @@ func (arg *argument) set(v interface{}) {
 
 func (arg *argument) len() (l int) {
 	switch v := arg.value.(type) {
-	case nil, int, int64, uint64, float64, bool, string, []byte, time.Time, NullString, NullInt64, NullFloat64, NullBool, NullTime:
+	case nil, int, int64, uint64, float64, bool, string, []byte, time.Time, NullString, NullInt64, NullFloat64, NullBool, NullTime, JSON, NullJSON:
 		l = 1
 	case []int:
 		l = len(v)
@@ -311,6 +311,10 @@ func (arg argument) writeTo(w *bytes.Buffer, pos uint) (err error) {
 			}
 			w.WriteByte(')')
 		}
+	case JSON:
+		err = v.writeTo(w)
+	case NullJSON:
+		err = v.writeTo(w)
 	case []byte:
 		err = writeBytes(w, v)
 
@@ -453,6 +457,14 @@ func (arg argument) GoString() string {
 		buf.WriteString(".NullString(")
 		buf.WriteString(v.GoString())
 		buf.WriteByte(')')
+	case JSON:
+		buf.WriteString(".JSON(")
+		buf.WriteString(v.GoString())
+		buf.WriteByte(')')
+	case NullJSON:
+		buf.WriteString(".NullJSON(")
+		buf.WriteString(v.GoString())
+		buf.WriteByte(')')
 	case []NullString:
 		buf.WriteString(".NullStrings(")
 		for i, nv := range v {
@@ -673,6 +685,10 @@ func (as arguments) Interfaces(args ...interface{}) []interface{} {
 			for _, v := range vv {
 				args = v.append(args)
 			}
+		case JSON:
+			args = vv.append(args)
+		case NullJSON:
+			args = vv.append(args)
 
 		case [][]byte:
 			for _, v := range vv {
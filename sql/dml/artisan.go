@@ -59,6 +59,10 @@ type Artisan struct {
 	raw               []interface{}
 	arguments
 	recs []QualifiedRecord
+	// shard contains the current shard of the TableMapper. buildShard rebuilds
+	// the SQL string in the DML type for another shard.
+	shard      string
+	buildShard func(shard string) ([]byte, []string, error)
 }
 
 const (
@@ -178,7 +182,7 @@ func (a *Artisan) prepareArgs(extArgs ...interface{}) (_ string, _ []interface{}
 	}
 
 	if a.Options&argOptionExpandPlaceholder != 0 {
-		if phCount := bytes.Count(sqlBuf.First.Bytes(), placeHolderByte); phCount < a.Len() {
+		if phCount := countPlaceHolderBytes(sqlBuf.First.Bytes()); phCount < a.Len() {
 			if err := expandPlaceHolders(sqlBuf.Second, sqlBuf.First.Bytes(), collectedArgs); err != nil {
 				return "", nil, errors.WithStack(err)
 			}
@@ -458,6 +462,8 @@ func (a *Artisan) NullBool(nv NullBool) *Artisan           { return a.add(nv) }
 func (a *Artisan) NullBools(nv ...NullBool) *Artisan       { return a.add(nv) }
 func (a *Artisan) NullTime(nv NullTime) *Artisan           { return a.add(nv) }
 func (a *Artisan) NullTimes(nv ...NullTime) *Artisan       { return a.add(nv) }
+func (a *Artisan) JSON(j JSON) *Artisan                    { return a.add(j) }
+func (a *Artisan) NullJSON(nv NullJSON) *Artisan           { return a.add(nv) }
 
 // Name sets the name for the following argument. Calling Name two times after
 // each other sets the first call to Name to a NULL value. A call to Name should
@@ -521,13 +527,21 @@ func (a *Artisan) WithDB(db QueryExecPreparer) *Artisan {
 }
 
 // WithTx sets the transaction query executor and the logger to run this query
-// within a transaction.
+// within a transaction. The lifecycle listeners of the transaction replace the
+// listeners the Artisan has inherited, because both got inherited from the
+// same ConnPool. Listeners added to the builder or Artisan itself are kept.
 func (a *Artisan) WithTx(tx *Tx) *Artisan {
 	if a.base.id == "" {
 		a.base.id = tx.makeUniqueID()
 	}
 	a.base.Log = tx.Log
 	a.base.DB = tx.DB
+	own := a.base.LifecycleListeners
+	if n := a.base.inheritedListeners; n <= len(own) {
+		own = own[n:]
+	}
+	a.base.LifecycleListeners = append(tx.LifecycleListeners.clip(), own...)
+	a.base.inheritedListeners = len(tx.LifecycleListeners)
 	return a
 }
 
@@ -587,18 +601,64 @@ func (a *Artisan) ExecContext(ctx context.Context, args ...interface{}) (sql.Res
 	return a.exec(ctx, args...)
 }
 
-// QueryContext traditional way of the databasel/sql package.
+// QueryContext traditional way of the databasel/sql package. The event
+// OnAfterQuery gets dispatched with a zero row count because the rows are
+// getting loaded by the caller.
 func (a *Artisan) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
-	return a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.queryDone(ctx, ev, 0, nil); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	return rows, nil
 }
 
-// QueryRowContext traditional way of the databasel/sql package.
+// QueryRowContext traditional way of the databasel/sql package. The event
+// OnAfterQuery gets dispatched with a zero row count because the row gets
+// scanned by the caller. An error of a listener gets only logged because the
+// query has already been sent. An error before the query, for example a
+// *QueryNotAllowedError of the Firewall, gets returned by the Scan function of
+// the *sql.Row without touching the database.
 func (a *Artisan) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	if err := a.applyShard(ctx); err != nil {
+		return errRow(errors.WithStack(err))
+	}
 	sqlStr, args, err := a.prepareArgs(args...)
 	if a.base.Log != nil && a.base.Log.IsDebug() {
 		defer log.WhenDone(a.base.Log).Debug("QueryRowContext", log.String("sql", sqlStr), log.String("source", string(a.base.source)), log.Err(err))
 	}
-	return a.base.DB.QueryRowContext(ctx, sqlStr, args...)
+	if err != nil {
+		return errRow(errors.WithStack(err))
+	}
+	if err = a.base.firewall.check(a.base.Log, sqlStr); err != nil {
+		return errRow(err)
+	}
+	ev := a.newLifecycleEvent(sqlStr, args)
+	row := a.base.DB.QueryRowContext(ctx, sqlStr, args...)
+	if err := a.queryDone(ctx, ev, 0, nil); err != nil && a.base.Log != nil && a.base.Log.IsInfo() {
+		a.base.Log.Info("dml.Artisan.QueryRowContext.OnAfterQuery", log.String("id", a.base.id), log.Err(err))
+	}
+	return row
+}
+
+// errConnector fails to connect with its error. database/sql provides no other
+// way to create a *sql.Row which carries an error.
+type errConnector struct{ err error }
+
+func (ec errConnector) Connect(context.Context) (driver.Conn, error) { return nil, ec.err }
+func (ec errConnector) Open(string) (driver.Conn, error)             { return nil, ec.err }
+func (ec errConnector) Driver() driver.Driver                        { return ec }
+
+// errRow returns a *sql.Row whose Scan function returns err.
+func errRow(err error) *sql.Row {
+	db := sql.OpenDB(errConnector{err: err})
+	defer db.Close()
+	// The background context makes sure that the row contains err and not
+	// the error of a canceled context.
+	return db.QueryRowContext(context.Background(), "")
 }
 
 // IterateSerial iterates in serial order over the result set by loading one row each
@@ -609,11 +669,15 @@ func (a *Artisan) IterateSerial(ctx context.Context, callBack func(*ColumnMap) e
 		defer log.WhenDone(a.base.Log).Debug("IterateSerial", log.String("id", a.base.id), log.Err(err))
 	}
 
-	r, err := a.query(ctx, args...)
+	r, ev, err := a.query(ctx, args...)
 	if err != nil {
 		err = errors.Wrapf(err, "[dml] IterateSerial.Query with query ID %q", a.base.id)
 		return
 	}
+	var rowCount uint64
+	if ev != nil {
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	cmr := pooledColumnMapGet() // this sync.Pool might not work correctly, write a complex test.
 	defer pooledBufferColumnMapPut(cmr, nil, func() {
 		// Not testable with the sqlmock package :-(
@@ -631,6 +695,7 @@ func (a *Artisan) IterateSerial(ctx context.Context, callBack func(*ColumnMap) e
 			err = errors.WithStack(err)
 			return
 		}
+		rowCount++
 	}
 	err = errors.WithStack(r.Err())
 	return
@@ -639,7 +704,7 @@ func (a *Artisan) IterateSerial(ctx context.Context, callBack func(*ColumnMap) e
 // iterateParallelForNextLoop has been extracted from IterateParallel to not
 // mess around with closing channels in different locations of the source code
 // when an error occurs.
-func iterateParallelForNextLoop(r *sql.Rows, rowChan chan<- *ColumnMap, errChan <-chan error) (err error) {
+func iterateParallelForNextLoop(r *sql.Rows, rowChan chan<- *ColumnMap, errChan <-chan error) (rowCount uint64, err error) {
 	defer func() {
 		if err2 := r.Err(); err2 != nil && err == nil {
 			err = errors.WithStack(err)
@@ -649,14 +714,13 @@ func iterateParallelForNextLoop(r *sql.Rows, rowChan chan<- *ColumnMap, errChan
 		}
 	}()
 
-	var idx uint64
 	for r.Next() {
 		var cm ColumnMap // must be empty because we're not collecting data
 		if errS := cm.Scan(r); errS != nil {
 			err = errors.WithStack(errS)
 			return
 		}
-		cm.Count = idx
+		cm.Count = rowCount
 		select {
 		case rowChan <- &cm:
 		case errC := <-errChan:
@@ -665,7 +729,7 @@ func iterateParallelForNextLoop(r *sql.Rows, rowChan chan<- *ColumnMap, errChan
 			}
 			return
 		}
-		idx++
+		rowCount++
 	}
 	return
 }
@@ -684,11 +748,15 @@ func (a *Artisan) IterateParallel(ctx context.Context, concurrencyLevel int, cal
 		return errors.OutofRange.Newf("[dml] Artisan.IterateParallel concurrencyLevel %d for query ID %q cannot be smaller zero.", concurrencyLevel, a.base.id)
 	}
 
-	r, err := a.query(ctx, args...)
+	r, ev, err := a.query(ctx, args...)
 	if err != nil {
 		err = errors.Wrapf(err, "[dml] IterateParallel.Query with query ID %q", a.base.id)
 		return
 	}
+	var rowCount uint64
+	if ev != nil {
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 
 	// start workers and a channel for communicating
 	rowChan := make(chan *ColumnMap)
@@ -708,7 +776,8 @@ func (a *Artisan) IterateParallel(ctx context.Context, concurrencyLevel int, cal
 		}(&wg, rowChan, errChan)
 	}
 
-	if err2 := iterateParallelForNextLoop(r, rowChan, errChan); err2 != nil {
+	rowCount, err2 := iterateParallelForNextLoop(r, rowChan, errChan)
+	if err2 != nil {
 		err = err2
 	}
 	close(rowChan)
@@ -738,11 +807,15 @@ func (a *Artisan) Load(ctx context.Context, s ColumnMapper, args ...interface{})
 		defer log.WhenDone(a.base.Log).Debug("Load", log.String("id", a.base.id), log.Err(err), log.ObjectTypeOf("ColumnMapper", s), log.Uint64("row_count", rowCount))
 	}
 
-	r, err := a.query(ctx, args...)
+	r, ev, err := a.query(ctx, args...)
 	if err != nil {
 		err = errors.Wrapf(err, "[dml] Artisan.Load.QueryContext failed with queryID %q and ColumnMapper %T", a.base.id, s)
 		return
 	}
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	cm := pooledColumnMapGet()
 	defer pooledBufferColumnMapPut(cm, nil, func() {
 		// Not testable with the sqlmock package :-(
@@ -780,7 +853,16 @@ func (a *Artisan) LoadInt64(ctx context.Context, args ...interface{}) (int64, er
 	if a.base.Log != nil && a.base.Log.IsDebug() {
 		defer log.WhenDone(a.base.Log).Debug("LoadInt64")
 	}
-	return loadInt64(a.query(ctx, args...))
+	rows, ev, err := a.query(ctx, args...)
+	value, err := loadInt64(rows, err)
+	if ev == nil {
+		return value, err
+	}
+	var rowCount uint64
+	if err == nil {
+		rowCount = 1
+	}
+	return value, a.queryDone(ctx, ev, rowCount, err)
 }
 
 // LoadInt64s executes the Select and returns the value as a slice of
@@ -790,7 +872,11 @@ func (a *Artisan) LoadInt64s(ctx context.Context, args ...interface{}) (ret []in
 		// do not use fullSQL because we might log sensitive data
 		defer log.WhenDone(a.base.Log).Debug("LoadInt64s", log.Int("row_count", len(ret)), log.Err(err))
 	}
-	ret, err = loadInt64s(a.query(ctx, args...))
+	rows, ev, err := a.query(ctx, args...)
+	ret, err = loadInt64s(rows, err)
+	if ev != nil {
+		err = a.queryDone(ctx, ev, uint64(len(ret)), err)
+	}
 	// Do not simplify it because we need ret in the defer. we don't log errors
 	// because they get handled.
 	return
@@ -805,10 +891,15 @@ func (a *Artisan) LoadUint64(ctx context.Context, args ...interface{}) (_ uint64
 		defer log.WhenDone(a.base.Log).Debug("LoadUint64", log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return 0, errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -822,6 +913,7 @@ func (a *Artisan) LoadUint64(ctx context.Context, args ...interface{}) (_ uint64
 			return 0, errors.WithStack(err)
 		}
 		found = true
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return 0, errors.WithStack(err)
@@ -839,10 +931,15 @@ func (a *Artisan) LoadUint64s(ctx context.Context, args ...interface{}) (values
 		defer log.WhenDone(a.base.Log).Debug("LoadUint64s", log.Int("row_count", len(values)), log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -856,6 +953,7 @@ func (a *Artisan) LoadUint64s(ctx context.Context, args ...interface{}) (values
 			return nil, errors.WithStack(err)
 		}
 		values = append(values, value)
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return nil, errors.WithStack(err)
@@ -871,10 +969,15 @@ func (a *Artisan) LoadFloat64(ctx context.Context, args ...interface{}) (_ float
 		defer log.WhenDone(a.base.Log).Debug("LoadFloat64", log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return 0, errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -888,6 +991,7 @@ func (a *Artisan) LoadFloat64(ctx context.Context, args ...interface{}) (_ float
 			return 0, errors.WithStack(err)
 		}
 		found = true
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return 0, errors.WithStack(err)
@@ -905,10 +1009,15 @@ func (a *Artisan) LoadFloat64s(ctx context.Context, args ...interface{}) (_ []fl
 		defer log.WhenDone(a.base.Log).Debug("LoadFloat64s", log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -922,6 +1031,7 @@ func (a *Artisan) LoadFloat64s(ctx context.Context, args ...interface{}) (_ []fl
 			return nil, errors.WithStack(err)
 		}
 		values = append(values, value)
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return nil, errors.WithStack(err)
@@ -937,10 +1047,15 @@ func (a *Artisan) LoadString(ctx context.Context, args ...interface{}) (_ string
 		defer log.WhenDone(a.base.Log).Debug("LoadString", log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return "", errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -954,6 +1069,7 @@ func (a *Artisan) LoadString(ctx context.Context, args ...interface{}) (_ string
 			return "", errors.WithStack(err)
 		}
 		found = true
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return "", errors.WithStack(err)
@@ -971,10 +1087,15 @@ func (a *Artisan) LoadStrings(ctx context.Context /* , result []string */, args
 		defer log.WhenDone(a.base.Log).Debug("LoadStrings", log.Int("row_count", len(values)), log.String("id", a.base.id), log.Err(err))
 	}
 
-	rows, err := a.query(ctx, args...)
+	rows, ev, err := a.query(ctx, args...)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	var rowCount uint64
+	if ev != nil {
+		// runs after closing the rows
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
 	defer func() {
 		if errC := rows.Close(); err == nil && errC != nil {
 			err = errors.WithStack(errC)
@@ -988,6 +1109,7 @@ func (a *Artisan) LoadStrings(ctx context.Context /* , result []string */, args
 			return nil, errors.WithStack(err)
 		}
 		values = append(values, value)
+		rowCount++
 	}
 	if err = rows.Err(); err != nil {
 		return nil, errors.WithStack(err)
@@ -995,19 +1117,54 @@ func (a *Artisan) LoadStrings(ctx context.Context /* , result []string */, args
 	return values, err
 }
 
-func (a *Artisan) query(ctx context.Context, args ...interface{}) (rows *sql.Rows, err error) {
+// applyShard switches the SQL string to the shard returned by the ShardFn of
+// the TableMapper. It rebuilds the cached SQL only when the shard changes and
+// resets the cached named arguments and INSERT statement. A prepared statement
+// cannot switch its shard.
+func (a *Artisan) applyShard(ctx context.Context) error {
+	if a.buildShard == nil || a.isPrepared {
+		return nil
+	}
+	shard := a.base.tableMapper.ShardFn(ctx)
+	if shard == a.shard {
+		return nil
+	}
+	sqlBytes, qualifiedColumns, err := a.buildShard(shard)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	a.shard = shard
+	a.base.cachedSQL = sqlBytes
+	a.base.qualifiedColumns = qualifiedColumns
+	a.hasNamedArgs = 0
+	a.insertCachedSQL = a.insertCachedSQL[:0]
+	return nil
+}
+
+// query executes the query. The returned LifecycleEvent is nil, if no
+// listeners have been set or if an error occurred. The caller must dispatch
+// OnAfterQuery via queryDone when the LifecycleEvent is not nil.
+func (a *Artisan) query(ctx context.Context, args ...interface{}) (rows *sql.Rows, ev *LifecycleEvent, err error) {
+	if err = a.applyShard(ctx); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
 	sqlStr, args, err2 := a.prepareArgs(args...)
 	err = err2
 	if a.base.Log != nil && a.base.Log.IsDebug() {
 		defer log.WhenDone(a.base.Log).Debug("Query", log.String("sql", sqlStr), log.String("source", string(a.base.source)), log.Err(err))
 	}
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, nil, errors.WithStack(err)
+	}
+	if err = a.base.firewall.check(a.base.Log, sqlStr); err != nil {
+		return nil, nil, err
 	}
 
+	ev = a.newLifecycleEvent(sqlStr, args)
 	rows, err = a.base.DB.QueryContext(ctx, sqlStr, args...)
 	if err != nil {
-		err = errors.Wrapf(err, "[dml] Query.QueryContext with query %q", sqlStr)
+		err = a.lifecycleDone(ctx, ev, OnAfterQuery, errors.Wrapf(err, "[dml] Query.QueryContext with query %q", sqlStr))
+		return nil, nil, err
 	}
 	return
 }
@@ -1064,6 +1221,9 @@ func loadInt64s(rows *sql.Rows, errIn error) (_ []int64, err error) {
 }
 
 func (a *Artisan) exec(ctx context.Context, args ...interface{}) (result sql.Result, err error) {
+	if err = a.applyShard(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	sqlStr, args, err2 := a.prepareArgs(args...)
 	err = err2
 	if a.base.Log != nil && a.base.Log.IsDebug() {
@@ -1072,12 +1232,31 @@ func (a *Artisan) exec(ctx context.Context, args ...interface{}) (result sql.Res
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if err = a.base.firewall.check(a.base.Log, sqlStr); err != nil {
+		return nil, err
+	}
+
+	ev := a.newLifecycleEvent(sqlStr, args)
+	if ev != nil {
+		ev.EventType = OnBeforeExec
+		if err = a.base.LifecycleListeners.dispatch(ctx, ev); err != nil {
+			return nil, err
+		}
+		ev.start = now()
+	}
 
 	result, err = a.base.DB.ExecContext(ctx, sqlStr, args...)
 	if err != nil {
 		err = errors.Wrapf(err, "[dml] ExecContext with query %q", sqlStr) // err gets catched by the defer
+		err = a.lifecycleDone(ctx, ev, OnAfterExec, err)
 		return
 	}
+	if ev != nil {
+		ev.Result = result
+		if err = a.lifecycleDone(ctx, ev, OnAfterExec, nil); err != nil {
+			return
+		}
+	}
 
 	if a.recs == nil {
 		return result, nil
@@ -96,6 +96,23 @@ type builderCommon struct {
 	// dedicated database session) or a *sql.Tx (an in-progress database
 	// transaction).
 	DB QueryExecPreparer
+	// LifecycleListeners get called by the Artisan before and after the
+	// execution of the statement. They are getting copied to the Artisan.
+	LifecycleListeners ListenersLifecycle
+	// inheritedListeners counts the leading LifecycleListeners inherited from
+	// the ConnPool, Conn or Tx. Artisan.WithTx replaces only those.
+	inheritedListeners int
+	// tableMapper rewrites the logical table names into physical names. Gets
+	// inherited from the ConnPool.
+	tableMapper *TableMapper
+	// tableFilters get applied to the conditions. Gets inherited from the
+	// ConnPool.
+	tableFilters []*TableFilter
+	// stmtCache caches the prepared statements of the ConnPool or Conn.
+	stmtCache *StmtCache
+	// firewall checks the fingerprint of each statement. Gets inherited from
+	// the ConnPool.
+	firewall *Firewall
 	// IsBuildCacheDisabled disable the caching and destroying of the DML statement objects
 	IsBuildCacheDisabled bool // see DisableBuildCache()
 	// EstimatedCachedSQLSize specifies the estimated size in bytes of the final
@@ -133,6 +150,13 @@ type BuilderBase struct {
 	propagationStoppedAt int
 
 	rwmu sync.RWMutex // also protects the whole SQL string building process
+	// shardCachedSQL contains the final SQL string per shard when the
+	// TableMapper has a ShardFn.
+	shardCachedSQL map[string][]byte
+	// filtersDisabled and disabledFilters contain the opt-out of the table
+	// filters, see WithoutFilters.
+	filtersDisabled bool
+	disabledFilters []string
 	builderCommon
 }
 
@@ -144,17 +168,14 @@ func (bb *BuilderBase) buildToSQL(qb queryBuilder) ([]byte, error) {
 	if bb.ärgErr != nil {
 		return nil, errors.WithStack(bb.ärgErr)
 	}
+	if bb.tableMapper.isSharded() {
+		return bb.buildShardToSQL(qb, "")
+	}
 	rawSQL := qb.readBuildCache()
 	if rawSQL == nil || bb.IsBuildCacheDisabled {
 
-		// Pre allocating that with a decent size, can speed up writing due to
-		// less re-slicing / buffer.Grow.
-		size := bb.EstimatedCachedSQLSize
-		if size == 0 {
-			size = estimatedCachedSQLSize
-		}
-		buf := bytes.NewBuffer(make([]byte, 0, size))
-		qualifiedColumns, err := qb.toSQL(buf, []string{})
+		buf := bb.newBuildBuffer()
+		qualifiedColumns, err := bb.rewriteToSQL(qb, "", buf)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -168,6 +189,42 @@ func (bb *BuilderBase) buildToSQL(qb queryBuilder) ([]byte, error) {
 	return rawSQL, nil
 }
 
+// newBuildBuffer pre allocates a buffer with a decent size, which can speed up
+// writing due to less re-slicing / buffer.Grow.
+func (bb *BuilderBase) newBuildBuffer() *bytes.Buffer {
+	size := bb.EstimatedCachedSQLSize
+	if size == 0 {
+		size = estimatedCachedSQLSize
+	}
+	return bytes.NewBuffer(make([]byte, 0, size))
+}
+
+// buildShardToSQL builds the SQL string with the table names of a shard. Each
+// shard has its own cache entry and the statement data of the DML type does
+// not get discarded to allow building of other shards. An empty shard
+// represents the default schema of the TableMapper.
+func (bb *BuilderBase) buildShardToSQL(qb queryBuilder, shard string) ([]byte, error) {
+	if bb.ärgErr != nil {
+		return nil, errors.WithStack(bb.ärgErr)
+	}
+	if rawSQL, ok := bb.shardCachedSQL[shard]; ok && !bb.IsBuildCacheDisabled {
+		return rawSQL, nil
+	}
+	buf := bb.newBuildBuffer()
+	qualifiedColumns, err := bb.rewriteToSQL(qb, shard, buf)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	bb.qualifiedColumns = qualifiedColumns
+	if !bb.IsBuildCacheDisabled {
+		if bb.shardCachedSQL == nil {
+			bb.shardCachedSQL = make(map[string][]byte, 4)
+		}
+		bb.shardCachedSQL[shard] = buf.Bytes()
+	}
+	return buf.Bytes(), nil
+}
+
 func (bb *BuilderBase) prepare(ctx context.Context, db Preparer, qb queryBuilder, source rune) (_ *Stmt, err error) {
 	var rawQuery []byte
 	rawQuery, err = bb.buildToSQL(qb)
@@ -177,6 +234,12 @@ func (bb *BuilderBase) prepare(ctx context.Context, db Preparer, qb queryBuilder
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if err = bb.firewall.check(bb.Log, string(rawQuery)); err != nil {
+		return nil, err
+	}
+	if bb.stmtCache.isCacheFor(db) {
+		return bb.prepareCached(ctx, rawQuery, source)
+	}
 	sqlStmt, err := db.PrepareContext(ctx, string(rawQuery))
 	if err != nil {
 		return nil, errors.Wrapf(err, "[dml] Prepare.PrepareContext with query %q", rawQuery)
@@ -192,6 +255,22 @@ func (bb *BuilderBase) prepare(ctx context.Context, db Preparer, qb queryBuilder
 	return stmt, nil
 }
 
+// prepareCached returns a Stmt whose *sql.Stmt gets managed by the StmtCache.
+func (bb *BuilderBase) prepareCached(ctx context.Context, rawQuery []byte, source rune) (*Stmt, error) {
+	query := string(rawQuery)
+	if _, err := bb.stmtCache.get(ctx, query, true); err != nil {
+		return nil, errors.Wrapf(err, "[dml] Prepare.StmtCache with query %q", rawQuery)
+	}
+	stmt := &Stmt{
+		base:  bb.builderCommon,
+		cache: bb.stmtCache,
+	}
+	stmt.base.cachedSQL = rawQuery
+	stmt.base.DB = stmtWrapper{stmt: cachedStmt{cache: bb.stmtCache, query: query}}
+	stmt.base.source = source
+	return stmt, nil
+}
+
 func (bb *BuilderBase) readBuildCache() (sql []byte) {
 	sql = bb.cachedSQL
 	return sql
@@ -210,6 +289,14 @@ func (bb *BuilderBase) withArtisan(qb queryBuilder) *Artisan {
 	bb.rwmu.Unlock()
 	a.base.cachedSQL = sqlBytes
 	a.base.ärgErr = errors.WithStack(err)
+	if bb.tableMapper.isSharded() {
+		a.buildShard = func(shard string) ([]byte, []string, error) {
+			bb.rwmu.Lock()
+			defer bb.rwmu.Unlock()
+			sqlBytes, err := bb.buildShardToSQL(qb, shard)
+			return sqlBytes, bb.qualifiedColumns, err
+		}
+	}
 	return &a
 }
 
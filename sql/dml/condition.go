@@ -709,6 +709,24 @@ func (c *Condition) NullTimes(nv ...NullTime) *Condition {
 	return c
 }
 
+func (c *Condition) JSON(j JSON) *Condition {
+	if c.isExpression() {
+		c.Right.args = c.Right.args.add(j)
+		return c
+	}
+	c.Right.arg.set(j)
+	return c
+}
+
+func (c *Condition) NullJSON(nv NullJSON) *Condition {
+	if c.isExpression() {
+		c.Right.args = c.Right.args.add(nv)
+		return c
+	}
+	c.Right.arg.set(nv)
+	return c
+}
+
 // Values only usable in case for ON DUPLICATE KEY to generate a statement like:
 //		column=VALUES(column)
 func (c *Condition) Values() *Condition {
@@ -837,14 +855,26 @@ func (cs Conditions) write(w *bytes.Buffer, conditionType byte, placeHolders []s
 			}
 
 			// Only write the operator in case there is no place holder and we
-			// have one value.
+			// have one value. Without an operator the value gets compared
+			// like a column, otherwise the argument would get lost.
 			switch {
-			case phCount == 0 && (lenArgs == 1 || cnd.Right.arg.isSet) && cnd.Operator > 0:
+			case phCount == 0 && (lenArgs == 1 || cnd.Right.arg.isSet):
 				eArg := cnd.Right.arg
 				if !eArg.isSet {
 					eArg = cnd.Right.args[0]
 				}
-				cnd.Operator.write(w, arguments{eArg})
+				if eArg.len() > 1 && cnd.Operator == 0 { // no operator but slice applied, so creating an IN query.
+					cnd.Operator = In
+				}
+				if err = cnd.Operator.write(w, arguments{eArg}); err != nil {
+					return nil, errors.WithStack(err)
+				}
+
+			case phCount == 0 && cnd.Right.PlaceHolder != "" && cnd.Operator > 0:
+				if err = cnd.Operator.write(w, nil); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				placeHolders = cnd.writePlaceHolder(w, placeHolders)
 
 			case cnd.Right.Sub != nil:
 				if err = cnd.Operator.write(w, nil); err != nil {
@@ -908,22 +938,7 @@ func (cs Conditions) write(w *bytes.Buffer, conditionType byte, placeHolders []s
 			if err = cnd.Operator.write(w, nil); err != nil {
 				return nil, errors.WithStack(err)
 			}
-
-			switch {
-			case cnd.Right.PlaceHolder == placeHolderStr:
-				placeHolders = append(placeHolders, cnd.Left)
-				w.WriteByte(placeHolderRune)
-			case isNamedArg(cnd.Right.PlaceHolder):
-				w.WriteByte(placeHolderRune)
-				ph := cnd.Right.PlaceHolder
-				if !strings.HasPrefix(cnd.Right.PlaceHolder, namedArgStartStr) {
-					ph = namedArgStartStr + ph
-				}
-				placeHolders = append(placeHolders, ph)
-			default:
-				placeHolders = append(placeHolders, cnd.Left)
-				w.WriteString(cnd.Right.PlaceHolder)
-			}
+			placeHolders = cnd.writePlaceHolder(w, placeHolders)
 
 		case !cnd.Right.arg.isSet && lenArgs == 0: // No Argument at all, which kinda is the default case
 			Quoter.WriteIdentifier(w, cnd.Left)
@@ -945,6 +960,27 @@ func (cs Conditions) write(w *bytes.Buffer, conditionType byte, placeHolders []s
 	return placeHolders, errors.WithStack(err)
 }
 
+// writePlaceHolder writes the place holder of the right hand side and appends
+// the name of the column or the named argument to placeHolders.
+func (c *Condition) writePlaceHolder(w *bytes.Buffer, placeHolders []string) []string {
+	switch {
+	case c.Right.PlaceHolder == placeHolderStr:
+		placeHolders = append(placeHolders, c.Left)
+		w.WriteByte(placeHolderRune)
+	case isNamedArg(c.Right.PlaceHolder):
+		w.WriteByte(placeHolderRune)
+		ph := c.Right.PlaceHolder
+		if !strings.HasPrefix(c.Right.PlaceHolder, namedArgStartStr) {
+			ph = namedArgStartStr + ph
+		}
+		placeHolders = append(placeHolders, ph)
+	default:
+		placeHolders = append(placeHolders, c.Left)
+		w.WriteString(c.Right.PlaceHolder)
+	}
+	return placeHolders
+}
+
 func (cs Conditions) writeSetClauses(w *bytes.Buffer, placeHolders []string) ([]string, error) {
 	for i, cnd := range cs {
 		if i > 0 {
@@ -1018,22 +1054,7 @@ func (cs Conditions) writeOnDuplicateKey(w *bytes.Buffer, placeHolders []string)
 			writeExpression(w, cnd.Right.Column, cnd.Right.args)
 
 		case cnd.Right.PlaceHolder != "":
-
-			switch {
-			case cnd.Right.PlaceHolder == placeHolderStr:
-				placeHolders = append(placeHolders, cnd.Left)
-				w.WriteByte(placeHolderRune)
-			case isNamedArg(cnd.Right.PlaceHolder):
-				w.WriteByte(placeHolderRune)
-				ph := cnd.Right.PlaceHolder
-				if !strings.HasPrefix(cnd.Right.PlaceHolder, namedArgStartStr) {
-					ph = namedArgStartStr + ph
-				}
-				placeHolders = append(placeHolders, ph)
-			default:
-				placeHolders = append(placeHolders, cnd.Left)
-				w.WriteString(cnd.Right.PlaceHolder)
-			}
+			placeHolders = cnd.writePlaceHolder(w, placeHolders)
 
 		case !cnd.Right.arg.isSet:
 			writeValues(w, cnd.Left)
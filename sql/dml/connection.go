@@ -42,6 +42,40 @@ type logWithID struct {
 	// into the SQL string. The returned string must not contain the
 	// comment-end-termination pattern: `*/`.
 	makeUniqueID uniqueIDFn
+	// LifecycleListeners get inherited to Conn, Tx, all DML types and to the
+	// Artisan created by WithQueryBuilder. A Tx dispatches OnTxCommit and
+	// OnTxRollback.
+	LifecycleListeners ListenersLifecycle
+	// tableMapper gets inherited to Conn, Tx and all DML types. See
+	// WithTableMapper.
+	tableMapper *TableMapper
+	// tableFilters get inherited to Conn, Tx and all DML types. See
+	// WithTableFilters.
+	tableFilters []*TableFilter
+	// txRetry gets inherited to Conn. See WithTxRetryPolicy.
+	txRetry *TxRetryPolicy
+	// stmtCache gets inherited to Tx and all DML types. A Conn creates its
+	// own cache. See WithStmtCache.
+	stmtCache *StmtCache
+	// firewall gets inherited to Conn, Tx and all DML types. See
+	// WithFirewall.
+	firewall *Firewall
+}
+
+// newBuilderCommon creates the common part of a DML type with all settings
+// inherited from the ConnPool, Conn or Tx.
+func (lw *logWithID) newBuilderCommon(db QueryExecPreparer, id string, l log.Logger) builderCommon {
+	return builderCommon{
+		id:                 id,
+		Log:                l,
+		DB:                 db,
+		LifecycleListeners: lw.LifecycleListeners.clip(),
+		inheritedListeners: len(lw.LifecycleListeners),
+		tableMapper:        lw.tableMapper,
+		tableFilters:       lw.tableFilters,
+		stmtCache:          lw.stmtCache,
+		firewall:           lw.firewall,
+	}
 }
 
 // ConnPool at a connection to the database with an EventReceiver to send
@@ -78,7 +112,11 @@ type Conn struct {
 // Practical Guide to SQL Transaction Isolation: https://begriffs.com/posts/2017-08-01-practical-guide-sql-isolation.html
 type Tx struct {
 	logWithID
+	id string
 	DB *sql.Tx
+	// savepointSeq generates the names of the savepoints of nested
+	// transactions.
+	savepointSeq uint32
 }
 
 // ConnPoolOption can be used at an argument in NewConnPool to configure a
@@ -91,8 +129,8 @@ type ConnPoolOption struct {
 	// Sort Order 8.
 	UniqueIDFn func() string
 	// TableNameMapper maps the old name in the DML query to a new name. E.g.
-	// for adding a prefix and/or a suffix.
-	// TODO implement TableNameMapper
+	// for adding a prefix and/or a suffix. Sets the field MapFn of the
+	// TableMapper, see WithTableMapper. Sort Order 14.
 	TableNameMapper func(oldName string) (newName string)
 	// OptimisticLock is enabled all queries with Exec will have a `version` field.
 	// UPDATE user SET ..., version = version + 1 WHERE id = ? AND version = ?
@@ -126,6 +164,24 @@ func WithLogger(l log.Logger, uniqueIDFn func() string) ConnPoolOption {
 	}
 }
 
+// WithLifecycleListeners adds the lifecycle listeners of the buckets to the
+// connection pool. The listeners get inherited to Conn, Tx, all DML types and
+// the Artisan created by WithQueryBuilder. Use the field LifecycleListeners of
+// a DML type to add listeners to a single statement, e.g. the listeners of a
+// ddl.Table.
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithLifecycleListeners(auditBucket))
+func WithLifecycleListeners(buckets ...*ListenerBucket) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 12,
+		fn: func(c *ConnPool) error {
+			for _, b := range buckets {
+				c.LifecycleListeners.Merge(b.Clone().Lifecycle)
+			}
+			return nil
+		},
+	}
+}
+
 // WithDB sets the DB value to an existing connection. Mainly used for testing.
 // Does not support DriverCallBack.
 func WithDB(db *sql.DB) ConnPoolOption {
@@ -225,6 +281,17 @@ func (c *ConnPool) Options(opts ...ConnPoolOption) error {
 				return nil
 			}
 		}
+		if opt.TableNameMapper != nil {
+			opts[i].sortOrder = 14
+			opt := opt
+			opts[i].fn = func(cp *ConnPool) error {
+				if cp.tableMapper == nil {
+					cp.tableMapper = new(TableMapper)
+				}
+				cp.tableMapper.MapFn = opt.TableNameMapper
+				return nil
+			}
+		}
 	}
 
 	// SliceStable must be stable to maintain the order of all options where
@@ -245,14 +312,21 @@ func (c *ConnPool) Options(opts ...ConnPoolOption) error {
 //
 // It is rare to Close a DB, as the DB handle is meant to be long-lived and
 // shared between many goroutines. It logs the time taken, if a logger has been
-// set with Info logging enabled.
+// set with Info logging enabled. All statements of the StmtCache get closed.
 func (c *ConnPool) Close() error {
 	if c.Log != nil && c.Log.IsDebug() {
 		defer c.Log.Debug("Close", log.Duration("duration", now().Sub(c.start)))
 	}
+	_ = c.stmtCache.Close()
 	return c.DB.Close() // no stack wrap otherwise error is hard to compare
 }
 
+// StmtCacheStats returns the metrics of the prepared statement cache. Returns
+// zero values if WithStmtCache has not been applied.
+func (c *ConnPool) StmtCacheStats() StmtCacheStats {
+	return c.stmtCache.Stats()
+}
+
 // BeginTx starts a transaction.
 //
 // The provided context is used until the transaction is committed or rolled
@@ -272,19 +346,27 @@ func (c *ConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	id := c.makeUniqueID()
 	l := c.Log
 	if l != nil {
-		l = l.With(log.String("tx_id", c.makeUniqueID()))
+		l = l.With(log.String("tx_id", id))
 		if l.IsDebug() {
 			l.Debug("BeginTx")
 		}
 	}
 	return &Tx{
 		logWithID: logWithID{
-			start:        start,
-			Log:          l,
-			makeUniqueID: c.makeUniqueID,
+			start:              start,
+			Log:                l,
+			makeUniqueID:       c.makeUniqueID,
+			LifecycleListeners: c.LifecycleListeners.clip(),
+			tableMapper:        c.tableMapper,
+			tableFilters:       c.tableFilters,
+			txRetry:            c.txRetry,
+			stmtCache:          c.stmtCache,
+			firewall:           c.firewall,
 		},
+		id: id,
 		DB: dbTx,
 	}, nil
 }
@@ -305,22 +387,20 @@ func (c *ConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error
 //           panic(err.Error()) // you could gracefully handle the error also
 //      }
 // It logs the time taken, if a logger has been set with Debug logging enabled.
-// The provided context gets used only for starting the transaction.
+// The provided context gets used only for starting the transaction. With a
+// TxRetryPolicy a deadlocked transaction gets repeated, see WithTxRetryPolicy.
 func (c *ConnPool) Transaction(ctx context.Context, opts *sql.TxOptions, fns ...func(*Tx) error) error {
+	return c.txRetry.run(ctx, c.Log, func() error {
+		return c.transaction(ctx, opts, fns)
+	})
+}
+
+func (c *ConnPool) transaction(ctx context.Context, opts *sql.TxOptions, fns []func(*Tx) error) error {
 	tx, err := c.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
-	for i, f := range fns {
-		if err := f(tx); err != nil {
-			err = errors.Wrapf(err, "[dml] ConnPool.Transaction.error at index %d", i)
-			if rErr := tx.Rollback(); rErr != nil {
-				err = errors.Wrapf(rErr, "[dml] ConnPool.Transaction.Rollback.error at index %d", i)
-			}
-			return err
-		}
-	}
-	return errors.WithStack(tx.Commit())
+	return tx.run(fns)
 }
 
 // WithQueryBuilder creates a new Artisan for handling the arguments with the
@@ -331,11 +411,14 @@ func (c *ConnPool) WithQueryBuilder(qb QueryBuilder) *Artisan {
 	var args [defaultArgumentsCapacity]argument
 	return &Artisan{
 		base: builderCommon{
-			cachedSQL: []byte(sqlStr),
-			Log:       c.Log,
-			id:        c.makeUniqueID(),
-			DB:        c.DB,
-			ärgErr:    errors.WithStack(err),
+			cachedSQL:          []byte(sqlStr),
+			Log:                c.Log,
+			id:                 c.makeUniqueID(),
+			DB:                 c.DB,
+			LifecycleListeners: c.LifecycleListeners.clip(),
+			inheritedListeners: len(c.LifecycleListeners),
+			firewall:           c.firewall,
+			ärgErr:             errors.WithStack(err),
 		},
 		raw:       argsRaw,
 		arguments: args[:0],
@@ -355,11 +438,21 @@ func (c *ConnPool) Conn(ctx context.Context) (*Conn, error) {
 	if l != nil {
 		l = c.Log.With(log.String("conn_id", c.makeUniqueID()))
 	}
+	var sc *StmtCache
+	if c.stmtCache != nil && dbc != nil {
+		sc = newStmtCache(dbc, c.stmtCache.maxSize)
+	}
 	return &Conn{
 		logWithID: logWithID{
-			start:        now(),
-			Log:          l,
-			makeUniqueID: c.makeUniqueID,
+			start:              now(),
+			Log:                l,
+			makeUniqueID:       c.makeUniqueID,
+			LifecycleListeners: c.LifecycleListeners.clip(),
+			tableMapper:        c.tableMapper,
+			tableFilters:       c.tableFilters,
+			txRetry:            c.txRetry,
+			stmtCache:          sc,
+			firewall:           c.firewall,
 		},
 		DB: dbc,
 	}, errors.WithStack(err)
@@ -382,19 +475,27 @@ func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
+	id := c.makeUniqueID()
 	l := c.Log
 	if l != nil {
-		l = l.With(log.String("tx_id", c.makeUniqueID()))
+		l = l.With(log.String("tx_id", id))
 		if l.IsDebug() {
 			l.Debug("BeginTx")
 		}
 	}
 	return &Tx{
 		logWithID: logWithID{
-			start:        start,
-			Log:          l,
-			makeUniqueID: c.makeUniqueID,
+			start:              start,
+			Log:                l,
+			makeUniqueID:       c.makeUniqueID,
+			LifecycleListeners: c.LifecycleListeners.clip(),
+			tableMapper:        c.tableMapper,
+			tableFilters:       c.tableFilters,
+			txRetry:            c.txRetry,
+			stmtCache:          c.stmtCache,
+			firewall:           c.firewall,
 		},
+		id: id,
 		DB: dbTx,
 	}, nil
 }
@@ -415,22 +516,20 @@ func (c *Conn) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
 //           panic(err.Error()) // you could gracefully handle the error also
 //      }
 // It logs the time taken, if a logger has been set with Debug logging enabled.
-// The provided context gets used only for starting the transaction.
+// The provided context gets used only for starting the transaction. With a
+// TxRetryPolicy a deadlocked transaction gets repeated, see WithTxRetryPolicy.
 func (c *Conn) Transaction(ctx context.Context, opts *sql.TxOptions, fns ...func(*Tx) error) error {
+	return c.txRetry.run(ctx, c.Log, func() error {
+		return c.transaction(ctx, opts, fns)
+	})
+}
+
+func (c *Conn) transaction(ctx context.Context, opts *sql.TxOptions, fns []func(*Tx) error) error {
 	tx, err := c.BeginTx(ctx, opts)
 	if err != nil {
 		return err
 	}
-	for i, f := range fns {
-		if err := f(tx); err != nil {
-			err = errors.Wrapf(err, "[dml] ConnPool.Transaction.error at index %d", i)
-			if rErr := tx.Rollback(); rErr != nil {
-				err = errors.Wrapf(rErr, "[dml] ConnPool.Transaction.Rollback.error at index %d", i)
-			}
-			return err
-		}
-	}
-	return errors.WithStack(tx.Commit())
+	return tx.run(fns)
 }
 
 // Close returns the connection to the connection pool. All operations after a
@@ -438,13 +537,21 @@ func (c *Conn) Transaction(ctx context.Context, opts *sql.TxOptions, fns ...func
 // other operations and will block until all other operations finish. It may be
 // useful to first cancel any used context and then call close directly after.
 // It logs the time taken, if a logger has been set with Info logging enabled.
+// All statements of the StmtCache get closed.
 func (c *Conn) Close() error {
 	if c.Log != nil && c.Log.IsDebug() {
 		defer c.Log.Debug("Close", log.Duration("duration", now().Sub(c.start)))
 	}
+	_ = c.stmtCache.Close()
 	return c.DB.Close() // no stack wrap otherwise error is hard to compare
 }
 
+// StmtCacheStats returns the metrics of the prepared statement cache of the
+// connection. Returns zero values if WithStmtCache has not been applied.
+func (c *Conn) StmtCacheStats() StmtCacheStats {
+	return c.stmtCache.Stats()
+}
+
 // WithQueryBuilder creates a new Artisan for handling the arguments with the
 // assigned connection and builds the SQL string. The returned arguments and
 // errors of the QueryBuilder will be forwarded to the Artisan type.
@@ -453,33 +560,59 @@ func (c *Conn) WithQueryBuilder(qb QueryBuilder) *Artisan {
 	var args [defaultArgumentsCapacity]argument
 	return &Artisan{
 		base: builderCommon{
-			cachedSQL: []byte(sqlStr),
-			Log:       c.Log,
-			id:        c.makeUniqueID(),
-			DB:        c.DB,
-			ärgErr:    errors.WithStack(err),
+			cachedSQL:          []byte(sqlStr),
+			Log:                c.Log,
+			id:                 c.makeUniqueID(),
+			DB:                 c.DB,
+			LifecycleListeners: c.LifecycleListeners.clip(),
+			inheritedListeners: len(c.LifecycleListeners),
+			firewall:           c.firewall,
+			ärgErr:             errors.WithStack(err),
 		},
 		raw:       argsRaw,
 		arguments: args[:0],
 	}
 }
 
+// run calls the functions and commits the transaction. An error or a panic of
+// a function rolls back the transaction. The panic gets re-raised.
+func (tx *Tx) run(fns []func(*Tx) error) error {
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+	for i, f := range fns {
+		if err := f(tx); err != nil {
+			err = errors.Wrapf(err, "[dml] ConnPool.Transaction.error at index %d", i)
+			if rErr := tx.Rollback(); rErr != nil {
+				err = errors.Wrapf(rErr, "[dml] ConnPool.Transaction.Rollback.error at index %d", i)
+			}
+			return err
+		}
+	}
+	return errors.WithStack(tx.Commit())
+}
+
 // Commit finishes the transaction. It logs the time taken, if a logger has been
-// set with Info logging enabled.
+// set with Info logging enabled. The event OnTxCommit gets dispatched to the
+// LifecycleListeners, even if the commit fails.
 func (tx *Tx) Commit() error {
 	if tx.Log != nil && tx.Log.IsDebug() {
 		defer tx.Log.Debug("Commit", log.Duration("duration", now().Sub(tx.start)))
 	}
-	return tx.DB.Commit()
+	return tx.dispatchTx(OnTxCommit, tx.DB.Commit())
 }
 
 // Rollback cancels the transaction. It logs the time taken, if a logger has
-// been set with Info logging enabled.
+// been set with Info logging enabled. The event OnTxRollback gets dispatched
+// to the LifecycleListeners, even if the rollback fails.
 func (tx *Tx) Rollback() error {
 	if tx.Log != nil && tx.Log.IsDebug() {
 		defer tx.Log.Debug("Rollback", log.Duration("duration", now().Sub(tx.start)))
 	}
-	return tx.DB.Rollback()
+	return tx.dispatchTx(OnTxRollback, tx.DB.Rollback())
 }
 
 // WithQueryBuilder creates a new Artisan for handling the arguments with the
@@ -490,11 +623,14 @@ func (tx *Tx) WithQueryBuilder(qb QueryBuilder) *Artisan {
 	var args [defaultArgumentsCapacity]argument
 	return &Artisan{
 		base: builderCommon{
-			cachedSQL: []byte(sqlStr),
-			Log:       tx.Log,
-			id:        tx.makeUniqueID(),
-			DB:        tx.DB,
-			ärgErr:    errors.WithStack(err),
+			cachedSQL:          []byte(sqlStr),
+			Log:                tx.Log,
+			id:                 tx.makeUniqueID(),
+			DB:                 tx.DB,
+			LifecycleListeners: tx.LifecycleListeners.clip(),
+			inheritedListeners: len(tx.LifecycleListeners),
+			firewall:           tx.firewall,
+			ärgErr:             errors.WithStack(err),
 		},
 		raw:       argsRaw,
 		arguments: args[:0],
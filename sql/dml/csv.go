@@ -0,0 +1,529 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+)
+
+// csvNullString gets written and read as NULL value by Export and ImportCSV,
+// if the field NullString of the options is empty.
+const csvNullString = "NULL"
+
+// CSVConfig allows to set special options when reading or writing CSV files.
+// It gets also used by dmltest.LoadCSV.
+type CSVConfig struct {
+	// Comma is the field delimiter. Defaults to comma (',') and to a tab for
+	// ExportFormatTSV.
+	Comma rune
+	// Comment, if not 0, is the comment character. Lines beginning with the
+	// Comment character without preceding whitespace are ignored. Only used
+	// for reading.
+	Comment rune
+	// FieldsPerRecord is the number of expected fields per record. If
+	// FieldsPerRecord is positive, Read requires each record to have the given
+	// number of fields. If FieldsPerRecord is 0, Read sets it to the number of
+	// fields in the first record, so that future records must have the same
+	// field count. If FieldsPerRecord is negative, no check is made and records
+	// may have a variable number of fields. Only used for reading.
+	FieldsPerRecord int
+	// If LazyQuotes is true, a quote may appear in an unquoted field and a
+	// non-doubled quote may appear in a quoted field. Only used for reading.
+	LazyQuotes *bool
+	// If TrimLeadingSpace is true, leading white space in a field is ignored.
+	// This is done even if the field delimiter, Comma, is white space. Only
+	// used for reading.
+	TrimLeadingSpace *bool
+	// UseCRLF uses \r\n as the line terminator. Only used for writing.
+	UseCRLF bool
+}
+
+func (cc CSVConfig) newReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	if cc.Comma > 0 {
+		cr.Comma = cc.Comma
+	}
+	if cc.Comment > 0 {
+		cr.Comment = cc.Comment
+	}
+	cr.FieldsPerRecord = cc.FieldsPerRecord
+	if cc.LazyQuotes != nil {
+		cr.LazyQuotes = *cc.LazyQuotes
+	}
+	if cc.TrimLeadingSpace != nil {
+		cr.TrimLeadingSpace = *cc.TrimLeadingSpace
+	}
+	cr.ReuseRecord = true
+	return cr
+}
+
+func (cc CSVConfig) newWriter(w io.Writer, defaultComma rune) *csv.Writer {
+	cw := csv.NewWriter(w)
+	cw.Comma = defaultComma
+	if cc.Comma > 0 {
+		cw.Comma = cc.Comma
+	}
+	cw.UseCRLF = cc.UseCRLF
+	return cw
+}
+
+// ExportFormat defines the output format of Artisan.Export.
+type ExportFormat uint8
+
+// ExportFormat* constants define the supported formats. NDJSON writes one JSON
+// object per row and line, see http://ndjson.org.
+const (
+	ExportFormatCSV ExportFormat = iota
+	ExportFormatTSV
+	ExportFormatNDJSON
+)
+
+// ExportOptions configures Artisan.Export.
+type ExportOptions struct {
+	Format ExportFormat
+	// CSV applies to the formats CSV and TSV.
+	CSV CSVConfig
+	// OmitHeader does not write the column names as the first row of a CSV or
+	// TSV file.
+	OmitHeader bool
+	// NullString gets written for a NULL value in the formats CSV and TSV.
+	// Defaults to `NULL`, the same default as ImportOptions.NullString, so that
+	// the file can be re-imported with Insert.ImportCSV. Use `\N` for LOAD
+	// DATA INFILE. NDJSON always writes null.
+	NullString string
+}
+
+// Export streams the result set of the query into w, row by row. Time values
+// get written in the MySQL format. Any QueryBuilder can be exported with
+// ConnPool.WithQueryBuilder or Conn.WithQueryBuilder. It returns the number of
+// exported rows.
+//		f, _ := os.Create("products.csv")
+//		n, err := dbc.WithQueryBuilder(dbc.SelectFrom("catalog_product_entity").Star()).
+//			Export(ctx, f, dml.ExportOptions{})
+func (a *Artisan) Export(ctx context.Context, w io.Writer, o ExportOptions, args ...interface{}) (rowCount uint64, err error) {
+	if a.base.Log != nil && a.base.Log.IsDebug() {
+		defer log.WhenDone(a.base.Log).Debug("Export", log.String("id", a.base.id), log.Int("format", int(o.Format)))
+	}
+
+	r, ev, err := a.query(ctx, args...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[dml] Export.Query with query ID %q", a.base.id)
+	}
+	if ev != nil {
+		defer func() { err = a.queryDone(ctx, ev, rowCount, err) }()
+	}
+	defer func() {
+		if err2 := r.Close(); err2 != nil && err == nil {
+			err = errors.Wrap(err2, "[dml] Export.Rows.Close")
+		}
+	}()
+	if o.NullString == "" {
+		o.NullString = csvNullString
+	}
+
+	var ew exportWriter
+	switch o.Format {
+	case ExportFormatCSV:
+		ew = &csvExportWriter{cw: o.CSV.newWriter(w, ','), null: o.NullString, header: !o.OmitHeader}
+	case ExportFormatTSV:
+		ew = &csvExportWriter{cw: o.CSV.newWriter(w, '\t'), null: o.NullString, header: !o.OmitHeader}
+	case ExportFormatNDJSON:
+		ew = &ndjsonExportWriter{w: bufio.NewWriter(w)}
+	default:
+		return 0, errors.NotSupported.Newf("[dml] Export format %d not supported", o.Format)
+	}
+
+	columns, err := r.Columns()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	cTypes, err := r.ColumnTypes()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if err = ew.writeHeader(columns, cTypes); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	values := make([]interface{}, len(columns))
+	scanArgs := make([]interface{}, len(columns))
+	for i := range values {
+		scanArgs[i] = &values[i]
+	}
+	for r.Next() {
+		if err = r.Scan(scanArgs...); err != nil {
+			return rowCount, errors.WithStack(err)
+		}
+		if err = ew.writeRow(values); err != nil {
+			return rowCount, errors.WithStack(err)
+		}
+		rowCount++
+	}
+	if err = r.Err(); err != nil {
+		return rowCount, errors.WithStack(err)
+	}
+	return rowCount, errors.WithStack(ew.flush())
+}
+
+type exportWriter interface {
+	writeHeader(columns []string, cTypes []*sql.ColumnType) error
+	writeRow(values []interface{}) error
+	flush() error
+}
+
+// exportAppendValue appends the textual representation of a scanned value.
+func exportAppendValue(buf []byte, v interface{}) []byte {
+	switch vt := v.(type) {
+	case []byte:
+		return append(buf, vt...)
+	case string:
+		return append(buf, vt...)
+	case int64:
+		return strconv.AppendInt(buf, vt, 10)
+	case float64:
+		return strconv.AppendFloat(buf, vt, 'f', -1, 64)
+	case bool:
+		if vt {
+			return append(buf, '1')
+		}
+		return append(buf, '0')
+	case time.Time:
+		return vt.AppendFormat(buf, timeFormat)
+	}
+	return buf
+}
+
+type csvExportWriter struct {
+	cw     *csv.Writer
+	null   string
+	header bool
+	record []string
+	buf    []byte
+}
+
+func (cw *csvExportWriter) writeHeader(columns []string, _ []*sql.ColumnType) error {
+	cw.record = make([]string, len(columns))
+	if !cw.header {
+		return nil
+	}
+	return cw.cw.Write(columns)
+}
+
+func (cw *csvExportWriter) writeRow(values []interface{}) error {
+	for i, v := range values {
+		if v == nil {
+			cw.record[i] = cw.null
+			continue
+		}
+		cw.buf = exportAppendValue(cw.buf[:0], v)
+		cw.record[i] = string(cw.buf)
+	}
+	return cw.cw.Write(cw.record)
+}
+
+func (cw *csvExportWriter) flush() error {
+	cw.cw.Flush()
+	return cw.cw.Error()
+}
+
+type ndjsonExportWriter struct {
+	w *bufio.Writer
+	// keys contains the JSON encoded column names including the colon.
+	keys [][]byte
+	// kinds contains for each column 'n' for numeric, 'j' for JSON and 's'
+	// for the remaining types.
+	kinds []byte
+	buf   []byte
+}
+
+func (nw *ndjsonExportWriter) writeHeader(columns []string, cTypes []*sql.ColumnType) error {
+	nw.keys = make([][]byte, len(columns))
+	nw.kinds = make([]byte, len(columns))
+	for i, c := range columns {
+		k, err := json.Marshal(c)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		nw.keys[i] = append(k, ':')
+		nw.kinds[i] = 's'
+		if i < len(cTypes) && cTypes[i] != nil {
+			nw.kinds[i] = ndjsonKind(cTypes[i].DatabaseTypeName())
+		}
+	}
+	return nil
+}
+
+func ndjsonKind(dbType string) byte {
+	switch strings.TrimPrefix(strings.ToUpper(dbType), "UNSIGNED ") {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "BIGINT", "DECIMAL", "FLOAT", "DOUBLE", "YEAR":
+		return 'n'
+	case "JSON":
+		return 'j'
+	}
+	return 's'
+}
+
+func (nw *ndjsonExportWriter) writeRow(values []interface{}) error {
+	buf := append(nw.buf[:0], '{')
+	for i, v := range values {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, nw.keys[i]...)
+		switch vt := v.(type) {
+		case nil:
+			buf = append(buf, "null"...)
+		case int64, float64:
+			buf = exportAppendValue(buf, vt)
+		case bool:
+			buf = strconv.AppendBool(buf, vt)
+		case []byte:
+			if (nw.kinds[i] == 'n' || nw.kinds[i] == 'j') && json.Valid(vt) {
+				buf = append(buf, vt...)
+				continue
+			}
+			buf = appendJSONString(buf, string(vt))
+		default:
+			buf = appendJSONString(buf, string(exportAppendValue(nil, vt)))
+		}
+	}
+	buf = append(buf, '}', '\n')
+	nw.buf = buf
+	_, err := nw.w.Write(buf)
+	return err
+}
+
+func appendJSONString(buf []byte, s string) []byte {
+	b, _ := json.Marshal(s) // a string cannot fail
+	return append(buf, b...)
+}
+
+func (nw *ndjsonExportWriter) flush() error { return nw.w.Flush() }
+
+// ImportOptions configures Insert.ImportCSV and Insert.ImportCSVFile.
+type ImportOptions struct {
+	CSV CSVConfig
+	// NoHeader defines that the first row contains data. The field Columns of
+	// the Insert must then contain the columns in the order of the CSV file.
+	NoHeader bool
+	// ColumnMap maps a column name of the CSV header to a column name of the
+	// table. If not empty, CSV columns missing in the map get skipped.
+	ColumnMap map[string]string
+	// NullString defines the value of a CSV field which gets inserted as NULL.
+	// The value must match exactly. Defaults to `NULL`, the same default as
+	// ExportOptions.NullString.
+	NullString string
+	// BatchSize number of rows inserted with one INSERT statement. Defaults to
+	// 500.
+	BatchSize int
+	// OnDuplicateKey updates all columns with ON DUPLICATE KEY UPDATE, except
+	// the columns in the field Insert.OnDuplicateKeyExclude.
+	OnDuplicateKey bool
+	// LoadDataInfile if set gets used by ImportCSVFile instead of batched
+	// INSERT statements. Usually a wrapper around ddl.Table.LoadDataInfile.
+	// The returned row count is then zero.
+	//		LoadDataInfile: func(ctx context.Context, filePath string) error {
+	//			return tbl.LoadDataInfile(ctx, dbc.DB, filePath, ddl.InfileOptions{
+	//				FieldsTerminatedBy: ",", IgnoreLinesAtStart: 1,
+	//			})
+	//		},
+	LoadDataInfile func(ctx context.Context, filePath string) error
+}
+
+// ImportCSVFile imports the CSV file, see ImportCSV. It uses the field
+// LoadDataInfile of the options, if set.
+func (b *Insert) ImportCSVFile(ctx context.Context, filePath string, o ImportOptions) (rowCount uint64, err error) {
+	if o.LoadDataInfile != nil {
+		if err := o.LoadDataInfile(ctx, filePath); err != nil {
+			return 0, errors.Wrapf(err, "[dml] ImportCSVFile.LoadDataInfile with file %q", filePath)
+		}
+		return 0, nil
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	defer func() {
+		if cErr := f.Close(); cErr != nil && err == nil {
+			err = errors.WithStack(cErr)
+		}
+	}()
+	return b.ImportCSV(ctx, f, o)
+}
+
+// ImportCSV reads the CSV data from r and inserts the rows in batches. The CSV
+// header gets mapped to the table columns with the field ColumnMap of the
+// options. If the field Columns of the Insert has been set, only those
+// columns get imported and must exist in the CSV header, otherwise all
+// (mapped) CSV columns get imported. All values get inserted as strings or
+// NULL. Use a Tx to import the whole file atomically. It returns the number
+// of imported rows. ImportCSV works on a copy of the Insert, so the same
+// Insert can import several files.
+//		n, err := dbc.InsertInto("catalog_product_entity").AddOnDuplicateKeyExclude("entity_id").
+//			ImportCSV(ctx, r, dml.ImportOptions{OnDuplicateKey: true})
+func (b *Insert) ImportCSV(ctx context.Context, r io.Reader, o ImportOptions) (rowCount uint64, err error) {
+	if b.Log != nil && b.Log.IsDebug() {
+		defer log.WhenDone(b.Log).Debug("ImportCSV", log.String("id", b.id), log.Int("batch_size", o.BatchSize))
+	}
+	if o.BatchSize < 1 {
+		o.BatchSize = 500
+	}
+	if o.NullString == "" {
+		o.NullString = csvNullString
+	}
+	b = b.importClone()
+
+	cr := o.CSV.newReader(r)
+	var fieldPos []int // index of the CSV field for each insert column
+	if o.NoHeader {
+		if len(b.Columns) == 0 {
+			return 0, errors.Empty.Newf("[dml] ImportCSV requires Insert.Columns when the CSV file has no header")
+		}
+		fieldPos = make([]int, len(b.Columns))
+		for i := range fieldPos {
+			fieldPos[i] = i
+		}
+	} else {
+		header, err := cr.Read()
+		if err != nil {
+			return 0, errors.Wrap(err, "[dml] ImportCSV.Read header")
+		}
+		if fieldPos, err = b.importColumns(header, o.ColumnMap); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	if o.OnDuplicateKey {
+		b.OnDuplicateKey()
+	}
+
+	a := b.WithArgs()
+	values := make([]interface{}, 0, o.BatchSize*len(fieldPos))
+	var batchRows int
+	insertBatch := func() error {
+		if batchRows == 0 {
+			return nil
+		}
+		if _, err := a.ResetInsert().ExecContext(ctx, values...); err != nil {
+			return errors.Wrapf(err, "[dml] ImportCSV.Exec after row %d", rowCount)
+		}
+		rowCount += uint64(batchRows)
+		values = values[:0]
+		batchRows = 0
+		return nil
+	}
+
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return rowCount, errors.Wrap(err, "[dml] ImportCSV.Read")
+		}
+		for _, pos := range fieldPos {
+			if pos >= len(rec) {
+				return rowCount, errors.NotValid.Newf("[dml] ImportCSV record %v has fewer fields than required", rec)
+			}
+			if v := rec[pos]; v != o.NullString {
+				values = append(values, v)
+			} else {
+				values = append(values, nil)
+			}
+		}
+		if batchRows++; batchRows == o.BatchSize {
+			if err := insertBatch(); err != nil {
+				return rowCount, err
+			}
+		}
+	}
+	return rowCount, insertBatch()
+}
+
+// importClone copies the settings of the Insert without the cached SQL string.
+// ImportCSV sets the columns and the ON DUPLICATE KEY flag on the copy.
+func (b *Insert) importClone() *Insert {
+	b.rwmu.RLock()
+	defer b.rwmu.RUnlock()
+	bc := b.builderCommon
+	bc.cachedSQL = nil
+	bc.qualifiedColumns = nil
+	bc.LifecycleListeners = bc.LifecycleListeners.clip()
+	return &Insert{
+		BuilderBase: BuilderBase{
+			RawFullSQL:      b.RawFullSQL,
+			Table:           b.Table,
+			IsUnsafe:        b.IsUnsafe,
+			filtersDisabled: b.filtersDisabled,
+			disabledFilters: b.disabledFilters,
+			builderCommon:   bc,
+		},
+		Into:                   b.Into,
+		Columns:                append([]string(nil), b.Columns...),
+		RowCount:               b.RowCount,
+		RecordPlaceHolderCount: b.RecordPlaceHolderCount,
+		Select:                 b.Select,
+		Pairs:                  b.Pairs,
+		OnDuplicateKeys:        b.OnDuplicateKeys,
+		OnDuplicateKeyExclude:  b.OnDuplicateKeyExclude,
+		IsOnDuplicateKey:       b.IsOnDuplicateKey,
+		IsReplace:              b.IsReplace,
+		IsIgnore:               b.IsIgnore,
+		IsBuildValues:          b.IsBuildValues,
+		Listeners:              b.Listeners,
+	}
+}
+
+// importColumns sets the insert columns from the CSV header or checks that
+// the already set columns exist in the header. It returns for each insert
+// column the index of the CSV field.
+func (b *Insert) importColumns(header []string, columnMap map[string]string) ([]int, error) {
+	headerPos := make(map[string]int, len(header))
+	var columns []string
+	for i, h := range header {
+		h = strings.TrimSpace(h)
+		if len(columnMap) > 0 {
+			var ok bool
+			if h, ok = columnMap[h]; !ok {
+				continue
+			}
+		}
+		headerPos[h] = i
+		columns = append(columns, h)
+	}
+	if len(b.Columns) == 0 {
+		b.AddColumns(columns...)
+	}
+	fieldPos := make([]int, len(b.Columns))
+	for i, c := range b.Columns {
+		pos, ok := headerPos[c]
+		if !ok {
+			return nil, errors.NotFound.Newf("[dml] ImportCSV column %q not found in the CSV header %v", c, header)
+		}
+		fieldPos[i] = pos
+	}
+	return fieldPos, nil
+}
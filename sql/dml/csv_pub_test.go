@@ -0,0 +1,172 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtisan_Export(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+	newRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"entity_id", "sku", "weight", "created_at"}).
+			AddRow(int64(1), []byte("SKU-1"), 1.5, created).
+			AddRow(int64(2), []byte(`SKU "2", blue`), nil, created)
+	}
+
+	tests := []struct {
+		name string
+		opt  dml.ExportOptions
+		want string
+	}{
+		{
+			"CSV",
+			dml.ExportOptions{},
+			"entity_id,sku,weight,created_at\n1,SKU-1,1.5,2018-03-04 05:06:07\n2,\"SKU \"\"2\"\", blue\",NULL,2018-03-04 05:06:07\n",
+		},
+		{
+			"TSV without header",
+			dml.ExportOptions{Format: dml.ExportFormatTSV, OmitHeader: true, NullString: `\N`},
+			"1\tSKU-1\t1.5\t2018-03-04 05:06:07\n2\t\"SKU \"\"2\"\", blue\"\t\\N\t2018-03-04 05:06:07\n",
+		},
+		{
+			"NDJSON",
+			dml.ExportOptions{Format: dml.ExportFormatNDJSON},
+			`{"entity_id":1,"sku":"SKU-1","weight":1.5,"created_at":"2018-03-04 05:06:07"}` + "\n" +
+				`{"entity_id":2,"sku":"SKU \"2\", blue","weight":null,"created_at":"2018-03-04 05:06:07"}` + "\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbc, dbMock := dmltest.MockDB(t)
+			defer dmltest.MockClose(t, dbc, dbMock)
+
+			dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT * FROM `catalog_product_entity`")).WillReturnRows(newRows())
+
+			var buf bytes.Buffer
+			n, err := dbc.WithQueryBuilder(dml.NewSelect().Star().From("catalog_product_entity")).
+				Export(context.TODO(), &buf, test.opt)
+			require.NoError(t, err)
+			assert.Exactly(t, uint64(2), n)
+			assert.Exactly(t, test.want, buf.String())
+		})
+	}
+}
+
+func TestInsert_ImportCSV(t *testing.T) {
+	t.Parallel()
+
+	const csvData = "sku,name,ignored,weight\nSKU-1,Shirt,x,1.5\nSKU-2,Pants,y,NULL\nSKU-3,Socks,z,0.2\n"
+
+	t.Run("batches with column map", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`sku`,`name`,`weight`) VALUES (?,?,?),(?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `weight`=VALUES(`weight`)")).
+			WithArgs("SKU-1", "Shirt", "1.5", "SKU-2", "Pants", nil).WillReturnResult(sqlmock.NewResult(0, 2))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`sku`,`name`,`weight`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `weight`=VALUES(`weight`)")).
+			WithArgs("SKU-3", "Socks", "0.2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		n, err := dbc.InsertInto("catalog_product_entity").AddOnDuplicateKeyExclude("sku").
+			ImportCSV(context.TODO(), strings.NewReader(csvData), dml.ImportOptions{
+				ColumnMap:      map[string]string{"sku": "sku", "name": "name", "weight": "weight"},
+				BatchSize:      2,
+				OnDuplicateKey: true,
+			})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(3), n)
+	})
+
+	t.Run("predefined columns", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`weight`,`sku`) VALUES (?,?),(?,?),(?,?)")).
+			WithArgs("1.5", "SKU-1", nil, "SKU-2", "0.2", "SKU-3").WillReturnResult(sqlmock.NewResult(0, 3))
+
+		n, err := dbc.InsertInto("catalog_product_entity").AddColumns("weight", "sku").
+			ImportCSV(context.TODO(), strings.NewReader(csvData), dml.ImportOptions{})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(3), n)
+	})
+
+	t.Run("NULL matches exactly", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`sku`,`name`) VALUES (?,?),(?,?)")).
+			WithArgs("SKU-1", "null", "SKU-2", nil).WillReturnResult(sqlmock.NewResult(0, 2))
+
+		n, err := dbc.InsertInto("catalog_product_entity").
+			ImportCSV(context.TODO(), strings.NewReader("sku,name\nSKU-1,null\nSKU-2,NULL\n"), dml.ImportOptions{})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(2), n)
+	})
+
+	t.Run("reused Insert", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`sku`,`name`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)")).
+			WithArgs("SKU-1", "Shirt").WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_product_entity` (`sku`,`weight`) VALUES (?,?)")).
+			WithArgs("SKU-2", "0.2").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ins := dbc.InsertInto("catalog_product_entity").AddOnDuplicateKeyExclude("sku")
+		n, err := ins.ImportCSV(context.TODO(), strings.NewReader("sku,name\nSKU-1,Shirt\n"), dml.ImportOptions{OnDuplicateKey: true})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(1), n)
+		n, err = ins.ImportCSV(context.TODO(), strings.NewReader("sku,weight\nSKU-2,0.2\n"), dml.ImportOptions{})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(1), n)
+		assert.Empty(t, ins.Columns)
+		assert.False(t, ins.IsOnDuplicateKey)
+	})
+
+	t.Run("column not found", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		_, err := dbc.InsertInto("catalog_product_entity").AddColumns("price").
+			ImportCSV(context.TODO(), strings.NewReader(csvData), dml.ImportOptions{})
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("LoadDataInfile", func(t *testing.T) {
+		var loaded string
+		n, err := dml.NewInsert("catalog_product_entity").ImportCSVFile(context.TODO(), "products.csv", dml.ImportOptions{
+			LoadDataInfile: func(_ context.Context, filePath string) error {
+				loaded = filePath
+				return nil
+			},
+		})
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(0), n)
+		assert.Exactly(t, "products.csv", loaded)
+	})
+}
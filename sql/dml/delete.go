@@ -56,6 +56,9 @@ type Delete struct {
 	// Listeners allows to dispatch certain functions in different
 	// situations.
 	Listeners ListenersDelete
+	// softDeleteColumn gets set by a TableFilter during the building of the
+	// SQL string and rewrites the DELETE into an UPDATE.
+	softDeleteColumn string
 }
 
 // NewDelete creates a new Delete object.
@@ -70,19 +73,16 @@ func NewDelete(from string) *Delete {
 	}
 }
 
-func newDeleteFrom(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, from string) *Delete {
-	id := idFn()
+func newDeleteFrom(db QueryExecPreparer, lw *logWithID, from string) *Delete {
+	id := lw.makeUniqueID()
+	l := lw.Log
 	if l != nil {
 		l = l.With(log.String("delete_id", id), log.String("table", from))
 	}
 	return &Delete{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  db,
-			},
-			Table: MakeIdentifier(from),
+			builderCommon: lw.newBuilderCommon(db, id, l),
+			Table:         MakeIdentifier(from),
 		},
 		BuilderConditional: BuilderConditional{
 			Wheres: make(Conditions, 0, 2),
@@ -92,19 +92,19 @@ func newDeleteFrom(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, from str
 
 // DeleteFrom creates a new Delete for the given table
 func (c *ConnPool) DeleteFrom(from string) *Delete {
-	return newDeleteFrom(c.DB, c.makeUniqueID, c.Log, from)
+	return newDeleteFrom(c.DB, &c.logWithID, from)
 }
 
 // DeleteFrom creates a new Delete for the given table
 // in the context for a single database connection.
 func (c *Conn) DeleteFrom(from string) *Delete {
-	return newDeleteFrom(c.DB, c.makeUniqueID, c.Log, from)
+	return newDeleteFrom(c.DB, &c.logWithID, from)
 }
 
 // DeleteFrom creates a new Delete for the given table
 // in the context for a transaction
 func (tx *Tx) DeleteFrom(from string) *Delete {
-	return newDeleteFrom(tx.DB, tx.makeUniqueID, tx.Log, from)
+	return newDeleteFrom(tx.DB, &tx.logWithID, from)
 }
 
 // FromTables specifies additional tables to delete from besides the default table.
@@ -271,6 +271,10 @@ func (b *Delete) toSQL(w *bytes.Buffer, placeHolders []string) (_ []string, err
 		return nil, errors.Empty.Newf("[dml] Delete: Table is missing")
 	}
 
+	if b.softDeleteColumn != "" {
+		return b.toSQLSoftDelete(w, placeHolders)
+	}
+
 	w.WriteString("DELETE ")
 	writeStmtID(w, b.id)
 
@@ -335,6 +339,48 @@ func (b *Delete) toSQL(w *bytes.Buffer, placeHolders []string) (_ []string, err
 	return placeHolders, nil
 }
 
+// toSQLSoftDelete writes an UPDATE statement which sets the soft delete column
+// to the current time.
+func (b *Delete) toSQLSoftDelete(w *bytes.Buffer, placeHolders []string) (_ []string, err error) {
+	if len(b.MultiTables) > 0 || b.Returning != nil {
+		return nil, errors.NotSupported.Newf("[dml] Delete: Soft delete of table %q does not support multiple tables or RETURNING", b.Table.Name)
+	}
+
+	w.WriteString("UPDATE ")
+	writeStmtID(w, b.id)
+	if placeHolders, err = b.Table.writeQuoted(w, placeHolders); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, f := range b.Joins {
+		w.WriteByte(' ')
+		w.WriteString(f.JoinType)
+		w.WriteString(" JOIN ")
+		if placeHolders, err = f.Table.writeQuoted(w, placeHolders); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if placeHolders, err = f.On.write(w, 'j', placeHolders); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	w.WriteString(" SET ")
+	if len(b.Joins) > 0 {
+		Quoter.writeQualifierName(w, b.Table.qualifier(), b.softDeleteColumn)
+	} else {
+		Quoter.quote(w, b.softDeleteColumn)
+	}
+	w.WriteString("=NOW()")
+
+	if placeHolders, err = b.Wheres.write(w, 'w', placeHolders); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sqlWriteOrderBy(w, b.OrderBys, false)
+	sqlWriteLimitOffset(w, b.LimitValid, b.LimitCount, false, 0)
+	return placeHolders, nil
+}
+
 // Prepare executes the statement represented by the Delete to create a prepared
 // statement. It returns a custom statement type or an error if there was one.
 // Provided arguments or records in the Delete are getting ignored. The provided
@@ -16,6 +16,7 @@ package dml
 
 import (
 	"bytes"
+	"sync"
 
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
@@ -28,19 +29,41 @@ func (et EventType) String() string {
 	return string(et)
 }
 
-// List of possible dispatched events.
+// List of possible dispatched events. OnBeforeToSQL gets dispatched by the
+// Select, Insert, Update and Delete listeners. All other events are lifecycle
+// events and get dispatched to the ListenLifecycleFn by the Artisan and the Tx.
 const (
 	OnBeforeToSQL EventType = iota + 65
+	// OnBeforeExec gets dispatched before a statement gets executed. A listener
+	// error aborts the execution.
+	OnBeforeExec
+	// OnAfterExec gets dispatched after a successful execution and provides
+	// the sql.Result.
+	OnAfterExec
+	// OnAfterQuery gets dispatched after a successful query and provides the
+	// number of loaded rows.
+	OnAfterQuery
+	// OnError gets dispatched when the execution of a statement or the loading
+	// of the rows fails.
+	OnError
+	// OnTxCommit gets dispatched after a transaction has been committed.
+	OnTxCommit
+	// OnTxRollback gets dispatched after a transaction has been rolled back.
+	OnTxRollback
 )
 
 // ListenerBucket a type for embedding into other structs to define events for
 // manipulating the SQL. Not an interface because interfaces are named with
-// verbs ;-) Not yet thread safe.
+// verbs ;-) The functions Add, Merge and Clone are safe for concurrent use.
+// Reading the fields directly while other goroutines call Add or Merge is a
+// race, use Clone to get a consistent copy.
 type ListenerBucket struct {
-	Select ListenersSelect
-	Insert ListenersInsert
-	Update ListenersUpdate
-	Delete ListenersDelete
+	mu        sync.RWMutex
+	Select    ListenersSelect
+	Insert    ListenersInsert
+	Update    ListenersUpdate
+	Delete    ListenersDelete
+	Lifecycle ListenersLifecycle
 }
 
 // NewListenerBucket creates a new event container to which multiple listeners
@@ -51,6 +74,7 @@ func NewListenerBucket(listeners ...Listen) (*ListenerBucket, error) {
 	ec.Insert.Add(listeners...)
 	ec.Update.Add(listeners...)
 	ec.Delete.Add(listeners...)
+	ec.Lifecycle.Add(listeners...)
 
 	for i, ls := range ec.Select {
 		if ls.error != nil {
@@ -72,6 +96,11 @@ func NewListenerBucket(listeners ...Listen) (*ListenerBucket, error) {
 			return nil, errors.Wrapf(ls.error, "[dml] NewListenerBucket Delete Index %d", i)
 		}
 	}
+	for i, ls := range ec.Lifecycle {
+		if ls.error != nil {
+			return nil, errors.Wrapf(ls.error, "[dml] NewListenerBucket Lifecycle Index %d", i)
+		}
+	}
 	return ec, nil
 }
 
@@ -84,17 +113,59 @@ func MustNewListenerBucket(listeners ...Listen) *ListenerBucket {
 	return ec
 }
 
-// Merge merges other events into the current event container.
+// Add adds new listeners to the bucket. It returns an error if a listener
+// has no EventType. In case of an error the bucket stays unchanged.
+func (lb *ListenerBucket) Add(listeners ...Listen) error {
+	nlb, err := NewListenerBucket(listeners...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	lb.Merge(nlb)
+	return nil
+}
+
+// Merge merges other events into the current event container. The slices of
+// the current container get copied and never modified in place, hence
+// previously read slices stay untouched.
 func (lb *ListenerBucket) Merge(buckets ...*ListenerBucket) *ListenerBucket {
+	// Clone before locking, so that a bucket can be merged with itself.
+	others := make([]*ListenerBucket, 0, len(buckets))
 	for _, b := range buckets {
-		lb.Select = append(lb.Select, b.Select...)
-		lb.Insert = append(lb.Insert, b.Insert...)
-		lb.Update = append(lb.Update, b.Update...)
-		lb.Delete = append(lb.Delete, b.Delete...)
+		others = append(others, b.Clone())
 	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	n := lb.clone()
+	for _, b := range others {
+		n.Select = append(n.Select, b.Select...)
+		n.Insert = append(n.Insert, b.Insert...)
+		n.Update = append(n.Update, b.Update...)
+		n.Delete = append(n.Delete, b.Delete...)
+		n.Lifecycle = append(n.Lifecycle, b.Lifecycle...)
+	}
+	lb.Select, lb.Insert, lb.Update, lb.Delete, lb.Lifecycle = n.Select, n.Insert, n.Update, n.Delete, n.Lifecycle
 	return lb
 }
 
+// Clone returns a copy of the bucket. The copy can be read without locking.
+func (lb *ListenerBucket) Clone() *ListenerBucket {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	return lb.clone()
+}
+
+// clone copies all listener slices. The caller must hold the lock.
+func (lb *ListenerBucket) clone() *ListenerBucket {
+	return &ListenerBucket{
+		Select:    append(ListenersSelect(nil), lb.Select...),
+		Insert:    append(ListenersInsert(nil), lb.Insert...),
+		Update:    append(ListenersUpdate(nil), lb.Update...),
+		Delete:    append(ListenersDelete(nil), lb.Delete...),
+		Lifecycle: append(ListenersLifecycle(nil), lb.Lifecycle...),
+	}
+}
+
 // Listen an argument to create a new listener when an event gets dispatched by
 // a "Select, Insert, Update, Delete" type. Implements Listener interface.
 type Listen struct {
@@ -108,6 +179,7 @@ type Listen struct {
 	ListenInsertFn
 	ListenUpdateFn
 	ListenDeleteFn
+	ListenLifecycleFn
 }
 
 // <-------------------------COPY------------------------->
@@ -0,0 +1,203 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+)
+
+// LifecycleEvent contains the details of an executed statement or of a
+// finished transaction. Depending on the EventType only some fields are set.
+// A listener must not keep a reference to the event or its fields after the
+// listener function returns.
+type LifecycleEvent struct {
+	EventType
+	// ID of the statement or of the transaction, the same as used in logging.
+	ID string
+	// Source defines the DML type which has created the statement: 's' Select,
+	// 'i' Insert, 'I' Insert with Select, 'u' Update, 'd' Delete, 'w' With, 'n'
+	// Union and 'h' Show. Zero for raw SQL queries and transactions.
+	Source rune
+	// SQL contains the statement. Empty for transaction events.
+	SQL string
+	// Args contains the arguments for the place holders. Must not be modified.
+	Args []interface{}
+	// Duration contains the time taken by the database for the statement or
+	// the total time of the transaction. Zero for OnBeforeExec.
+	Duration time.Duration
+	// Result gets set for OnAfterExec.
+	Result sql.Result
+	// RowCount contains the number of loaded rows for OnAfterQuery. It is zero
+	// when the rows have been returned to the caller, e.g. by QueryContext.
+	RowCount uint64
+	// Err gets set for OnError. For OnTxCommit and OnTxRollback Err contains
+	// the error of the commit or rollback, if any.
+	Err error
+
+	start time.Time
+}
+
+// ListenLifecycleFn receives a lifecycle event when a statement or a
+// transaction gets dispatched. A returned error aborts the further dispatching
+// and gets returned to the caller of the statement. For OnError the original
+// error takes precedence over the listener error.
+type ListenLifecycleFn func(context.Context, *LifecycleEvent) error
+
+// lifecycleListen wrapper struct because we might wrap the ListenLifecycleFn
+// from the Listen struct.
+type lifecycleListen struct {
+	name string
+	EventType
+	ListenLifecycleFn
+	error
+}
+
+func makeLifecycleListen(idx int, sl Listen) lifecycleListen {
+	nsl := lifecycleListen{
+		name:      sl.Name,
+		EventType: sl.EventType,
+	}
+	if nsl.EventType == 0 {
+		nsl.error = errors.Empty.Newf("[dml] Eventype at empty for %q; index %d", nsl.name, idx)
+	}
+
+	nsl.ListenLifecycleFn = sl.ListenLifecycleFn
+	return nsl
+}
+
+// ListenersLifecycle contains multiple lifecycle event listener
+type ListenersLifecycle []lifecycleListen
+
+// Add adds multiple listener to the listener stack and transforms the listener
+// functions according to the configuration.
+func (se *ListenersLifecycle) Add(sls ...Listen) ListenersLifecycle {
+	for idx, sl := range sls {
+		if sl.ListenLifecycleFn != nil {
+			*se = append(*se, makeLifecycleListen(idx, sl))
+		}
+	}
+	return *se
+}
+
+// Merge merges other ListenersLifecycle into the current listeners.
+func (se *ListenersLifecycle) Merge(sls ...ListenersLifecycle) ListenersLifecycle {
+	for _, sl := range sls {
+		*se = append(*se, sl...)
+	}
+	return *se
+}
+
+func (se ListenersLifecycle) dispatch(ctx context.Context, e *LifecycleEvent) error {
+	for i, s := range se {
+		switch {
+		case s.error != nil:
+			return errors.Wrapf(s.error, "[dml] ListenersLifecycle.dispatch Index %d EventType: %s", i, e.EventType)
+		case s.EventType == e.EventType:
+			if err := s.ListenLifecycleFn(ctx, e); err != nil {
+				return errors.Wrapf(err, "[dml] ListenersLifecycle.dispatch Listener %q Index %d EventType: %s", s.name, i, e.EventType)
+			}
+		}
+	}
+	return nil
+}
+
+// clip limits the capacity to the length, so that appending to the returned
+// slice never modifies the backing array of an inherited slice.
+func (se ListenersLifecycle) clip() ListenersLifecycle {
+	return se[:len(se):len(se)]
+}
+
+// String returns a list of all named event listeners.
+func (se ListenersLifecycle) String() string {
+	var buf bytes.Buffer
+	for i, li := range se {
+		_, _ = buf.WriteString(li.name)
+		if i < len(se)-1 {
+			_, _ = buf.WriteString("; ")
+		}
+	}
+	return buf.String()
+}
+
+// newLifecycleEvent creates a new event only if listeners have been
+// registered, otherwise it returns nil. The Artisan does not allocate when no
+// listeners are set.
+func (a *Artisan) newLifecycleEvent(sqlStr string, args []interface{}) *LifecycleEvent {
+	if len(a.base.LifecycleListeners) == 0 {
+		return nil
+	}
+	return &LifecycleEvent{
+		ID:     a.base.id,
+		Source: a.base.source,
+		SQL:    sqlStr,
+		Args:   args,
+		start:  now(),
+	}
+}
+
+// lifecycleDone dispatches OnError if errIn is not nil, otherwise it
+// dispatches the EventType et. errIn gets returned unchanged and the error of
+// an OnError listener gets only logged. If errIn is nil, the error of a
+// listener gets returned.
+func (a *Artisan) lifecycleDone(ctx context.Context, e *LifecycleEvent, et EventType, errIn error) error {
+	if e == nil {
+		return errIn
+	}
+	e.Duration = now().Sub(e.start)
+	if errIn == nil {
+		e.EventType = et
+		return a.base.LifecycleListeners.dispatch(ctx, e)
+	}
+	e.EventType, e.Err = OnError, errIn
+	if err := a.base.LifecycleListeners.dispatch(ctx, e); err != nil && a.base.Log != nil && a.base.Log.IsInfo() {
+		a.base.Log.Info("dml.Artisan.lifecycleDone.OnError", log.String("id", a.base.id), log.Err(err), log.ErrWithKey("original_error", errIn))
+	}
+	return errIn
+}
+
+// queryDone dispatches OnAfterQuery with the amount of loaded rows or OnError.
+func (a *Artisan) queryDone(ctx context.Context, e *LifecycleEvent, rowCount uint64, errIn error) error {
+	if e != nil {
+		e.RowCount = rowCount
+	}
+	return a.lifecycleDone(ctx, e, OnAfterQuery, errIn)
+}
+
+// dispatchTx dispatches the transaction events OnTxCommit and OnTxRollback.
+// The error of the commit or rollback takes precedence over the listener
+// error.
+func (tx *Tx) dispatchTx(et EventType, errIn error) error {
+	if len(tx.LifecycleListeners) == 0 {
+		return errIn
+	}
+	e := &LifecycleEvent{
+		EventType: et,
+		ID:        tx.id,
+		Duration:  now().Sub(tx.start),
+		Err:       errIn,
+	}
+	// The context of BeginTx is not available anymore.
+	err := tx.LifecycleListeners.dispatch(context.Background(), e)
+	if errIn != nil {
+		return errIn
+	}
+	return err
+}
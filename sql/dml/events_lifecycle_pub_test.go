@@ -0,0 +1,284 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lifecycleRecorder collects the dispatched lifecycle events.
+type lifecycleRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (lr *lifecycleRecorder) record(_ context.Context, e *dml.LifecycleEvent) error {
+	lr.mu.Lock()
+	defer lr.mu.Unlock()
+	var s string
+	switch e.EventType {
+	case dml.OnBeforeExec:
+		s = fmt.Sprintf("OnBeforeExec %c %s", e.Source, e.SQL)
+	case dml.OnAfterExec:
+		ra, _ := e.Result.RowsAffected()
+		s = fmt.Sprintf("OnAfterExec %c rows_affected:%d", e.Source, ra)
+	case dml.OnAfterQuery:
+		s = fmt.Sprintf("OnAfterQuery %c row_count:%d", e.Source, e.RowCount)
+	case dml.OnError:
+		s = fmt.Sprintf("OnError %c %t", e.Source, errors.Aborted.Match(e.Err))
+	case dml.OnTxCommit:
+		s = fmt.Sprintf("OnTxCommit %v", e.Err)
+	case dml.OnTxRollback:
+		s = fmt.Sprintf("OnTxRollback %v", e.Err)
+	}
+	if e.Duration < 0 {
+		s += " negative duration"
+	}
+	lr.events = append(lr.events, s)
+	return nil
+}
+
+func (lr *lifecycleRecorder) bucket() *dml.ListenerBucket {
+	lb := dml.MustNewListenerBucket()
+	for _, et := range []dml.EventType{dml.OnBeforeExec, dml.OnAfterExec, dml.OnAfterQuery, dml.OnError, dml.OnTxCommit, dml.OnTxRollback} {
+		if err := lb.Add(dml.Listen{
+			Name:              "recorder",
+			EventType:         et,
+			ListenLifecycleFn: lr.record,
+		}); err != nil {
+			panic(err)
+		}
+	}
+	return lb
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	t.Parallel()
+
+	t.Run("transaction commit", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(rec.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WithArgs().WillReturnResult(sqlmock.NewResult(0, 9))
+		dbMock.ExpectQuery("SELECT `value` FROM `tableX`").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(5).AddRow(6))
+		dbMock.ExpectCommit()
+
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			if _, err := tx.Update("tableX").Set(dml.Column("value").Int(5)).WithArgs().ExecContext(context.TODO()); err != nil {
+				return err
+			}
+			vals, err := tx.SelectFrom("tableX").AddColumns("value").WithArgs().LoadInt64s(context.TODO())
+			assert.Exactly(t, []int64{5, 6}, vals)
+			return err
+		}))
+
+		assert.Exactly(t, []string{
+			"OnBeforeExec u UPDATE `tableX` SET `value`=5",
+			"OnAfterExec u rows_affected:9",
+			"OnAfterQuery s row_count:2",
+			"OnTxCommit <nil>",
+		}, rec.events)
+	})
+
+	t.Run("transaction rollback", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(rec.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("DELETE FROM `tableX`").WithArgs().WillReturnError(errors.Aborted.Newf("Sorry dude"))
+		dbMock.ExpectRollback()
+
+		err := dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			_, err := tx.DeleteFrom("tableX").WithArgs().ExecContext(context.TODO())
+			return err
+		})
+		assert.True(t, errors.Aborted.Match(err), "%+v", err)
+
+		assert.Exactly(t, []string{
+			"OnBeforeExec d DELETE FROM `tableX`",
+			"OnError d true",
+			"OnTxRollback <nil>",
+		}, rec.events)
+	})
+
+	t.Run("before exec aborts", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		del := dbc.DeleteFrom("tableX")
+		del.LifecycleListeners.Add(dml.Listen{
+			Name:      "read only mode",
+			EventType: dml.OnBeforeExec,
+			ListenLifecycleFn: func(context.Context, *dml.LifecycleEvent) error {
+				return errors.NotAllowed.Newf("read only mode")
+			},
+		})
+		res, err := del.WithArgs().ExecContext(context.TODO())
+		assert.Nil(t, res)
+		assert.True(t, errors.NotAllowed.Match(err), "%+v", err)
+	})
+
+	t.Run("row count guard", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WithArgs().WillReturnResult(sqlmock.NewResult(0, 1000))
+
+		upd := dbc.Update("tableX").Set(dml.Column("value").Int(5))
+		upd.LifecycleListeners.Add(dml.Listen{
+			Name:      "guard",
+			EventType: dml.OnAfterExec,
+			ListenLifecycleFn: func(_ context.Context, e *dml.LifecycleEvent) error {
+				if ra, _ := e.Result.RowsAffected(); ra > 100 {
+					return errors.Exceeded.Newf("too many affected rows: %d", ra)
+				}
+				return nil
+			},
+		})
+		_, err := upd.WithArgs().ExecContext(context.TODO())
+		assert.True(t, errors.Exceeded.Match(err), "%+v", err)
+	})
+
+	t.Run("Load and IterateSerial row count", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT `value` FROM `tableX`").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("a").AddRow("b").AddRow("c"))
+		dbMock.ExpectQuery("SELECT `value` FROM `tableX`").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("a"))
+		dbMock.ExpectQuery("SELECT `value` FROM `tableX`").WillReturnError(errors.Aborted.Newf("Connection lost"))
+
+		sel := dbc.SelectFrom("tableX").AddColumns("value")
+		sel.LifecycleListeners.Merge(rec.bucket().Lifecycle)
+
+		var n int
+		require.NoError(t, sel.WithArgs().IterateSerial(context.TODO(), func(*dml.ColumnMap) error {
+			n++
+			return nil
+		}))
+		assert.Exactly(t, 3, n)
+
+		s, err := sel.WithArgs().LoadString(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, "a", s)
+
+		_, err = sel.WithArgs().LoadStrings(context.TODO())
+		assert.True(t, errors.Aborted.Match(err), "%+v", err)
+
+		assert.Exactly(t, []string{
+			"OnAfterQuery s row_count:3",
+			"OnAfterQuery s row_count:1",
+			"OnError s true",
+		}, rec.events)
+	})
+
+	t.Run("raw query", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(rec.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+
+		rows, err := dbc.WithQueryBuilder(dml.QuerySQL("SELECT 1")).QueryContext(context.TODO())
+		require.NoError(t, err)
+		require.NoError(t, rows.Close())
+
+		assert.Exactly(t, []string{"OnAfterQuery \x00 row_count:0"}, rec.events)
+	})
+
+	t.Run("query row", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(rec.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT `value` FROM `tableX`").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(7))
+
+		var v int64
+		require.NoError(t, dbc.SelectFrom("tableX").AddColumns("value").WithArgs().QueryRowContext(context.TODO()).Scan(&v))
+		assert.Exactly(t, int64(7), v)
+
+		assert.Exactly(t, []string{"OnAfterQuery s row_count:0"}, rec.events)
+	})
+
+	t.Run("WithTx dispatches once", func(t *testing.T) {
+		rec := new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(rec.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WithArgs().WillReturnResult(sqlmock.NewResult(0, 3))
+		dbMock.ExpectCommit()
+
+		upd := dbc.Update("tableX").Set(dml.Column("value").Int(5)).WithArgs()
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			_, err := upd.WithTx(tx).ExecContext(context.TODO())
+			return err
+		}))
+
+		assert.Exactly(t, []string{
+			"OnBeforeExec u UPDATE `tableX` SET `value`=5",
+			"OnAfterExec u rows_affected:3",
+			"OnTxCommit <nil>",
+		}, rec.events)
+	})
+
+	t.Run("WithTx keeps builder listeners", func(t *testing.T) {
+		recPool, recBuilder, recTx := new(lifecycleRecorder), new(lifecycleRecorder), new(lifecycleRecorder)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithLifecycleListeners(recPool.bucket()))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WithArgs().WillReturnResult(sqlmock.NewResult(0, 3))
+		dbMock.ExpectCommit()
+
+		upd := dbc.Update("tableX").Set(dml.Column("value").Int(5))
+		upd.LifecycleListeners.Add(dml.Listen{
+			Name:              "builder",
+			EventType:         dml.OnAfterExec,
+			ListenLifecycleFn: recBuilder.record,
+		})
+		updA := upd.WithArgs()
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			tx.LifecycleListeners.Add(dml.Listen{
+				Name:              "tx",
+				EventType:         dml.OnAfterExec,
+				ListenLifecycleFn: recTx.record,
+			})
+			_, err := updA.WithTx(tx).ExecContext(context.TODO())
+			return err
+		}))
+
+		assert.Exactly(t, []string{
+			"OnBeforeExec u UPDATE `tableX` SET `value`=5",
+			"OnAfterExec u rows_affected:3",
+			"OnTxCommit <nil>",
+		}, recPool.events)
+		assert.Exactly(t, []string{"OnAfterExec u rows_affected:3"}, recBuilder.events)
+		assert.Exactly(t, []string{"OnAfterExec u rows_affected:3"}, recTx.events)
+	})
+}
@@ -15,7 +15,9 @@
 package dml
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/corestoreio/errors"
@@ -26,6 +28,7 @@ var _ fmt.Stringer = (*ListenersSelect)(nil)
 var _ fmt.Stringer = (*ListenersInsert)(nil)
 var _ fmt.Stringer = (*ListenersUpdate)(nil)
 var _ fmt.Stringer = (*ListenersDelete)(nil)
+var _ fmt.Stringer = (*ListenersLifecycle)(nil)
 
 func TestNewListenerBucket(t *testing.T) {
 
@@ -268,3 +271,94 @@ func TestNewListenerBucket(t *testing.T) {
 	})
 
 }
+
+func TestListenerBucket_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	newListen := func(name string, et EventType, called *int) Listen {
+		return Listen{
+			Name:      name,
+			EventType: et,
+			ListenLifecycleFn: func(_ context.Context, e *LifecycleEvent) error {
+				*called++
+				if e.Err != nil {
+					return errors.WithStack(e.Err)
+				}
+				return nil
+			},
+		}
+	}
+
+	t.Run("dispatch", func(t *testing.T) {
+		var called int
+		lb := MustNewListenerBucket(newListen("a", OnAfterExec, &called), newListen("b", OnAfterQuery, &called), newListen("c", OnAfterExec, &called))
+		assert.Exactly(t, `a; b; c`, lb.Lifecycle.String())
+		assert.Len(t, lb.Select, 0)
+
+		assert.NoError(t, lb.Lifecycle.dispatch(context.TODO(), &LifecycleEvent{EventType: OnAfterExec}))
+		assert.Exactly(t, 2, called)
+
+		err := lb.Lifecycle.dispatch(context.TODO(), &LifecycleEvent{EventType: OnAfterQuery, Err: errors.Exceeded.Newf("too many rows")})
+		assert.True(t, errors.Exceeded.Match(err), "%+v", err)
+		assert.Exactly(t, 3, called)
+	})
+
+	t.Run("Error Lifecycle", func(t *testing.T) {
+		lb, err := NewListenerBucket(Listen{
+			ListenLifecycleFn: func(context.Context, *LifecycleEvent) error { return nil },
+		})
+		assert.Nil(t, lb)
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+		lb = MustNewListenerBucket()
+		err = lb.Add(Listen{
+			ListenLifecycleFn: func(context.Context, *LifecycleEvent) error { return nil },
+		})
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+		assert.Len(t, lb.Lifecycle, 0)
+	})
+
+	t.Run("merge with itself", func(t *testing.T) {
+		var called int
+		lb := MustNewListenerBucket(newListen("a", OnError, &called))
+		lb.Merge(lb)
+		assert.Exactly(t, `a; a`, lb.Lifecycle.String())
+	})
+
+	t.Run("concurrent Add, Merge and Clone", func(t *testing.T) {
+		lb := MustNewListenerBucket()
+		other := MustNewListenerBucket(Listen{
+			Name:           "s",
+			EventType:      OnBeforeToSQL,
+			ListenSelectFn: func(*Select) {},
+		})
+		cloned := lb.Clone()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, lb.Add(Listen{
+					Name:              "l",
+					EventType:         OnAfterQuery,
+					ListenLifecycleFn: func(context.Context, *LifecycleEvent) error { return nil },
+				}))
+			}()
+			go func() {
+				defer wg.Done()
+				lb.Merge(other)
+			}()
+			go func() {
+				defer wg.Done()
+				_ = lb.Clone().Lifecycle.String()
+			}()
+		}
+		wg.Wait()
+
+		lb2 := lb.Clone()
+		assert.Len(t, lb2.Lifecycle, 10)
+		assert.Len(t, lb2.Select, 10)
+		assert.Len(t, cloned.Lifecycle, 0, "A clone must not change")
+	})
+}
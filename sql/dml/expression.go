@@ -23,9 +23,10 @@ import (
 )
 
 // write writes the strings into `w` and correctly handles the place holder
-// repetition depending on the number of arguments.
+// repetition depending on the number of arguments. Question marks within quoted
+// literals do not count as place holders.
 func writeExpression(w *bytes.Buffer, expression string, args arguments) (phCount int, err error) {
-	phCount = strings.Count(expression, placeHolderStr)
+	phCount = countPlaceHolders(expression)
 	if phCount == 0 || len(args) == 0 {
 		// fast path
 		_, err = w.WriteString(expression)
@@ -161,3 +162,106 @@ func sqlCase(value, defaultValue string, compareResult ...string) string {
 	bufferpool.Put(buf)
 	return e
 }
+
+// SQLJSONExtract creates a JSON_EXTRACT expression which returns the data of a
+// JSON column selected by the paths. Each path must start with `$`. Available
+// in MySQL >= 5.7 and MariaDB >= 10.2.
+//		SQLJSONExtract("attributes", "$.color") -> JSON_EXTRACT(`attributes`,'$.color')
+func SQLJSONExtract(column string, paths ...string) *Condition {
+	if len(paths) == 0 {
+		return &Condition{
+			previousErr: errors.NotValid.Newf("[dml] SQLJSONExtract requires at least one path for column %q", column),
+		}
+	}
+	return sqlJSON("JSON_EXTRACT(", column, ")", paths...)
+}
+
+// SQLJSONUnquote creates a JSON_UNQUOTE(JSON_EXTRACT()) expression which
+// returns the unquoted scalar value of a JSON column selected by the path. It
+// is the portable version of the MySQL operator `->>` and works also in
+// MariaDB.
+//		SQLJSONUnquote("attributes", "$.color") -> JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.color'))
+func SQLJSONUnquote(column, path string) *Condition {
+	return sqlJSON("JSON_UNQUOTE(JSON_EXTRACT(", column, "))", path)
+}
+
+// SQLJSONPath creates the short hand `->` expression of JSON_EXTRACT. Only
+// available in MySQL >= 5.7.
+//		SQLJSONPath("attributes", "$.color") -> `attributes`->'$.color'
+func SQLJSONPath(column, path string) *Condition {
+	return sqlJSONOperator(column, "->", path)
+}
+
+// SQLJSONPathUnquote creates the short hand `->>` expression of
+// JSON_UNQUOTE(JSON_EXTRACT()). Only available in MySQL >= 5.7.13.
+//		SQLJSONPathUnquote("attributes", "$.color") -> `attributes`->>'$.color'
+func SQLJSONPathUnquote(column, path string) *Condition {
+	return sqlJSONOperator(column, "->>", path)
+}
+
+// SQLJSONContains creates a JSON_CONTAINS expression which checks whether the
+// candidate argument is contained in the JSON column at the optional path. The
+// candidate gets set via a placeholder or via an argument function, for example
+// Condition.JSON. Available in MySQL >= 5.7 and MariaDB >= 10.2.3.
+//		SQLJSONContains("attributes", "$.color") -> JSON_CONTAINS(`attributes`,?,'$.color')
+func SQLJSONContains(column string, path ...string) *Condition {
+	if len(path) > 1 {
+		return &Condition{
+			previousErr: errors.NotValid.Newf("[dml] SQLJSONContains allows only one path for column %q, got: %v", column, path),
+		}
+	}
+	return sqlJSON("JSON_CONTAINS(", column, ")", append([]string{placeHolderStr}, path...)...)
+}
+
+// sqlJSON writes a JSON function call with the quoted column as first
+// argument. The paths get written as escaped strings, except the placeholder.
+func sqlJSON(fnOpen, column, fnClose string, paths ...string) *Condition {
+	if err := validateJSONPaths(paths); err != nil {
+		return &Condition{previousErr: err}
+	}
+	buf := bufferpool.Get()
+	buf.WriteString(fnOpen)
+	Quoter.WriteIdentifier(buf, column)
+	for _, p := range paths {
+		buf.WriteByte(',')
+		if p == placeHolderStr {
+			buf.WriteByte(placeHolderRune)
+		} else {
+			dialect.EscapeString(buf, p)
+		}
+	}
+	buf.WriteString(fnClose)
+	c := &Condition{
+		Left:             buf.String(),
+		IsLeftExpression: true,
+	}
+	bufferpool.Put(buf)
+	return c
+}
+
+func sqlJSONOperator(column, operator, path string) *Condition {
+	if err := validateJSONPaths([]string{path}); err != nil {
+		return &Condition{previousErr: err}
+	}
+	buf := bufferpool.Get()
+	Quoter.WriteIdentifier(buf, column)
+	buf.WriteString(operator)
+	dialect.EscapeString(buf, path)
+	c := &Condition{
+		Left:             buf.String(),
+		IsLeftExpression: true,
+	}
+	bufferpool.Put(buf)
+	return c
+}
+
+// validateJSONPaths checks that each path starts with the scope `$`. The
+// placeholder gets skipped.
+func validateJSONPaths(paths []string) error {
+	for _, p := range paths {
+		if p != placeHolderStr && !strings.HasPrefix(p, "$") {
+			return errors.NotValid.Newf("[dml] JSON path %q must start with `$`", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+)
+
+// FirewallMode defines how the Firewall treats an unknown fingerprint.
+type FirewallMode uint32
+
+// FirewallMode* constants define the supported modes. FirewallDisabled lets
+// all queries pass without computing the fingerprint.
+const (
+	FirewallDisabled FirewallMode = iota
+	FirewallLearning
+	FirewallEnforcing
+)
+
+// QueryNotAllowedError gets returned by the Firewall in enforcing mode if the
+// fingerprint of a query is not in the allowlist.
+type QueryNotAllowedError struct {
+	Fingerprint string
+	SQL         string
+}
+
+func (e *QueryNotAllowedError) Error() string {
+	return "[dml] Firewall: query not allowed with fingerprint " + e.Fingerprint
+}
+
+// Firewall checks the normalized fingerprint of each executed or prepared
+// statement against an allowlist. In learning mode, all unknown fingerprints
+// get added to the allowlist. In enforcing mode, a statement with an unknown
+// fingerprint gets rejected with a *QueryNotAllowedError. The typical
+// workflow: run the test suite or a staging system in learning mode, persist
+// the allowlist with WriteTo, review it and load it in production with
+// ReadFrom in enforcing mode. Firewall is safe for concurrent use.
+type Firewall struct {
+	mode uint32 // FirewallMode, atomic
+
+	mu        sync.RWMutex
+	allowlist map[string]struct{}
+}
+
+// NewFirewall creates a new Firewall with an optional allowlist of
+// fingerprints, see function Fingerprint.
+func NewFirewall(mode FirewallMode, allowlist ...string) *Firewall {
+	fw := &Firewall{
+		mode:      uint32(mode),
+		allowlist: make(map[string]struct{}, len(allowlist)),
+	}
+	fw.Allow(allowlist...)
+	return fw
+}
+
+// WithFirewall enables the firewall for all statements created by the
+// ConnPool and its Conn and Tx, including those of WithQueryBuilder and of
+// prepared statements. Raw queries executed directly on the *sql.DB field are
+// not checked. QueryRowContext cannot return the *QueryNotAllowedError, the
+// query does not get executed and Scan returns an error.
+//		fw := dml.NewFirewall(dml.FirewallEnforcing)
+//		_, err := fw.ReadFrom(allowlistFile)
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithFirewall(fw))
+func WithFirewall(fw *Firewall) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 18,
+		fn: func(c *ConnPool) error {
+			c.firewall = fw
+			return nil
+		},
+	}
+}
+
+// Mode returns the current mode.
+func (fw *Firewall) Mode() FirewallMode {
+	return FirewallMode(atomic.LoadUint32(&fw.mode))
+}
+
+// SetMode switches the mode, e.g. from learning to enforcing.
+func (fw *Firewall) SetMode(m FirewallMode) {
+	atomic.StoreUint32(&fw.mode, uint32(m))
+}
+
+// Allow adds fingerprints to the allowlist.
+func (fw *Firewall) Allow(fingerprints ...string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	for _, fp := range fingerprints {
+		fw.allowlist[fp] = struct{}{}
+	}
+}
+
+// Fingerprints returns the sorted allowlist.
+func (fw *Firewall) Fingerprints() []string {
+	fw.mu.RLock()
+	fps := make([]string, 0, len(fw.allowlist))
+	for fp := range fw.allowlist {
+		fps = append(fps, fp)
+	}
+	fw.mu.RUnlock()
+	sort.Strings(fps)
+	return fps
+}
+
+// WriteTo writes the sorted allowlist with one fingerprint per line.
+func (fw *Firewall) WriteTo(w io.Writer) (n int64, err error) {
+	for _, fp := range fw.Fingerprints() {
+		var nw int
+		nw, err = io.WriteString(w, fp+"\n")
+		n += int64(nw)
+		if err != nil {
+			return n, errors.WithStack(err)
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom adds the fingerprints from r, one per line, to the allowlist.
+// Empty lines get ignored.
+func (fw *Firewall) ReadFrom(r io.Reader) (n int64, err error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 4096), 1<<20)
+	var fps []string
+	for s.Scan() {
+		n += int64(len(s.Bytes()) + 1)
+		if line := string(bytes.TrimSpace(s.Bytes())); line != "" {
+			fps = append(fps, line)
+		}
+	}
+	if err = s.Err(); err != nil {
+		return n, errors.WithStack(err)
+	}
+	fw.Allow(fps...)
+	return n, nil
+}
+
+// check returns a *QueryNotAllowedError in enforcing mode if the fingerprint
+// of sqlStr is unknown. A nil Firewall allows everything.
+func (fw *Firewall) check(l log.Logger, sqlStr string) error {
+	if fw == nil || sqlStr == "" {
+		return nil
+	}
+	mode := fw.Mode()
+	if mode == FirewallDisabled {
+		return nil
+	}
+	fp := Fingerprint(sqlStr)
+	fw.mu.RLock()
+	_, ok := fw.allowlist[fp]
+	fw.mu.RUnlock()
+	switch {
+	case ok:
+		return nil
+	case mode == FirewallLearning:
+		fw.Allow(fp)
+		if l != nil && l.IsDebug() {
+			l.Debug("dml.Firewall.Learned", log.String("fingerprint", fp))
+		}
+		return nil
+	}
+	if l != nil && l.IsInfo() {
+		l.Info("dml.Firewall.Rejected", log.String("fingerprint", fp), log.String("sql", sqlStr))
+	}
+	return &QueryNotAllowedError{Fingerprint: fp, SQL: sqlStr}
+}
+
+var (
+	fingerprintList   = regexp.MustCompile(`\(\?(?:,\?)*\)`)
+	fingerprintValues = regexp.MustCompile(`\(\?\+\)(?:,\(\?\+\))+`)
+)
+
+// Fingerprint normalizes a SQL statement so that all statements which differ
+// only in their values have the same fingerprint. It removes comments,
+// including the unique statement IDs, collapses white space, lowercases all
+// keywords and unquoted identifiers, replaces string and number literals with
+// a place holder and reduces lists of place holders and multiple VALUES rows
+// to `(?+)`.
+//		SELECT * FROM `a` WHERE id IN (1, 2,3) AND name='x'
+// becomes
+//		select * from `a` where id in (?+) and name=?
+func Fingerprint(sqlStr string) string {
+	var buf bytes.Buffer
+	buf.Grow(len(sqlStr))
+	space := false // pending white space
+	writeSpace := func() {
+		if space && buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		space = false
+	}
+
+	for i := 0; i < len(sqlStr); i++ {
+		c := sqlStr[i]
+		switch {
+		case c == '/' && i+1 < len(sqlStr) && sqlStr[i+1] == '*':
+			i = indexFrom(sqlStr, "*/", i+2)
+			space = true
+		case c == '#' || (c == '-' && i+2 < len(sqlStr) && sqlStr[i+1] == '-' && (sqlStr[i+2] == ' ' || sqlStr[i+2] == '\t')):
+			i = indexFrom(sqlStr, "\n", i)
+			space = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		case c == '\'' || c == '"':
+			writeSpace()
+			i = skipQuoted(sqlStr, i, c)
+			buf.WriteByte('?')
+		case c == '`':
+			writeSpace()
+			end := skipQuoted(sqlStr, i, c)
+			buf.WriteString(sqlStr[i : end+1])
+			i = end
+		case c >= '0' && c <= '9' && (space || !isIdentByte(lastByte(&buf))):
+			writeSpace()
+			for i+1 < len(sqlStr) && (isIdentByte(sqlStr[i+1]) || sqlStr[i+1] == '.') {
+				i++
+			}
+			buf.WriteByte('?')
+		case c == ',' || c == '(' || c == ')':
+			if c == '(' {
+				writeSpace()
+			}
+			space = false
+			buf.WriteByte(c)
+			if c == ')' {
+				continue
+			}
+			// skip white space after , and (
+			for i+1 < len(sqlStr) && unicode.IsSpace(rune(sqlStr[i+1])) {
+				i++
+			}
+		default:
+			writeSpace()
+			if c >= 'A' && c <= 'Z' {
+				c += 'a' - 'A'
+			}
+			buf.WriteByte(c)
+		}
+	}
+	fp := fingerprintList.ReplaceAll(buf.Bytes(), []byte("(?+)"))
+	fp = fingerprintValues.ReplaceAll(fp, []byte("(?+)"))
+	return string(bytes.TrimSpace(fp))
+}
+
+// indexFrom returns the index of the last byte of sep in s starting at from
+// or the last index of s.
+func indexFrom(s, sep string, from int) int {
+	if from > len(s) {
+		return len(s) - 1
+	}
+	if idx := strings.Index(s[from:], sep); idx >= 0 {
+		return from + idx + len(sep) - 1
+	}
+	return len(s) - 1
+}
+
+// skipQuoted returns the index of the closing quote q of the literal starting
+// at pos. It takes care of backslash escapes and doubled quotes.
+func skipQuoted(s string, pos int, q byte) int {
+	for i := pos + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q != '`' {
+				i++
+			}
+		case q:
+			if i+1 < len(s) && s[i+1] == q {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(s) - 1
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c == '`' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func lastByte(buf *bytes.Buffer) byte {
+	if buf.Len() == 0 {
+		return 0
+	}
+	return buf.Bytes()[buf.Len()-1]
+}
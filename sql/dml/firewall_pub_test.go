@@ -0,0 +1,126 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{
+			"SELECT * FROM `a` WHERE id IN (1, 2,3) AND name='x'",
+			"select * from `a` where id in (?+) and name=?",
+		},
+		{
+			"/*ID$abc*/SELECT  `Name`\n FROM `a`  WHERE (`id` = 42.5) -- comment\n AND `b` = \"it\\\"s\"",
+			"select `Name` from `a` where (`id` = ?) and `b` = ?",
+		},
+		{
+			"INSERT INTO `a` (`x`,`y`) VALUES (1,'a'),(2,'b'),(3, 'c') # trailing",
+			"insert into `a` (`x`,`y`) values (?+)",
+		},
+		{
+			"select col1, t2.x from tbl2 where a=? limit 10",
+			"select col1,t2.x from tbl2 where a=? limit ?",
+		},
+	}
+	for _, test := range tests {
+		assert.Exactly(t, test.want, dml.Fingerprint(test.sql), "%q", test.sql)
+	}
+}
+
+func TestFirewall(t *testing.T) {
+	t.Parallel()
+
+	const sqlEntity = "SELECT `entity_id` FROM `customer_entity` WHERE (`entity_id` = ?)"
+	selectEntity := func(dbc *dml.ConnPool) *dml.Artisan {
+		return dbc.SelectFrom("customer_entity").AddColumns("entity_id").Where(dml.Column("entity_id").PlaceHolder()).WithArgs()
+	}
+
+	t.Run("learning then enforcing", func(t *testing.T) {
+		fw := dml.NewFirewall(dml.FirewallLearning)
+		dbc, dbMock := dmltest.MockDB(t, dml.WithFirewall(fw))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlEntity)).WithArgs(1).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(1))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlEntity)).WithArgs(2).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(2))
+
+		ctx := context.TODO()
+		ids, err := selectEntity(dbc).LoadInt64s(ctx, 1)
+		require.NoError(t, err)
+		assert.Exactly(t, []int64{1}, ids)
+		assert.Exactly(t, []string{"select `entity_id` from `customer_entity` where (`entity_id` = ?)"}, fw.Fingerprints())
+
+		fw.SetMode(dml.FirewallEnforcing)
+		ids, err = selectEntity(dbc).LoadInt64s(ctx, 2)
+		require.NoError(t, err)
+		assert.Exactly(t, []int64{2}, ids)
+
+		_, err = dbc.DeleteFrom("customer_entity").Where(dml.Column("entity_id").Int(3)).WithArgs().ExecContext(ctx)
+		qErr, ok := err.(*dml.QueryNotAllowedError)
+		require.True(t, ok, "%+v", err)
+		assert.Exactly(t, "delete from `customer_entity` where (`entity_id` = ?)", qErr.Fingerprint)
+	})
+
+	t.Run("prepare rejected", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithFirewall(dml.NewFirewall(dml.FirewallEnforcing)))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		stmt, err := dbc.SelectFrom("customer_entity").AddColumns("entity_id").Prepare(context.TODO())
+		assert.Nil(t, stmt)
+		_, ok := err.(*dml.QueryNotAllowedError)
+		assert.True(t, ok, "%+v", err)
+	})
+
+	t.Run("query row rejected", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithFirewall(dml.NewFirewall(dml.FirewallEnforcing)))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		var id int64
+		err := selectEntity(dbc).QueryRowContext(context.TODO(), 1).Scan(&id)
+		qErr, ok := err.(*dml.QueryNotAllowedError)
+		require.True(t, ok, "%+v", err)
+		assert.Exactly(t, "select `entity_id` from `customer_entity` where (`entity_id` = ?)", qErr.Fingerprint)
+	})
+
+	t.Run("WriteTo ReadFrom", func(t *testing.T) {
+		fw := dml.NewFirewall(dml.FirewallEnforcing, "select ?", "delete from `a`")
+		var buf bytes.Buffer
+		_, err := fw.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Exactly(t, "delete from `a`\nselect ?\n", buf.String())
+
+		fw2 := dml.NewFirewall(dml.FirewallEnforcing)
+		_, err = fw2.ReadFrom(bytes.NewBufferString("\n" + buf.String()))
+		require.NoError(t, err)
+		assert.Exactly(t, fw.Fingerprints(), fw2.Fingerprints())
+	})
+}
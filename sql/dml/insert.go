@@ -98,18 +98,15 @@ func NewInsert(into string) *Insert {
 	}
 }
 
-func newInsertInto(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, into string) *Insert {
-	id := idFn()
+func newInsertInto(db QueryExecPreparer, lw *logWithID, into string) *Insert {
+	id := lw.makeUniqueID()
+	l := lw.Log
 	if l != nil {
 		l = l.With(log.String("insert_id", id), log.String("table", into))
 	}
 	return &Insert{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  db,
-			},
+			builderCommon: lw.newBuilderCommon(db, id, l),
 		},
 		Into: into,
 	}
@@ -117,17 +114,17 @@ func newInsertInto(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, into str
 
 // InsertInto instantiates a Insert for the given table
 func (c *ConnPool) InsertInto(into string) *Insert {
-	return newInsertInto(c.DB, c.makeUniqueID, c.Log, into)
+	return newInsertInto(c.DB, &c.logWithID, into)
 }
 
 // InsertInto instantiates a Insert for the given table
 func (c *Conn) InsertInto(into string) *Insert {
-	return newInsertInto(c.DB, c.makeUniqueID, c.Log, into)
+	return newInsertInto(c.DB, &c.logWithID, into)
 }
 
 // InsertInto instantiates a Insert for the given table bound to a transaction
 func (tx *Tx) InsertInto(into string) *Insert {
-	return newInsertInto(tx.DB, tx.makeUniqueID, tx.Log, into)
+	return newInsertInto(tx.DB, &tx.logWithID, into)
 }
 
 // WithDB sets the database query object.
@@ -337,7 +334,7 @@ func (b *Insert) toSQL(buf *bytes.Buffer, placeHolders []string) ([]string, erro
 	}
 
 	buf.WriteString("INTO ")
-	Quoter.quote(buf, b.Into)
+	Quoter.WriteIdentifier(buf, b.Into)
 	buf.WriteByte(' ')
 
 	if b.Select != nil {
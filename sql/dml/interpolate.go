@@ -183,6 +183,8 @@ func (in *ip) NullBool(nv NullBool) *ip           { in.args = in.args.add(nv); r
 func (in *ip) NullBools(nv ...NullBool) *ip       { in.args = in.args.add(nv); return in }
 func (in *ip) NullTime(nv NullTime) *ip           { in.args = in.args.add(nv); return in }
 func (in *ip) NullTimes(nv ...NullTime) *ip       { in.args = in.args.add(nv); return in }
+func (in *ip) JSON(j JSON) *ip                    { in.args = in.args.add(j); return in }
+func (in *ip) NullJSON(nv NullJSON) *ip           { in.args = in.args.add(nv); return in }
 
 // DriverValues adds each Valuer as its own argument.
 func (in *ip) DriverValues(dvs ...driver.Valuer) *ip {
@@ -232,12 +234,74 @@ func (in *ip) Named(nArgs ...sql.NamedArg) *ip {
 	return in
 }
 
+// countPlaceHolders counts the place holders in `sql` which are not part of a
+// quoted string literal or identifier, for example a JSON path '$."size?"'.
+func countPlaceHolders(sql string) (n int) {
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case placeHolderRune:
+			n++
+		case '`', '\'', '"':
+			if p := quoteEnd(sql[i+1:], c); p > -1 {
+				i += p + 1
+			}
+		}
+	}
+	return n
+}
+
+// quoteEnd returns the index of the closing quote `q` in `s` or -1. Characters
+// escaped with a backslash get skipped, except in back tick quoted identifiers.
+func quoteEnd(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q != '`' {
+				i++
+			}
+		case q:
+			return i
+		}
+	}
+	return -1
+}
+
+// countPlaceHolderBytes same as countPlaceHolders.
+func countPlaceHolderBytes(sql []byte) (n int) {
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case placeHolderRune:
+			n++
+		case '`', '\'', '"':
+			if p := quoteEndBytes(sql[i+1:], c); p > -1 {
+				i += p + 1
+			}
+		}
+	}
+	return n
+}
+
+// quoteEndBytes same as quoteEnd.
+func quoteEndBytes(s []byte, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if q != '`' {
+				i++
+			}
+		case q:
+			return i
+		}
+	}
+	return -1
+}
+
 // writeInterpolate merges `args` into `sql` and writes the result into `buf`. `sql`
 // stays unchanged.
 func writeInterpolate(buf *bytes.Buffer, sql string, args arguments) error {
 	// TODO support :name identifier and the name field in argument
 
-	phCount, argCount := strings.Count(sql, placeHolderStr), len(args)
+	phCount, argCount := countPlaceHolders(sql), len(args)
 	if argCount > 0 && phCount != argCount {
 		return errors.Mismatch.Newf("[dml] Number of place holders (%d) vs number of arguments (%d) do not match.", phCount, argCount)
 	}
@@ -257,7 +321,7 @@ func writeInterpolate(buf *bytes.Buffer, sql string, args arguments) error {
 			}
 			phCounter++
 		case r == '`', r == '\'', r == '"':
-			p := strings.IndexRune(sql[pos:], r)
+			p := quoteEnd(sql[pos:], byte(r))
 			if r == '"' {
 				r = '\''
 			}
@@ -285,7 +349,7 @@ func writeInterpolate(buf *bytes.Buffer, sql string, args arguments) error {
 // the above original function writeInterpolateByte.
 func writeInterpolateBytes(buf *bytes.Buffer, sql []byte, args arguments) error {
 
-	phCount, argCount := bytes.Count(sql, placeHolderByte), len(args)
+	phCount, argCount := countPlaceHolderBytes(sql), len(args)
 	if argCount > 0 && phCount != argCount {
 		return errors.Mismatch.Newf("[dml] Number of place holders (%d) vs number of arguments (%d) do not match.", phCount, argCount)
 	}
@@ -305,7 +369,7 @@ func writeInterpolateBytes(buf *bytes.Buffer, sql []byte, args arguments) error
 			}
 			phCounter++
 		case r == '`', r == '\'', r == '"':
-			p := bytes.IndexRune(sql[pos:], r)
+			p := quoteEndBytes(sql[pos:], byte(r))
 			if r == '"' {
 				r = '\''
 			}
@@ -963,6 +963,63 @@ func (b *ColumnMap) NullTime(ptr *NullTime) *ColumnMap {
 	return b
 }
 
+// JSON reads a JSON value and appends it to the arguments slice or assigns the
+// raw JSON value stored in sql.RawBytes to the pointer. The data gets copied.
+// See the documentation for function Scan.
+func (b *ColumnMap) JSON(ptr *JSON) *ColumnMap {
+	if b.shouldCollectArgs() {
+		if ptr == nil {
+			b.arguments = b.arguments.add(nil)
+		} else {
+			b.arguments = b.arguments.add(*ptr)
+		}
+		return b
+	}
+	if b.scanErr == nil {
+		switch v := b.scanCol[b.index]; v.field {
+		case 's':
+			*ptr = append((*ptr)[:0], v.string...)
+		case 'y':
+			*ptr = append((*ptr)[:0], v.byte...)
+		case 'n':
+			*ptr = nil
+		default:
+			b.scanErr = errors.NotSupported.Newf("[dml] Column %q does not support field type: %q", b.Column(), v.field)
+		}
+	}
+	return b
+}
+
+// NullJSON reads a JSON value and appends it to the arguments slice or assigns
+// the NullJSON value stored in sql.RawBytes to the pointer. The data gets
+// copied. See the documentation for function Scan.
+func (b *ColumnMap) NullJSON(ptr *NullJSON) *ColumnMap {
+	if b.shouldCollectArgs() {
+		if ptr == nil {
+			b.arguments = b.arguments.add(nil)
+		} else {
+			b.arguments = b.arguments.add(*ptr)
+		}
+		return b
+	}
+	if b.scanErr == nil {
+		switch v := b.scanCol[b.index]; v.field {
+		case 's':
+			ptr.JSON = append(ptr.JSON[:0], v.string...)
+			ptr.Valid = true
+		case 'y':
+			ptr.JSON = append(ptr.JSON[:0], v.byte...)
+			ptr.Valid = v.byte != nil
+		case 'n':
+			ptr.JSON = ptr.JSON[:0]
+			ptr.Valid = false
+		default:
+			b.scanErr = errors.NotSupported.Newf("[dml] Column %q does not support field type: %q", b.Column(), v.field)
+		}
+	}
+	return b
+}
+
 const columnMapErrMsgSlices = "[dml] ColumnMap.%s does only support mode ColumnMapCollectionReadSet"
 
 func (b *ColumnMap) addSlice(fnName string, slice interface{}) *ColumnMap {
@@ -85,19 +85,16 @@ func NewSelectWithDerivedTable(subSelect *Select, aliasName string) *Select {
 	}
 }
 
-func newSelect(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, from []string) *Select {
-	id := idFn()
+func newSelect(db QueryExecPreparer, lw *logWithID, from []string) *Select {
+	id := lw.makeUniqueID()
+	l := lw.Log
 	if l != nil {
 		l = l.With(log.String("select_id", id), log.String("table", from[0]))
 	}
 	s := &Select{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  db,
-			},
-			Table: MakeIdentifier(from[0]),
+			builderCommon: lw.newBuilderCommon(db, id, l),
+			Table:         MakeIdentifier(from[0]),
 		},
 	}
 	if len(from) > 1 {
@@ -108,18 +105,18 @@ func newSelect(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, from []strin
 
 // SelectFrom creates a new Select with a connection from the pool.
 func (c *ConnPool) SelectFrom(fromAlias ...string) *Select {
-	return newSelect(c.DB, c.makeUniqueID, c.Log, fromAlias)
+	return newSelect(c.DB, &c.logWithID, fromAlias)
 }
 
 // SelectFrom creates a new Select in a dedicated connection.
 func (c *Conn) SelectFrom(fromAlias ...string) *Select {
-	return newSelect(c.DB, c.makeUniqueID, c.Log, fromAlias)
+	return newSelect(c.DB, &c.logWithID, fromAlias)
 }
 
 // SelectFrom creates a new Select that select that given columns bound to the
 // transaction.
 func (tx *Tx) SelectFrom(fromAlias ...string) *Select {
-	return newSelect(tx.DB, tx.makeUniqueID, tx.Log, fromAlias)
+	return newSelect(tx.DB, &tx.logWithID, fromAlias)
 }
 
 // SelectBySQL creates a new Select for the given SQL string and arguments.
@@ -131,12 +128,8 @@ func (c *ConnPool) SelectBySQL(sql string) *Select {
 	}
 	return &Select{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  c.DB,
-			},
-			RawFullSQL: sql,
+			builderCommon: c.newBuilderCommon(c.DB, id, l),
+			RawFullSQL:    sql,
 		},
 	}
 }
@@ -151,12 +144,8 @@ func (tx *Tx) SelectBySQL(sql string) *Select {
 	}
 	return &Select{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  tx.DB,
-			},
-			RawFullSQL: sql,
+			builderCommon: tx.newBuilderCommon(tx.DB, id, l),
+			RawFullSQL:    sql,
 		},
 	}
 }
@@ -0,0 +1,230 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+)
+
+// SequenceProfile defines how the values of a sequence get converted into an
+// increment ID, like the Magento table sales_sequence_profile. The increment
+// ID is: Prefix + zero padded (StartValue + (n-1) * Step) + Suffix, where n is
+// the n-th value of the sequence.
+type SequenceProfile struct {
+	Prefix string
+	Suffix string
+	// Padding minimum number of digits, left padded with zeros. Default 9.
+	Padding int
+	// StartValue first value of the sequence. Default 1.
+	StartValue uint64
+	// Step increment between two values. Default 1.
+	Step uint64
+	// MaxValue upper limit. Zero means unlimited. Reaching the limit returns
+	// an Exceeded error.
+	MaxValue uint64
+}
+
+func (p SequenceProfile) withDefaults() SequenceProfile {
+	if p.Padding == 0 {
+		p.Padding = 9
+	}
+	if p.StartValue == 0 {
+		p.StartValue = 1
+	}
+	if p.Step == 0 {
+		p.Step = 1
+	}
+	return p
+}
+
+// Format returns the increment ID of a value returned by
+// SequenceGenerator.Next.
+func (p SequenceProfile) Format(value uint64) string {
+	p = p.withDefaults()
+	v := strconv.FormatUint(value, 10)
+	var buf strings.Builder
+	buf.Grow(len(p.Prefix) + p.Padding + len(v) + len(p.Suffix))
+	buf.WriteString(p.Prefix)
+	for i := len(v); i < p.Padding; i++ {
+		buf.WriteByte('0')
+	}
+	buf.WriteString(v)
+	buf.WriteString(p.Suffix)
+	return buf.String()
+}
+
+// SequenceOptions configures a SequenceGenerator.
+type SequenceOptions struct {
+	// TableName of the table storing the counters. Default dml_sequence. The
+	// table gets created with SequenceGenerator.CreateTable.
+	TableName string
+	// BlockSize number of values reserved with one round trip. Values of a
+	// block not used until the process ends are lost, which leaves gaps.
+	// Default 20.
+	BlockSize uint64
+}
+
+// SequenceGenerator hands out unique and ascending values per sequence, e.g.
+// the order increment IDs per store. Instead of one auto increment table per
+// sequence, like the sequence_order_1 tables of Magento, all counters are
+// stored in one table with one row per sequence. Each round trip reserves a
+// block of values with a single atomic statement, so many processes can share
+// the same table without handing out a value twice. The values of a block are
+// served from memory. SequenceGenerator is safe for concurrent use.
+type SequenceGenerator struct {
+	db          *ConnPool
+	blockSize   uint64
+	sqlCreate   string
+	sqlAllocate string
+
+	mu       sync.Mutex
+	profiles map[string]SequenceProfile
+	blocks   map[string]*sequenceBlock
+}
+
+// sequenceBlock contains the reserved but not yet used values of a sequence.
+type sequenceBlock struct {
+	mu   sync.Mutex
+	next uint64 // next value to hand out
+	last uint64 // last reserved value; next > last means empty
+}
+
+// NewSequenceGenerator creates a new generator which uses the connection
+// pool to reserve the blocks.
+//		sg := dml.NewSequenceGenerator(dbc, dml.SequenceOptions{BlockSize: 50})
+//		sg.SetProfile("order", 1, dml.SequenceProfile{Prefix: "DE-", Padding: 8})
+//		incrementID, err := sg.NextIncrementID(ctx, "order", 1) // DE-00000001
+func NewSequenceGenerator(db *ConnPool, o SequenceOptions) *SequenceGenerator {
+	if o.TableName == "" {
+		o.TableName = "dml_sequence"
+	}
+	if o.BlockSize == 0 {
+		o.BlockSize = 20
+	}
+	tbl := Quoter.Name(o.TableName)
+	return &SequenceGenerator{
+		db:        db,
+		blockSize: o.BlockSize,
+		sqlCreate: "CREATE TABLE IF NOT EXISTS " + tbl + " (" +
+			"`sequence_name` VARCHAR(64) NOT NULL, " +
+			"`sequence_value` BIGINT UNSIGNED NOT NULL, " +
+			"PRIMARY KEY (`sequence_name`)) ENGINE=InnoDB",
+		// LAST_INSERT_ID(expr) returns the new counter value in the OK packet
+		// of the same statement, the row lock serializes concurrent processes.
+		sqlAllocate: "INSERT INTO " + tbl + " (`sequence_name`,`sequence_value`) VALUES (?,LAST_INSERT_ID(?)) " +
+			"ON DUPLICATE KEY UPDATE `sequence_value`=LAST_INSERT_ID(`sequence_value`+?)",
+		profiles: make(map[string]SequenceProfile),
+		blocks:   make(map[string]*sequenceBlock),
+	}
+}
+
+// SequenceName returns the name of the sequence of an entity type in a store,
+// e.g. sequence_order_1.
+func SequenceName(entityType string, storeID uint32) string {
+	return "sequence_" + entityType + "_" + strconv.FormatUint(uint64(storeID), 10)
+}
+
+// CreateTable creates the counter table if it does not exist.
+func (sg *SequenceGenerator) CreateTable(ctx context.Context) error {
+	_, err := sg.db.WithQueryBuilder(QuerySQL(sg.sqlCreate)).ExecContext(ctx)
+	return errors.WithStack(err)
+}
+
+// SetProfile sets the profile of the sequence of an entity type in a store.
+// A sequence without a profile uses the default values of SequenceProfile.
+func (sg *SequenceGenerator) SetProfile(entityType string, storeID uint32, p SequenceProfile) {
+	sg.mu.Lock()
+	sg.profiles[SequenceName(entityType, storeID)] = p.withDefaults()
+	sg.mu.Unlock()
+}
+
+// Profile returns the profile of the sequence of an entity type in a store.
+func (sg *SequenceGenerator) Profile(entityType string, storeID uint32) SequenceProfile {
+	sg.mu.Lock()
+	p, ok := sg.profiles[SequenceName(entityType, storeID)]
+	sg.mu.Unlock()
+	if !ok {
+		p = p.withDefaults()
+	}
+	return p
+}
+
+// Next returns the next value of the sequence of an entity type in a store.
+// The value considers StartValue, Step and MaxValue of the profile.
+func (sg *SequenceGenerator) Next(ctx context.Context, entityType string, storeID uint32) (uint64, error) {
+	name := SequenceName(entityType, storeID)
+	p := sg.Profile(entityType, storeID)
+
+	sg.mu.Lock()
+	b, ok := sg.blocks[name]
+	if !ok {
+		b = new(sequenceBlock)
+		sg.blocks[name] = b
+	}
+	sg.mu.Unlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.next == 0 || b.next > b.last {
+		if err := sg.allocate(ctx, name, b); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	n := b.next
+	b.next++
+
+	value := p.StartValue + (n-1)*p.Step
+	if p.MaxValue > 0 && value > p.MaxValue {
+		return 0, errors.Exceeded.Newf("[dml] SequenceGenerator: Sequence %q value %d exceeds the maximum value %d", name, value, p.MaxValue)
+	}
+	return value, nil
+}
+
+// NextIncrementID returns the next value of the sequence of an entity type in
+// a store formatted with its profile.
+func (sg *SequenceGenerator) NextIncrementID(ctx context.Context, entityType string, storeID uint32) (string, error) {
+	v, err := sg.Next(ctx, entityType, storeID)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return sg.Profile(entityType, storeID).Format(v), nil
+}
+
+// allocate reserves the next block of the sequence name in the database.
+func (sg *SequenceGenerator) allocate(ctx context.Context, name string, b *sequenceBlock) (err error) {
+	if sg.db.Log != nil && sg.db.Log.IsDebug() {
+		defer log.WhenDone(sg.db.Log).Debug("SequenceGenerator.Allocate", log.String("sequence", name), log.Uint64("block_size", sg.blockSize), log.Err(err))
+	}
+	res, err := sg.db.WithQueryBuilder(QuerySQL(sg.sqlAllocate)).ExecContext(ctx, name, sg.blockSize, sg.blockSize)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	last, err := res.LastInsertId()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if uint64(last) < sg.blockSize {
+		return errors.Mismatch.Newf("[dml] SequenceGenerator: Sequence %q returned invalid value %d for block size %d", name, last, sg.blockSize)
+	}
+	b.last = uint64(last)
+	b.next = b.last - sg.blockSize + 1
+	return nil
+}
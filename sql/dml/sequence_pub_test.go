@@ -0,0 +1,90 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequenceProfile_Format(t *testing.T) {
+	t.Parallel()
+
+	assert.Exactly(t, "000000042", dml.SequenceProfile{}.Format(42))
+	assert.Exactly(t, "DE-0042-X", dml.SequenceProfile{Prefix: "DE-", Suffix: "-X", Padding: 4}.Format(42))
+	assert.Exactly(t, "123456", dml.SequenceProfile{Padding: 3}.Format(123456))
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	t.Parallel()
+
+	const sqlAllocate = "INSERT INTO `dml_sequence` (`sequence_name`,`sequence_value`) VALUES (?,LAST_INSERT_ID(?)) ON DUPLICATE KEY UPDATE `sequence_value`=LAST_INSERT_ID(`sequence_value`+?)"
+
+	t.Run("blocks and profiles per store", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlAllocate)).WithArgs("sequence_order_1", 2, 2).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlAllocate)).WithArgs("sequence_order_2", 2, 2).
+			WillReturnResult(sqlmock.NewResult(6, 2))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlAllocate)).WithArgs("sequence_order_1", 2, 2).
+			WillReturnResult(sqlmock.NewResult(8, 2))
+
+		sg := dml.NewSequenceGenerator(dbc, dml.SequenceOptions{BlockSize: 2})
+		sg.SetProfile("order", 2, dml.SequenceProfile{Prefix: "2", Padding: 8, StartValue: 100, Step: 10})
+
+		ctx := context.TODO()
+		var got []string
+		for _, storeID := range []uint32{1, 1, 2, 1} {
+			id, err := sg.NextIncrementID(ctx, "order", storeID)
+			require.NoError(t, err)
+			got = append(got, id)
+		}
+		assert.Exactly(t, []string{"000000001", "000000002", "200000140", "000000007"}, got)
+	})
+
+	t.Run("max value exceeded", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlAllocate)).WithArgs("sequence_invoice_0", 20, 20).
+			WillReturnResult(sqlmock.NewResult(20, 1))
+
+		sg := dml.NewSequenceGenerator(dbc, dml.SequenceOptions{})
+		sg.SetProfile("invoice", 0, dml.SequenceProfile{MaxValue: 1})
+		v, err := sg.Next(context.TODO(), "invoice", 0)
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(1), v)
+		_, err = sg.Next(context.TODO(), "invoice", 0)
+		assert.True(t, errors.Exceeded.Match(err), "%+v", err)
+	})
+
+	t.Run("CreateTable", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE IF NOT EXISTS `increment_ids` (`sequence_name` VARCHAR(64) NOT NULL")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		require.NoError(t, dml.NewSequenceGenerator(dbc, dml.SequenceOptions{TableName: "increment_ids"}).CreateTable(context.TODO()))
+	})
+}
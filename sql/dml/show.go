@@ -55,11 +55,7 @@ func (c *ConnPool) Show() *Show {
 	}
 	return &Show{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, l),
 		},
 	}
 }
@@ -73,11 +69,7 @@ func (c *Conn) Show() *Show {
 	}
 	return &Show{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, l),
 		},
 	}
 }
@@ -91,11 +83,7 @@ func (tx *Tx) Show() *Show {
 	}
 	return &Show{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  tx.DB,
-			},
+			builderCommon: tx.newBuilderCommon(tx.DB, id, l),
 		},
 	}
 }
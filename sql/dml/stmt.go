@@ -63,7 +63,11 @@ func (sw stmtWrapper) QueryRowContext(ctx context.Context, sql string, args ...i
 // forget to call Close!
 type Stmt struct {
 	base builderCommon
+	// Stmt is nil if the statement belongs to the StmtCache, because the
+	// cache closes and replaces the *sql.Stmt at any time.
 	Stmt *sql.Stmt
+	// cache if not nil, the statement belongs to the StmtCache.
+	cache *StmtCache
 }
 
 // WithArgs creates a new argument handler.
@@ -74,9 +78,17 @@ func (st *Stmt) WithArgs() *Artisan {
 		arguments:  args[:0],
 		isPrepared: true,
 	}
-	a.base.DB = stmtWrapper{stmt: st.Stmt}
+	if st.cache == nil {
+		a.base.DB = stmtWrapper{stmt: st.Stmt}
+	}
 	return a
 }
 
-// Close closes the statement in the database and frees its resources.
-func (st *Stmt) Close() error { return st.Stmt.Close() }
+// Close closes the statement in the database and frees its resources. A
+// statement from the StmtCache stays open because the cache owns it.
+func (st *Stmt) Close() error {
+	if st.cache != nil {
+		return nil
+	}
+	return st.Stmt.Close()
+}
@@ -0,0 +1,276 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"sync"
+	"sync/atomic"
+
+	"github.com/corestoreio/errors"
+)
+
+// mySQLErrUnknownStmtHandler gets returned by the server when a prepared
+// statement does not exist anymore, e.g. after a reconnect.
+const mySQLErrUnknownStmtHandler uint16 = 1243
+
+// StmtCacheStats contains the metrics of a StmtCache.
+type StmtCacheStats struct {
+	// Hits number of Prepare calls served from the cache.
+	Hits uint64
+	// Misses number of statements prepared because they were not cached.
+	Misses uint64
+	// Evictions number of statements closed because the cache was full.
+	Evictions uint64
+	// Reprepares number of statements prepared again after a lost connection
+	// or a closed statement.
+	Reprepares uint64
+	// Size current number of prepared statements in the cache.
+	Size int
+}
+
+type stmtCacheItem struct {
+	query string
+	stmt  *sql.Stmt
+	// closed gets set to one before the cache closes the statement. A
+	// concurrent execution which still uses the statement prepares it again.
+	closed uint32
+}
+
+func (it *stmtCacheItem) close() error {
+	atomic.StoreUint32(&it.closed, 1)
+	return it.stmt.Close()
+}
+
+// isGone reports whether the statement must be prepared again because the
+// connection has been lost or the cache has closed the statement.
+func (it *stmtCacheItem) isGone(err error) bool {
+	if err == nil {
+		return false
+	}
+	if atomic.LoadUint32(&it.closed) == 1 || errors.Cause(err) == driver.ErrBadConn {
+		return true
+	}
+	num, ok := mySQLErrorNumber(err)
+	return ok && num == mySQLErrUnknownStmtHandler
+}
+
+// StmtCache caches prepared statements of a ConnPool or a Conn keyed by the
+// SQL string of the DML type. The least recently used statement gets closed
+// once the maximum size has been reached. StmtCache is safe for concurrent
+// use.
+type StmtCache struct {
+	db      QueryExecPreparer
+	maxSize int
+
+	hits       uint64
+	misses     uint64
+	evictions  uint64
+	reprepares uint64
+
+	mu    sync.Mutex
+	lru   *list.List // front most recently used
+	items map[string]*list.Element
+}
+
+func newStmtCache(db QueryExecPreparer, maxSize int) *StmtCache {
+	return &StmtCache{
+		db:      db,
+		maxSize: maxSize,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element, maxSize),
+	}
+}
+
+// WithStmtCache enables an LRU cache for prepared statements with maxSize
+// entries. Each Prepare function of a DML type created by the ConnPool or a
+// Conn returns then a cached statement if the SQL string has already been
+// prepared. A Conn gets its own cache with the same size. Statements prepared
+// within a Tx are not cached. The Close function of a cached Stmt does
+// nothing and its field Stmt is nil, the statements get closed by the eviction
+// or when the ConnPool or Conn gets closed. A statement gets transparently prepared again after a
+// lost connection. The option must be applied after WithDB or WithDSN.
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithStmtCache(500))
+func WithStmtCache(maxSize int) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 17,
+		fn: func(c *ConnPool) error {
+			if maxSize < 1 {
+				return errors.NotValid.Newf("[dml] WithStmtCache: maxSize must be greater than zero, have %d", maxSize)
+			}
+			if c.DB == nil {
+				return errors.NotFound.Newf("[dml] WithStmtCache: DB not found, apply WithDB or WithDSN")
+			}
+			c.stmtCache = newStmtCache(c.DB, maxSize)
+			return nil
+		},
+	}
+}
+
+// isCacheFor reports whether the cache belongs to the database handle db. A Tx
+// inherits the cache of its parent but must not use it.
+func (sc *StmtCache) isCacheFor(db Preparer) bool {
+	return sc != nil && sc.db == db
+}
+
+// Stats returns the current metrics of the cache.
+func (sc *StmtCache) Stats() StmtCacheStats {
+	if sc == nil {
+		return StmtCacheStats{}
+	}
+	sc.mu.Lock()
+	size := sc.lru.Len()
+	sc.mu.Unlock()
+	return StmtCacheStats{
+		Hits:       atomic.LoadUint64(&sc.hits),
+		Misses:     atomic.LoadUint64(&sc.misses),
+		Evictions:  atomic.LoadUint64(&sc.evictions),
+		Reprepares: atomic.LoadUint64(&sc.reprepares),
+		Size:       size,
+	}
+}
+
+// get returns the cached statement for the query or prepares a new one. Only
+// the Prepare functions count hits, executions of a cached Stmt call get with
+// countHit false.
+func (sc *StmtCache) get(ctx context.Context, query string, countHit bool) (*stmtCacheItem, error) {
+	sc.mu.Lock()
+	if e, ok := sc.items[query]; ok {
+		sc.lru.MoveToFront(e)
+		it := e.Value.(*stmtCacheItem)
+		sc.mu.Unlock()
+		if countHit {
+			atomic.AddUint64(&sc.hits, 1)
+		}
+		return it, nil
+	}
+	sc.mu.Unlock()
+
+	atomic.AddUint64(&sc.misses, 1)
+	// Preparing happens outside the lock to not block other queries during a
+	// round trip to the server.
+	stmt, err := sc.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.items[query]; ok {
+		// A concurrent call has been faster.
+		sc.lru.MoveToFront(e)
+		_ = stmt.Close()
+		return e.Value.(*stmtCacheItem), nil
+	}
+	it := &stmtCacheItem{query: query, stmt: stmt}
+	sc.items[query] = sc.lru.PushFront(it)
+	for sc.lru.Len() > sc.maxSize {
+		e := sc.lru.Back()
+		old := sc.lru.Remove(e).(*stmtCacheItem)
+		delete(sc.items, old.query)
+		atomic.AddUint64(&sc.evictions, 1)
+		_ = old.close() // statements in use get closed by database/sql once released
+	}
+	return it, nil
+}
+
+// remove deletes the statement from the cache and closes it, if it is still the
+// cached statement for the query.
+func (sc *StmtCache) remove(it *stmtCacheItem) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if e, ok := sc.items[it.query]; ok && e.Value.(*stmtCacheItem) == it {
+		sc.lru.Remove(e)
+		delete(sc.items, it.query)
+	}
+	_ = it.close()
+}
+
+// reprepare replaces a broken statement with a newly prepared one.
+func (sc *StmtCache) reprepare(ctx context.Context, it *stmtCacheItem) (*stmtCacheItem, error) {
+	sc.remove(it)
+	atomic.AddUint64(&sc.reprepares, 1)
+	return sc.get(ctx, it.query, false)
+}
+
+// Close closes all cached statements and empties the cache. The first error
+// gets returned.
+func (sc *StmtCache) Close() (err error) {
+	if sc == nil {
+		return nil
+	}
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	for e := sc.lru.Front(); e != nil; e = e.Next() {
+		if cErr := e.Value.(*stmtCacheItem).close(); cErr != nil && err == nil {
+			err = errors.WithStack(cErr)
+		}
+	}
+	sc.lru.Init()
+	sc.items = make(map[string]*list.Element, sc.maxSize)
+	return err
+}
+
+// cachedStmt gets used by a Stmt from the StmtCache. It always executes the
+// currently cached *sql.Stmt of the query and prepares the query once again
+// when the statement has gone.
+type cachedStmt struct {
+	cache *StmtCache
+	query string
+}
+
+func (cs cachedStmt) ExecContext(ctx context.Context, args ...interface{}) (sql.Result, error) {
+	it, err := cs.cache.get(ctx, cs.query, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := it.stmt.ExecContext(ctx, args...)
+	if it.isGone(err) {
+		if it, err = cs.cache.reprepare(ctx, it); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res, err = it.stmt.ExecContext(ctx, args...)
+	}
+	return res, err
+}
+
+func (cs cachedStmt) QueryContext(ctx context.Context, args ...interface{}) (*sql.Rows, error) {
+	it, err := cs.cache.get(ctx, cs.query, false)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rows, err := it.stmt.QueryContext(ctx, args...)
+	if it.isGone(err) {
+		if it, err = cs.cache.reprepare(ctx, it); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		rows, err = it.stmt.QueryContext(ctx, args...)
+	}
+	return rows, err
+}
+
+// QueryRowContext cannot detect a gone statement because *sql.Row defers the
+// error to Scan. If the query cannot be prepared, it runs unprepared and Scan
+// reports the error.
+func (cs cachedStmt) QueryRowContext(ctx context.Context, args ...interface{}) *sql.Row {
+	it, err := cs.cache.get(ctx, cs.query, false)
+	if err != nil {
+		return cs.cache.db.QueryRowContext(ctx, cs.query, args...)
+	}
+	return it.stmt.QueryRowContext(ctx, args...)
+}
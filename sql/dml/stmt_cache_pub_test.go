@@ -0,0 +1,127 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sqlEntity = "DELETE FROM `customer_entity` WHERE (`entity_id` = ?)"
+		sqlNote   = "DELETE FROM `customer_note` WHERE (`note_id` = ?)"
+	)
+	deleteFrom := func(p interface {
+		DeleteFrom(string) *dml.Delete
+	}, table, column string) *dml.Delete {
+		return p.DeleteFrom(table).Where(dml.Column(column).PlaceHolder())
+	}
+
+	t.Run("hit miss and eviction", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithStmtCache(1))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		prepEntity := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlEntity)).WillBeClosed()
+		prepEntity.ExpectExec().WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+		prepEntity.ExpectExec().WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+		prepNote := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlNote)).WillBeClosed()
+		prepNote.ExpectExec().WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ctx := context.TODO()
+		for i := 1; i <= 2; i++ {
+			stmt, err := deleteFrom(dbc, "customer_entity", "entity_id").Prepare(ctx)
+			require.NoError(t, err)
+			assert.Nil(t, stmt.Stmt, "cached statement must not be reachable")
+			_, err = stmt.WithArgs().ExecContext(ctx, i)
+			require.NoError(t, err)
+			require.NoError(t, stmt.Close()) // no-op
+		}
+		stmt, err := deleteFrom(dbc, "customer_note", "note_id").Prepare(ctx)
+		require.NoError(t, err)
+		_, err = stmt.WithArgs().ExecContext(ctx, 3)
+		require.NoError(t, err)
+
+		assert.Exactly(t, dml.StmtCacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, dbc.StmtCacheStats())
+	})
+
+	t.Run("re-prepare unknown statement", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithStmtCache(5))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlEntity)).WillBeClosed().
+			ExpectExec().WithArgs(4).WillReturnError(&mysql.MySQLError{Number: 1243, Message: "Unknown prepared statement handler"})
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlEntity)).WillBeClosed().
+			ExpectExec().WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+
+		ctx := context.TODO()
+		stmt, err := deleteFrom(dbc, "customer_entity", "entity_id").Prepare(ctx)
+		require.NoError(t, err)
+		_, err = stmt.WithArgs().ExecContext(ctx, 4)
+		require.NoError(t, err)
+
+		assert.Exactly(t, dml.StmtCacheStats{Misses: 2, Reprepares: 1, Size: 1}, dbc.StmtCacheStats())
+	})
+
+	t.Run("Conn has own cache and Tx bypasses it", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithStmtCache(5))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlEntity)).WillBeClosed().
+			ExpectExec().WithArgs(5).WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectBegin()
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlEntity)).WillBeClosed().
+			ExpectExec().WithArgs(6).WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		ctx := context.TODO()
+		conn, err := dbc.Conn(ctx)
+		require.NoError(t, err)
+
+		stmt, err := deleteFrom(conn, "customer_entity", "entity_id").Prepare(ctx)
+		require.NoError(t, err)
+		_, err = stmt.WithArgs().ExecContext(ctx, 5)
+		require.NoError(t, err)
+
+		require.NoError(t, conn.Transaction(ctx, nil, func(tx *dml.Tx) error {
+			stmt, err := deleteFrom(tx, "customer_entity", "entity_id").Prepare(ctx)
+			if err != nil {
+				return err
+			}
+			defer dmltest.Close(t, stmt)
+			_, err = stmt.WithArgs().ExecContext(ctx, 6)
+			return err
+		}))
+
+		assert.Exactly(t, dml.StmtCacheStats{Misses: 1, Size: 1}, conn.StmtCacheStats())
+		assert.Exactly(t, dml.StmtCacheStats{}, dbc.StmtCacheStats())
+		dmltest.Close(t, conn)
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		_, err := dml.NewConnPool(dml.WithStmtCache(0))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
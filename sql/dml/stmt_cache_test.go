@@ -0,0 +1,52 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStmtCache_EvictedInUse(t *testing.T) {
+	t.Parallel()
+
+	db, dbMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	dbMock.ExpectPrepare("SELECT 1").WillBeClosed()
+	dbMock.ExpectPrepare("SELECT 2").WillBeClosed()
+
+	sc := newStmtCache(db, 1)
+	ctx := context.TODO()
+	it, err := sc.get(ctx, "SELECT 1", true)
+	require.NoError(t, err)
+	assert.False(t, it.isGone(nil))
+
+	// A concurrent Prepare evicts the statement which is still in use.
+	_, err = sc.get(ctx, "SELECT 2", true)
+	require.NoError(t, err)
+
+	_, err = it.stmt.ExecContext(ctx)
+	require.Error(t, err)
+	assert.True(t, it.isGone(err), "%+v", err)
+
+	require.NoError(t, sc.Close())
+	require.NoError(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,359 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/corestoreio/errors"
+)
+
+// TableFilter defines default conditions for a list of tables, for example a
+// soft delete flag or the scoping to a tenant. The conditions get appended to
+// the WHERE clause of Select, Update and Delete statements, or to the ON
+// clause of a JOIN, which use one of the tables. Sub-selects, Union and With
+// statements get filtered too. The filter gets applied before the SQL string
+// gets cached, hence the conditions should either contain static values or
+// named arguments like `dml.Column("website_id").NamedArg("websiteID")`,
+// which get bound with `WithArgs().Name("websiteID").Int(2)`. The name of a
+// named argument can contain only letters and digits. A positional place
+// holder would shift the position of the arguments.
+//
+// A filter can be disabled per statement with the function WithoutFilters of
+// Select, Update and Delete.
+type TableFilter struct {
+	// Name of the filter, used in WithoutFilters. Required.
+	Name string
+	// Tables contains the logical table names to which the filter applies.
+	Tables []string
+	// Conditions get appended with AND to the conditions of a table.
+	Conditions Conditions
+	// SoftDeleteColumn enables the soft delete mode when set. A DELETE
+	// statement gets rewritten into an UPDATE statement which sets the column
+	// to NOW(). Select and Update statements load only rows where the column
+	// IS NULL. The column must be a nullable DATETIME or TIMESTAMP. A multi
+	// table DELETE or a DELETE with RETURNING are not supported.
+	SoftDeleteColumn string
+}
+
+// WithTableFilters registers filters which get applied to all Select, Update
+// and Delete statements created from the connection pool, its Conn and Tx
+// types.
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithTableFilters(
+//			&dml.TableFilter{
+//				Name:             "soft_delete",
+//				Tables:           []string{"customer_note"},
+//				SoftDeleteColumn: "deleted_at",
+//			},
+//			&dml.TableFilter{
+//				Name:       "tenant",
+//				Tables:     []string{"customer_note", "customer_tag"},
+//				Conditions: dml.Conditions{dml.Column("website_id").NamedArg("websiteID")},
+//			},
+//		))
+func WithTableFilters(filters ...*TableFilter) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 15,
+		fn: func(c *ConnPool) error {
+			for _, tf := range filters {
+				if err := tf.validate(); err != nil {
+					return errors.WithStack(err)
+				}
+				c.tableFilters = append(c.tableFilters, tf)
+			}
+			return nil
+		},
+	}
+}
+
+func (tf *TableFilter) validate() error {
+	switch {
+	case tf == nil:
+		return errors.Empty.Newf("[dml] TableFilter cannot be nil")
+	case tf.Name == "":
+		return errors.Empty.Newf("[dml] TableFilter requires a name")
+	case len(tf.Tables) == 0:
+		return errors.Empty.Newf("[dml] TableFilter %q requires at least one table", tf.Name)
+	case len(tf.Conditions) == 0 && tf.SoftDeleteColumn == "":
+		return errors.Empty.Newf("[dml] TableFilter %q requires either Conditions or a SoftDeleteColumn", tf.Name)
+	}
+	return nil
+}
+
+func (tf *TableFilter) hasTable(name string) bool {
+	for _, t := range tf.Tables {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// appendConditions appends the conditions of the filter to cs. A non-empty
+// qualifier gets prepended to the unqualified columns.
+func (tf *TableFilter) appendConditions(cs Conditions, qualifier string) Conditions {
+	if tf.SoftDeleteColumn != "" {
+		cs = append(cs, qualifyCondition(Column(tf.SoftDeleteColumn).Null(), qualifier))
+	}
+	for _, c := range tf.Conditions {
+		cs = append(cs, qualifyCondition(c, qualifier))
+	}
+	return cs
+}
+
+func qualifyCondition(c *Condition, qualifier string) *Condition {
+	if qualifier == "" || c.IsLeftExpression || strings.IndexByte(c.Left, '.') >= 0 {
+		return c
+	}
+	cc := *c
+	cc.Left = qualifier + "." + c.Left
+	return &cc
+}
+
+// withoutFilters disables the filters for the statement and its sub-selects.
+func (bb *BuilderBase) withoutFilters(names []string) {
+	if len(names) == 0 {
+		bb.filtersDisabled = true
+		return
+	}
+	bb.disabledFilters = append(bb.disabledFilters, names...)
+}
+
+func (bb *BuilderBase) isFilterDisabled(name string) bool {
+	if bb.filtersDisabled {
+		return true
+	}
+	for _, n := range bb.disabledFilters {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutFilters disables the TableFilter with the provided names for this
+// statement. Calling it without names disables all filters.
+func (b *Select) WithoutFilters(names ...string) *Select {
+	b.withoutFilters(names)
+	return b
+}
+
+// WithoutFilters disables the TableFilter with the provided names for this
+// statement. Calling it without names disables all filters.
+func (b *Update) WithoutFilters(names ...string) *Update {
+	b.withoutFilters(names)
+	return b
+}
+
+// WithoutFilters disables the TableFilter with the provided names for this
+// statement. Calling it without names disables the soft delete mode and all
+// other filters.
+func (b *Delete) WithoutFilters(names ...string) *Delete {
+	b.withoutFilters(names)
+	return b
+}
+
+// filterApplier walks through a DML type and appends the conditions of the
+// table filters. The original conditions get restored after the SQL string has
+// been build.
+type filterApplier struct {
+	filters []*TableFilter
+	// disabled contains the BuilderBase of the outer statements whose opt-out
+	// settings apply to the current statement.
+	disabled    []*BuilderBase
+	conditions  []filterRestore
+	softDeletes []*Delete
+}
+
+type filterRestore struct {
+	ptr *Conditions
+	old Conditions
+}
+
+func (fa *filterApplier) isDisabled(name string) bool {
+	for _, bb := range fa.disabled {
+		if bb.isFilterDisabled(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendTo appends the filter conditions of table t to the conditions in ptr.
+func (fa *filterApplier) appendTo(ptr *Conditions, t id, qualify bool) {
+	if t.DerivedTable != nil || t.Expression != "" || t.Name == "" {
+		return
+	}
+	var qualifier string
+	if qualify {
+		qualifier = t.qualifier()
+	}
+	cs := *ptr
+	for _, tf := range fa.filters {
+		if tf.hasTable(t.Name) && !fa.isDisabled(tf.Name) {
+			if len(cs) == len(*ptr) {
+				cs = cs[:len(cs):len(cs)] // force a copy to not modify the backing array
+			}
+			cs = tf.appendConditions(cs, qualifier)
+		}
+	}
+	if len(cs) == len(*ptr) {
+		return
+	}
+	fa.conditions = append(fa.conditions, filterRestore{ptr: ptr, old: *ptr})
+	*ptr = cs
+}
+
+func (fa *filterApplier) joins(js Joins) {
+	for _, j := range js {
+		fa.appendTo(&j.On, j.Table, true)
+		fa.subSelects(j.On)
+		if j.Table.DerivedTable != nil {
+			fa.selects(j.Table.DerivedTable)
+		}
+	}
+}
+
+func (fa *filterApplier) subSelects(cs Conditions) {
+	for _, c := range cs {
+		if c != nil && c.Right.Sub != nil {
+			fa.selects(c.Right.Sub)
+		}
+	}
+}
+
+func (fa *filterApplier) push(bb *BuilderBase) func() {
+	fa.disabled = append(fa.disabled, bb)
+	return func() { fa.disabled = fa.disabled[:len(fa.disabled)-1] }
+}
+
+func (fa *filterApplier) selects(sels ...*Select) {
+	for _, s := range sels {
+		if s == nil || s.RawFullSQL != "" {
+			continue
+		}
+		pop := fa.push(&s.BuilderBase)
+		fa.subSelects(s.Wheres)
+		fa.subSelects(s.Havings)
+		fa.joins(s.Joins)
+		if s.Table.DerivedTable != nil {
+			fa.selects(s.Table.DerivedTable)
+		}
+		fa.appendTo(&s.Wheres, s.Table, len(s.Joins) > 0)
+		pop()
+	}
+}
+
+func (fa *filterApplier) union(u *Union) {
+	if u == nil || u.RawFullSQL != "" {
+		return
+	}
+	pop := fa.push(&u.BuilderBase)
+	fa.selects(u.Selects...)
+	pop()
+}
+
+func (fa *filterApplier) update(b *Update) {
+	if b == nil || b.RawFullSQL != "" {
+		return
+	}
+	pop := fa.push(&b.BuilderBase)
+	fa.subSelects(b.Wheres)
+	fa.joins(b.Joins)
+	fa.appendTo(&b.Wheres, b.Table, len(b.Joins) > 0)
+	pop()
+}
+
+func (fa *filterApplier) delete(b *Delete) {
+	if b == nil || b.RawFullSQL != "" {
+		return
+	}
+	pop := fa.push(&b.BuilderBase)
+	defer pop()
+	fa.subSelects(b.Wheres)
+	fa.joins(b.Joins)
+	if b.Table.Name == "" {
+		return
+	}
+	for _, tf := range fa.filters {
+		if tf.SoftDeleteColumn != "" && tf.hasTable(b.Table.Name) && !fa.isDisabled(tf.Name) {
+			b.softDeleteColumn = tf.SoftDeleteColumn
+			fa.softDeletes = append(fa.softDeletes, b)
+			break
+		}
+	}
+	fa.appendTo(&b.Wheres, b.Table, len(b.Joins) > 0 || len(b.MultiTables) > 0)
+}
+
+// apply appends the filter conditions to all tables in qb.
+func (fa *filterApplier) apply(qb queryBuilder) {
+	switch b := qb.(type) {
+	case *Select:
+		fa.selects(b)
+	case *Insert:
+		if b.RawFullSQL == "" {
+			pop := fa.push(&b.BuilderBase)
+			fa.selects(b.Select)
+			pop()
+		}
+	case *Update:
+		fa.update(b)
+	case *Delete:
+		fa.delete(b)
+	case *Union:
+		fa.union(b)
+	case *With:
+		if b.RawFullSQL != "" {
+			return
+		}
+		pop := fa.push(&b.BuilderBase)
+		for _, sc := range b.Subclauses {
+			fa.selects(sc.Select)
+			fa.union(sc.Union)
+		}
+		fa.selects(b.TopLevel.Select)
+		fa.union(b.TopLevel.Union)
+		fa.update(b.TopLevel.Update)
+		fa.delete(b.TopLevel.Delete)
+		pop()
+	}
+}
+
+// restore resets all modified conditions to their original values.
+func (fa *filterApplier) restore() {
+	for i := len(fa.conditions) - 1; i >= 0; i-- {
+		*fa.conditions[i].ptr = fa.conditions[i].old
+	}
+	for _, d := range fa.softDeletes {
+		d.softDeleteColumn = ""
+	}
+	fa.conditions = fa.conditions[:0]
+	fa.softDeletes = fa.softDeletes[:0]
+}
+
+// rewriteToSQL calls toSQL of the query builder with the applied table filters
+// and the table mapper.
+func (bb *BuilderBase) rewriteToSQL(qb queryBuilder, shard string, w *bytes.Buffer) ([]string, error) {
+	if len(bb.tableFilters) > 0 {
+		fa := filterApplier{filters: bb.tableFilters}
+		fa.apply(qb)
+		defer fa.restore()
+	}
+	if bb.tableMapper != nil {
+		return bb.tableMapper.toSQLMapped(qb, shard, w)
+	}
+	return qb.toSQL(w, []string{})
+}
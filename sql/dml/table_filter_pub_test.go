@@ -0,0 +1,187 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTableFilters() []*dml.TableFilter {
+	return []*dml.TableFilter{
+		{
+			Name:             "soft_delete",
+			Tables:           []string{"customer_note"},
+			SoftDeleteColumn: "deleted_at",
+		},
+		{
+			Name:       "tenant",
+			Tables:     []string{"customer_note", "customer_tag"},
+			Conditions: dml.Conditions{dml.Column("website_id").Int(2)},
+		},
+	}
+}
+
+func TestTableFilter_ToSQL(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t, dml.WithTableFilters(newTableFilters()...))
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	tests := []struct {
+		name string
+		qb   dml.QueryBuilder
+		want string
+	}{
+		{
+			"Select",
+			dbc.SelectFrom("customer_note").Star().Where(dml.Column("customer_id").Int(5)),
+			"SELECT * FROM `customer_note` WHERE (`customer_id` = 5) AND (`deleted_at` IS NULL) AND (`website_id` = 2)",
+		},
+		{
+			"Select not configured table",
+			dbc.SelectFrom("customer_entity").Star(),
+			"SELECT * FROM `customer_entity`",
+		},
+		{
+			"Select join",
+			dbc.SelectFrom("customer_entity", "ce").Star().
+				LeftJoin(dml.MakeIdentifier("customer_tag").Alias("ct"), dml.Column("ct.customer_id").Equal().Column("ce.entity_id")),
+			"SELECT * FROM `customer_entity` AS `ce` LEFT JOIN `customer_tag` AS `ct` ON (`ct`.`customer_id` = `ce`.`entity_id`) AND (`ct`.`website_id` = 2)",
+		},
+		{
+			"Select sub-select",
+			dbc.SelectFrom("customer_entity").Star().Where(
+				dml.Column("entity_id").In().Sub(dml.NewSelect("customer_id").From("customer_tag")),
+			),
+			"SELECT * FROM `customer_entity` WHERE (`entity_id` IN (SELECT `customer_id` FROM `customer_tag` WHERE (`website_id` = 2)))",
+		},
+		{
+			"Select without tenant filter",
+			dbc.SelectFrom("customer_note").Star().WithoutFilters("tenant"),
+			"SELECT * FROM `customer_note` WHERE (`deleted_at` IS NULL)",
+		},
+		{
+			"Select without all filters",
+			dbc.SelectFrom("customer_note").Star().WithoutFilters(),
+			"SELECT * FROM `customer_note`",
+		},
+		{
+			"Update",
+			dbc.Update("customer_tag").Set(dml.Column("name").Str("VIP")),
+			"UPDATE `customer_tag` SET `name`='VIP' WHERE (`website_id` = 2)",
+		},
+		{
+			"Delete soft",
+			dbc.DeleteFrom("customer_note").Where(dml.Column("note_id").Int(3)),
+			"UPDATE `customer_note` SET `deleted_at`=NOW() WHERE (`note_id` = 3) AND (`deleted_at` IS NULL) AND (`website_id` = 2)",
+		},
+		{
+			"Delete soft with join",
+			dbc.DeleteFrom("customer_note").Alias("cn").
+				Join(dml.MakeIdentifier("customer_entity").Alias("ce"), dml.Column("ce.entity_id").Equal().Column("cn.customer_id")).
+				Where(dml.Column("ce.is_active").Int(0)),
+			"UPDATE `customer_note` AS `cn` INNER JOIN `customer_entity` AS `ce` ON (`ce`.`entity_id` = `cn`.`customer_id`) SET `cn`.`deleted_at`=NOW() WHERE (`ce`.`is_active` = 0) AND (`cn`.`deleted_at` IS NULL) AND (`cn`.`website_id` = 2)",
+		},
+		{
+			"Delete hard",
+			dbc.DeleteFrom("customer_note").Where(dml.Column("note_id").Int(3)).WithoutFilters("soft_delete"),
+			"DELETE FROM `customer_note` WHERE (`note_id` = 3) AND (`website_id` = 2)",
+		},
+		{
+			"Union",
+			dbc.Union(dml.NewSelect("name").From("customer_tag"), dml.NewSelect("name").From("customer_group")),
+			"(SELECT `name` FROM `customer_tag` WHERE (`website_id` = 2))\nUNION\n(SELECT `name` FROM `customer_group`)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sqlStr, _, err := test.qb.ToSQL()
+			require.NoError(t, err)
+			assert.Exactly(t, test.want, sqlStr)
+		})
+	}
+
+	t.Run("soft delete with RETURNING", func(t *testing.T) {
+		del := dbc.DeleteFrom("customer_note")
+		del.Returning = dml.NewSelect("note_id")
+		_, _, err := del.ToSQL()
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+}
+
+func TestTableFilter_Validate(t *testing.T) {
+	t.Parallel()
+
+	_, err := dml.NewConnPool(dml.WithTableFilters(&dml.TableFilter{Name: "x", Tables: []string{"y"}}))
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+}
+
+func TestTableFilter_WithTableMapper(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t,
+		dml.WithTableFilters(newTableFilters()...),
+		dml.WithTableMapper(&dml.TableMapper{Prefix: "mage_"}),
+	)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(regexp.QuoteMeta("UPDATE `mage_customer_note` SET `deleted_at`=NOW() WHERE (`note_id` = ?) AND (`deleted_at` IS NULL) AND (`website_id` = 2)")).
+		WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+		_, err := tx.DeleteFrom("customer_note").Where(dml.Column("note_id").PlaceHolder()).WithArgs().ExecContext(context.TODO(), 7)
+		return err
+	}))
+}
+
+func TestTableFilter_NamedArg(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t, dml.WithTableFilters(&dml.TableFilter{
+		Name:       "tenant",
+		Tables:     []string{"customer_note", "customer_tag"},
+		Conditions: dml.Conditions{dml.Column("website_id").NamedArg("websiteID")},
+	}))
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `note_id` FROM `customer_note` WHERE (`customer_id` = ?) AND (`website_id` = ?)")).
+		WithArgs(5, 2).WillReturnRows(sqlmock.NewRows([]string{"note_id"}).AddRow(8))
+	dbMock.ExpectExec(regexp.QuoteMeta("UPDATE `customer_tag` SET `is_vip`=1 WHERE (`customer_id` = 5) AND (`website_id` = ?)")).
+		WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 4))
+
+	ids, err := dbc.SelectFrom("customer_note").AddColumns("note_id").
+		Where(dml.Column("customer_id").PlaceHolder()).
+		WithArgs().Int(5).Name("websiteID").Int(2).LoadInt64s(context.TODO())
+	require.NoError(t, err)
+	assert.Exactly(t, []int64{8}, ids)
+
+	res, err := dbc.Update("customer_tag").Set(dml.Column("is_vip").Int(1)).
+		Where(dml.Column("customer_id").Int(5)).
+		WithArgs().Name("websiteID").Int(3).ExecContext(context.TODO())
+	require.NoError(t, err)
+	ra, _ := res.RowsAffected()
+	assert.Exactly(t, int64(4), ra)
+}
@@ -0,0 +1,278 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"github.com/corestoreio/errors"
+)
+
+// TableMapper rewrites the logical table names used in the DML types into the
+// physical table names of the database. A Magento installation can use a table
+// prefix and a multi store setup might run each store in a separately sharded
+// schema. The rewrite gets applied to all table names of Select, Insert,
+// Update, Delete, Union and With including JOINs, sub-selects, derived tables
+// and the RETURNING clause, before the SQL string gets cached. Names of common
+// table expressions and RawFullSQL stay untouched. A table without an alias
+// gets its logical name as alias, hence qualified columns in conditions keep
+// working. Exceptions are the table of an INSERT and of a single table DELETE.
+//
+// The rewrite order is: MapFn, Prefix and then the schema qualification. A
+// logical table name which already contains a schema (`schema.table`) keeps its
+// schema.
+type TableMapper struct {
+	// Prefix gets prepended to each table name, e.g. "mage_".
+	Prefix string
+	// Schema qualifies each table name with a database name. Gets used when
+	// ShardFn is nil or returns an empty string.
+	Schema string
+	// MapFn maps a logical table name to a new name. Optional.
+	MapFn func(name string) string
+	// ShardFn returns the schema name for the current context, for example
+	// derived from the website ID of the scope package. The Artisan calls
+	// ShardFn in every Exec and Query function and switches to the SQL
+	// string of that shard. Every shard has its own build cache entry, hence
+	// the DML types keep their statement data. ToSQL, String, Prepare and
+	// WithQueryBuilder use the default shard, which means the Schema field.
+	ShardFn func(ctx context.Context) (schema string)
+}
+
+// WithTableMapper applies the table name rewrite to all DML types created
+// from the connection pool, its Conn and Tx types.
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithTableMapper(&dml.TableMapper{
+//			Prefix: "mage_",
+//			ShardFn: func(ctx context.Context) string {
+//				_, websiteID := scope.FromContext(ctx)
+//				return "shop_" + strconv.FormatInt(websiteID, 10)
+//			},
+//		}))
+func WithTableMapper(tm *TableMapper) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 13,
+		fn: func(c *ConnPool) error {
+			if tm == nil {
+				return errors.Empty.Newf("[dml] WithTableMapper: TableMapper cannot be nil")
+			}
+			tmc := *tm
+			c.tableMapper = &tmc
+			return nil
+		},
+	}
+}
+
+func (tm *TableMapper) isSharded() bool {
+	return tm != nil && tm.ShardFn != nil
+}
+
+// physicalName returns the physical name of a table and the logical name
+// without the schema.
+func (tm *TableMapper) physicalName(name, shard string) (physical, logical string) {
+	schema, table := "", name
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		schema, table = name[:i], name[i+1:]
+	}
+	logical = table
+	if tm.MapFn != nil {
+		table = tm.MapFn(table)
+	}
+	table = tm.Prefix + table
+	if schema == "" {
+		schema = shard
+	}
+	if schema == "" {
+		schema = tm.Schema
+	}
+	if schema == "" {
+		return table, logical
+	}
+	return schema + "." + table, logical
+}
+
+type tableRename struct {
+	name     *string
+	alias    *string
+	oldName  string
+	oldAlias string
+}
+
+// tableRewriter walks through a DML type and rewrites all table names. The old
+// names get restored after the SQL string has been build to allow a rebuild
+// for another shard.
+type tableRewriter struct {
+	tm       *TableMapper
+	shard    string
+	cteNames []string
+	renamed  []tableRename
+}
+
+func (tr *tableRewriter) isCTE(name string) bool {
+	for _, n := range tr.cteNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (tr *tableRewriter) isRenamed(name *string) bool {
+	for _, r := range tr.renamed {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rename rewrites a table name and sets the logical name as alias if the
+// pointer to the alias is not nil.
+func (tr *tableRewriter) rename(name, alias *string) {
+	if *name == "" || strings.IndexByte(*name, quoteRune) >= 0 || tr.isCTE(*name) || tr.isRenamed(name) {
+		return
+	}
+	r := tableRename{name: name, alias: alias, oldName: *name}
+	physical, logical := tr.tm.physicalName(*name, tr.shard)
+	*name = physical
+	if alias != nil {
+		r.oldAlias = *alias
+		if *alias == "" && physical != logical {
+			*alias = logical
+		}
+	}
+	tr.renamed = append(tr.renamed, r)
+}
+
+func (tr *tableRewriter) table(t *id, withAlias bool) {
+	switch {
+	case t.DerivedTable != nil:
+		tr.selects(t.DerivedTable)
+	case t.Expression != "":
+		// an expression stays untouched
+	case withAlias:
+		tr.rename(&t.Name, &t.Aliased)
+	default:
+		tr.rename(&t.Name, nil)
+	}
+}
+
+func (tr *tableRewriter) joins(js Joins) {
+	for _, j := range js {
+		tr.table(&j.Table, true)
+		tr.conditions(j.On)
+	}
+}
+
+func (tr *tableRewriter) conditions(cs Conditions) {
+	for _, c := range cs {
+		if c != nil && c.Right.Sub != nil {
+			tr.selects(c.Right.Sub)
+		}
+	}
+}
+
+func (tr *tableRewriter) selects(sels ...*Select) {
+	for _, s := range sels {
+		if s == nil || s.RawFullSQL != "" {
+			continue
+		}
+		tr.table(&s.Table, true)
+		tr.joins(s.Joins)
+		tr.conditions(s.Wheres)
+		tr.conditions(s.Havings)
+	}
+}
+
+func (tr *tableRewriter) union(u *Union) {
+	if u != nil && u.RawFullSQL == "" {
+		tr.selects(u.Selects...)
+	}
+}
+
+func (tr *tableRewriter) update(b *Update) {
+	if b != nil && b.RawFullSQL == "" {
+		tr.table(&b.Table, true)
+		tr.joins(b.Joins)
+		tr.conditions(b.Wheres)
+		tr.conditions(b.SetClauses)
+	}
+}
+
+func (tr *tableRewriter) delete(b *Delete) {
+	if b == nil || b.RawFullSQL != "" {
+		return
+	}
+	// MySQL supports an alias in a single table DELETE only since 8.0.16.
+	tr.table(&b.Table, len(b.Joins) > 0 || len(b.MultiTables) > 0)
+	tr.joins(b.Joins)
+	tr.conditions(b.Wheres)
+	tr.selects(b.Returning)
+}
+
+// rewrite applies the table mapping to all table names in qb.
+func (tr *tableRewriter) rewrite(qb queryBuilder) {
+	switch b := qb.(type) {
+	case *Select:
+		tr.selects(b)
+	case *Insert:
+		if b.RawFullSQL == "" {
+			tr.rename(&b.Into, nil)
+			tr.selects(b.Select)
+			tr.conditions(b.OnDuplicateKeys)
+		}
+	case *Update:
+		tr.update(b)
+	case *Delete:
+		tr.delete(b)
+	case *Union:
+		tr.union(b)
+	case *With:
+		if b.RawFullSQL != "" {
+			return
+		}
+		for _, sc := range b.Subclauses {
+			tr.cteNames = append(tr.cteNames, sc.Name)
+		}
+		for _, sc := range b.Subclauses {
+			tr.selects(sc.Select)
+			tr.union(sc.Union)
+		}
+		tr.selects(b.TopLevel.Select)
+		tr.union(b.TopLevel.Union)
+		tr.update(b.TopLevel.Update)
+		tr.delete(b.TopLevel.Delete)
+	}
+}
+
+// restore resets all table names and aliases to their logical values.
+func (tr *tableRewriter) restore() {
+	for i := len(tr.renamed) - 1; i >= 0; i-- {
+		r := tr.renamed[i]
+		*r.name = r.oldName
+		if r.alias != nil {
+			*r.alias = r.oldAlias
+		}
+	}
+	tr.renamed = tr.renamed[:0]
+}
+
+// toSQLMapped calls toSQL of the query builder with rewritten table names.
+func (tm *TableMapper) toSQLMapped(qb queryBuilder, shard string, w *bytes.Buffer) ([]string, error) {
+	tr := tableRewriter{tm: tm, shard: shard}
+	tr.rewrite(qb)
+	defer tr.restore()
+	return qb.toSQL(w, []string{})
+}
@@ -0,0 +1,160 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ctxKeyShard struct{}
+
+func shardFromContext(ctx context.Context) string {
+	s, _ := ctx.Value(ctxKeyShard{}).(string)
+	return s
+}
+
+func TestTableMapper_ToSQL(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t, dml.WithTableMapper(&dml.TableMapper{
+		Prefix: "mage_",
+		Schema: "shop",
+	}))
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	t.Run("Select with join and sub-select", func(t *testing.T) {
+		sel := dbc.SelectFrom("catalog_product_entity", "cpe").AddColumns("cpe.entity_id", "sku").
+			Join(dml.MakeIdentifier("catalog_product_website"), dml.Column("cpe.entity_id").Equal().Column("catalog_product_website.product_id")).
+			Where(dml.Column("cpe.entity_id").In().Sub(
+				dml.NewSelect("product_id").From("cataloginventory_stock_item").Where(dml.Column("is_in_stock").Int(1)),
+			))
+		sqlStr, _, err := sel.ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "SELECT `cpe`.`entity_id`, `sku` FROM `shop`.`mage_catalog_product_entity` AS `cpe` "+
+			"INNER JOIN `shop`.`mage_catalog_product_website` AS `catalog_product_website` ON (`cpe`.`entity_id` = `catalog_product_website`.`product_id`) "+
+			"WHERE (`cpe`.`entity_id` IN (SELECT `product_id` FROM `shop`.`mage_cataloginventory_stock_item` AS `cataloginventory_stock_item` WHERE (`is_in_stock` = 1)))",
+			sqlStr)
+	})
+
+	t.Run("Insert and Update", func(t *testing.T) {
+		sqlStr, _, err := dbc.InsertInto("core_config_data").AddColumns("path", "value").WithArgs().String("a/b/c").String("x").ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "INSERT INTO `shop`.`mage_core_config_data` (`path`,`value`) VALUES (?,?)", sqlStr)
+
+		sqlStr, _, err = dbc.Update("core_config_data").Set(dml.Column("value").Str("y")).
+			Where(dml.Column("core_config_data.path").Str("a/b/c")).ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "UPDATE `shop`.`mage_core_config_data` AS `core_config_data` SET `value`='y' WHERE (`core_config_data`.`path` = 'a/b/c')", sqlStr)
+	})
+
+	t.Run("Delete single and multi table", func(t *testing.T) {
+		sqlStr, _, err := dbc.DeleteFrom("customer_entity").Where(dml.Column("entity_id").Int(3)).ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "DELETE FROM `shop`.`mage_customer_entity` WHERE (`entity_id` = 3)", sqlStr)
+
+		sqlStr, _, err = dbc.DeleteFrom("customer_entity").
+			Join(dml.MakeIdentifier("customer_address_entity").Alias("cae"), dml.Column("cae.parent_id").Equal().Column("customer_entity.entity_id")).
+			FromTables("cae").ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "DELETE `customer_entity`,`cae` FROM `shop`.`mage_customer_entity` AS `customer_entity` "+
+			"INNER JOIN `shop`.`mage_customer_address_entity` AS `cae` ON (`cae`.`parent_id` = `customer_entity`.`entity_id`)", sqlStr)
+	})
+
+	t.Run("Union", func(t *testing.T) {
+		sqlStr, _, err := dbc.Union(
+			dml.NewSelect("value").From("core_config_data"),
+			dml.NewSelect("value").From("other.core_config_data"),
+		).ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "(SELECT `value` FROM `shop`.`mage_core_config_data` AS `core_config_data`)\nUNION\n"+
+			"(SELECT `value` FROM `other`.`mage_core_config_data` AS `core_config_data`)", sqlStr)
+	})
+
+	t.Run("With CTE name untouched", func(t *testing.T) {
+		sqlStr, _, err := dbc.With(dml.WithCTE{
+			Name:   "sales",
+			Select: dml.NewSelect("store_id").AddColumnsConditions(dml.Expr("SUM(grand_total)").Alias("total")).From("sales_order").GroupBy("store_id"),
+		}).Select(dml.NewSelect("total").From("sales")).ToSQL()
+		require.NoError(t, err)
+		assert.Exactly(t, "WITH `sales` AS (SELECT `store_id`, SUM(grand_total) AS `total` FROM `shop`.`mage_sales_order` AS `sales_order` GROUP BY `store_id`)\n"+
+			"SELECT `total` FROM `sales`", sqlStr)
+	})
+}
+
+func TestTableMapper_TableNameMapper(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t, dml.ConnPoolOption{
+		TableNameMapper: strings.ToUpper,
+	})
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	sqlStr, _, err := dbc.SelectFrom("store").Star().ToSQL()
+	require.NoError(t, err)
+	assert.Exactly(t, "SELECT * FROM `STORE` AS `store`", sqlStr)
+}
+
+func TestTableMapper_Shard(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t, dml.WithTableMapper(&dml.TableMapper{
+		Prefix:  "mage_",
+		Schema:  "shop_default",
+		ShardFn: shardFromContext,
+	}))
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `value` FROM `shop_1`.`mage_core_config_data` AS `core_config_data` WHERE (`path` = ?)")).
+		WithArgs("a/b/c").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("shop1"))
+	dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `value` FROM `shop_2`.`mage_core_config_data` AS `core_config_data` WHERE (`path` = ?)")).
+		WithArgs("a/b/c").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("shop2"))
+	dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `value` FROM `shop_default`.`mage_core_config_data` AS `core_config_data` WHERE (`path` = ?)")).
+		WithArgs("a/b/c").WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("default"))
+	dbMock.ExpectExec(regexp.QuoteMeta("UPDATE `shop_2`.`mage_core_config_data` AS `core_config_data` SET `value`=? WHERE (`path` = ?)")).
+		WithArgs("x", "a/b/c").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	sel := dbc.SelectFrom("core_config_data").AddColumns("value").Where(dml.Column("path").PlaceHolder())
+	a := sel.WithArgs()
+	for _, test := range []struct {
+		shard string
+		want  string
+	}{
+		{"shop_1", "shop1"},
+		{"shop_2", "shop2"},
+		{"", "default"},
+	} {
+		v, err := a.String("a/b/c").LoadString(context.WithValue(context.Background(), ctxKeyShard{}, test.shard))
+		require.NoError(t, err)
+		assert.Exactly(t, test.want, v)
+		a.Reset()
+	}
+
+	sqlStr, _, err := sel.ToSQL()
+	require.NoError(t, err)
+	assert.Exactly(t, "SELECT `value` FROM `shop_default`.`mage_core_config_data` AS `core_config_data` WHERE (`path` = ?)", sqlStr)
+
+	upd := dbc.Update("core_config_data").AddColumns("value").Where(dml.Column("path").PlaceHolder())
+	_, err = upd.WithArgs().String("x").String("a/b/c").ExecContext(context.WithValue(context.Background(), ctxKeyShard{}, "shop_2"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL error numbers which allow a retry of the whole transaction.
+const (
+	mySQLErrLockWaitTimeout uint16 = 1205
+	mySQLErrDeadlock        uint16 = 1213
+)
+
+// TxRetryPolicy defines how ConnPool.Transaction and Conn.Transaction repeat a
+// transaction which failed due to a deadlock or a lock wait timeout. MySQL
+// rolls back the whole transaction in such a case, hence all functions of the
+// transaction get called again. The functions must not have side effects
+// outside of the transaction. The waiting time between two attempts grows
+// exponentially from InitialBackoff up to MaxBackoff.
+type TxRetryPolicy struct {
+	// MaxAttempts total number of attempts including the first one. Default 3.
+	MaxAttempts int
+	// InitialBackoff waiting time before the second attempt. Default 20ms.
+	InitialBackoff time.Duration
+	// MaxBackoff upper limit of the waiting time. Default 2s.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff after each attempt. Default 2.
+	Multiplier float64
+	// Jitter randomizes the backoff by +/- the fraction of the backoff to
+	// avoid that concurrent transactions collide again. A value between 0 and
+	// 1, e.g. 0.2. Zero disables the jitter.
+	Jitter float64
+	// ErrorNumbers defines the retriable MySQL error numbers. Default 1213
+	// (ER_LOCK_DEADLOCK) and 1205 (ER_LOCK_WAIT_TIMEOUT).
+	ErrorNumbers []uint16
+}
+
+// WithTxRetryPolicy enables the retry of transactions started with
+// ConnPool.Transaction and Conn.Transaction. Zero fields of the policy are
+// getting replaced with their default values. Each retry gets logged with
+// Info level to the logger of the connection pool.
+//		dml.NewConnPool(dml.WithDSN(dsn), dml.WithTxRetryPolicy(dml.TxRetryPolicy{
+//			MaxAttempts: 5,
+//			Jitter:      0.2,
+//		}))
+func WithTxRetryPolicy(p TxRetryPolicy) ConnPoolOption {
+	return ConnPoolOption{
+		sortOrder: 16,
+		fn: func(c *ConnPool) error {
+			if p.MaxAttempts < 0 || p.Jitter < 0 || p.Jitter > 1 || p.Multiplier < 0 {
+				return errors.NotValid.Newf("[dml] WithTxRetryPolicy: invalid policy %#v", p)
+			}
+			if p.MaxAttempts == 0 {
+				p.MaxAttempts = 3
+			}
+			if p.InitialBackoff == 0 {
+				p.InitialBackoff = 20 * time.Millisecond
+			}
+			if p.MaxBackoff == 0 {
+				p.MaxBackoff = 2 * time.Second
+			}
+			if p.Multiplier == 0 {
+				p.Multiplier = 2
+			}
+			if len(p.ErrorNumbers) == 0 {
+				p.ErrorNumbers = []uint16{mySQLErrDeadlock, mySQLErrLockWaitTimeout}
+			}
+			c.txRetry = &p
+			return nil
+		},
+	}
+}
+
+// mySQLErrorNumber extracts the error number of a go-sql-driver/mysql error.
+func mySQLErrorNumber(err error) (uint16, bool) {
+	for err != nil {
+		if me, ok := errors.Cause(err).(*mysql.MySQLError); ok {
+			return me.Number, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return 0, false
+		}
+		err = u.Unwrap()
+	}
+	return 0, false
+}
+
+func (p *TxRetryPolicy) isRetriable(err error) (uint16, bool) {
+	num, ok := mySQLErrorNumber(err)
+	if !ok {
+		return 0, false
+	}
+	for _, n := range p.ErrorNumbers {
+		if n == num {
+			return num, true
+		}
+	}
+	return num, false
+}
+
+// backoff returns the waiting time after the attempt, starting with 1.
+func (p *TxRetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if d >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// run calls txFn until it succeeds, returns a non retriable error, the
+// attempts are exhausted or the context gets canceled. A nil policy calls txFn
+// once.
+func (p *TxRetryPolicy) run(ctx context.Context, l log.Logger, txFn func() error) error {
+	if p == nil {
+		return txFn()
+	}
+	for attempt := 1; ; attempt++ {
+		err := txFn()
+		if err == nil {
+			return nil
+		}
+		num, ok := p.isRetriable(err)
+		if !ok {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return errors.Wrapf(err, "[dml] Transaction failed after %d attempts", attempt)
+		}
+
+		backoff := p.backoff(attempt)
+		if l != nil && l.IsInfo() {
+			l.Info("dml.Transaction.Retry",
+				log.Int("attempt", attempt), log.Int("max_attempts", p.MaxAttempts),
+				log.Uint64("mysql_error_number", uint64(num)), log.Duration("backoff", backoff), log.Err(err))
+		}
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Wrapf(err, "[dml] Transaction retry aborted after %d attempts: %s", attempt, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
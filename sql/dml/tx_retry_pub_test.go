@@ -0,0 +1,131 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxRetryPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := dml.WithTxRetryPolicy(dml.TxRetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		Jitter:         0.5,
+	})
+	deadlock := &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}
+	updateFn := func(tx *dml.Tx) error {
+		_, err := tx.Update("tableX").Set(dml.Column("value").Int(5)).WithArgs().ExecContext(context.TODO())
+		return err
+	}
+
+	t.Run("retry succeeds", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, policy)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(deadlock)
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(&mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"})
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		var calls int
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			calls++
+			return updateFn(tx)
+		}))
+		assert.Exactly(t, 3, calls)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, policy)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		for i := 0; i < 3; i++ {
+			dbMock.ExpectBegin()
+			dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(deadlock)
+			dbMock.ExpectRollback()
+		}
+		err := dbc.Transaction(context.TODO(), nil, updateFn)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed after 3 attempts")
+		assert.Contains(t, err.Error(), "Deadlock found")
+	})
+
+	t.Run("not retriable", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, policy)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+		dbMock.ExpectRollback()
+
+		err := dbc.Transaction(context.TODO(), nil, updateFn)
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "attempts")
+	})
+
+	t.Run("context canceled during backoff", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithTxRetryPolicy(dml.TxRetryPolicy{InitialBackoff: time.Hour}))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(deadlock)
+		dbMock.ExpectRollback()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := dbc.Transaction(ctx, nil, updateFn)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "retry aborted after 1 attempts")
+	})
+
+	t.Run("Conn", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, policy)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnError(deadlock)
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("UPDATE `tableX` SET `value`").WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		conn, err := dbc.Conn(context.TODO())
+		require.NoError(t, err)
+		defer dmltest.Close(t, conn)
+		require.NoError(t, conn.Transaction(context.TODO(), nil, updateFn))
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		_, err := dml.NewConnPool(dml.WithTxRetryPolicy(dml.TxRetryPolicy{Jitter: 2}))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxRetryPolicy_backoff(t *testing.T) {
+	t.Parallel()
+
+	p := &TxRetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     50 * time.Millisecond,
+		Multiplier:     2,
+	}
+	assert.Exactly(t, 10*time.Millisecond, p.backoff(1))
+	assert.Exactly(t, 20*time.Millisecond, p.backoff(2))
+	assert.Exactly(t, 40*time.Millisecond, p.backoff(3))
+	assert.Exactly(t, 50*time.Millisecond, p.backoff(4))
+	assert.Exactly(t, 50*time.Millisecond, p.backoff(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.backoff(2)
+		assert.True(t, d >= 10*time.Millisecond && d <= 30*time.Millisecond, "backoff %s out of range", d)
+	}
+}
+
+func TestMySQLErrorNumber(t *testing.T) {
+	t.Parallel()
+
+	num, ok := mySQLErrorNumber(errors.Wrapf(&mysql.MySQLError{Number: 1213}, "[dml] ExecContext"))
+	assert.True(t, ok)
+	assert.Exactly(t, uint16(1213), num)
+
+	_, ok = mySQLErrorNumber(errors.NotFound.Newf("not found"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,79 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+)
+
+// Transaction runs the functions in a nested transaction which maps to a
+// SAVEPOINT within the current transaction. The savepoint gets released once
+// all functions have been successfully executed. An error or a panic of a
+// function rolls back to the savepoint, all changes of the outer transaction
+// made before the call of Transaction stay intact. The panic gets re-raised
+// and the outer ConnPool.Transaction or Conn.Transaction rolls back the whole
+// transaction. Nested transactions can be nested again, hence a reusable
+// function can always call Transaction on the provided *Tx.
+//
+//		err := dbc.Transaction(ctx, nil, func(tx *dml.Tx) error {
+//			// SQL of the outer transaction
+//			return tx.Transaction(ctx, importProducts, importPrices)
+//		})
+//
+// MySQL commits implicitly with statements like CREATE or ALTER TABLE, which
+// destroys all savepoints. A deadlock rolls back the whole transaction and
+// removes the savepoint too. A failing ROLLBACK TO SAVEPOINT gets only logged,
+// the error of the function stays the returned error so that the
+// TxRetryPolicy can retry the outer transaction.
+func (tx *Tx) Transaction(ctx context.Context, fns ...func(*Tx) error) error {
+	tx.savepointSeq++
+	name := "dml_sp_" + strconv.FormatUint(uint64(tx.savepointSeq), 10)
+
+	if err := tx.execSavepoint(ctx, "SAVEPOINT ", name); err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.execSavepoint(ctx, "ROLLBACK TO SAVEPOINT ", name)
+			panic(r)
+		}
+	}()
+
+	for i, f := range fns {
+		if err := f(tx); err != nil {
+			err = errors.Wrapf(err, "[dml] Tx.Transaction.error at index %d with savepoint %q", i, name)
+			if rErr := tx.execSavepoint(ctx, "ROLLBACK TO SAVEPOINT ", name); rErr != nil && tx.Log != nil && tx.Log.IsInfo() {
+				tx.Log.Info("dml.Tx.Transaction.Rollback", log.String("savepoint", name), log.Int("index", i), log.Err(rErr), log.ErrWithKey("original_error", err))
+			}
+			return err
+		}
+	}
+	return errors.WithStack(tx.execSavepoint(ctx, "RELEASE SAVEPOINT ", name))
+}
+
+func (tx *Tx) execSavepoint(ctx context.Context, stmt, name string) error {
+	sqlStr := stmt + Quoter.Name(name)
+	if tx.Log != nil && tx.Log.IsDebug() {
+		defer log.WhenDone(tx.Log).Debug("Savepoint", log.String("sql", sqlStr))
+	}
+	if _, err := tx.DB.ExecContext(ctx, sqlStr); err != nil {
+		return errors.Wrapf(err, "[dml] Tx.Savepoint with query %q", sqlStr)
+	}
+	return nil
+}
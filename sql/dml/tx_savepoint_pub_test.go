@@ -0,0 +1,132 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx_Transaction(t *testing.T) {
+	t.Parallel()
+
+	insertFn := func(tx *dml.Tx) error {
+		_, err := tx.InsertInto("tableX").AddColumns("value").WithArgs().ExecContext(context.TODO(), 1)
+		return err
+	}
+	failFn := func(*dml.Tx) error {
+		return errors.NotValid.Newf("invalid row")
+	}
+
+	t.Run("nested commit and error", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("INSERT INTO `tableX`").WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("INSERT INTO `tableX`").WillReturnResult(sqlmock.NewResult(2, 1))
+		dbMock.ExpectExec("RELEASE SAVEPOINT `dml_sp_2`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("RELEASE SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_3`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("INSERT INTO `tableX`").WillReturnResult(sqlmock.NewResult(3, 1))
+		dbMock.ExpectExec("ROLLBACK TO SAVEPOINT `dml_sp_3`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectCommit()
+
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			if err := tx.Transaction(context.TODO(), insertFn, func(tx *dml.Tx) error {
+				return tx.Transaction(context.TODO(), insertFn)
+			}); err != nil {
+				return err
+			}
+			err := tx.Transaction(context.TODO(), insertFn, failFn)
+			assert.True(t, errors.NotValid.Match(err), "%+v", err)
+			return nil // the outer transaction ignores the failed import
+		}))
+	})
+
+	t.Run("panic unwinds", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("ROLLBACK TO SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectRollback()
+
+		assert.PanicsWithValue(t, "import failed", func() {
+			_ = dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+				return tx.Transaction(context.TODO(), func(*dml.Tx) error {
+					panic("import failed")
+				})
+			})
+		})
+	})
+
+	t.Run("deadlock retries outer transaction", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t, dml.WithTxRetryPolicy(dml.TxRetryPolicy{
+			MaxAttempts:    2,
+			InitialBackoff: time.Millisecond,
+		}))
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("INSERT INTO `tableX`").WillReturnError(&mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"})
+		dbMock.ExpectExec("ROLLBACK TO SAVEPOINT `dml_sp_1`").WillReturnError(&mysql.MySQLError{Number: 1305, Message: "SAVEPOINT dml_sp_1 does not exist"})
+		dbMock.ExpectRollback()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec("INSERT INTO `tableX`").WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectExec("RELEASE SAVEPOINT `dml_sp_1`").WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectCommit()
+
+		var calls int
+		require.NoError(t, dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			calls++
+			return tx.Transaction(context.TODO(), insertFn)
+		}))
+		assert.Exactly(t, 2, calls)
+	})
+
+	t.Run("savepoint error", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("SAVEPOINT `dml_sp_1`").WillReturnError(errors.AlreadyClosed.Newf("Connection gone"))
+		dbMock.ExpectRollback()
+
+		var called bool
+		err := dbc.Transaction(context.TODO(), nil, func(tx *dml.Tx) error {
+			return tx.Transaction(context.TODO(), func(*dml.Tx) error {
+				called = true
+				return nil
+			})
+		})
+		assert.True(t, errors.AlreadyClosed.Match(err), "%+v", err)
+		assert.False(t, called)
+	})
+}
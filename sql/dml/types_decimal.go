@@ -41,9 +41,12 @@ const (
 
 // Decimal defines a container type for any MySQL/MariaDB
 // decimal/numeric/float/double data type and their representation in Go.
-// Decimal does not perform any kind of calculations. Helpful packages for
-// arbitrary precision calculations are github.com/ericlagergren/decimal or
-// gopkg.in/inf.v0 or github.com/shopspring/decimal or a future new Go type.
+// Decimal performs exact fixed point calculations, see the functions Add, Sub,
+// Mul, MulRound, Div, Round and Cmp. The result must fit into the uint64
+// Precision field, otherwise an Overflowed error gets returned. Helpful
+// packages for arbitrary precision calculations are
+// github.com/ericlagergren/decimal or gopkg.in/inf.v0 or
+// github.com/shopspring/decimal or a future new Go type.
 // https://dev.mysql.com/doc/refman/5.7/en/precision-math-decimal-characteristics.html
 // https://dev.mysql.com/doc/refman/5.7/en/floating-point-types.html
 type Decimal struct {
@@ -179,7 +182,7 @@ func (d Decimal) string(buf *bytes.Buffer) {
 		return
 	}
 
-	digits := int32(math.Log10(float64(d.Precision)) + 1)
+	digits := decimalDigits(d.Precision)
 	leadingZeros := d.Scale - digits + 1
 
 	if leadingZeros > 0 {
@@ -0,0 +1,303 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"math/big"
+
+	"github.com/corestoreio/errors"
+)
+
+// RoundingMode defines how a Decimal gets rounded when digits after the
+// requested scale must be dropped.
+type RoundingMode uint8
+
+// These rounding modes are supported by Decimal.Round, Decimal.MulRound and
+// Decimal.Div. The default RoundHalfUp behaves like the ROUND() function of
+// MySQL/MariaDB for exact-value numbers.
+const (
+	// RoundHalfUp rounds to the nearest neighbour and a tie away from zero:
+	// 2.5 => 3, -2.5 => -3.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest neighbour and a tie to the even
+	// neighbour: 2.5 => 2, 3.5 => 4. Also known as banker's rounding.
+	RoundHalfEven
+	// RoundHalfDown rounds to the nearest neighbour and a tie towards zero:
+	// 2.5 => 2, -2.5 => -2.
+	RoundHalfDown
+	// RoundDown truncates towards zero: 2.9 => 2, -2.9 => -2.
+	RoundDown
+	// RoundUp rounds away from zero: 2.1 => 3, -2.1 => -3.
+	RoundUp
+	// RoundCeiling rounds towards positive infinity: 2.1 => 3, -2.9 => -2.
+	RoundCeiling
+	// RoundFloor rounds towards negative infinity: 2.9 => 2, -2.1 => -3.
+	RoundFloor
+)
+
+// RoundBankers is an alias for RoundHalfEven.
+const RoundBankers = RoundHalfEven
+
+var bigOne = big.NewInt(1)
+
+// bigInt returns the signed unscaled value.
+func (d Decimal) bigInt() *big.Int {
+	i := new(big.Int).SetUint64(d.Precision)
+	if d.Negative {
+		i.Neg(i)
+	}
+	return i
+}
+
+// bigPow10 returns 10^n. n must be positive.
+func bigPow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// alignDecimals returns the unscaled values of a and b expressed in the larger
+// scale of both.
+func alignDecimals(a, b Decimal) (x, y *big.Int, scale int32) {
+	x, y, scale = a.bigInt(), b.bigInt(), a.Scale
+	switch {
+	case a.Scale > b.Scale:
+		y.Mul(y, bigPow10(a.Scale-b.Scale))
+	case a.Scale < b.Scale:
+		x.Mul(x, bigPow10(b.Scale-a.Scale))
+		scale = b.Scale
+	}
+	return x, y, scale
+}
+
+// makeDecimalBig creates a Decimal from the signed unscaled value. It returns
+// an Overflowed error if the value does not fit into the uint64 Precision.
+func makeDecimalBig(i *big.Int, scale int32, quote bool) (Decimal, error) {
+	neg := i.Sign() < 0
+	abs := new(big.Int).Abs(i)
+	if !abs.IsUint64() {
+		return Decimal{}, errors.Overflowed.Newf("[dml] Decimal: Result %s with scale %d overflows the precision", i.String(), scale)
+	}
+	return Decimal{
+		Precision: abs.Uint64(),
+		Scale:     scale,
+		Negative:  neg,
+		Valid:     true,
+		Quote:     quote,
+	}, nil
+}
+
+// quoRound returns num/den rounded with the rounding mode.
+func quoRound(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	neg := (num.Sign() < 0) != (den.Sign() < 0)
+
+	// c compares the double remainder with the denominator to detect whether
+	// the dropped part is below, at or above the half.
+	r2 := new(big.Int).Abs(r)
+	c := r2.Lsh(r2, 1).CmpAbs(den)
+
+	var awayFromZero bool
+	switch mode {
+	case RoundHalfUp:
+		awayFromZero = c >= 0
+	case RoundHalfEven:
+		awayFromZero = c > 0 || (c == 0 && q.Bit(0) == 1)
+	case RoundHalfDown:
+		awayFromZero = c > 0
+	case RoundDown:
+		awayFromZero = false
+	case RoundUp:
+		awayFromZero = true
+	case RoundCeiling:
+		awayFromZero = !neg
+	case RoundFloor:
+		awayFromZero = neg
+	}
+	if awayFromZero {
+		if neg {
+			q.Sub(q, bigOne)
+		} else {
+			q.Add(q, bigOne)
+		}
+	}
+	return q
+}
+
+// rescaleBig changes the scale of the unscaled value i from scale to
+// newScale and rounds if digits get dropped.
+func rescaleBig(i *big.Int, scale, newScale int32, mode RoundingMode) *big.Int {
+	switch {
+	case newScale > scale:
+		return i.Mul(i, bigPow10(newScale-scale))
+	case newScale < scale:
+		return quoRound(i, bigPow10(scale-newScale), mode)
+	}
+	return i
+}
+
+func validateScale(fnName string, scale int32) error {
+	if scale < 0 {
+		return errors.NotValid.Newf("[dml] Decimal.%s: Scale must be positive, have %d", fnName, scale)
+	}
+	return nil
+}
+
+// Add returns d+d2 in the larger scale of both. If one of the values is NULL,
+// the result is NULL, like in SQL. The Quote field of d gets inherited.
+func (d Decimal) Add(d2 Decimal) (Decimal, error) {
+	if !d.Valid || !d2.Valid {
+		return Decimal{}, nil
+	}
+	x, y, scale := alignDecimals(d, d2)
+	return makeDecimalBig(x.Add(x, y), scale, d.Quote)
+}
+
+// Sub returns d-d2 in the larger scale of both. If one of the values is NULL,
+// the result is NULL, like in SQL. The Quote field of d gets inherited.
+func (d Decimal) Sub(d2 Decimal) (Decimal, error) {
+	if !d.Valid || !d2.Valid {
+		return Decimal{}, nil
+	}
+	x, y, scale := alignDecimals(d, d2)
+	return makeDecimalBig(x.Sub(x, y), scale, d.Quote)
+}
+
+// Mul returns the exact product d*d2. The scale of the result is the sum of
+// both scales. Use MulRound to limit the scale. If one of the values is NULL,
+// the result is NULL.
+func (d Decimal) Mul(d2 Decimal) (Decimal, error) {
+	if !d.Valid || !d2.Valid {
+		return Decimal{}, nil
+	}
+	x := d.bigInt()
+	return makeDecimalBig(x.Mul(x, d2.bigInt()), d.Scale+d2.Scale, d.Quote)
+}
+
+// MulRound returns the product d*d2 rounded to scale with the rounding mode.
+// The rounding happens before the overflow check of the result, hence products
+// can be calculated whose exact value does not fit into a Decimal.
+//		price.MulRound(taxRate, 4, dml.RoundHalfUp)
+func (d Decimal) MulRound(d2 Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if err := validateScale("MulRound", scale); err != nil {
+		return Decimal{}, err
+	}
+	if !d.Valid || !d2.Valid {
+		return Decimal{}, nil
+	}
+	x := d.bigInt()
+	x.Mul(x, d2.bigInt())
+	return makeDecimalBig(rescaleBig(x, d.Scale+d2.Scale, scale, mode), scale, d.Quote)
+}
+
+// Div returns the quotient d/d2 rounded to scale with the rounding mode. A
+// division by zero returns a NotValid error. If one of the values is NULL, the
+// result is NULL.
+//		amount.Div(rate, 4, dml.RoundHalfEven)
+func (d Decimal) Div(d2 Decimal, scale int32, mode RoundingMode) (Decimal, error) {
+	if err := validateScale("Div", scale); err != nil {
+		return Decimal{}, err
+	}
+	if !d.Valid || !d2.Valid {
+		return Decimal{}, nil
+	}
+	if d2.Precision == 0 {
+		return Decimal{}, errors.NotValid.Newf("[dml] Decimal.Div: Division by zero of %s", d.String())
+	}
+	// d/d2 = (x/10^xs) / (y/10^ys) and the unscaled result with the requested
+	// scale s is: x * 10^(ys+s) / (y * 10^xs).
+	num := d.bigInt()
+	den := d2.bigInt()
+	if e := d2.Scale + scale - d.Scale; e > 0 {
+		num.Mul(num, bigPow10(e))
+	} else if e < 0 {
+		den.Mul(den, bigPow10(-e))
+	}
+	return makeDecimalBig(quoRound(num, den, mode), scale, d.Quote)
+}
+
+// Round rounds d to scale with the rounding mode. A scale larger than the
+// current scale pads with zeros. A NULL value stays NULL.
+func (d Decimal) Round(scale int32, mode RoundingMode) (Decimal, error) {
+	if err := validateScale("Round", scale); err != nil {
+		return Decimal{}, err
+	}
+	if !d.Valid {
+		return d, nil
+	}
+	return makeDecimalBig(rescaleBig(d.bigInt(), d.Scale, scale, mode), scale, d.Quote)
+}
+
+// Neg returns -d. A NULL value stays NULL.
+func (d Decimal) Neg() Decimal {
+	if d.Valid && d.Precision > 0 {
+		d.Negative = !d.Negative
+	}
+	return d
+}
+
+// Abs returns the absolute value of d. A NULL value stays NULL.
+func (d Decimal) Abs() Decimal {
+	d.Negative = false
+	return d
+}
+
+// Sign returns -1 if d < 0, 0 if d == 0 or NULL and +1 if d > 0.
+func (d Decimal) Sign() int {
+	switch {
+	case !d.Valid || d.Precision == 0:
+		return 0
+	case d.Negative:
+		return -1
+	}
+	return 1
+}
+
+// Cmp compares the numeric values of d and d2 independent of their scale and
+// returns -1 if d < d2, 0 if d == d2 and +1 if d > d2. A NULL value is smaller
+// than any valid value and two NULL values are equal.
+func (d Decimal) Cmp(d2 Decimal) int {
+	switch {
+	case !d.Valid && !d2.Valid:
+		return 0
+	case !d.Valid:
+		return -1
+	case !d2.Valid:
+		return 1
+	case d.Sign() != d2.Sign():
+		// fast path, no allocation
+		if d.Sign() < d2.Sign() {
+			return -1
+		}
+		return 1
+	}
+	x, y, _ := alignDecimals(d, d2)
+	return x.Cmp(y)
+}
+
+// Equal reports whether d and d2 represent the same numeric value, for example
+// 1.50 and 1.5 are equal. Two NULL values are equal.
+func (d Decimal) Equal(d2 Decimal) bool {
+	return d.Cmp(d2) == 0
+}
+
+// decimalDigits returns the number of decimal digits of u. Zero has one digit.
+func decimalDigits(u uint64) (n int32) {
+	for n = 1; u >= 10; n++ {
+		u /= 10
+	}
+	return n
+}
@@ -0,0 +1,233 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustDecimal(s string) dml.Decimal {
+	d, err := dml.MakeDecimalBytes([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestDecimal_String_Zero(t *testing.T) {
+	t.Parallel()
+	assert.Exactly(t, "0.00", dml.Decimal{Valid: true, Scale: 2}.String())
+	assert.Exactly(t, "0.9999999999999999999", dml.Decimal{Valid: true, Precision: 9999999999999999999, Scale: 19}.String())
+}
+
+func TestDecimal_AddSub(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b     string
+		add, sub string
+	}{
+		{"0.1", "0.2", "0.3", "-0.1"},
+		{"12.3456", "7.5", "19.8456", "4.8456"},
+		{"-3.10", "1.1", "-2.00", "-4.20"},
+		{"1.50", "-1.5", "0.00", "3.00"},
+		{"99999999.9999", "0.0001", "100000000.0000", "99999999.9998"},
+	}
+	for _, test := range tests {
+		a, b := mustDecimal(test.a), mustDecimal(test.b)
+		add, err := a.Add(b)
+		require.NoError(t, err)
+		assert.Exactly(t, test.add, add.String(), "%s + %s", test.a, test.b)
+		sub, err := a.Sub(b)
+		require.NoError(t, err)
+		assert.Exactly(t, test.sub, sub.String(), "%s - %s", test.a, test.b)
+	}
+
+	t.Run("NULL", func(t *testing.T) {
+		d, err := mustDecimal("1.2").Add(dml.Decimal{})
+		require.NoError(t, err)
+		assert.False(t, d.Valid)
+		d, err = dml.Decimal{}.Sub(mustDecimal("1.2"))
+		require.NoError(t, err)
+		assert.False(t, d.Valid)
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		_, err := dml.Decimal{Valid: true, Precision: math.MaxUint64}.Add(mustDecimal("1"))
+		assert.True(t, errors.Overflowed.Match(err), "%+v", err)
+	})
+
+	t.Run("inherits Quote", func(t *testing.T) {
+		a := mustDecimal("2.5")
+		a.Quote = true
+		d, err := a.Add(mustDecimal("1"))
+		require.NoError(t, err)
+		assert.True(t, d.Quote)
+	})
+}
+
+func TestDecimal_Mul(t *testing.T) {
+	t.Parallel()
+
+	d, err := mustDecimal("19.99").Mul(mustDecimal("-0.19"))
+	require.NoError(t, err)
+	assert.Exactly(t, "-3.7981", d.String())
+
+	d, err = mustDecimal("19.9900").MulRound(mustDecimal("0.1900"), 4, dml.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Exactly(t, "3.7981", d.String())
+
+	d, err = mustDecimal("19.99").MulRound(mustDecimal("0.19"), 2, dml.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Exactly(t, "3.80", d.String())
+
+	d, err = mustDecimal("19.99").MulRound(mustDecimal("0.19"), 2, dml.RoundDown)
+	require.NoError(t, err)
+	assert.Exactly(t, "3.79", d.String())
+
+	t.Run("exact overflow but rounded fits", func(t *testing.T) {
+		a := mustDecimal("12345678.12345678")
+		b := mustDecimal("98765432.98765432")
+		_, err := a.Mul(b)
+		assert.True(t, errors.Overflowed.Match(err), "%+v", err)
+
+		d, err := a.MulRound(b, 4, dml.RoundHalfEven)
+		require.NoError(t, err)
+		assert.Exactly(t, "1219326245389420.5420", d.String())
+	})
+
+	t.Run("invalid scale", func(t *testing.T) {
+		_, err := mustDecimal("1").MulRound(mustDecimal("1"), -1, dml.RoundHalfUp)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
+
+func TestDecimal_Div(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b  string
+		scale int32
+		mode  dml.RoundingMode
+		want  string
+	}{
+		{"10", "3", 4, dml.RoundHalfUp, "3.3333"},
+		{"20", "3", 4, dml.RoundHalfUp, "6.6667"},
+		{"20", "3", 4, dml.RoundDown, "6.6666"},
+		{"-20", "3", 4, dml.RoundFloor, "-6.6667"},
+		{"-20", "3", 4, dml.RoundCeiling, "-6.6666"},
+		{"1.0000", "0.0008", 2, dml.RoundHalfUp, "1250.00"},
+		{"0.125", "1", 2, dml.RoundHalfEven, "0.12"},
+		{"0.135", "1", 2, dml.RoundHalfEven, "0.14"},
+		{"0.125", "1", 2, dml.RoundHalfUp, "0.13"},
+		{"-0.125", "1", 2, dml.RoundHalfUp, "-0.13"},
+		{"0.125", "1", 2, dml.RoundHalfDown, "0.12"},
+		{"100", "-0.4", 0, dml.RoundHalfUp, "-250"},
+		{"1.23", "10", 5, dml.RoundHalfUp, "0.12300"},
+	}
+	for _, test := range tests {
+		d, err := mustDecimal(test.a).Div(mustDecimal(test.b), test.scale, test.mode)
+		require.NoError(t, err)
+		assert.Exactly(t, test.want, d.String(), "%s / %s", test.a, test.b)
+	}
+
+	t.Run("division by zero", func(t *testing.T) {
+		_, err := mustDecimal("1").Div(mustDecimal("0.00"), 2, dml.RoundHalfUp)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+	t.Run("NULL", func(t *testing.T) {
+		d, err := dml.Decimal{}.Div(mustDecimal("0"), 2, dml.RoundHalfUp)
+		require.NoError(t, err)
+		assert.False(t, d.Valid)
+	})
+}
+
+func TestDecimal_Round(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		have string
+		mode dml.RoundingMode
+		want string
+	}{
+		{"2.5", dml.RoundHalfUp, "3"},
+		{"-2.5", dml.RoundHalfUp, "-3"},
+		{"2.5", dml.RoundBankers, "2"},
+		{"3.5", dml.RoundHalfEven, "4"},
+		{"-2.5", dml.RoundHalfEven, "-2"},
+		{"2.5", dml.RoundHalfDown, "2"},
+		{"2.51", dml.RoundHalfDown, "3"},
+		{"2.9", dml.RoundDown, "2"},
+		{"-2.9", dml.RoundDown, "-2"},
+		{"2.1", dml.RoundUp, "3"},
+		{"-2.1", dml.RoundUp, "-3"},
+		{"2.1", dml.RoundCeiling, "3"},
+		{"-2.9", dml.RoundCeiling, "-2"},
+		{"2.9", dml.RoundFloor, "2"},
+		{"-2.1", dml.RoundFloor, "-3"},
+		{"-0.4", dml.RoundHalfUp, "0"},
+	}
+	for _, test := range tests {
+		d, err := mustDecimal(test.have).Round(0, test.mode)
+		require.NoError(t, err)
+		assert.Exactly(t, test.want, d.String(), "Round(%s)", test.have)
+	}
+
+	d, err := mustDecimal("1.5").Round(4, dml.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Exactly(t, "1.5000", d.String())
+
+	d, err = mustDecimal("123.45675").Round(4, dml.RoundHalfEven)
+	require.NoError(t, err)
+	assert.Exactly(t, "123.4568", d.String())
+
+	d, err = dml.Decimal{}.Round(2, dml.RoundHalfUp)
+	require.NoError(t, err)
+	assert.False(t, d.Valid)
+}
+
+func TestDecimal_Cmp(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.50", "1.5", 0},
+		{"1.49", "1.5", -1},
+		{"-1.5", "1.5", -1},
+		{"0.00", "-0", 0},
+		{"10", "9.9999", 1},
+		{"-10", "-9.9999", -1},
+	}
+	for _, test := range tests {
+		assert.Exactly(t, test.want, mustDecimal(test.a).Cmp(mustDecimal(test.b)), "%s <=> %s", test.a, test.b)
+		assert.Exactly(t, -test.want, mustDecimal(test.b).Cmp(mustDecimal(test.a)), "%s <=> %s", test.b, test.a)
+	}
+	assert.True(t, mustDecimal("1.50").Equal(mustDecimal("1.5")))
+	assert.Exactly(t, -1, dml.Decimal{}.Cmp(mustDecimal("-1")))
+	assert.Exactly(t, 0, dml.Decimal{}.Cmp(dml.Decimal{}))
+
+	assert.Exactly(t, "-1.5", mustDecimal("1.5").Neg().String())
+	assert.Exactly(t, "1.5", mustDecimal("-1.5").Abs().String())
+	assert.Exactly(t, -1, mustDecimal("-0.01").Sign())
+	assert.Exactly(t, 0, mustDecimal("-0.00").Sign())
+}
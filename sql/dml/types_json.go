@@ -0,0 +1,294 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+
+	"github.com/corestoreio/errors"
+)
+
+// JSON contains the raw encoded value of a MySQL/MariaDB JSON column. An empty
+// JSON represents the JSON literal null. JSON gets sent as a string to the
+// server because MySQL refuses to create JSON values from binary strings. JSON
+// implements interface Argument, sql.Scanner and driver.Valuer.
+type JSON []byte
+
+// MakeJSON encodes v with the function JSONMarshalFn.
+func MakeJSON(v interface{}) (JSON, error) {
+	data, err := JSONMarshalFn(v)
+	if err != nil {
+		return nil, errors.BadEncoding.New(err, "[dml] MakeJSON failed to encode %T", v)
+	}
+	return JSON(data), nil
+}
+
+// Decode decodes the JSON into v with the function JSONUnMarshalFn.
+func (j JSON) Decode(v interface{}) error {
+	if err := JSONUnMarshalFn(j.bytes(), v); err != nil {
+		return errors.BadEncoding.New(err, "[dml] JSON.Decode failed to decode into %T", v)
+	}
+	return nil
+}
+
+// bytes returns the JSON literal null for an empty JSON.
+func (j JSON) bytes() []byte {
+	if len(j) == 0 {
+		return sqlBytesNullLC
+	}
+	return j
+}
+
+// String returns the JSON as a string. An empty JSON returns null.
+func (j JSON) String() string {
+	return string(j.bytes())
+}
+
+// IsValid reports whether the JSON is empty or a valid JSON encoding.
+func (j JSON) IsValid() bool {
+	return len(j) == 0 || json.Valid(j)
+}
+
+// GoString prints an optimized Go representation.
+func (j JSON) GoString() string {
+	return "dml.JSON(`" + strings.Join(strings.Split(string(j), "`"), "`+\"`\"+`") + "`)"
+}
+
+// MarshalJSON implements json.Marshaler.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return j.bytes(), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It copies the data.
+func (j *JSON) UnmarshalJSON(data []byte) error {
+	*j = append((*j)[:0], data...)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (j JSON) MarshalText() ([]byte, error) {
+	return j.bytes(), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It returns an error if
+// the text is not valid JSON.
+func (j *JSON) UnmarshalText(text []byte) error {
+	if !json.Valid(text) {
+		return errors.NotValid.Newf("[dml] JSON.UnmarshalText: Input is not valid JSON: %q", text)
+	}
+	*j = append((*j)[:0], text...)
+	return nil
+}
+
+// Scan implements the sql.Scanner interface. A NULL value resets the JSON.
+func (j *JSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*j = nil
+	case []byte:
+		*j = append((*j)[:0], v...)
+	case string:
+		*j = append((*j)[:0], v...)
+	default:
+		return errors.NotSupported.Newf("[dml] JSON.Scan: Unsupported type %T", value)
+	}
+	return nil
+}
+
+// Value implements the driver Valuer interface.
+func (j JSON) Value() (driver.Value, error) {
+	return j.String(), nil
+}
+
+func (j JSON) writeTo(w *bytes.Buffer) error {
+	if !j.IsValid() {
+		return errors.NotValid.Newf("[dml] JSON.writeTo: Invalid JSON: %q", []byte(j))
+	}
+	dialect.EscapeString(w, j.String())
+	return nil
+}
+
+func (j JSON) append(args []interface{}) []interface{} {
+	return append(args, j.String())
+}
+
+// NullJSON is a nullable JSON value of a MySQL/MariaDB JSON column. The JSON
+// literal null and SQL NULL are different: An invalid NullJSON represents SQL
+// NULL. NullJSON implements interface Argument.
+type NullJSON struct {
+	JSON  JSON
+	Valid bool // Valid is true if JSON is not NULL
+}
+
+// MakeNullJSON creates a new NullJSON. Setting the second optional argument to
+// false, the JSON will not be valid anymore, hence NULL.
+func MakeNullJSON(j JSON, valid ...bool) NullJSON {
+	v := true
+	if len(valid) == 1 {
+		v = valid[0]
+	}
+	return NullJSON{
+		JSON:  j,
+		Valid: v,
+	}
+}
+
+// GoString prints an optimized Go representation.
+func (a NullJSON) GoString() string {
+	if !a.Valid {
+		return "dml.NullJSON{}"
+	}
+	return "dml.MakeNullJSON(" + a.JSON.GoString() + ")"
+}
+
+// UnmarshalJSON implements json.Unmarshaler. The JSON literal null produces a
+// NULL NullJSON.
+func (a *NullJSON) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), sqlBytesNullLC) {
+		a.JSON = a.JSON[:0]
+		a.Valid = false
+		return nil
+	}
+	a.Valid = true
+	return a.JSON.UnmarshalJSON(data)
+}
+
+// MarshalJSON implements json.Marshaler. It will encode null if this NullJSON
+// is NULL.
+func (a NullJSON) MarshalJSON() ([]byte, error) {
+	if !a.Valid {
+		return sqlBytesNullLC, nil
+	}
+	return a.JSON.MarshalJSON()
+}
+
+// MarshalText implements encoding.TextMarshaler. It will encode a blank string
+// when this NullJSON is NULL.
+func (a NullJSON) MarshalText() ([]byte, error) {
+	if !a.Valid {
+		return nil, nil
+	}
+	return a.JSON.MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It will unmarshal to a
+// NULL NullJSON if the input is a blank string.
+func (a *NullJSON) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		a.JSON = a.JSON[:0]
+		a.Valid = false
+		return nil
+	}
+	if err := a.JSON.UnmarshalText(text); err != nil {
+		return errors.WithStack(err)
+	}
+	a.Valid = true
+	return nil
+}
+
+// SetValid changes this NullJSON's value and also sets it to be non-NULL.
+func (a *NullJSON) SetValid(j JSON) {
+	a.JSON = j
+	a.Valid = true
+}
+
+// IsZero returns true for NULL JSON, for potential future omitempty support.
+func (a NullJSON) IsZero() bool {
+	return !a.Valid
+}
+
+// Scan implements the sql.Scanner interface.
+func (a *NullJSON) Scan(value interface{}) error {
+	if value == nil {
+		a.JSON, a.Valid = a.JSON[:0], false
+		return nil
+	}
+	a.Valid = true
+	return a.JSON.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (a NullJSON) Value() (driver.Value, error) {
+	if !a.Valid {
+		return nil, nil
+	}
+	return a.JSON.Value()
+}
+
+// GobEncode implements the gob.GobEncoder interface for gob serialization.
+func (a NullJSON) GobEncode() ([]byte, error) {
+	return a.Marshal()
+}
+
+// GobDecode implements the gob.GobDecoder interface for gob serialization.
+func (a *NullJSON) GobDecode(data []byte) error {
+	return a.Unmarshal(data)
+}
+
+// UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+func (a *NullJSON) UnmarshalBinary(data []byte) error {
+	return a.Unmarshal(data)
+}
+
+// MarshalBinary implements the encoding.BinaryMarshaler interface.
+func (a NullJSON) MarshalBinary() (data []byte, err error) {
+	return a.Marshal()
+}
+
+// Marshal binary encoder for protocol buffers. Implements proto.Marshaler.
+func (a NullJSON) Marshal() ([]byte, error) {
+	return a.MarshalText()
+}
+
+// MarshalTo binary encoder for protocol buffers which writes into data.
+func (a NullJSON) MarshalTo(data []byte) (n int, err error) {
+	if !a.Valid {
+		return 0, nil
+	}
+	n = copy(data, a.JSON.bytes())
+	return
+}
+
+// Unmarshal binary decoder for protocol buffers. Implements proto.Unmarshaler.
+func (a *NullJSON) Unmarshal(data []byte) error {
+	return a.UnmarshalText(data)
+}
+
+// Size returns the size of the underlying type. If not valid, the size will be
+// 0. Implements proto.Sizer.
+func (a NullJSON) Size() (s int) {
+	if !a.Valid {
+		return 0
+	}
+	return len(a.JSON.bytes())
+}
+
+func (a NullJSON) writeTo(w *bytes.Buffer) (err error) {
+	if a.Valid {
+		return a.JSON.writeTo(w)
+	}
+	_, err = w.WriteString(sqlStrNullUC)
+	return
+}
+
+func (a NullJSON) append(args []interface{}) []interface{} {
+	if a.Valid {
+		return a.JSON.append(args)
+	}
+	return append(args, nil)
+}
@@ -0,0 +1,323 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ fmt.GoStringer             = (*JSON)(nil)
+	_ fmt.Stringer               = (*JSON)(nil)
+	_ json.Marshaler             = (*JSON)(nil)
+	_ json.Unmarshaler           = (*JSON)(nil)
+	_ encoding.TextMarshaler     = (*JSON)(nil)
+	_ encoding.TextUnmarshaler   = (*JSON)(nil)
+	_ driver.Valuer              = (*JSON)(nil)
+	_ sql.Scanner                = (*JSON)(nil)
+	_ fmt.GoStringer             = (*NullJSON)(nil)
+	_ json.Marshaler             = (*NullJSON)(nil)
+	_ json.Unmarshaler           = (*NullJSON)(nil)
+	_ encoding.BinaryMarshaler   = (*NullJSON)(nil)
+	_ encoding.BinaryUnmarshaler = (*NullJSON)(nil)
+	_ encoding.TextMarshaler     = (*NullJSON)(nil)
+	_ encoding.TextUnmarshaler   = (*NullJSON)(nil)
+	_ gob.GobEncoder             = (*NullJSON)(nil)
+	_ gob.GobDecoder             = (*NullJSON)(nil)
+	_ driver.Valuer              = (*NullJSON)(nil)
+	_ sql.Scanner                = (*NullJSON)(nil)
+)
+
+func TestJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Make and Decode", func(t *testing.T) {
+		prevM, prevU := JSONMarshalFn, JSONUnMarshalFn
+		JSONMarshalFn, JSONUnMarshalFn = json.Marshal, json.Unmarshal
+		defer func() { JSONMarshalFn, JSONUnMarshalFn = prevM, prevU }()
+
+		j, err := MakeJSON(map[string]string{"color": "red"})
+		require.NoError(t, err)
+		assert.Exactly(t, `{"color":"red"}`, j.String())
+
+		var m map[string]string
+		require.NoError(t, j.Decode(&m))
+		assert.Exactly(t, map[string]string{"color": "red"}, m)
+
+		err = JSON(`{"color":`).Decode(&m)
+		assert.True(t, errors.BadEncoding.Match(err), "%+v", err)
+	})
+
+	t.Run("empty is null", func(t *testing.T) {
+		var j JSON
+		assert.Exactly(t, "null", j.String())
+		assert.True(t, j.IsValid())
+		v, err := j.Value()
+		require.NoError(t, err)
+		assert.Exactly(t, "null", v)
+		data, err := json.Marshal(struct{ J JSON }{})
+		require.NoError(t, err)
+		assert.Exactly(t, `{"J":null}`, string(data))
+	})
+
+	t.Run("Scan", func(t *testing.T) {
+		var j JSON
+		require.NoError(t, j.Scan([]byte(`[1,2]`)))
+		assert.Exactly(t, `[1,2]`, j.String())
+		require.NoError(t, j.Scan(`{"a":true}`))
+		assert.Exactly(t, `{"a":true}`, j.String())
+		require.NoError(t, j.Scan(nil))
+		assert.Nil(t, j)
+		err := j.Scan(3)
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+
+	t.Run("UnmarshalText invalid", func(t *testing.T) {
+		var j JSON
+		err := j.UnmarshalText([]byte(`{"a":`))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("GoString", func(t *testing.T) {
+		assert.Exactly(t, "dml.JSON(`{\"a\":\"`+\"`\"+`\"}`)", JSON("{\"a\":\"`\"}").GoString())
+	})
+}
+
+func TestNullJSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JSON round trip", func(t *testing.T) {
+		type record struct {
+			Attr NullJSON
+		}
+		var r record
+		require.NoError(t, json.Unmarshal([]byte(`{"Attr":{"size":["S","M"]}}`), &r))
+		assert.True(t, r.Attr.Valid)
+		assert.Exactly(t, `{"size":["S","M"]}`, r.Attr.JSON.String())
+
+		data, err := json.Marshal(r)
+		require.NoError(t, err)
+		assert.Exactly(t, `{"Attr":{"size":["S","M"]}}`, string(data))
+
+		require.NoError(t, json.Unmarshal([]byte(`{"Attr":null}`), &r))
+		assert.False(t, r.Attr.Valid)
+		data, err = json.Marshal(r)
+		require.NoError(t, err)
+		assert.Exactly(t, `{"Attr":null}`, string(data))
+	})
+
+	t.Run("Scan and Value", func(t *testing.T) {
+		var nj NullJSON
+		require.NoError(t, nj.Scan([]byte(`"red"`)))
+		assert.Exactly(t, MakeNullJSON(JSON(`"red"`)), nj)
+		v, err := nj.Value()
+		require.NoError(t, err)
+		assert.Exactly(t, `"red"`, v)
+
+		require.NoError(t, nj.Scan(nil))
+		assert.False(t, nj.Valid)
+		v, err = nj.Value()
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("Binary", func(t *testing.T) {
+		nj := MakeNullJSON(JSON(`{"a":1}`))
+		data, err := nj.MarshalBinary()
+		require.NoError(t, err)
+		assert.Exactly(t, nj.Size(), len(data))
+
+		buf := make([]byte, nj.Size())
+		n, err := nj.MarshalTo(buf)
+		require.NoError(t, err)
+		assert.Exactly(t, data, buf[:n])
+
+		var nj2 NullJSON
+		require.NoError(t, nj2.UnmarshalBinary(data))
+		assert.Exactly(t, nj, nj2)
+
+		require.NoError(t, nj2.UnmarshalBinary(nil))
+		assert.True(t, nj2.IsZero())
+		assert.Exactly(t, 0, nj2.Size())
+	})
+
+	t.Run("GoString", func(t *testing.T) {
+		assert.Exactly(t, "dml.NullJSON{}", NullJSON{}.GoString())
+		assert.Exactly(t, "dml.MakeNullJSON(dml.JSON(`[1]`))", MakeNullJSON(JSON(`[1]`)).GoString())
+	})
+
+	t.Run("Interfaces", func(t *testing.T) {
+		args := MakeArgs(3).JSON(JSON(`{"a":1}`)).NullJSON(NullJSON{}).NullJSON(MakeNullJSON(nil)).Interfaces()
+		assert.Exactly(t, []interface{}{`{"a":1}`, nil, "null"}, args)
+	})
+}
+
+func TestCondition_JSON(t *testing.T) {
+	t.Parallel()
+
+	t.Run("JSON argument", func(t *testing.T) {
+		compareToSQL(t,
+			NewUpdate("catalog_product_entity").
+				Set(Column("attributes").JSON(JSON(`{"color":"it's red"}`))).
+				Where(Column("entity_id").Int(3)),
+			errors.NoKind,
+			"UPDATE `catalog_product_entity` SET `attributes`='{\\\"color\\\":\\\"it\\'s red\\\"}' WHERE (`entity_id` = 3)",
+			"UPDATE `catalog_product_entity` SET `attributes`='{\\\"color\\\":\\\"it\\'s red\\\"}' WHERE (`entity_id` = 3)",
+		)
+	})
+
+	t.Run("invalid JSON argument", func(t *testing.T) {
+		_, _, err := NewSelect("a").From("t").Where(Column("a").JSON(JSON(`{"a":`))).ToSQL()
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("extract", func(t *testing.T) {
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONExtract("attributes", "$.color").Equal().Str(`"red"`),
+				SQLJSONUnquote("attributes", "$.size").In().Strs("S", "M"),
+				SQLJSONPath("p.attributes", "$.weight").GreaterOrEqual().Float64(2.5),
+				SQLJSONPathUnquote("attributes", "$.brand").Equal().PlaceHolder(),
+				SQLJSONUnquote("attributes", "$.sku").Like().NamedArg("sku"),
+			),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_EXTRACT(`attributes`,'$.color') = '\\\"red\\\"') AND (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.size')) IN ('S','M')) AND (`p`.`attributes`->'$.weight' >= 2.5) AND (`attributes`->>'$.brand' = ?) AND (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.sku')) LIKE ?)",
+			"",
+		)
+	})
+
+	t.Run("question mark in quoted path", func(t *testing.T) {
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONUnquote("attributes", `$."size?"`).Equal().Str("L"),
+			),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.\\\"size?\\\"')) = 'L')",
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.\\\"size?\\\"')) = 'L')",
+		)
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONContains("attributes", `$."it's?"`),
+			).WithArgs().JSON(JSON(`"M"`)),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,?,'$.\\\"it\\'s?\\\"'))",
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,'\\\"M\\\"','$.\\\"it\\'s?\\\"'))",
+			`"M"`,
+		)
+	})
+
+	t.Run("value without operator", func(t *testing.T) {
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONUnquote("attributes", "$.color").Str("red"),
+				SQLJSONUnquote("attributes", "$.size").Strs("S", "M"),
+			),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.color')) = 'red') AND (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.size')) IN ('S','M'))",
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.color')) = 'red') AND (JSON_UNQUOTE(JSON_EXTRACT(`attributes`,'$.size')) IN ('S','M'))",
+		)
+	})
+
+	t.Run("contains", func(t *testing.T) {
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONContains("attributes", "$.size").JSON(JSON(`"M"`)),
+				SQLJSONContains("attributes").NullJSON(MakeNullJSON(JSON(`{"color":"red"}`))),
+			),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,'\\\"M\\\"','$.size')) AND (JSON_CONTAINS(`attributes`,'{\\\"color\\\":\\\"red\\\"}'))",
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,'\\\"M\\\"','$.size')) AND (JSON_CONTAINS(`attributes`,'{\\\"color\\\":\\\"red\\\"}'))",
+		)
+		compareToSQL(t,
+			NewSelect("entity_id").From("catalog_product_entity").Where(
+				SQLJSONContains("attributes", "$.size"),
+			).WithArgs().JSON(JSON(`"M"`)),
+			errors.NoKind,
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,?,'$.size'))",
+			"SELECT `entity_id` FROM `catalog_product_entity` WHERE (JSON_CONTAINS(`attributes`,'\\\"M\\\"','$.size'))",
+			`"M"`,
+		)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		for _, c := range []*Condition{
+			SQLJSONExtract("attributes"),
+			SQLJSONExtract("attributes", "color"),
+			SQLJSONPath("attributes", "color"),
+			SQLJSONContains("attributes", "$.a", "$.b"),
+		} {
+			_, _, err := NewSelect("a").From("t").Where(c.Int(1)).ToSQL()
+			assert.True(t, errors.NotValid.Match(err), "%+v", err)
+		}
+	})
+}
+
+func TestColumnMap_JSON(t *testing.T) {
+	t.Parallel()
+
+	cm := NewColumnMap(0, "attributes")
+	cm.index = 0
+	cm.scanCol = make([]scannedColumn, 1)
+
+	t.Run("bytes get copied", func(t *testing.T) {
+		raw := []byte(`{"a":1}`)
+		cm.scanCol[0] = scannedColumn{field: 'y', byte: raw}
+		var j JSON
+		var nj NullJSON
+		require.NoError(t, cm.JSON(&j).NullJSON(&nj).Err())
+		raw[2] = 'b'
+		assert.Exactly(t, `{"a":1}`, j.String())
+		assert.Exactly(t, MakeNullJSON(JSON(`{"a":1}`)), nj)
+	})
+	t.Run("string", func(t *testing.T) {
+		cm.scanCol[0] = scannedColumn{field: 's', string: `[true]`}
+		var j JSON
+		var nj NullJSON
+		require.NoError(t, cm.JSON(&j).NullJSON(&nj).Err())
+		assert.Exactly(t, `[true]`, j.String())
+		assert.Exactly(t, MakeNullJSON(JSON(`[true]`)), nj)
+	})
+	t.Run("null", func(t *testing.T) {
+		cm.scanCol[0] = scannedColumn{field: 'n'}
+		j := JSON(`[1]`)
+		nj := MakeNullJSON(JSON(`[1]`))
+		require.NoError(t, cm.JSON(&j).NullJSON(&nj).Err())
+		assert.Nil(t, j)
+		assert.False(t, nj.Valid)
+	})
+	t.Run("unsupported", func(t *testing.T) {
+		cm.scanCol[0] = scannedColumn{field: 'i'}
+		var j JSON
+		err := cm.JSON(&j).Err()
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+		cm.scanErr = nil
+	})
+	t.Run("collect arguments", func(t *testing.T) {
+		cm := NewColumnMap(2)
+		j := JSON(`{"a":1}`)
+		cm.JSON(&j).NullJSON(nil)
+		assert.Exactly(t, "dml.MakeArgs(2).JSON(dml.JSON(`{\"a\":1}`)).Null()", cm.arguments.GoString())
+	})
+}
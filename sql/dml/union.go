@@ -66,11 +66,7 @@ func (c *ConnPool) Union(selects ...*Select) *Union {
 	id := c.makeUniqueID()
 	return &Union{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: unionInitLog(c.Log, selects, id),
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, unionInitLog(c.Log, selects, id)),
 		},
 		Selects: selects,
 	}
@@ -81,11 +77,7 @@ func (c *Conn) Union(selects ...*Select) *Union {
 	id := c.makeUniqueID()
 	return &Union{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: unionInitLog(c.Log, selects, id),
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, unionInitLog(c.Log, selects, id)),
 		},
 		Selects: selects,
 	}
@@ -97,11 +89,7 @@ func (tx *Tx) Union(selects ...*Select) *Union {
 	id := tx.makeUniqueID()
 	return &Union{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: unionInitLog(tx.Log, selects, id),
-				DB:  tx.DB,
-			},
+			builderCommon: tx.newBuilderCommon(tx.DB, id, unionInitLog(tx.Log, selects, id)),
 		},
 		Selects: selects,
 	}
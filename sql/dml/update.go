@@ -44,19 +44,16 @@ func NewUpdate(table string) *Update {
 	}
 }
 
-func newUpdate(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, table string) *Update {
-	id := idFn()
+func newUpdate(db QueryExecPreparer, lw *logWithID, table string) *Update {
+	id := lw.makeUniqueID()
+	l := lw.Log
 	if l != nil {
 		l = l.With(log.String("update_id", id), log.String("table", table))
 	}
 	return &Update{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: l,
-				DB:  db,
-			},
-			Table: MakeIdentifier(table),
+			builderCommon: lw.newBuilderCommon(db, id, l),
+			Table:         MakeIdentifier(table),
 		},
 	}
 }
@@ -64,17 +61,17 @@ func newUpdate(db QueryExecPreparer, idFn uniqueIDFn, l log.Logger, table string
 // Update creates a new Update for the given table with a random connection from
 // the pool.
 func (c *ConnPool) Update(table string) *Update {
-	return newUpdate(c.DB, c.makeUniqueID, c.Log, table)
+	return newUpdate(c.DB, &c.logWithID, table)
 }
 
 // Update creates a new Update for the given table bound to a single connection.
 func (c *Conn) Update(table string) *Update {
-	return newUpdate(c.DB, c.makeUniqueID, c.Log, table)
+	return newUpdate(c.DB, &c.logWithID, table)
 }
 
 // Update creates a new Update for the given table bound to a transaction.
 func (tx *Tx) Update(table string) *Update {
-	return newUpdate(tx.DB, tx.makeUniqueID, tx.Log, table)
+	return newUpdate(tx.DB, &tx.logWithID, table)
 }
 
 // Alias sets an alias for the table name.
@@ -91,11 +91,7 @@ func (c *ConnPool) With(expressions ...WithCTE) *With {
 	id := c.makeUniqueID()
 	return &With{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: withInitLog(c.Log, expressions, id),
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, withInitLog(c.Log, expressions, id)),
 		},
 		Subclauses: expressions,
 	}
@@ -106,11 +102,7 @@ func (c *Conn) With(expressions ...WithCTE) *With {
 	id := c.makeUniqueID()
 	return &With{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: withInitLog(c.Log, expressions, id),
-				DB:  c.DB,
-			},
+			builderCommon: c.newBuilderCommon(c.DB, id, withInitLog(c.Log, expressions, id)),
 		},
 		Subclauses: expressions,
 	}
@@ -121,11 +113,7 @@ func (tx *Tx) With(expressions ...WithCTE) *With {
 	id := tx.makeUniqueID()
 	return &With{
 		BuilderBase: BuilderBase{
-			builderCommon: builderCommon{
-				id:  id,
-				Log: withInitLog(tx.Log, expressions, id),
-				DB:  tx.DB,
-			},
+			builderCommon: tx.newBuilderCommon(tx.DB, id, withInitLog(tx.Log, expressions, id)),
 		},
 		Subclauses: expressions,
 	}
@@ -0,0 +1,247 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"strconv"
+
+	"github.com/corestoreio/errors"
+)
+
+const (
+	// hierarchyCTE name of the recursive common table expression.
+	hierarchyCTE = "dml_hierarchy"
+	// hierarchyPathLength maximum length of the column tree_path. MySQL
+	// derives the column type of a recursive CTE from the non-recursive part,
+	// hence the CAST.
+	hierarchyPathLength = 2048
+	// hierarchyNodeColumns number of columns at the end of each row which get
+	// scanned into the HierarchyNode: ID, parent ID, depth and tree_path.
+	hierarchyNodeColumns = 4
+)
+
+// Hierarchy describes a table which stores a tree as an adjacency list, like
+// catalog_category_entity with the columns entity_id and parent_id. It gets
+// used in With.Descendants and With.Ancestors to create a recursive common
+// table expression. Each result row contains additionally the columns `depth`,
+// starting at zero for the start nodes, and `tree_path`, the comma separated
+// list of IDs from the start node to the current node. An ID which occurs
+// already in the tree_path stops the recursion, so cycles in the data do not
+// cause an endless loop. Supported in MySQL >= 8.0.1 and MariaDB >= 10.2.2.
+type Hierarchy struct {
+	// Table name of the adjacency list table.
+	Table string
+	// IDColumn name of the primary key column. Default entity_id.
+	IDColumn string
+	// ParentColumn name of the column referencing the parent row. Default
+	// parent_id.
+	ParentColumn string
+	// Columns additional columns of the table to load, e.g. path, position or
+	// level. Those columns get scanned into the HierarchyNode.Record.
+	Columns []string
+	// MaxDepth limits the levels of the traversal. Zero means unlimited.
+	MaxDepth int
+}
+
+func (h Hierarchy) withDefaults() Hierarchy {
+	if h.IDColumn == "" {
+		h.IDColumn = "entity_id"
+	}
+	if h.ParentColumn == "" {
+		h.ParentColumn = "parent_id"
+	}
+	return h
+}
+
+// cteColumns returns the unique columns of the CTE without depth and
+// tree_path.
+func (h Hierarchy) cteColumns() []string {
+	cols := make([]string, 0, len(h.Columns)+2)
+	cols = append(cols, h.IDColumn, h.ParentColumn)
+	for _, c := range h.Columns {
+		if !strInSlice(c, cols) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// Descendants creates a recursive query which loads the rows with the IDs and
+// all their children. Without IDs the query contains one place holder for
+// the ID of the start row. The rows are ordered by depth and ID. Load the
+// result into HierarchyNodes to build the tree.
+//		h := dml.Hierarchy{Table: "catalog_category_entity", Columns: []string{"path", "position"}, MaxDepth: 3}
+//		nodes := &dml.HierarchyNodes{}
+//		_, err := dbc.With().Descendants(h, 2).WithArgs().Load(ctx, nodes)
+//		roots := nodes.Tree()
+func (b *With) Descendants(h Hierarchy, ids ...int64) *With {
+	return b.hierarchy(h, false, ids)
+}
+
+// Ancestors creates a recursive query which loads the rows with the IDs and
+// all their parents up to the root. Without IDs the query contains one place
+// holder for the ID of the start row. The rows are ordered by depth and ID,
+// the root has the highest depth.
+func (b *With) Ancestors(h Hierarchy, ids ...int64) *With {
+	return b.hierarchy(h, true, ids)
+}
+
+func (b *With) hierarchy(h Hierarchy, ancestors bool, ids []int64) *With {
+	if h.Table == "" {
+		b.ärgErr = errors.Empty.Newf("[dml] With.Hierarchy: Table name is empty")
+		return b
+	}
+	if h.MaxDepth < 0 {
+		b.ärgErr = errors.NotValid.Newf("[dml] With.Hierarchy: MaxDepth %d must not be negative", h.MaxDepth)
+		return b
+	}
+	h = h.withDefaults()
+	cteCols := h.cteColumns()
+
+	startCond := Column(h.IDColumn).PlaceHolder()
+	if len(ids) > 0 {
+		startCond = Column(h.IDColumn).In().Int64s(ids...)
+	}
+	anchor := NewSelect(cteCols...).From(h.Table).
+		AddColumnsConditions(
+			Expr("0"),
+			Expr("CAST("+Quoter.Name(h.IDColumn)+" AS CHAR("+strconv.Itoa(hierarchyPathLength)+"))"),
+		).
+		Where(startCond)
+
+	// join condition: descendants follow the children, ancestors the parents
+	joinCol, cteCol := h.ParentColumn, h.IDColumn
+	if ancestors {
+		joinCol, cteCol = h.IDColumn, h.ParentColumn
+	}
+	// ColumnsWithQualifier modifies the slice, hence the copy.
+	recursive := NewSelect(Quoter.ColumnsWithQualifier("c", append([]string(nil), cteCols...)...)...).
+		FromAlias(h.Table, "c").
+		AddColumnsConditions(
+			Expr(Quoter.QualifierName("h", "depth")+"+1"),
+			Expr("CONCAT("+Quoter.QualifierName("h", "tree_path")+",',',"+Quoter.QualifierName("c", h.IDColumn)+")"),
+		).
+		Join(MakeIdentifier(hierarchyCTE).Alias("h"),
+			Column("c."+joinCol).Equal().Column("h."+cteCol),
+		).
+		Where(Expr("FIND_IN_SET(" + Quoter.QualifierName("c", h.IDColumn) + "," + Quoter.QualifierName("h", "tree_path") + ") = 0"))
+	if h.MaxDepth > 0 {
+		recursive.Where(Column("h.depth").Less().Int(h.MaxDepth))
+	}
+
+	topCols := make([]string, 0, len(h.Columns)+hierarchyNodeColumns)
+	topCols = append(topCols, h.Columns...)
+	topCols = append(topCols, h.IDColumn, h.ParentColumn, "depth", "tree_path")
+
+	b.Subclauses = append(b.Subclauses, WithCTE{
+		Name:    hierarchyCTE,
+		Columns: append(cteCols, "depth", "tree_path"),
+		Union:   NewUnion(anchor, recursive).All(),
+	})
+	b.TopLevel.Select = NewSelect(topCols...).From(hierarchyCTE).OrderBy("depth", h.IDColumn)
+	return b.Recursive()
+}
+
+// HierarchyNode represents a row loaded with With.Descendants or
+// With.Ancestors.
+type HierarchyNode struct {
+	ID       int64
+	ParentID int64
+	// Depth zero for the start nodes.
+	Depth int
+	// Path contains the comma separated IDs from the start node to this node.
+	Path string
+	// Record contains the additional columns of Hierarchy.Columns, if
+	// HierarchyNodes.NewRecord has been set.
+	Record   ColumnMapper
+	Children []*HierarchyNode
+}
+
+// HierarchyNodes implements ColumnMapper to load the result of
+// With.Descendants or With.Ancestors.
+type HierarchyNodes struct {
+	// NewRecord optional function to create for each row a type which scans
+	// the columns of Hierarchy.Columns, e.g. a generated entity type.
+	NewRecord func() ColumnMapper
+	// Data contains the nodes in the order of the result set.
+	Data []*HierarchyNode
+}
+
+// MapColumns implements interface ColumnMapper only partially for scanning.
+func (hn *HierarchyNodes) MapColumns(cm *ColumnMap) error {
+	if m := cm.Mode(); m != ColumnMapScan {
+		return errors.NotSupported.Newf("[dml] HierarchyNodes.MapColumns mode %q not supported", m)
+	}
+	if cm.Count == 0 {
+		hn.Data = hn.Data[:0]
+	}
+	recCols := cm.columnsLen - hierarchyNodeColumns
+	if recCols < 0 {
+		return errors.Mismatch.Newf("[dml] HierarchyNodes.MapColumns requires at least %d columns, have %d", hierarchyNodeColumns, cm.columnsLen)
+	}
+
+	n := new(HierarchyNode)
+	if hn.NewRecord != nil && recCols > 0 {
+		n.Record = hn.NewRecord()
+		allCols := cm.columnsLen
+		cm.columnsLen = recCols
+		err := n.Record.MapColumns(cm)
+		cm.columnsLen = allCols
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	cm.index = recCols - 1
+	cm.Next()
+	cm.Int64(&n.ID)
+	cm.Next()
+	cm.Int64(&n.ParentID)
+	cm.Next()
+	cm.Int(&n.Depth)
+	cm.Next()
+	cm.String(&n.Path)
+	cm.Next() // resets the index for the next row
+	if err := cm.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	hn.Data = append(hn.Data, n)
+	return nil
+}
+
+// Tree links the nodes via their parent IDs and returns the nodes whose
+// parent has not been loaded. For descendants these are the start nodes, for
+// ancestors the roots. The Children of all nodes get rebuilt. A node reached
+// via several start nodes gets only linked once.
+func (hn *HierarchyNodes) Tree() []*HierarchyNode {
+	byID := make(map[int64]*HierarchyNode, len(hn.Data))
+	nodes := make([]*HierarchyNode, 0, len(hn.Data))
+	for _, n := range hn.Data {
+		if _, ok := byID[n.ID]; ok {
+			continue
+		}
+		n.Children = n.Children[:0]
+		byID[n.ID] = n
+		nodes = append(nodes, n)
+	}
+	var roots []*HierarchyNode
+	for _, n := range nodes {
+		if p, ok := byID[n.ParentID]; ok && p != n {
+			p.Children = append(p.Children, n)
+			continue
+		}
+		roots = append(roots, n)
+	}
+	return roots
+}
@@ -0,0 +1,104 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCategory struct {
+	Path     string
+	Position int64
+}
+
+func (c *testCategory) MapColumns(cm *dml.ColumnMap) error {
+	for cm.Next() {
+		switch col := cm.Column(); col {
+		case "path":
+			cm.String(&c.Path)
+		case "position":
+			cm.Int64(&c.Position)
+		default:
+			return errors.NotFound.Newf("[dml_test] testCategory Column %q not found", col)
+		}
+	}
+	return cm.Err()
+}
+
+func TestWith_Hierarchy(t *testing.T) {
+	t.Parallel()
+
+	h := dml.Hierarchy{Table: "catalog_category_entity", Columns: []string{"path", "position"}, MaxDepth: 3}
+
+	t.Run("Descendants", func(t *testing.T) {
+		compareToSQL(t, dml.NewWith().Descendants(h, 2, 5), errors.NoKind,
+			"WITH RECURSIVE `dml_hierarchy` (`entity_id`,`parent_id`,`path`,`position`,`depth`,`tree_path`) AS ((SELECT `entity_id`, `parent_id`, `path`, `position`, 0, CAST(`entity_id` AS CHAR(2048)) FROM `catalog_category_entity` WHERE (`entity_id` IN (2,5)))\nUNION ALL\n(SELECT `c`.`entity_id`, `c`.`parent_id`, `c`.`path`, `c`.`position`, `h`.`depth`+1, CONCAT(`h`.`tree_path`,',',`c`.`entity_id`) FROM `catalog_category_entity` AS `c` INNER JOIN `dml_hierarchy` AS `h` ON (`c`.`parent_id` = `h`.`entity_id`) WHERE (FIND_IN_SET(`c`.`entity_id`,`h`.`tree_path`) = 0) AND (`h`.`depth` < 3)))\nSELECT `path`, `position`, `entity_id`, `parent_id`, `depth`, `tree_path` FROM `dml_hierarchy` ORDER BY `depth`, `entity_id`",
+			"",
+		)
+	})
+
+	t.Run("Ancestors with place holder", func(t *testing.T) {
+		compareToSQL(t, dml.NewWith().Ancestors(dml.Hierarchy{Table: "catalog_category_entity"}), errors.NoKind,
+			"WITH RECURSIVE `dml_hierarchy` (`entity_id`,`parent_id`,`depth`,`tree_path`) AS ((SELECT `entity_id`, `parent_id`, 0, CAST(`entity_id` AS CHAR(2048)) FROM `catalog_category_entity` WHERE (`entity_id` = ?))\nUNION ALL\n(SELECT `c`.`entity_id`, `c`.`parent_id`, `h`.`depth`+1, CONCAT(`h`.`tree_path`,',',`c`.`entity_id`) FROM `catalog_category_entity` AS `c` INNER JOIN `dml_hierarchy` AS `h` ON (`c`.`entity_id` = `h`.`parent_id`) WHERE (FIND_IN_SET(`c`.`entity_id`,`h`.`tree_path`) = 0)))\nSELECT `entity_id`, `parent_id`, `depth`, `tree_path` FROM `dml_hierarchy` ORDER BY `depth`, `entity_id`",
+			"",
+		)
+	})
+
+	t.Run("empty table", func(t *testing.T) {
+		compareToSQL(t, dml.NewWith().Descendants(dml.Hierarchy{}), errors.Empty, "", "")
+	})
+
+	t.Run("load tree", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("WITH RECURSIVE `dml_hierarchy`")).
+			WillReturnRows(sqlmock.NewRows([]string{"path", "position", "entity_id", "parent_id", "depth", "tree_path"}).
+				AddRow("1/2", 1, 2, 1, 0, "2").
+				AddRow("1/2/3", 1, 3, 2, 1, "2,3").
+				AddRow("1/2/4", 2, 4, 2, 1, "2,4").
+				AddRow("1/2/3/5", 1, 5, 3, 2, "2,3,5"))
+
+		nodes := &dml.HierarchyNodes{
+			NewRecord: func() dml.ColumnMapper { return new(testCategory) },
+		}
+		rows, err := dbc.With().Descendants(h, 2).WithArgs().Load(context.TODO(), nodes)
+		require.NoError(t, err)
+		assert.Exactly(t, uint64(4), rows)
+
+		roots := nodes.Tree()
+		require.Len(t, roots, 1)
+		root := roots[0]
+		assert.Exactly(t, int64(2), root.ID)
+		assert.Exactly(t, &testCategory{Path: "1/2", Position: 1}, root.Record)
+		require.Len(t, root.Children, 2)
+		assert.Exactly(t, int64(3), root.Children[0].ID)
+		assert.Exactly(t, int64(4), root.Children[1].ID)
+		require.Len(t, root.Children[0].Children, 1)
+		leaf := root.Children[0].Children[0]
+		assert.Exactly(t, int64(5), leaf.ID)
+		assert.Exactly(t, 2, leaf.Depth)
+		assert.Exactly(t, "2,3,5", leaf.Path)
+		assert.Exactly(t, &testCategory{Path: "1/2/3/5", Position: 1}, leaf.Record)
+	})
+}
@@ -121,6 +121,17 @@ var (
 		ProtobufSignedNull:      "dml.Decimal", // Proto package and its type not the Go package!
 		ProtobufSignedNotNull:   "dml.Decimal", // Proto package and its type not the Go package!
 	}
+	goTypeJSON = &TypeDef{
+		MysqlUnsignedNull:    "dml.NullJSON",
+		MysqlUnsignedNotNull: "dml.JSON",
+		MysqlSignedNull:      "dml.NullJSON",
+		MysqlSignedNotNull:   "dml.JSON",
+
+		ProtobufUnsignedNull:    "dml.NullJSON", // Proto package and its type, not the Go package!
+		ProtobufUnsignedNotNull: "bytes",
+		ProtobufSignedNull:      "dml.NullJSON", // Proto package and its type, not the Go package!
+		ProtobufSignedNotNull:   "bytes",
+	}
 	goTypeByte = &TypeDef{
 		MysqlUnsignedNull:    "[]byte",
 		MysqlUnsignedNotNull: "[]byte",
@@ -164,6 +175,7 @@ var MysqlTypeToGo = map[string]*TypeDef{
 	"binary":     goTypeByte,
 	"varbinary":  goTypeByte,
 	"bit":        goTypeBool,
+	"json":       goTypeJSON,
 }
 
 func toGoTypeNull(c *ddl.Column) string {
@@ -58,6 +58,8 @@ func TestGetGoPrimitive(t *testing.T) {
 		{ddl.Column{Field: `image002`, DataType: `varbinary`, Null: "YES"}, "[]byte"},
 		{ddl.Column{Field: `ok_dude1`, DataType: `bit`, Null: "NO"}, "bool"},
 		{ddl.Column{Field: `ok_dude2`, DataType: `bit`, Null: "YES"}, "dml.NullBool"},
+		{ddl.Column{Field: `attributes1`, DataType: `json`, Null: "NO"}, "dml.JSON"},
+		{ddl.Column{Field: `attributes2`, DataType: `json`, Null: "YES"}, "dml.NullJSON"},
 		{ddl.Column{Field: `description_001`, DataType: `varchar`, Null: "YES"}, "dml.NullString"},
 		{ddl.Column{Field: `description_002`, DataType: `varchar`, Null: "NO"}, "string"},
 		{ddl.Column{Field: `description_003`, DataType: `char`, Null: "YES"}, "dml.NullString"},
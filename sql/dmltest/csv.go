@@ -25,6 +25,7 @@ import (
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/storage/text"
 )
 
@@ -42,30 +43,9 @@ func WithFile(elem ...string) csvOptions {
 	return func(c *config) { c.path = filepath.Join(elem...) }
 }
 
-// CSVConfig allows to set special options when parsing the csv file.
-type CSVConfig struct {
-	// Comma is the field delimiter.
-	// It is set to comma (',') by NewReader.
-	Comma rune
-	// Comment, if not 0, is the comment character. Lines beginning with the
-	// Comment character without preceding whitespace are ignored. With leading
-	// whitespace the Comment character becomes part of the field, even if
-	// TrimLeadingSpace is true.
-	Comment rune
-	// FieldsPerRecord is the number of expected fields per record. If
-	// FieldsPerRecord is positive, Read requires each record to have the given
-	// number of fields. If FieldsPerRecord is 0, Read sets it to the number of
-	// fields in the first record, so that future records must have the same
-	// field count. If FieldsPerRecord is negative, no check is made and records
-	// may have a variable number of fields.
-	FieldsPerRecord int
-	// If LazyQuotes is true, a quote may appear in an unquoted field and a
-	// non-doubled quote may appear in a quoted field.
-	LazyQuotes *bool
-	// If TrimLeadingSpace is true, leading white space in a field is ignored.
-	// This is done even if the field delimiter, Comma, is white space.
-	TrimLeadingSpace *bool
-}
+// CSVConfig allows to set special options when parsing the csv file. The
+// field UseCRLF gets ignored by LoadCSV.
+type CSVConfig = dml.CSVConfig
 
 // WithReaderConfig sets CSV reader options
 func WithReaderConfig(cr CSVConfig) csvOptions {
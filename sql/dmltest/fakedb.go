@@ -0,0 +1,475 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/storage/text"
+)
+
+// FakeDB implements a deterministic in-memory database which understands the
+// subset of SQL emitted by the dml query builders: SELECT, INSERT, UPDATE and
+// DELETE statements on a single table with WHERE, ORDER BY and LIMIT clauses.
+// Fixture tables are getting loaded from the same CSV format as used by
+// LoadCSV. Instead of matching SQL strings a test asserts the resulting table
+// state with TableRows or AssertTableCSV.
+//
+// All values are stored like the MySQL text protocol returns them: as byte
+// slices or nil for NULL. Comparisons are numeric if both sides can be parsed
+// as a number, otherwise binary. If the first column of a table contains only
+// integers, it acts as an AUTO_INCREMENT primary key when an INSERT omits it or
+// provides NULL.
+//
+// FakeDB implements driver.Connector and is safe for concurrent use. A
+// transaction writes into private copies of the tables, other connections see
+// its changes after the commit. Commit merges the inserted, updated and deleted
+// rows of the transaction into the tables, changes committed meanwhile by other
+// connections are preserved. Rollback discards the copies.
+type FakeDB struct {
+	mu     sync.RWMutex
+	tables map[string]*fakeTable
+}
+
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+	// ids identifies each row, same length as rows. Used to merge the changes
+	// of a transaction.
+	ids []uint64
+	// autoInc contains the last generated AUTO_INCREMENT value. It is shared
+	// between the table and all its copies, so concurrent transactions
+	// generate distinct IDs. Protected by FakeDB.mu.
+	autoInc *int64
+}
+
+// fakeRowSeq generates the row IDs.
+var fakeRowSeq uint64
+
+func nextFakeRowID() uint64 { return atomic.AddUint64(&fakeRowSeq, 1) }
+
+func (ft *fakeTable) clone() *fakeTable {
+	nt := &fakeTable{
+		columns: append([]string(nil), ft.columns...),
+		rows:    make([][]driver.Value, len(ft.rows)),
+		ids:     append([]uint64(nil), ft.ids...),
+		autoInc: ft.autoInc,
+	}
+	for i, r := range ft.rows {
+		nt.rows[i] = cloneFakeRow(r)
+	}
+	return nt
+}
+
+// merge applies the differences between base and changed to ft. base has been
+// copied from ft when a transaction wrote the first time into the table,
+// changed is the copy of the transaction. Rows get identified by their ID. Rows
+// changed by the transaction overwrite concurrent changes of the same row.
+func (ft *fakeTable) merge(base, changed *fakeTable) {
+	baseRows := make(map[uint64][]driver.Value, len(base.ids))
+	for i, id := range base.ids {
+		baseRows[id] = base.rows[i]
+	}
+	txRows := make(map[uint64][]driver.Value, len(changed.ids))
+	for i, id := range changed.ids {
+		txRows[id] = changed.rows[i]
+	}
+
+	rows := make([][]driver.Value, 0, len(ft.rows))
+	ids := make([]uint64, 0, len(ft.ids))
+	for i, id := range ft.ids {
+		r := ft.rows[i]
+		if br, ok := baseRows[id]; ok {
+			nr, ok := txRows[id]
+			if !ok {
+				continue // deleted by the transaction
+			}
+			if !equalFakeRow(br, nr) {
+				r = nr
+			}
+		}
+		rows = append(rows, r)
+		ids = append(ids, id)
+	}
+	for i, id := range changed.ids {
+		if _, ok := baseRows[id]; !ok {
+			rows = append(rows, changed.rows[i])
+			ids = append(ids, id)
+		}
+	}
+	ft.rows, ft.ids = rows, ids
+}
+
+func (ft *fakeTable) columnIndex(name string) int {
+	for i, c := range ft.columns {
+		if strings.EqualFold(c, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func cloneFakeRow(r []driver.Value) []driver.Value {
+	nr := make([]driver.Value, len(r))
+	for i, v := range r {
+		if b, ok := v.([]byte); ok {
+			v = append([]byte{}, b...)
+		}
+		nr[i] = v
+	}
+	return nr
+}
+
+// NewFakeDB creates a new empty in-memory database.
+func NewFakeDB() *FakeDB {
+	return &FakeDB{
+		tables: make(map[string]*fakeTable),
+	}
+}
+
+// CreateTable creates or replaces a table with the provided columns and rows.
+// The values of a row get converted into their textual representation.
+func (db *FakeDB) CreateTable(table string, columns []string, rows ...[]driver.Value) error {
+	ft := &fakeTable{
+		columns: append([]string(nil), columns...),
+		rows:    make([][]driver.Value, 0, len(rows)),
+		autoInc: new(int64),
+	}
+	for i, r := range rows {
+		if len(r) != len(columns) {
+			return errors.Mismatch.Newf("[dmltest] FakeDB.CreateTable %q: row %d has %d values but the table %d columns", table, i, len(r), len(columns))
+		}
+		nr := make([]driver.Value, len(r))
+		for j, v := range r {
+			var err error
+			if nr[j], err = fakeStoreValue(v); err != nil {
+				return errors.Wrapf(err, "[dmltest] FakeDB.CreateTable %q row %d column %q", table, i, columns[j])
+			}
+		}
+		ft.rows = append(ft.rows, nr)
+		ft.ids = append(ft.ids, nextFakeRowID())
+	}
+	db.mu.Lock()
+	db.tables[table] = ft
+	db.mu.Unlock()
+	return nil
+}
+
+// LoadCSV creates or replaces a table with the columns and rows of a CSV
+// file. The options are the same as for the package function LoadCSV.
+func (db *FakeDB) LoadCSV(table string, opts ...csvOptions) error {
+	cols, rows, err := LoadCSV(opts...)
+	if err != nil {
+		return errors.Wrapf(err, "[dmltest] FakeDB.LoadCSV for table %q", table)
+	}
+	return db.CreateTable(table, cols, rows...)
+}
+
+// MustLoadCSV same as LoadCSV but panics on error.
+func (db *FakeDB) MustLoadCSV(table string, opts ...csvOptions) *FakeDB {
+	if err := db.LoadCSV(table, opts...); err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// TableRows returns a copy of the current columns and rows of a table. The
+// values are byte slices or nil and can be compared with the result of
+// function LoadCSV without the test mode.
+func (db *FakeDB) TableRows(table string) (columns []string, rows [][]driver.Value, err error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	ft, ok := db.tables[table]
+	if !ok {
+		return nil, nil, errors.NotFound.Newf("[dmltest] FakeDB table %q not found", table)
+	}
+	ft = ft.clone()
+	return ft.columns, ft.rows, nil
+}
+
+// Connect implements driver.Connector.
+func (db *FakeDB) Connect(_ context.Context) (driver.Conn, error) {
+	return &fakeConn{db: db}, nil
+}
+
+// Driver implements driver.Connector.
+func (db *FakeDB) Driver() driver.Driver {
+	return fakeDriver{db: db}
+}
+
+// ConnPool creates a new connection pool which runs all queries against the
+// in-memory database. Fatals on error.
+func (db *FakeDB) ConnPool(t testing.TB, opts ...dml.ConnPoolOption) *dml.ConnPool {
+	if t != nil { // t can be nil in Example functions
+		t.Helper()
+	}
+	cfg := []dml.ConnPoolOption{dml.WithDB(sql.OpenDB(db))}
+	dbc, err := dml.NewConnPool(append(cfg, opts...)...)
+	FatalIfError(t, err)
+	return dbc
+}
+
+// AssertTableCSV compares the current state of a table with the content of a
+// CSV file and reports the differences as a test error. The options are the
+// same as for the package function LoadCSV, the test mode gets ignored.
+func AssertTableCSV(t testing.TB, db *FakeDB, table string, opts ...csvOptions) {
+	t.Helper()
+	wantCols, wantRows, err := LoadCSV(opts...)
+	FatalIfError(t, err)
+	haveCols, haveRows, err := db.TableRows(table)
+	FatalIfError(t, err)
+
+	want := formatTableRows(wantCols, wantRows)
+	have := formatTableRows(haveCols, haveRows)
+	if want != have {
+		t.Errorf("[dmltest] Table %q does not match the expected content.\nWant:\n%s\nHave:\n%s", table, want, have)
+	}
+}
+
+// formatTableRows writes the columns and rows in a pipe separated format, one
+// row per line.
+func formatTableRows(columns []string, rows [][]driver.Value) string {
+	var buf bytes.Buffer
+	buf.WriteString(strings.Join(columns, " | "))
+	buf.WriteByte('\n')
+	for _, r := range rows {
+		for i, v := range r {
+			if i > 0 {
+				buf.WriteString(" | ")
+			}
+			if v == nil {
+				buf.WriteString("NULL")
+				continue
+			}
+			buf.Write(fakeValueBytes(v))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+// fakeStoreValue converts a driver.Value into the stored representation.
+func fakeStoreValue(v driver.Value) (driver.Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case text.Chars:
+		return append([]byte{}, val...), nil
+	case []byte:
+		return append([]byte{}, val...), nil
+	default:
+		return fakeValueBytes(v), nil
+	}
+}
+
+func fakeValueBytes(v driver.Value) []byte {
+	switch val := v.(type) {
+	case []byte:
+		return val
+	case text.Chars:
+		return val
+	case string:
+		return []byte(val)
+	case int64:
+		return strconv.AppendInt(nil, val, 10)
+	case float64:
+		return strconv.AppendFloat(nil, val, 'f', -1, 64)
+	case bool:
+		if val {
+			return []byte("1")
+		}
+		return []byte("0")
+	case time.Time:
+		return []byte(val.Format(fakeTimeFormat))
+	}
+	return []byte(fmt.Sprint(v))
+}
+
+const fakeTimeFormat = "2006-01-02 15:04:05"
+
+/*****************************************************************************************************
+	database/sql/driver implementation
+*****************************************************************************************************/
+
+type fakeDriver struct {
+	db *FakeDB
+}
+
+// Open implements driver.Driver. The name gets ignored.
+func (d fakeDriver) Open(_ string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+	db *FakeDB
+	tx *fakeTx
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	q, err := parseFakeQuery(query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &fakeStmt{conn: c, query: q}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(_ context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	if c.tx != nil {
+		return nil, errors.AlreadyExists.Newf("[dmltest] FakeDB: transaction already started")
+	}
+	c.tx = &fakeTx{
+		conn:   c,
+		base:   make(map[string]*fakeTable),
+		tables: make(map[string]*fakeTable),
+	}
+	return c.tx, nil
+}
+
+func (c *fakeConn) CheckNamedValue(nv *driver.NamedValue) (err error) {
+	if u, ok := nv.Value.(uint64); ok {
+		nv.Value = strconv.AppendUint(nil, u, 10)
+		return nil
+	}
+	nv.Value, err = driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	return err
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	q, err := parseFakeQuery(query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.db.exec(c.tx, q, namedValuesToValues(args))
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, err := parseFakeQuery(query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.db.query(c.tx, q, namedValuesToValues(args))
+}
+
+func namedValuesToValues(nvs []driver.NamedValue) []driver.Value {
+	args := make([]driver.Value, len(nvs))
+	for i, nv := range nvs {
+		args[i] = nv.Value
+	}
+	return args
+}
+
+// fakeTx contains the write set of a transaction. A table gets copied twice
+// when the transaction writes the first time into it: base keeps the state
+// before the first write and tables receives all writes.
+type fakeTx struct {
+	conn   *fakeConn
+	base   map[string]*fakeTable
+	tables map[string]*fakeTable
+}
+
+func (tx *fakeTx) Commit() error {
+	db := tx.conn.db
+	db.mu.Lock()
+	for name, ft := range tx.tables {
+		if shared, ok := db.tables[name]; ok {
+			shared.merge(tx.base[name], ft)
+		}
+	}
+	db.mu.Unlock()
+	tx.conn.tx = nil
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.conn.tx = nil
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query *fakeQuery
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return s.query.placeHolders }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.db.exec(s.conn.tx, s.query, args)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.db.query(s.conn.tx, s.query, args)
+}
+
+func (s *fakeStmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.conn.db.exec(s.conn.tx, s.query, namedValuesToValues(args))
+}
+
+func (s *fakeStmt) QueryContext(_ context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.conn.db.query(s.conn.tx, s.query, namedValuesToValues(args))
+}
+
+func (s *fakeStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.conn.CheckNamedValue(nv)
+}
+
+type fakeResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r fakeResult) LastInsertId() (int64, error) { return r.lastInsertID, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	pos     int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.pos])
+	r.pos++
+	return nil
+}
@@ -0,0 +1,1613 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+)
+
+/*****************************************************************************************************
+	Lexer
+*****************************************************************************************************/
+
+type fakeTokenKind uint8
+
+const (
+	fakeTokEOF fakeTokenKind = iota
+	fakeTokIdent
+	fakeTokQuotedIdent
+	fakeTokString
+	fakeTokNumber
+	fakeTokPlaceHolder
+	fakeTokSymbol
+)
+
+type fakeToken struct {
+	kind fakeTokenKind
+	val  string
+}
+
+func isFakeDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isFakeIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isFakeIdent(c byte) bool { return isFakeIdentStart(c) || isFakeDigit(c) }
+
+func isFakeHex(c byte) bool {
+	return isFakeDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// lexFakeSQL splits a query into tokens. Comments including the dml statement
+// ID get skipped.
+func lexFakeSQL(q string) ([]fakeToken, error) {
+	toks := make([]fakeToken, 0, 32)
+	for i := 0; i < len(q); {
+		c := q[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '/' && i+1 < len(q) && q[i+1] == '*':
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.NotValid.Newf("[dmltest] FakeDB: unterminated comment in query %q", q)
+			}
+			i += end + 4
+
+		case c == '#' || (c == '-' && strings.HasPrefix(q[i:], "-- ")):
+			end := strings.IndexByte(q[i:], '\n')
+			if end < 0 {
+				end = len(q) - i
+			}
+			i += end
+
+		case c == '`':
+			var buf strings.Builder
+			j := i + 1
+			for ; ; j++ {
+				if j >= len(q) {
+					return nil, errors.NotValid.Newf("[dmltest] FakeDB: unterminated identifier in query %q", q)
+				}
+				if q[j] == '`' {
+					if j+1 < len(q) && q[j+1] == '`' {
+						buf.WriteByte('`')
+						j++
+						continue
+					}
+					break
+				}
+				buf.WriteByte(q[j])
+			}
+			toks = append(toks, fakeToken{kind: fakeTokQuotedIdent, val: buf.String()})
+			i = j + 1
+
+		case c == '\'' || c == '"':
+			var buf strings.Builder
+			j := i + 1
+			for ; ; j++ {
+				if j >= len(q) {
+					return nil, errors.NotValid.Newf("[dmltest] FakeDB: unterminated string in query %q", q)
+				}
+				if q[j] == '\\' && j+1 < len(q) {
+					j++
+					switch q[j] {
+					case '0':
+						buf.WriteByte(0)
+					case 'n':
+						buf.WriteByte('\n')
+					case 'r':
+						buf.WriteByte('\r')
+					case 't':
+						buf.WriteByte('\t')
+					case 'b':
+						buf.WriteByte('\b')
+					case 'Z':
+						buf.WriteByte(0x1a)
+					default:
+						buf.WriteByte(q[j])
+					}
+					continue
+				}
+				if q[j] == c {
+					if j+1 < len(q) && q[j+1] == c {
+						buf.WriteByte(c)
+						j++
+						continue
+					}
+					break
+				}
+				buf.WriteByte(q[j])
+			}
+			toks = append(toks, fakeToken{kind: fakeTokString, val: buf.String()})
+			i = j + 1
+
+		case c == '0' && i+1 < len(q) && (q[i+1] == 'x' || q[i+1] == 'X'):
+			j := i + 2
+			for j < len(q) && isFakeHex(q[j]) {
+				j++
+			}
+			b, err := hex.DecodeString(q[i+2 : j])
+			if err != nil {
+				return nil, errors.NotValid.New(err, "[dmltest] FakeDB: invalid hex literal in query %q", q)
+			}
+			toks = append(toks, fakeToken{kind: fakeTokString, val: string(b)})
+			i = j
+
+		case isFakeDigit(c) || (c == '.' && i+1 < len(q) && isFakeDigit(q[i+1])):
+			j := i
+			for j < len(q) {
+				d := q[j]
+				if isFakeDigit(d) || d == '.' || d == 'e' || d == 'E' || ((d == '+' || d == '-') && (q[j-1] == 'e' || q[j-1] == 'E')) {
+					j++
+					continue
+				}
+				break
+			}
+			toks = append(toks, fakeToken{kind: fakeTokNumber, val: q[i:j]})
+			i = j
+
+		case c == '?':
+			toks = append(toks, fakeToken{kind: fakeTokPlaceHolder, val: "?"})
+			i++
+
+		case isFakeIdentStart(c):
+			j := i
+			for j < len(q) && isFakeIdent(q[j]) {
+				j++
+			}
+			toks = append(toks, fakeToken{kind: fakeTokIdent, val: q[i:j]})
+			i = j
+
+		default:
+			sym := ""
+			for _, s := range [...]string{"<=>", "<=", ">=", "!=", "<>", "||", "&&"} {
+				if strings.HasPrefix(q[i:], s) {
+					sym = s
+					break
+				}
+			}
+			if sym == "" {
+				if strings.IndexByte("=<>(),.*;+-/%!", c) < 0 {
+					return nil, errors.NotSupported.Newf("[dmltest] FakeDB: unexpected character %q in query %q", c, q)
+				}
+				sym = q[i : i+1]
+			}
+			toks = append(toks, fakeToken{kind: fakeTokSymbol, val: sym})
+			i += len(sym)
+		}
+	}
+	return append(toks, fakeToken{kind: fakeTokEOF}), nil
+}
+
+/*****************************************************************************************************
+	Parser
+*****************************************************************************************************/
+
+// fakeQuery represents a parsed statement.
+type fakeQuery struct {
+	kind         byte // s=SELECT, i=INSERT, u=UPDATE, d=DELETE
+	table        string
+	columns      []fakeSelectColumn
+	insertCols   []string
+	values       [][]fakeExpr
+	sets         []fakeAssignment
+	where        fakeExpr
+	orderBy      []fakeOrderBy
+	limit        int64 // -1 means no limit
+	offset       int64
+	placeHolders int
+}
+
+type fakeSelectColumn struct {
+	name  string
+	expr  fakeExpr
+	star  bool
+	count bool
+}
+
+type fakeAssignment struct {
+	column string
+	expr   fakeExpr
+}
+
+type fakeOrderBy struct {
+	expr fakeExpr
+	desc bool
+}
+
+// fakeReserved contains the keywords which can't be used as an unquoted
+// identifier or alias.
+var fakeReserved = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "ORDER": true, "GROUP": true, "HAVING": true,
+	"LIMIT": true, "OFFSET": true, "SET": true, "VALUES": true, "VALUE": true, "AND": true, "OR": true,
+	"NOT": true, "IN": true, "IS": true, "LIKE": true, "BETWEEN": true, "JOIN": true, "LEFT": true,
+	"RIGHT": true, "INNER": true, "OUTER": true, "CROSS": true, "STRAIGHT_JOIN": true, "ON": true,
+	"USING": true, "UNION": true, "FOR": true, "LOCK": true, "AS": true, "ASC": true, "DESC": true,
+	"INTO": true, "DUPLICATE": true, "NULL": true, "XOR": true,
+}
+
+type fakeParser struct {
+	query        string
+	toks         []fakeToken
+	pos          int
+	placeHolders int
+}
+
+// parseFakeQuery parses one of the supported statements.
+func parseFakeQuery(query string) (*fakeQuery, error) {
+	toks, err := lexFakeSQL(query)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p := &fakeParser{query: query, toks: toks}
+
+	var q *fakeQuery
+	switch {
+	case p.acceptKeyword("SELECT"):
+		q, err = p.parseSelect()
+	case p.acceptKeyword("INSERT"):
+		q, err = p.parseInsert()
+	case p.acceptKeyword("UPDATE"):
+		q, err = p.parseUpdate()
+	case p.acceptKeyword("DELETE"):
+		q, err = p.parseDelete()
+	default:
+		return nil, p.errorf("unsupported statement")
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p.acceptSymbol(";")
+	if t := p.peek(); t.kind != fakeTokEOF {
+		return nil, p.errorf("unexpected token %q", t.val)
+	}
+	q.placeHolders = p.placeHolders
+	return q, nil
+}
+
+func (p *fakeParser) errorf(format string, args ...interface{}) error {
+	return errors.NotSupported.Newf("[dmltest] FakeDB: "+format+" in query: %q", append(args, p.query)...)
+}
+
+func (p *fakeParser) peek() fakeToken { return p.toks[p.pos] }
+
+func (p *fakeParser) next() fakeToken {
+	t := p.toks[p.pos]
+	if t.kind != fakeTokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *fakeParser) isKeyword(kws ...string) bool {
+	t := p.peek()
+	if t.kind != fakeTokIdent {
+		return false
+	}
+	for _, kw := range kws {
+		if strings.EqualFold(t.val, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *fakeParser) acceptKeyword(kw string) bool {
+	if p.isKeyword(kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *fakeParser) expectKeyword(kw string) error {
+	if !p.acceptKeyword(kw) {
+		return p.errorf("expected %s but got %q", kw, p.peek().val)
+	}
+	return nil
+}
+
+func (p *fakeParser) isSymbol(s string) bool {
+	t := p.peek()
+	return t.kind == fakeTokSymbol && t.val == s
+}
+
+func (p *fakeParser) acceptSymbol(s string) bool {
+	if p.isSymbol(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *fakeParser) expectSymbol(s string) error {
+	if !p.acceptSymbol(s) {
+		return p.errorf("expected %q but got %q", s, p.peek().val)
+	}
+	return nil
+}
+
+func (p *fakeParser) isIdentifier() bool {
+	t := p.peek()
+	return t.kind == fakeTokQuotedIdent || (t.kind == fakeTokIdent && !fakeReserved[strings.ToUpper(t.val)])
+}
+
+// identifier parses a possibly qualified identifier and returns the last part.
+func (p *fakeParser) identifier() (string, error) {
+	if !p.isIdentifier() {
+		return "", p.errorf("expected identifier but got %q", p.peek().val)
+	}
+	name := p.next().val
+	for p.isSymbol(".") {
+		p.next()
+		if !p.isIdentifier() {
+			return "", p.errorf("expected identifier after %q", name+".")
+		}
+		name = p.next().val
+	}
+	return name, nil
+}
+
+// tableReference parses the table name and an optional alias. Joins are not
+// supported.
+func (p *fakeParser) tableReference() (string, error) {
+	table, err := p.identifier()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	if p.acceptKeyword("AS") || p.isIdentifier() {
+		if _, err := p.identifier(); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	if p.isSymbol(",") || p.isKeyword("JOIN", "LEFT", "RIGHT", "INNER", "OUTER", "CROSS", "STRAIGHT_JOIN") {
+		return "", p.errorf("joins are not supported")
+	}
+	return table, nil
+}
+
+func (p *fakeParser) parseSelect() (*fakeQuery, error) {
+	q := &fakeQuery{kind: 's', limit: -1}
+	for p.isKeyword("SQL_NO_CACHE", "SQL_CACHE", "HIGH_PRIORITY") {
+		p.next()
+	}
+	if p.isKeyword("DISTINCT", "DISTINCTROW", "STRAIGHT_JOIN", "SQL_CALC_FOUND_ROWS") {
+		return nil, p.errorf("%s is not supported", p.peek().val)
+	}
+	for {
+		col, err := p.parseSelectColumn(len(q.columns))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		q.columns = append(q.columns, col)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if p.acceptKeyword("FROM") {
+		var err error
+		if q.table, err = p.tableReference(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := p.parseWhere(q); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if p.isKeyword("GROUP", "HAVING", "UNION") {
+		return nil, p.errorf("%s is not supported", p.peek().val)
+	}
+	if err := p.parseOrderLimit(q); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch {
+	case p.acceptKeyword("FOR"):
+		if err := p.expectKeyword("UPDATE"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	case p.acceptKeyword("LOCK"):
+		for _, kw := range [...]string{"IN", "SHARE", "MODE"} {
+			if err := p.expectKeyword(kw); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	return q, nil
+}
+
+func (p *fakeParser) parseSelectColumn(pos int) (fakeSelectColumn, error) {
+	var col fakeSelectColumn
+	switch {
+	case p.acceptSymbol("*"):
+		col.star = true
+		return col, nil
+	case p.isIdentifier() && p.toks[p.pos+1].val == "." && p.toks[p.pos+2].val == "*":
+		p.pos += 3
+		col.star = true
+		return col, nil
+	case p.isKeyword("COUNT") && p.toks[p.pos+1].val == "(":
+		p.pos += 2
+		if err := p.expectSymbol("*"); err != nil {
+			return col, errors.WithStack(err)
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return col, errors.WithStack(err)
+		}
+		col.count = true
+		col.name = "COUNT(*)"
+	default:
+		ex, err := p.parseExpr()
+		if err != nil {
+			return col, errors.WithStack(err)
+		}
+		col.expr = ex
+		col.name = "col" + strconv.Itoa(pos+1)
+		if c, ok := ex.(fakeColumn); ok {
+			col.name = string(c)
+		}
+	}
+	if p.acceptKeyword("AS") || p.isIdentifier() {
+		alias, err := p.identifier()
+		if err != nil {
+			return col, errors.WithStack(err)
+		}
+		col.name = alias
+	}
+	return col, nil
+}
+
+func (p *fakeParser) parseInsert() (*fakeQuery, error) {
+	q := &fakeQuery{kind: 'i', limit: -1}
+	p.acceptKeyword("IGNORE")
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var err error
+	if q.table, err = p.identifier(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if p.acceptSymbol("(") {
+		for {
+			c, err := p.identifier()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			q.insertCols = append(q.insertCols, c)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if !p.acceptKeyword("VALUES") && !p.acceptKeyword("VALUE") {
+		return nil, p.errorf("only INSERT ... VALUES is supported")
+	}
+	for {
+		if err := p.expectSymbol("("); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var row []fakeExpr
+		for {
+			ex, err := p.parseExpr()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			row = append(row, ex)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+		if err := p.expectSymbol(")"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		q.values = append(q.values, row)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if p.isKeyword("ON") {
+		return nil, p.errorf("ON DUPLICATE KEY UPDATE is not supported")
+	}
+	return q, nil
+}
+
+func (p *fakeParser) parseUpdate() (*fakeQuery, error) {
+	q := &fakeQuery{kind: 'u', limit: -1}
+	var err error
+	if q.table, err = p.tableReference(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for {
+		c, err := p.identifier()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := p.expectSymbol("="); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ex, err := p.parseExpr()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		q.sets = append(q.sets, fakeAssignment{column: c, expr: ex})
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.parseWhere(q); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return q, errors.WithStack(p.parseOrderLimit(q))
+}
+
+func (p *fakeParser) parseDelete() (*fakeQuery, error) {
+	q := &fakeQuery{kind: 'd', limit: -1}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var err error
+	if q.table, err = p.tableReference(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.parseWhere(q); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return q, errors.WithStack(p.parseOrderLimit(q))
+}
+
+func (p *fakeParser) parseWhere(q *fakeQuery) (err error) {
+	if p.acceptKeyword("WHERE") {
+		q.where, err = p.parseExpr()
+	}
+	return err
+}
+
+func (p *fakeParser) parseOrderLimit(q *fakeQuery) error {
+	if p.acceptKeyword("ORDER") {
+		if err := p.expectKeyword("BY"); err != nil {
+			return errors.WithStack(err)
+		}
+		for {
+			if p.acceptKeyword("NULL") { // ORDER BY NULL disables sorting
+				break
+			}
+			ex, err := p.parseExpr()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ob := fakeOrderBy{expr: ex}
+			if p.acceptKeyword("DESC") {
+				ob.desc = true
+			} else {
+				p.acceptKeyword("ASC")
+			}
+			q.orderBy = append(q.orderBy, ob)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if !p.acceptKeyword("LIMIT") {
+		return nil
+	}
+	n, err := p.parseUint()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	q.limit = n
+	switch {
+	case p.acceptKeyword("OFFSET"):
+		q.offset, err = p.parseUint()
+	case p.acceptSymbol(","): // LIMIT offset, row_count
+		q.offset = n
+		q.limit, err = p.parseUint()
+	}
+	return errors.WithStack(err)
+}
+
+func (p *fakeParser) parseUint() (int64, error) {
+	t := p.next()
+	if t.kind != fakeTokNumber {
+		return 0, p.errorf("expected number but got %q", t.val)
+	}
+	n, err := strconv.ParseInt(t.val, 10, 64)
+	if err != nil || n < 0 {
+		return 0, p.errorf("invalid number %q", t.val)
+	}
+	return n, nil
+}
+
+/*****************************************************************************************************
+	Expressions
+*****************************************************************************************************/
+
+// fakeEnv provides the current row and the arguments to an expression.
+type fakeEnv struct {
+	table *fakeTable
+	row   []driver.Value
+	args  []driver.Value
+}
+
+type fakeExpr interface {
+	eval(e *fakeEnv) (driver.Value, error)
+}
+
+type fakeFunc func(e *fakeEnv) (driver.Value, error)
+
+func (f fakeFunc) eval(e *fakeEnv) (driver.Value, error) { return f(e) }
+
+type fakeColumn string
+
+func (c fakeColumn) eval(e *fakeEnv) (driver.Value, error) {
+	idx := e.table.columnIndex(string(c))
+	if idx < 0 {
+		return nil, errors.NotFound.Newf("[dmltest] FakeDB: unknown column %q", string(c))
+	}
+	return e.row[idx], nil
+}
+
+func fakeLiteral(v driver.Value) fakeExpr {
+	return fakeFunc(func(*fakeEnv) (driver.Value, error) { return v, nil })
+}
+
+func (p *fakeParser) parseExpr() (fakeExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for p.acceptKeyword("OR") || p.acceptSymbol("||") {
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l = fakeLogical(l, r, true)
+	}
+	return l, nil
+}
+
+func (p *fakeParser) parseAnd() (fakeExpr, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for p.acceptKeyword("AND") || p.acceptSymbol("&&") {
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l = fakeLogical(l, r, false)
+	}
+	return l, nil
+}
+
+// fakeLogical implements the three valued logic of AND and OR.
+func fakeLogical(l, r fakeExpr, isOr bool) fakeExpr {
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		lv, err := l.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := r.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		lt, rt := fakeTruth(lv), fakeTruth(rv)
+		switch {
+		case isOr && (lt || rt):
+			return int64(1), nil
+		case !isOr && ((lv != nil && !lt) || (rv != nil && !rt)):
+			return int64(0), nil
+		case lv == nil || rv == nil:
+			return nil, nil
+		}
+		return fakeBool(!isOr || lt || rt), nil
+	})
+}
+
+func (p *fakeParser) parseNot() (fakeExpr, error) {
+	if p.acceptKeyword("NOT") || p.acceptSymbol("!") {
+		ex, err := p.parseNot()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fakeNot(ex), nil
+	}
+	return p.parsePredicate()
+}
+
+func fakeNot(ex fakeExpr) fakeExpr {
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		v, err := ex.eval(e)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		return fakeBool(!fakeTruth(v)), nil
+	})
+}
+
+func (p *fakeParser) parsePredicate() (fakeExpr, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if t := p.peek(); t.kind == fakeTokSymbol {
+		switch op := t.val; op {
+		case "=", "!=", "<>", "<", ">", "<=", ">=", "<=>":
+			p.next()
+			r, err := p.parseAdditive()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return fakeComparison(op, l, r), nil
+		}
+	}
+
+	if p.acceptKeyword("IS") {
+		not := p.acceptKeyword("NOT")
+		if err := p.expectKeyword("NULL"); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+			v, err := l.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			return fakeBool((v == nil) != not), nil
+		}), nil
+	}
+
+	not := p.acceptKeyword("NOT")
+	var ex fakeExpr
+	switch {
+	case p.acceptKeyword("IN"):
+		ex, err = p.parseIn(l)
+	case p.acceptKeyword("LIKE"):
+		var r fakeExpr
+		if r, err = p.parseAdditive(); err == nil {
+			ex = fakeLikeExpr(l, r)
+		}
+	case p.acceptKeyword("BETWEEN"):
+		ex, err = p.parseBetween(l)
+	case not:
+		return nil, p.errorf("unexpected NOT before %q", p.peek().val)
+	default:
+		return l, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if not {
+		ex = fakeNot(ex)
+	}
+	return ex, nil
+}
+
+func fakeComparison(op string, l, r fakeExpr) fakeExpr {
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		lv, err := l.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := r.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		if op == "<=>" {
+			if lv == nil || rv == nil {
+				return fakeBool(lv == nil && rv == nil), nil
+			}
+			c, _ := fakeCompare(lv, rv)
+			return fakeBool(c == 0), nil
+		}
+		c, ok := fakeCompare(lv, rv)
+		if !ok {
+			return nil, nil
+		}
+		var b bool
+		switch op {
+		case "=":
+			b = c == 0
+		case "!=", "<>":
+			b = c != 0
+		case "<":
+			b = c < 0
+		case ">":
+			b = c > 0
+		case "<=":
+			b = c <= 0
+		case ">=":
+			b = c >= 0
+		}
+		return fakeBool(b), nil
+	})
+}
+
+func (p *fakeParser) parseIn(l fakeExpr) (fakeExpr, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if p.isKeyword("SELECT") {
+		return nil, p.errorf("sub-selects are not supported")
+	}
+	var list []fakeExpr
+	for {
+		ex, err := p.parseAdditive()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		list = append(list, ex)
+		if !p.acceptSymbol(",") {
+			break
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		lv, err := l.eval(e)
+		if err != nil || lv == nil {
+			return nil, err
+		}
+		var hasNull bool
+		for _, ex := range list {
+			v, err := ex.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			if v == nil {
+				hasNull = true
+				continue
+			}
+			if c, _ := fakeCompare(lv, v); c == 0 {
+				return int64(1), nil
+			}
+		}
+		if hasNull {
+			return nil, nil
+		}
+		return int64(0), nil
+	}), nil
+}
+
+func (p *fakeParser) parseBetween(l fakeExpr) (fakeExpr, error) {
+	lo, err := p.parseAdditive()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.expectKeyword("AND"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	hi, err := p.parseAdditive()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ge, le := fakeComparison(">=", l, lo), fakeComparison("<=", l, hi)
+	return fakeLogical(ge, le, false), nil
+}
+
+func fakeLikeExpr(l, r fakeExpr) fakeExpr {
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		lv, err := l.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := r.eval(e)
+		if err != nil || lv == nil || rv == nil {
+			return nil, err
+		}
+		return fakeBool(fakeLike(fakeValueBytes(lv), fakeValueBytes(rv))), nil
+	})
+}
+
+// fakeLike matches s against a LIKE pattern with the wildcards % and _ and the
+// escape character backslash.
+func fakeLike(s, pattern []byte) bool {
+	var re strings.Builder
+	re.WriteString("(?s)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			re.WriteString(".*")
+		case '_':
+			re.WriteByte('.')
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteByte('$')
+	return regexp.MustCompile(re.String()).Match(s)
+}
+
+func (p *fakeParser) parseAdditive() (fakeExpr, error) {
+	l, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for p.isSymbol("+") || p.isSymbol("-") {
+		op := p.next().val[0]
+		r, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l = fakeArithmetic(op, l, r)
+	}
+	return l, nil
+}
+
+func (p *fakeParser) parseMultiplicative() (fakeExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for p.isSymbol("*") || p.isSymbol("/") || p.isSymbol("%") {
+		op := p.next().val[0]
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l = fakeArithmetic(op, l, r)
+	}
+	return l, nil
+}
+
+func (p *fakeParser) parseUnary() (fakeExpr, error) {
+	switch {
+	case p.acceptSymbol("-"):
+		ex, err := p.parseUnary()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fakeArithmetic('-', fakeLiteral(int64(0)), ex), nil
+	case p.acceptSymbol("+"):
+		return p.parseUnary()
+	}
+	return p.parseOperand()
+}
+
+func fakeArithmetic(op byte, l, r fakeExpr) fakeExpr {
+	return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+		lv, err := l.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		rv, err := r.eval(e)
+		if err != nil || lv == nil || rv == nil {
+			return nil, err
+		}
+		if li, ok := fakeInt(lv); ok && op != '/' {
+			if ri, ok := fakeInt(rv); ok {
+				switch op {
+				case '+':
+					return li + ri, nil
+				case '-':
+					return li - ri, nil
+				case '*':
+					return li * ri, nil
+				case '%':
+					if ri == 0 {
+						return nil, nil
+					}
+					return li % ri, nil
+				}
+			}
+		}
+		lf, _ := fakeNumber(lv)
+		rf, _ := fakeNumber(rv)
+		switch op {
+		case '+':
+			return lf + rf, nil
+		case '-':
+			return lf - rf, nil
+		case '*':
+			return lf * rf, nil
+		}
+		if rf == 0 {
+			return nil, nil
+		}
+		if op == '%' {
+			return lf - rf*float64(int64(lf/rf)), nil
+		}
+		return lf / rf, nil
+	})
+}
+
+func (p *fakeParser) parseOperand() (fakeExpr, error) {
+	t := p.peek()
+	switch t.kind {
+	case fakeTokPlaceHolder:
+		p.next()
+		idx := p.placeHolders
+		p.placeHolders++
+		return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+			if idx >= len(e.args) {
+				return nil, errors.NotFound.Newf("[dmltest] FakeDB: missing argument for place holder %d", idx+1)
+			}
+			return e.args[idx], nil
+		}), nil
+
+	case fakeTokString:
+		p.next()
+		return fakeLiteral([]byte(t.val)), nil
+
+	case fakeTokNumber:
+		p.next()
+		if i, err := strconv.ParseInt(t.val, 10, 64); err == nil {
+			return fakeLiteral(i), nil
+		}
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", t.val)
+		}
+		return fakeLiteral(f), nil
+
+	case fakeTokSymbol:
+		if !p.acceptSymbol("(") {
+			break
+		}
+		if p.isKeyword("SELECT") {
+			return nil, p.errorf("sub-selects are not supported")
+		}
+		ex, err := p.parseExpr()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return ex, errors.WithStack(p.expectSymbol(")"))
+
+	case fakeTokIdent, fakeTokQuotedIdent:
+		if t.kind == fakeTokIdent {
+			switch strings.ToUpper(t.val) {
+			case "NULL":
+				p.next()
+				return fakeLiteral(nil), nil
+			case "TRUE":
+				p.next()
+				return fakeLiteral(int64(1)), nil
+			case "FALSE":
+				p.next()
+				return fakeLiteral(int64(0)), nil
+			}
+		}
+		if p.toks[p.pos+1].val == "(" && p.toks[p.pos+1].kind == fakeTokSymbol {
+			return p.parseFunction()
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return fakeColumn(name), nil
+	}
+	return nil, p.errorf("unexpected token %q", t.val)
+}
+
+// parseFunction supports a small set of scalar functions.
+func (p *fakeParser) parseFunction() (fakeExpr, error) {
+	name := strings.ToUpper(p.next().val)
+	p.next() // (
+	var args []fakeExpr
+	if !p.isSymbol(")") {
+		for {
+			ex, err := p.parseExpr()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			args = append(args, ex)
+			if !p.acceptSymbol(",") {
+				break
+			}
+		}
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	evalArgs := func(e *fakeEnv) ([]driver.Value, error) {
+		vals := make([]driver.Value, len(args))
+		for i, a := range args {
+			v, err := a.eval(e)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = v
+		}
+		return vals, nil
+	}
+
+	switch name {
+	case "COALESCE", "IFNULL":
+		return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+			vals, err := evalArgs(e)
+			for _, v := range vals {
+				if v != nil {
+					return v, err
+				}
+			}
+			return nil, err
+		}), nil
+	case "CONCAT":
+		return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+			vals, err := evalArgs(e)
+			if err != nil {
+				return nil, err
+			}
+			var buf []byte
+			for _, v := range vals {
+				if v == nil {
+					return nil, nil
+				}
+				buf = append(buf, fakeValueBytes(v)...)
+			}
+			return buf, nil
+		}), nil
+	case "LOWER", "UPPER":
+		if len(args) != 1 {
+			return nil, p.errorf("%s requires one argument", name)
+		}
+		fn := bytes.ToLower
+		if name == "UPPER" {
+			fn = bytes.ToUpper
+		}
+		return fakeFunc(func(e *fakeEnv) (driver.Value, error) {
+			v, err := args[0].eval(e)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return fn(fakeValueBytes(v)), nil
+		}), nil
+	}
+	return nil, p.errorf("function %s is not supported", name)
+}
+
+/*****************************************************************************************************
+	Values
+*****************************************************************************************************/
+
+func fakeBool(b bool) driver.Value {
+	if b {
+		return int64(1)
+	}
+	return int64(0)
+}
+
+// fakeNumber converts a value into a float64. The boolean reports whether the
+// value is a valid number.
+func fakeNumber(v driver.Value) (float64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return float64(val), true
+	case float64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case []byte, string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(string(fakeValueBytes(val))), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func fakeInt(v driver.Value) (int64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return val, true
+	case bool:
+		if val {
+			return 1, true
+		}
+		return 0, true
+	case []byte, string:
+		i, err := strconv.ParseInt(strings.TrimSpace(string(fakeValueBytes(val))), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func fakeTruth(v driver.Value) bool {
+	f, _ := fakeNumber(v)
+	return v != nil && f != 0
+}
+
+// fakeCompare compares two values numerically when both are numbers,
+// otherwise binary. The boolean is false if one of the values is NULL.
+func fakeCompare(a, b driver.Value) (int, bool) {
+	if a == nil || b == nil {
+		return 0, false
+	}
+	if ai, ok := fakeInt(a); ok {
+		if bi, ok := fakeInt(b); ok {
+			switch {
+			case ai < bi:
+				return -1, true
+			case ai > bi:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	if af, ok := fakeNumber(a); ok {
+		if bf, ok := fakeNumber(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return bytes.Compare(fakeValueBytes(a), fakeValueBytes(b)), true
+}
+
+// fakeOrderCompare sorts NULL values first like MySQL.
+func fakeOrderCompare(a, b driver.Value) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	c, _ := fakeCompare(a, b)
+	return c
+}
+
+/*****************************************************************************************************
+	Execution
+*****************************************************************************************************/
+
+// table returns the table to read from or to write into. Within a transaction
+// the first write copies the table into the write set of the transaction. The
+// copy will be read by all further statements of the transaction. tx can be
+// nil. Must be called while holding the lock.
+func (db *FakeDB) table(tx *fakeTx, name string, write bool) (*fakeTable, error) {
+	if tx != nil {
+		if ft, ok := tx.tables[name]; ok {
+			return ft, nil
+		}
+	}
+	ft, ok := db.tables[name]
+	if !ok {
+		return nil, errors.NotFound.Newf("[dmltest] FakeDB: table %q not found", name)
+	}
+	if tx != nil && write {
+		tx.base[name] = ft.clone()
+		ft = ft.clone()
+		tx.tables[name] = ft
+	}
+	return ft, nil
+}
+
+// match returns the indexes of all rows which match the WHERE condition,
+// ordered by the ORDER BY clause. Applies LIMIT and OFFSET if applyLimit is
+// true.
+func (q *fakeQuery) match(ft *fakeTable, args []driver.Value, applyLimit bool) ([]int, error) {
+	var idx []int
+	env := &fakeEnv{table: ft, args: args}
+	for i, r := range ft.rows {
+		if q.where != nil {
+			env.row = r
+			v, err := q.where.eval(env)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if !fakeTruth(v) {
+				continue
+			}
+		}
+		idx = append(idx, i)
+	}
+
+	if len(q.orderBy) > 0 {
+		// Calculate the sort keys upfront to avoid errors within the less
+		// function.
+		keys := make(map[int][]driver.Value, len(idx))
+		for _, i := range idx {
+			env.row = ft.rows[i]
+			k := make([]driver.Value, len(q.orderBy))
+			for j, ob := range q.orderBy {
+				v, err := ob.expr.eval(env)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				k[j] = v
+			}
+			keys[i] = k
+		}
+		sort.SliceStable(idx, func(i, j int) bool {
+			ki, kj := keys[idx[i]], keys[idx[j]]
+			for k, ob := range q.orderBy {
+				c := fakeOrderCompare(ki[k], kj[k])
+				if c == 0 {
+					continue
+				}
+				if ob.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	if applyLimit {
+		idx = q.applyLimit(idx)
+	}
+	return idx, nil
+}
+
+func (q *fakeQuery) applyLimit(idx []int) []int {
+	if q.offset >= int64(len(idx)) {
+		return idx[:0]
+	}
+	idx = idx[q.offset:]
+	if q.limit >= 0 && q.limit < int64(len(idx)) {
+		idx = idx[:q.limit]
+	}
+	return idx
+}
+
+func (db *FakeDB) query(tx *fakeTx, q *fakeQuery, args []driver.Value) (driver.Rows, error) {
+	if q.kind != 's' {
+		if _, err := db.exec(tx, q, args); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &fakeRows{}, nil
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	ft := &fakeTable{rows: [][]driver.Value{nil}} // SELECT without FROM
+	if q.table != "" {
+		var err error
+		if ft, err = db.table(tx, q.table, false); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	var hasCount bool
+	for _, c := range q.columns {
+		hasCount = hasCount || c.count
+	}
+	if hasCount {
+		if len(q.columns) > 1 {
+			return nil, errors.NotSupported.Newf("[dmltest] FakeDB: COUNT(*) can't be mixed with other columns")
+		}
+		idx, err := q.match(ft, args, false)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res := &fakeRows{
+			columns: []string{q.columns[0].name},
+			rows:    [][]driver.Value{{int64(len(idx))}},
+		}
+		res.rows = res.rows[:len(q.applyLimit([]int{0}))]
+		return res, nil
+	}
+
+	idx, err := q.match(ft, args, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &fakeRows{}
+	for _, c := range q.columns {
+		if c.star {
+			res.columns = append(res.columns, ft.columns...)
+			continue
+		}
+		res.columns = append(res.columns, c.name)
+	}
+	env := &fakeEnv{table: ft, args: args}
+	for _, i := range idx {
+		env.row = ft.rows[i]
+		row := make([]driver.Value, 0, len(res.columns))
+		for _, c := range q.columns {
+			if c.star {
+				row = append(row, cloneFakeRow(env.row)...)
+				continue
+			}
+			v, err := c.expr.eval(env)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			sv, _ := fakeStoreValue(v)
+			row = append(row, sv)
+		}
+		res.rows = append(res.rows, row)
+	}
+	return res, nil
+}
+
+func (db *FakeDB) exec(tx *fakeTx, q *fakeQuery, args []driver.Value) (driver.Result, error) {
+	if q.kind == 's' {
+		_, err := db.query(tx, q, args)
+		return fakeResult{}, errors.WithStack(err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ft, err := db.table(tx, q.table, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch q.kind {
+	case 'i':
+		return q.execInsert(ft, args)
+	case 'u':
+		return q.execUpdate(ft, args)
+	}
+	return q.execDelete(ft, args)
+}
+
+func (q *fakeQuery) execInsert(ft *fakeTable, args []driver.Value) (driver.Result, error) {
+	cols := q.insertCols
+	if len(cols) == 0 {
+		cols = ft.columns
+	}
+	colIdx := make([]int, len(cols))
+	for i, c := range cols {
+		if colIdx[i] = ft.columnIndex(c); colIdx[i] < 0 {
+			return nil, errors.NotFound.Newf("[dmltest] FakeDB: unknown column %q in table %q", c, q.table)
+		}
+	}
+
+	var res fakeResult
+	origLen := len(ft.rows)
+	env := &fakeEnv{table: ft, args: args}
+	for _, vals := range q.values {
+		if len(vals) != len(cols) {
+			ft.rows, ft.ids = ft.rows[:origLen], ft.ids[:origLen]
+			return nil, errors.Mismatch.Newf("[dmltest] FakeDB: column count %d doesn't match value count %d", len(cols), len(vals))
+		}
+		row := make([]driver.Value, len(ft.columns))
+		for i, ex := range vals {
+			v, err := ex.eval(env)
+			if err != nil {
+				ft.rows, ft.ids = ft.rows[:origLen], ft.ids[:origLen]
+				return nil, errors.WithStack(err)
+			}
+			row[colIdx[i]], _ = fakeStoreValue(v)
+		}
+		if len(row) > 0 && row[0] == nil {
+			if id, ok := ft.nextAutoIncrement(); ok {
+				row[0] = strconv.AppendInt(nil, id, 10)
+				if res.lastInsertID == 0 {
+					res.lastInsertID = id
+				}
+			}
+		}
+		ft.rows = append(ft.rows, row)
+		ft.ids = append(ft.ids, nextFakeRowID())
+		res.rowsAffected++
+	}
+	return res, nil
+}
+
+// nextAutoIncrement returns the next ID of the first column if all values are
+// integers. The ID is greater than all IDs in the rows and than all IDs
+// generated before, even by other transactions.
+func (ft *fakeTable) nextAutoIncrement() (int64, bool) {
+	if len(ft.columns) == 0 || ft.autoInc == nil {
+		return 0, false
+	}
+	max := *ft.autoInc
+	for _, r := range ft.rows {
+		if r[0] == nil {
+			continue
+		}
+		i, ok := fakeInt(r[0])
+		if !ok {
+			return 0, false
+		}
+		if i > max {
+			max = i
+		}
+	}
+	*ft.autoInc = max + 1
+	return max + 1, true
+}
+
+func (q *fakeQuery) execUpdate(ft *fakeTable, args []driver.Value) (driver.Result, error) {
+	colIdx := make([]int, len(q.sets))
+	for i, s := range q.sets {
+		if colIdx[i] = ft.columnIndex(s.column); colIdx[i] < 0 {
+			return nil, errors.NotFound.Newf("[dmltest] FakeDB: unknown column %q in table %q", s.column, q.table)
+		}
+	}
+	idx, err := q.match(ft, args, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// MySQL evaluates the assignments from left to right and each assignment
+	// sees the already updated values.
+	env := &fakeEnv{table: ft, args: args}
+	newRows := make([][]driver.Value, len(idx))
+	for j, i := range idx {
+		env.row = cloneFakeRow(ft.rows[i])
+		for k, s := range q.sets {
+			v, err := s.expr.eval(env)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			env.row[colIdx[k]], _ = fakeStoreValue(v)
+		}
+		newRows[j] = env.row
+	}
+
+	var res fakeResult
+	for j, i := range idx {
+		if !equalFakeRow(ft.rows[i], newRows[j]) {
+			res.rowsAffected++
+		}
+		ft.rows[i] = newRows[j]
+	}
+	return res, nil
+}
+
+func equalFakeRow(a, b []driver.Value) bool {
+	for i := range a {
+		if (a[i] == nil) != (b[i] == nil) || !bytes.Equal(fakeValueBytes(a[i]), fakeValueBytes(b[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q *fakeQuery) execDelete(ft *fakeTable, args []driver.Value) (driver.Result, error) {
+	idx, err := q.match(ft, args, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	del := make(map[int]bool, len(idx))
+	for _, i := range idx {
+		del[i] = true
+	}
+	rows := ft.rows[:0:0]
+	ids := ft.ids[:0:0]
+	for i, r := range ft.rows {
+		if !del[i] {
+			rows = append(rows, r)
+			ids = append(ids, ft.ids[i])
+		}
+	}
+	ft.rows, ft.ids = rows, ids
+	return fakeResult{rowsAffected: int64(len(idx))}, nil
+}
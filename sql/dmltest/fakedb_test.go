@@ -0,0 +1,213 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFakeCoreConfigData(t *testing.T) (*dmltest.FakeDB, *dml.ConnPool) {
+	fdb := dmltest.NewFakeDB().MustLoadCSV("core_config_data", dmltest.WithFile("testdata", "core_config_data1.csv"))
+	return fdb, fdb.ConnPool(t)
+}
+
+func TestFakeDB_Select(t *testing.T) {
+	t.Parallel()
+	_, dbc := newFakeCoreConfigData(t)
+	defer dmltest.Close(t, dbc)
+	ctx := context.Background()
+
+	t.Run("WHERE IN ORDER BY LIMIT", func(t *testing.T) {
+		paths, err := dbc.SelectFrom("core_config_data").AddColumns("path").
+			Where(
+				dml.Column("scope").Str("default"),
+				dml.Column("config_id").In().Int64s(3, 12, 16, 19),
+			).
+			OrderByDesc("config_id").Limit(2).
+			WithArgs().LoadStrings(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, []string{"web/secure/offloader_header", "web/secure/base_js_url"}, paths)
+	})
+
+	t.Run("LIKE and NULL", func(t *testing.T) {
+		paths, err := dbc.SelectFrom("core_config_data", "ccd").AddColumns("ccd.path").
+			Where(
+				dml.Column("ccd.path").Like().Str("web/secure/%"),
+				dml.Column("ccd.value").NotNull(),
+			).
+			OrderBy("config_id").Limit(2).Offset(1).
+			WithArgs().LoadStrings(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, []string{"web/secure/base_link_url", "web/secure/base_skin_url"}, paths)
+	})
+
+	t.Run("COUNT", func(t *testing.T) {
+		count, err := dbc.SelectFrom("core_config_data").Count().
+			Where(dml.Column("scope_id").GreaterOrEqual().Int(1)).
+			WithArgs().LoadInt64(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, int64(2), count)
+	})
+
+	t.Run("unknown table", func(t *testing.T) {
+		_, err := dbc.SelectFrom("core_config_dataXX").Star().WithArgs().LoadStrings(ctx)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("unsupported join", func(t *testing.T) {
+		_, err := dbc.SelectFrom("core_config_data", "ccd").Star().
+			Join(dml.MakeIdentifier("store").Alias("s"), dml.Column("s.store_id").Int(1)).
+			WithArgs().LoadStrings(ctx)
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+}
+
+func TestFakeDB_InsertUpdateDelete(t *testing.T) {
+	t.Parallel()
+	fdb, dbc := newFakeCoreConfigData(t)
+	defer dmltest.Close(t, dbc)
+	ctx := context.Background()
+
+	res, err := dbc.InsertInto("core_config_data").AddColumns("scope", "scope_id", "path", "value").
+		SetRowCount(2).WithArgs().
+		ExecContext(ctx, "websites", 2, "web/cookie/cookie_path", "/", "stores", 4, "web/cookie/cookie_domain", nil)
+	assert.NoError(t, err)
+	lid, err := res.LastInsertId()
+	assert.NoError(t, err)
+	assert.Exactly(t, int64(21), lid)
+
+	res, err = dbc.Update("core_config_data").AddColumns("value").
+		Where(dml.Column("path").Like().PlaceHolder()).
+		WithArgs().ExecContext(ctx, "changed", "general/region/%")
+	assert.NoError(t, err)
+	ra, err := res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Exactly(t, int64(3), ra)
+
+	res, err = dbc.DeleteFrom("core_config_data").
+		Where(dml.Column("config_id").LessOrEqual().Int(1)).
+		WithArgs().ExecContext(ctx)
+	assert.NoError(t, err)
+	ra, err = res.RowsAffected()
+	assert.NoError(t, err)
+	assert.Exactly(t, int64(1), ra)
+
+	cols, rows, err := fdb.TableRows("core_config_data")
+	assert.NoError(t, err)
+	assert.Exactly(t, []string{"config_id", "scope", "scope_id", "path", "value"}, cols)
+	assert.Exactly(t, []driver.Value{[]byte("3"), []byte("stores"), []byte("2"), []byte("general/region/state_required"), []byte("changed")}, rows[2])
+	assert.Exactly(t, []driver.Value{[]byte("22"), []byte("stores"), []byte("4"), []byte("web/cookie/cookie_domain"), nil}, rows[len(rows)-1])
+	assert.Len(t, rows, 21)
+}
+
+func TestFakeDB_Transaction(t *testing.T) {
+	t.Parallel()
+	fdb, dbc := newFakeCoreConfigData(t)
+	defer dmltest.Close(t, dbc)
+
+	err := dbc.Transaction(context.Background(), nil, func(tx *dml.Tx) error {
+		_, err := tx.DeleteFrom("core_config_data").WithArgs().ExecContext(context.Background())
+		assert.NoError(t, err)
+		return errors.Aborted.Newf("Rollback please")
+	})
+	assert.True(t, errors.Aborted.Match(err), "%+v", err)
+
+	dmltest.AssertTableCSV(t, fdb, "core_config_data", dmltest.WithFile("testdata", "core_config_data1.csv"))
+}
+
+func TestFakeDB_TransactionIsolation(t *testing.T) {
+	t.Parallel()
+	_, dbc := newFakeCoreConfigData(t)
+	defer dmltest.Close(t, dbc)
+	ctx := context.Background()
+
+	countPath := func(path string) int64 {
+		c, err := dbc.SelectFrom("core_config_data").Count().
+			Where(dml.Column("path").Str(path)).WithArgs().LoadInt64(ctx)
+		assert.NoError(t, err)
+		return c
+	}
+	insert := func(db interface {
+		InsertInto(string) *dml.Insert
+	}, path string) {
+		_, err := db.InsertInto("core_config_data").AddColumns("scope", "scope_id", "path", "value").
+			WithArgs().ExecContext(ctx, "default", 0, path, "1")
+		assert.NoError(t, err)
+	}
+
+	t.Run("rollback keeps concurrent commits", func(t *testing.T) {
+		tx, err := dbc.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		insert(tx, "tx/rollback/insert")
+		_, err = tx.DeleteFrom("core_config_data").Where(dml.Column("config_id").Int(1)).WithArgs().ExecContext(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, int64(0), countPath("tx/rollback/insert"), "Uncommitted insert must not be visible")
+
+		insert(dbc, "other/insert")
+		assert.NoError(t, tx.Rollback())
+
+		assert.Exactly(t, int64(0), countPath("tx/rollback/insert"))
+		assert.Exactly(t, int64(1), countPath("other/insert"), "Rollback must not discard writes of other connections")
+		c, err := dbc.SelectFrom("core_config_data").Count().Where(dml.Column("config_id").Int(1)).WithArgs().LoadInt64(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, int64(1), c)
+	})
+
+	t.Run("commit merges", func(t *testing.T) {
+		tx, err := dbc.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		insert(tx, "tx/commit/insert")
+		_, err = tx.Update("core_config_data").AddColumns("value").Where(dml.Column("config_id").Int(2)).
+			WithArgs().ExecContext(ctx, "tx changed")
+		assert.NoError(t, err)
+
+		_, err = dbc.DeleteFrom("core_config_data").Where(dml.Column("config_id").Int(3)).WithArgs().ExecContext(ctx)
+		assert.NoError(t, err)
+		insert(dbc, "other/insert2")
+		assert.NoError(t, tx.Commit())
+
+		assert.Exactly(t, int64(1), countPath("tx/commit/insert"))
+		assert.Exactly(t, int64(1), countPath("other/insert2"))
+		vals, err := dbc.SelectFrom("core_config_data").AddColumns("value").
+			Where(dml.Column("config_id").In().Int64s(2, 3)).WithArgs().LoadStrings(ctx)
+		assert.NoError(t, err)
+		assert.Exactly(t, []string{"tx changed"}, vals, "Row 3 deleted by the other connection must stay deleted")
+	})
+
+	t.Run("concurrent inserts get distinct IDs", func(t *testing.T) {
+		tx1, err := dbc.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		tx2, err := dbc.BeginTx(ctx, nil)
+		assert.NoError(t, err)
+		insert(tx1, "tx/autoinc/1")
+		insert(tx2, "tx/autoinc/2")
+		assert.NoError(t, tx1.Commit())
+		assert.NoError(t, tx2.Commit())
+
+		ids, err := dbc.SelectFrom("core_config_data").AddColumns("config_id").
+			Where(dml.Column("path").Like().Str("tx/autoinc/%")).WithArgs().LoadInt64s(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, ids, 2) {
+			assert.NotEqual(t, ids[0], ids[1])
+		}
+	})
+}
@@ -0,0 +1,417 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/diff"
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v2"
+)
+
+// fixtureBatchSize defines the maximum amount of rows in one INSERT statement.
+const fixtureBatchSize = 250
+
+// Fixture defines the rows of a table or a SQL script which gets applied to a
+// database. If Table is set, all existing rows get deleted before the new rows
+// get inserted. FixtureTx deletes only the existing rows with the same Key as
+// the new rows. A Fixture with a Table but without Rows empties the table and
+// clones it in FixtureSchema. SQL gets executed after the rows have been
+// inserted.
+type Fixture struct {
+	Table   string
+	Columns []string
+	// Key defines the column which identifies the rows in FixtureTx. Default
+	// the first column.
+	Key  string
+	Rows [][]driver.Value
+	// SQL contains statements separated by a semicolon at the end of a line.
+	SQL string
+}
+
+// LoadFixtureCSV loads the rows of a table from a CSV file. The options are
+// the same as for function LoadCSV.
+func LoadFixtureCSV(table string, opts ...csvOptions) (Fixture, error) {
+	cols, rows, err := LoadCSV(opts...)
+	if err != nil {
+		return Fixture{}, errors.Wrapf(err, "[dmltest] LoadFixtureCSV for table %q", table)
+	}
+	return Fixture{Table: table, Columns: cols, Rows: rows}, nil
+}
+
+// LoadFixtureYAML loads the rows of one or more tables from a YAML file. The
+// top level keys define the table names in the order of insertion. Each table
+// contains a list of rows with the column names as keys. Missing columns in a
+// row are getting inserted as NULL.
+//		core_config_data:
+//		  - config_id: 1
+//		    scope: default
+//		    path: web/secure/base_url
+//		    value: null
+func LoadFixtureYAML(file ...string) ([]Fixture, error) {
+	fp := filepath.Join(file...)
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return nil, errors.NotFound.New(err, "[dmltest] LoadFixtureYAML: Failed to read file %q", fp)
+	}
+
+	var tables yaml.MapSlice
+	if err := yaml.Unmarshal(data, &tables); err != nil {
+		return nil, errors.NotValid.New(err, "[dmltest] LoadFixtureYAML: Failed to parse file %q", fp)
+	}
+
+	fixtures := make([]Fixture, 0, len(tables))
+	for _, tbl := range tables {
+		f := Fixture{Table: fmt.Sprint(tbl.Key)}
+		// Decode the rows again to preserve the order of the columns.
+		raw, err := yaml.Marshal(tbl.Value)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var rows []yaml.MapSlice
+		if err := yaml.Unmarshal(raw, &rows); err != nil {
+			return nil, errors.NotValid.New(err, "[dmltest] LoadFixtureYAML: Table %q in file %q must contain a list of rows", f.Table, fp)
+		}
+		for _, row := range rows {
+			for _, kv := range row {
+				if k := fmt.Sprint(kv.Key); !containsString(f.Columns, k) {
+					f.Columns = append(f.Columns, k)
+				}
+			}
+		}
+		for _, row := range rows {
+			vals := make([]driver.Value, len(f.Columns))
+			for _, kv := range row {
+				for j, c := range f.Columns {
+					if c == fmt.Sprint(kv.Key) {
+						vals[j] = kv.Value
+					}
+				}
+			}
+			f.Rows = append(f.Rows, vals)
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// LoadFixtureSQL loads a SQL script from a file. The statements must be
+// separated by a semicolon at the end of a line.
+func LoadFixtureSQL(file ...string) (Fixture, error) {
+	fp := filepath.Join(file...)
+	data, err := ioutil.ReadFile(fp)
+	if err != nil {
+		return Fixture{}, errors.NotFound.New(err, "[dmltest] LoadFixtureSQL: Failed to read file %q", fp)
+	}
+	return Fixture{SQL: string(data)}, nil
+}
+
+// MustLoadFixtures collects the fixtures of the provided load functions and
+// panics on error.
+//		fixtures := dmltest.MustLoadFixtures(
+//			dmltest.LoadFixtureYAML("testdata", "stores.yaml"),
+//		)
+func MustLoadFixtures(fixtures []Fixture, err error) []Fixture {
+	if err != nil {
+		panic(err)
+	}
+	return fixtures
+}
+
+func containsString(sl []string, s string) bool {
+	for _, v := range sl {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// statements splits the SQL script into single statements.
+func (f Fixture) statements() []string {
+	parts := strings.Split(strings.Replace(f.SQL, "\r\n", "\n", -1), ";\n")
+	stmts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p = strings.TrimSuffix(strings.TrimSpace(p), ";"); p != "" {
+			stmts = append(stmts, p)
+		}
+	}
+	return stmts
+}
+
+// ApplyFixtures deletes all rows of the fixture tables, inserts the new rows in
+// batches and runs the SQL scripts. The argument db can be a *sql.DB, *sql.Conn
+// or a *sql.Tx.
+func ApplyFixtures(ctx context.Context, db dml.QueryExecPreparer, fixtures ...Fixture) error {
+	return applyFixtures(ctx, db, false, fixtures)
+}
+
+// deleteKeys deletes the existing rows with the same key as the rows of the
+// fixture. In InnoDB the DELETE locks only these rows and not the whole table.
+func (f Fixture) deleteKeys(ctx context.Context, db dml.QueryExecPreparer) error {
+	if len(f.Rows) == 0 {
+		return nil
+	}
+	key := f.Key
+	if key == "" && len(f.Columns) > 0 {
+		key = f.Columns[0]
+	}
+	ki := -1
+	for i, c := range f.Columns {
+		if c == key {
+			ki = i
+		}
+	}
+	if ki < 0 {
+		return errors.NotFound.Newf("[dmltest] Fixture: Key column %q not found in table %q", key, f.Table)
+	}
+	for start := 0; start < len(f.Rows); start += fixtureBatchSize {
+		end := start + fixtureBatchSize
+		if end > len(f.Rows) {
+			end = len(f.Rows)
+		}
+		args := make([]interface{}, 0, end-start)
+		for _, r := range f.Rows[start:end] {
+			args = append(args, r[ki])
+		}
+		_, err := dml.NewDelete(f.Table).Where(dml.Column(key).In().PlaceHolders(len(args))).
+			WithDB(db).WithArgs().ExecContext(ctx, args...)
+		if err != nil {
+			return errors.Wrapf(err, "[dmltest] Fixture: Failed to delete rows %d-%d of table %q", start, end, f.Table)
+		}
+	}
+	return nil
+}
+
+// applyFixtures deletes only the rows with the keys of the fixtures if byKey
+// is true, otherwise all rows of a table.
+func applyFixtures(ctx context.Context, db dml.QueryExecPreparer, byKey bool, fixtures []Fixture) error {
+	for _, f := range fixtures {
+		switch {
+		case f.Table == "":
+		case byKey:
+			if err := f.deleteKeys(ctx, db); err != nil {
+				return errors.WithStack(err)
+			}
+		default:
+			if _, err := db.ExecContext(ctx, "DELETE FROM "+dml.Quoter.Name(f.Table)); err != nil {
+				return errors.Wrapf(err, "[dmltest] ApplyFixtures: Failed to delete rows of table %q", f.Table)
+			}
+		}
+		for start := 0; start < len(f.Rows); start += fixtureBatchSize {
+			end := start + fixtureBatchSize
+			if end > len(f.Rows) {
+				end = len(f.Rows)
+			}
+			args := make([]interface{}, 0, (end-start)*len(f.Columns))
+			for _, r := range f.Rows[start:end] {
+				for _, v := range r {
+					args = append(args, v)
+				}
+			}
+			_, err := dml.NewInsert(f.Table).AddColumns(f.Columns...).SetRowCount(end-start).
+				WithDB(db).WithArgs().ExecContext(ctx, args...)
+			if err != nil {
+				return errors.Wrapf(err, "[dmltest] ApplyFixtures: Failed to insert rows %d-%d into table %q", start, end, f.Table)
+			}
+		}
+		for _, stmt := range f.statements() {
+			if _, err := db.ExecContext(ctx, stmt); err != nil {
+				return errors.Wrapf(err, "[dmltest] ApplyFixtures: Failed to execute %q", stmt)
+			}
+		}
+	}
+	return nil
+}
+
+// FixtureTx starts a new transaction and applies the fixtures within it. Use
+// the returned transaction for all queries of the test. The returned function
+// rolls the transaction back, so tests running in parallel don't see each
+// others data. Fatals on error.
+//
+//		tx, rollback := dmltest.FixtureTx(t, dbc, fixtures...)
+//		defer rollback()
+//
+// Other than ApplyFixtures it deletes only the existing rows with the keys of
+// the fixture rows, see Fixture.Key, because deleting all rows would lock the
+// whole table. Tests running in parallel must use different key ranges for the
+// same table, otherwise they block each other. A Fixture without Rows does not
+// empty its table, use FixtureSchema if a test needs an empty table.
+func FixtureTx(t testing.TB, dbc *dml.ConnPool, fixtures ...Fixture) (_ *dml.Tx, rollback func()) {
+	t.Helper()
+	tx, err := dbc.BeginTx(context.Background(), nil)
+	FatalIfError(t, err)
+	rollback = func() {
+		if err := tx.Rollback(); err != nil && errors.Cause(err) != sql.ErrTxDone {
+			t.Errorf("[dmltest] FixtureTx rollback failed: %+v", err)
+		}
+	}
+	if err := applyFixtures(context.Background(), tx.DB, true, fixtures); err != nil {
+		rollback()
+		FatalIfError(t, err)
+	}
+	return tx, rollback
+}
+
+var fixtureSchemaCounter uint32
+
+// FixtureSchema creates a temporary schema next to the schema of the DSN in
+// environment variable CS_DSN. All tables of the fixtures get cloned with
+// CREATE TABLE ... LIKE and filled with the fixtures. The returned connection
+// pool uses the temporary schema. The returned function closes the connection
+// pool and drops the temporary schema. Skips the test if the DSN is not
+// available, fatals on all other errors.
+//
+//		dbc, drop := dmltest.FixtureSchema(t, fixtures...)
+//		defer drop()
+func FixtureSchema(t testing.TB, fixtures ...Fixture) (_ *dml.ConnPool, drop func()) {
+	t.Helper()
+	cfg, err := mysql.ParseDSN(MustGetDSN(t))
+	FatalIfError(t, err)
+	srcSchema := cfg.DBName
+	// The process ID avoids collisions with go test running the packages in
+	// parallel processes.
+	cfg.DBName = fmt.Sprintf("%s_fx%d_%d_%d", srcSchema, time.Now().Unix(), os.Getpid(), atomic.AddUint32(&fixtureSchemaCounter, 1))
+
+	ctx := context.Background()
+	admin := MustConnectDB(t)
+	_, err = admin.DB.ExecContext(ctx, "CREATE DATABASE "+dml.Quoter.Name(cfg.DBName))
+	FatalIfError(t, err)
+	var dbc *dml.ConnPool
+	drop = func() {
+		if dbc != nil {
+			Close(t, dbc) // before the DROP DATABASE
+		}
+		if _, err := admin.DB.ExecContext(ctx, "DROP DATABASE IF EXISTS "+dml.Quoter.Name(cfg.DBName)); err != nil {
+			t.Errorf("[dmltest] FixtureSchema drop failed: %+v", err)
+		}
+		Close(t, admin)
+	}
+	fatal := func(err error) {
+		if err != nil {
+			t.Helper()
+			drop()
+			FatalIfError(t, err)
+		}
+	}
+
+	cloned := map[string]bool{}
+	for _, f := range fixtures {
+		if f.Table == "" || cloned[f.Table] {
+			continue
+		}
+		_, err = admin.DB.ExecContext(ctx, "CREATE TABLE "+dml.Quoter.QualifierName(cfg.DBName, f.Table)+
+			" LIKE "+dml.Quoter.QualifierName(srcSchema, f.Table))
+		fatal(err)
+		cloned[f.Table] = true
+	}
+
+	dbc, err = dml.NewConnPool(dml.WithDSN(cfg.FormatDSN()))
+	fatal(err)
+	fatal(ApplyFixtures(ctx, dbc.DB, fixtures...))
+	return dbc, drop
+}
+
+// TableSnapshot contains the content of a table at a point in time. The values
+// are byte slices or nil.
+type TableSnapshot struct {
+	Table   string
+	Columns []string
+	Rows    [][]driver.Value
+}
+
+// String returns the rows in a pipe separated format, one row per line.
+func (ts TableSnapshot) String() string {
+	return formatTableRows(ts.Columns, ts.Rows)
+}
+
+// SnapshotTable reads all rows of a table. The rows get sorted by all columns
+// to be independent of the storage order.
+func SnapshotTable(ctx context.Context, db dml.Querier, table string) (TableSnapshot, error) {
+	ts := TableSnapshot{Table: table}
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+dml.Quoter.Name(table))
+	if err != nil {
+		return ts, errors.Wrapf(err, "[dmltest] SnapshotTable %q", table)
+	}
+	defer rows.Close()
+
+	if ts.Columns, err = rows.Columns(); err != nil {
+		return ts, errors.WithStack(err)
+	}
+	raw := make([]sql.RawBytes, len(ts.Columns))
+	dest := make([]interface{}, len(ts.Columns))
+	for i := range raw {
+		dest[i] = &raw[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return ts, errors.Wrapf(err, "[dmltest] SnapshotTable %q", table)
+		}
+		row := make([]driver.Value, len(raw))
+		for i, r := range raw {
+			if r != nil {
+				row[i] = append([]byte{}, r...)
+			}
+		}
+		ts.Rows = append(ts.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return ts, errors.WithStack(err)
+	}
+
+	sort.SliceStable(ts.Rows, func(i, j int) bool {
+		for k := range ts.Columns {
+			if c := fakeOrderCompare(ts.Rows[i][k], ts.Rows[j][k]); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return ts, nil
+}
+
+// MustSnapshotTable same as SnapshotTable but fatals on error.
+func MustSnapshotTable(t testing.TB, db dml.Querier, table string) TableSnapshot {
+	t.Helper()
+	ts, err := SnapshotTable(context.Background(), db, table)
+	FatalIfError(t, err)
+	return ts
+}
+
+// AssertTableSnapshot takes a new snapshot of the table in `want` and reports
+// the differences as a unified diff.
+func AssertTableSnapshot(t testing.TB, db dml.Querier, want TableSnapshot) {
+	t.Helper()
+	have := MustSnapshotTable(t, db, want.Table)
+	if ws, hs := want.String(), have.String(); ws != hs {
+		d, err := diff.Unified(ws, hs)
+		FatalIfError(t, err)
+		t.Errorf("[dmltest] Table %q has changed:\n%s", want.Table, d)
+	}
+}
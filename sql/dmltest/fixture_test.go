@@ -0,0 +1,107 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFakeStore(t *testing.T) *dmltest.FakeDB {
+	fdb := dmltest.NewFakeDB()
+	require.NoError(t, fdb.CreateTable("store_website", []string{"website_id", "code", "name"}))
+	require.NoError(t, fdb.CreateTable("store", []string{"store_id", "code", "website_id", "name"}))
+	return fdb
+}
+
+func TestLoadFixtureYAML(t *testing.T) {
+	t.Parallel()
+	fixtures, err := dmltest.LoadFixtureYAML("testdata", "fixture_store.yaml")
+	require.NoError(t, err)
+	require.Len(t, fixtures, 2)
+	assert.Exactly(t, "store_website", fixtures[0].Table)
+	assert.Exactly(t, "store", fixtures[1].Table)
+	assert.Exactly(t, []string{"store_id", "code", "website_id", "name"}, fixtures[1].Columns)
+	assert.Nil(t, fixtures[1].Rows[0][3])
+	assert.Exactly(t, "Österreich", fixtures[1].Rows[2][3])
+
+	_, err = dmltest.LoadFixtureYAML("testdata", "fixture_storeXX.yaml")
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+}
+
+func TestApplyFixtures(t *testing.T) {
+	t.Parallel()
+	fdb := newFakeStore(t)
+	dbc := fdb.ConnPool(t)
+	defer dmltest.Close(t, dbc)
+
+	fixtures := dmltest.MustLoadFixtures(dmltest.LoadFixtureYAML("testdata", "fixture_store.yaml"))
+	sqlFixture, err := dmltest.LoadFixtureSQL("testdata", "fixture_store.sql")
+	require.NoError(t, err)
+	ccdFixture, err := dmltest.LoadFixtureCSV("core_config_data", dmltest.WithFile("testdata", "core_config_data1.csv"))
+	require.NoError(t, err)
+	require.NoError(t, fdb.CreateTable("core_config_data", ccdFixture.Columns))
+
+	require.NoError(t, dmltest.ApplyFixtures(context.Background(), dbc.DB, append(fixtures, sqlFixture, ccdFixture)...))
+
+	ts := dmltest.MustSnapshotTable(t, dbc.DB, "store")
+	assert.Exactly(t, "store_id | code | website_id | name\n0 | admin | 0 | Admin\n1 | de | 1 | Germany\n2 | at | 1 | Österreich\n3 | ch | 1 | Schweiz\n", ts.String())
+	dmltest.AssertTableCSV(t, fdb, "core_config_data", dmltest.WithFile("testdata", "core_config_data1.csv"))
+}
+
+func TestFixtureTx(t *testing.T) {
+	t.Parallel()
+	fdb := newFakeStore(t)
+	dbc := fdb.ConnPool(t)
+	defer dmltest.Close(t, dbc)
+
+	_, err := dbc.DB.ExecContext(context.Background(), "INSERT INTO store (store_id, code, website_id, name) VALUES (10, 'fr', 2, 'France')")
+	require.NoError(t, err)
+	before := dmltest.MustSnapshotTable(t, dbc.DB, "store")
+
+	t.Run("isolated", func(t *testing.T) {
+		tx, rollback := dmltest.FixtureTx(t, dbc, dmltest.MustLoadFixtures(dmltest.LoadFixtureYAML("testdata", "fixture_store.yaml"))...)
+		defer rollback()
+		ts := dmltest.MustSnapshotTable(t, tx.DB, "store")
+		assert.Len(t, ts.Rows, 4, "Rows with other keys must not be deleted")
+	})
+
+	t.Run("parallel key ranges", func(t *testing.T) {
+		for _, id := range []int64{20, 30} {
+			id := id
+			t.Run(fmt.Sprintf("store_id %d", id), func(t *testing.T) {
+				t.Parallel()
+				tx, rollback := dmltest.FixtureTx(t, dbc, dmltest.Fixture{
+					Table:   "store",
+					Columns: []string{"code", "store_id", "website_id", "name"},
+					Key:     "store_id",
+					Rows:    [][]driver.Value{{"x", id, 1, nil}, {"y", id + 1, 1, nil}},
+				})
+				defer rollback()
+				ts := dmltest.MustSnapshotTable(t, tx.DB, "store")
+				assert.Len(t, ts.Rows, 3, "Must only see the own and the committed rows")
+			})
+		}
+	})
+
+	dmltest.AssertTableSnapshot(t, dbc.DB, before)
+}
@@ -0,0 +1,125 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/diff"
+)
+
+// updateGolden rewrites the golden files instead of comparing them. Run the
+// tests with `go test -dmltest.update` after an intended change of the
+// generated SQL. The flag name contains the package name to avoid a conflict
+// with an -update flag of the package under test.
+var updateGolden = flag.Bool("dmltest.update", false, "[dmltest] Rewrites the SQL golden files in the testdata directory")
+
+// GoldenDir defines the directory, relative to the package under test, where
+// the golden files are stored.
+const GoldenDir = "testdata"
+
+// goldenExt gets appended to the name of a golden file.
+const goldenExt = ".golden"
+
+// RenderSQL renders the prepared SQL string with its arguments and the
+// interpolated SQL string of a query builder into the golden file format. The
+// interpolated form gets created by calling Interpolate on an *dml.Artisan or
+// by using dml.Interpolate for all other query builders.
+func RenderSQL(qb dml.QueryBuilder) (string, error) {
+	sqlStr, args, err := qb.ToSQL()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("-- Prepared\n")
+	buf.WriteString(sqlStr)
+	buf.WriteString("\n-- Arguments\n")
+	for i, a := range args {
+		fmt.Fprintf(&buf, "%d: %#v\n", i+1, a)
+	}
+
+	var iSQL string
+	if a, ok := qb.(*dml.Artisan); ok {
+		prev := a.Options
+		iSQL, _, err = a.Interpolate().ToSQL()
+		a.Options = prev
+	} else {
+		ip := dml.Interpolate(sqlStr)
+		for _, a := range args {
+			ip.Unsafe(a)
+		}
+		iSQL, _, err = ip.ToSQL()
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	buf.WriteString("-- Interpolated\n")
+	buf.WriteString(iSQL)
+	buf.WriteByte('\n')
+	return normalizeGolden(buf.String()), nil
+}
+
+// normalizeGolden unifies the line endings and removes trailing white spaces
+// from all lines to avoid differences caused by editors.
+func normalizeGolden(s string) string {
+	s = strings.Replace(s, "\r\n", "\n", -1)
+	lines := strings.Split(strings.TrimRight(s, " \t\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight(l, " \t")
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// AssertGoldenSQL renders the query builder with RenderSQL and compares the
+// result with the golden file testdata/<name>.golden. On mismatch a unified
+// diff gets reported as a test error. With the flag -dmltest.update the golden
+// file gets written instead. An empty name uses the name of the test.
+func AssertGoldenSQL(t testing.TB, qb dml.QueryBuilder, name string) {
+	t.Helper()
+	if name == "" {
+		name = t.Name()
+	}
+	have, err := RenderSQL(qb)
+	FatalIfError(t, err)
+
+	file := filepath.Join(GoldenDir, filepath.FromSlash(name)+goldenExt)
+	if *updateGolden {
+		FatalIfError(t, os.MkdirAll(filepath.Dir(file), 0755))
+		FatalIfError(t, ioutil.WriteFile(file, []byte(have), 0644))
+		return
+	}
+
+	want, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		t.Fatalf("[dmltest] Golden file %q does not exist. Run the test with flag -dmltest.update to create it.", file)
+	}
+	FatalIfError(t, err)
+
+	if wantStr := normalizeGolden(string(want)); wantStr != have {
+		d, err := diff.Unified(wantStr, have)
+		FatalIfError(t, err)
+		t.Errorf("[dmltest] SQL does not match golden file %q. Run the test with flag -dmltest.update to rewrite it.\n%s", file, d)
+	}
+}
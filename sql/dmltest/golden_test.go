@@ -0,0 +1,59 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmltest_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertGoldenSQL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Select", func(t *testing.T) {
+		sel := dml.NewSelect("config_id", "value").From("core_config_data").
+			Where(
+				dml.Column("scope").Str("websites"),
+				dml.Column("scope_id").In().Int64s(1, 2),
+				dml.Column("path").Like().PlaceHolder(),
+			).
+			OrderByDesc("config_id").Limit(5)
+		dmltest.AssertGoldenSQL(t, sel, "golden_select")
+	})
+
+	t.Run("Artisan", func(t *testing.T) {
+		upd := dml.NewUpdate("core_config_data").AddColumns("value").
+			Where(dml.Column("config_id").PlaceHolder()).
+			WithArgs().String("It's a \"value\"").Int64(33)
+		dmltest.AssertGoldenSQL(t, upd, "golden_update_artisan")
+	})
+}
+
+func TestGoldenUpdateFlag(t *testing.T) {
+	// A package under test might define its own -update flag.
+	assert.Nil(t, flag.Lookup("update"))
+	assert.NotNil(t, flag.Lookup("dmltest.update"))
+}
+
+func TestRenderSQL(t *testing.T) {
+	t.Parallel()
+	have, err := dmltest.RenderSQL(dml.NewDelete("store").Where(dml.Column("store_id").Int(3)))
+	assert.NoError(t, err)
+	assert.Exactly(t, "-- Prepared\nDELETE FROM `store` WHERE (`store_id` = 3)\n-- Arguments\n-- Interpolated\nDELETE FROM `store` WHERE (`store_id` = 3)\n", have)
+}
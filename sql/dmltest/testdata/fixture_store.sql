@@ -0,0 +1,2 @@
+UPDATE `store` SET `name` = 'Admin' WHERE `store_id` = 0;
+INSERT INTO `store` (`store_id`,`code`,`website_id`,`name`) VALUES (3,'ch',1,'Schweiz');